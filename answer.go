@@ -0,0 +1,119 @@
+package capnp
+
+import (
+	"reflect"
+
+	"golang.org/x/net/context"
+)
+
+// An AsyncAnswer is an Answer that can report whether it has been
+// resolved without blocking.  All answers returned by this package and
+// by the rpc and server packages implement AsyncAnswer.
+type AsyncAnswer interface {
+	Answer
+
+	// Done returns a channel that is closed once the answer is
+	// resolved.
+	Done() <-chan struct{}
+
+	// Peek returns the resolved answer or nil if the answer has not
+	// been resolved.  The Struct method of an answer returned from
+	// Peek returns immediately.
+	Peek() Answer
+
+	// OnResolve arranges for f to be called once the answer is
+	// resolved, or calls f right away if it already is.  f is called
+	// from the goroutine that resolves the answer, which may be holding
+	// locks, so f must not block or make calls.
+	OnResolve(f func())
+}
+
+// AnswerDone returns a channel that is closed once a is resolved.  If
+// a is not an AsyncAnswer, AnswerDone starts a goroutine that waits on
+// a's Struct method.  That goroutine runs until a is resolved, even if
+// the caller stops waiting on the channel.
+func AnswerDone(a Answer) <-chan struct{} {
+	if aa, ok := a.(AsyncAnswer); ok {
+		return aa.Done()
+	}
+	done := make(chan struct{})
+	go func() {
+		a.Struct()
+		close(done)
+	}()
+	return done
+}
+
+// AnswerOnResolve arranges for f to be called once a is resolved.  See
+// AsyncAnswer.OnResolve for the restrictions on f.  If a is not an
+// AsyncAnswer, AnswerOnResolve starts a goroutine that waits on a's
+// Struct method and then calls f.
+func AnswerOnResolve(a Answer, f func()) {
+	if aa, ok := a.(AsyncAnswer); ok {
+		aa.OnResolve(f)
+		return
+	}
+	go func() {
+		a.Struct()
+		f()
+	}()
+}
+
+// PeekAnswer returns a's resolved answer without blocking, or nil if a
+// has not been resolved.  PeekAnswer always returns nil for answers
+// that are not an AsyncAnswer.
+func PeekAnswer(a Answer) Answer {
+	if aa, ok := a.(AsyncAnswer); ok {
+		return aa.Peek()
+	}
+	return nil
+}
+
+// WaitAll waits until all the answers are resolved or ctx is done.  It
+// returns the error of the first answer in the argument list that
+// failed, or ctx.Err() if ctx finished first.
+func WaitAll(ctx context.Context, answers ...Answer) error {
+	for _, a := range answers {
+		select {
+		case <-AnswerDone(a):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	for _, a := range answers {
+		if _, err := a.Struct(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WaitAny waits until any of the answers is resolved or ctx is done.
+// It returns the index of a resolved answer, or -1 and ctx.Err() if ctx
+// finished first.  If more than one answer is resolved, the lowest
+// index is returned.  The error returned is the answer's error.
+func WaitAny(ctx context.Context, answers ...Answer) (int, error) {
+	for i, a := range answers {
+		if r := PeekAnswer(a); r != nil {
+			_, err := r.Struct()
+			return i, err
+		}
+	}
+	cases := make([]reflect.SelectCase, len(answers)+1)
+	for i, a := range answers {
+		cases[i] = reflect.SelectCase{
+			Dir:  reflect.SelectRecv,
+			Chan: reflect.ValueOf(AnswerDone(a)),
+		}
+	}
+	cases[len(answers)] = reflect.SelectCase{
+		Dir:  reflect.SelectRecv,
+		Chan: reflect.ValueOf(ctx.Done()),
+	}
+	i, _, _ := reflect.Select(cases)
+	if i == len(answers) {
+		return -1, ctx.Err()
+	}
+	_, err := answers[i].Struct()
+	return i, err
+}
@@ -0,0 +1,79 @@
+package capnp
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+var (
+	_ AsyncAnswer = immediateAnswer{}
+	_ AsyncAnswer = errorAnswer{}
+	_ AsyncAnswer = (*hookAnswer)(nil)
+)
+
+func TestPeekAnswer(t *testing.T) {
+	ans := &chanAnswer{done: make(chan struct{})}
+	if a := PeekAnswer(ans); a != nil {
+		t.Errorf("PeekAnswer(unresolved) = %v; want nil", a)
+	}
+	close(ans.done)
+	if a := PeekAnswer(ans); a == nil {
+		t.Error("PeekAnswer(resolved) = nil")
+	}
+	if a := PeekAnswer(&recordAnswer{}); a != nil {
+		t.Errorf("PeekAnswer(non-async answer) = %v; want nil", a)
+	}
+	errFoo := errors.New("foo")
+	if a := PeekAnswer(ErrorAnswer(errFoo)); a == nil {
+		t.Error("PeekAnswer(ErrorAnswer) = nil")
+	} else if _, err := a.Struct(); err != errFoo {
+		t.Errorf("PeekAnswer(ErrorAnswer).Struct() error = %v; want %v", err, errFoo)
+	}
+}
+
+func TestWaitAll(t *testing.T) {
+	a1 := &chanAnswer{done: make(chan struct{})}
+	a2 := &chanAnswer{done: make(chan struct{})}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	close(a1.done)
+	if err := WaitAll(ctx, a1, a2); err != context.DeadlineExceeded {
+		t.Errorf("WaitAll with unresolved answer = %v; want %v", err, context.DeadlineExceeded)
+	}
+	cancel()
+
+	close(a2.done)
+	if err := WaitAll(context.Background(), a1, a2); err != nil {
+		t.Errorf("WaitAll with resolved answers = %v; want <nil>", err)
+	}
+	errFoo, errBar := errors.New("foo"), errors.New("bar")
+	if err := WaitAll(context.Background(), a1, ErrorAnswer(errFoo), ErrorAnswer(errBar)); err != errFoo {
+		t.Errorf("WaitAll with failed answers = %v; want %v", err, errFoo)
+	}
+	if err := WaitAll(context.Background()); err != nil {
+		t.Errorf("WaitAll() = %v; want <nil>", err)
+	}
+}
+
+func TestWaitAny(t *testing.T) {
+	a1 := &chanAnswer{done: make(chan struct{})}
+	a2 := &chanAnswer{done: make(chan struct{})}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	if i, err := WaitAny(ctx, a1, a2); i != -1 || err != context.DeadlineExceeded {
+		t.Errorf("WaitAny with unresolved answers = %d, %v; want -1, %v", i, err, context.DeadlineExceeded)
+	}
+	cancel()
+
+	go close(a2.done)
+	if i, err := WaitAny(context.Background(), a1, a2); i != 1 || err != nil {
+		t.Errorf("WaitAny = %d, %v; want 1, <nil>", i, err)
+	}
+	errFoo := errors.New("foo")
+	if i, err := WaitAny(context.Background(), a1, ErrorAnswer(errFoo), a2); i != 1 || err != errFoo {
+		t.Errorf("WaitAny with failed answer = %d, %v; want 1, %v", i, err, errFoo)
+	}
+}
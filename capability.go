@@ -316,6 +316,35 @@ func (p *Pipeline) GetPipelineDefault(off uint16, def []byte) *Pipeline {
 	}
 }
 
+// Done returns a channel that is closed once the pipeline's answer is
+// resolved.  See AnswerDone for details.
+func (p *Pipeline) Done() <-chan struct{} {
+	return AnswerDone(p.answer)
+}
+
+// Await waits until the answer is resolved or ctx is done, whichever
+// comes first.  It returns the struct this pipeline represents or
+// ctx.Err() if ctx is done first.  Await does not cancel the call.
+// Errors from the answer can be classified with ErrorTypeOf.
+func (p *Pipeline) Await(ctx context.Context) (Struct, error) {
+	select {
+	case <-p.Done():
+		return p.Struct()
+	case <-ctx.Done():
+		return Struct{}, ctx.Err()
+	}
+}
+
+// Then arranges for f to be called with the results of Struct once the
+// answer is resolved.  f is called as described in AnswerOnResolve, so
+// it must not block or make calls; long-running work should be started
+// in a new goroutine.
+func (p *Pipeline) Then(f func(Struct, error)) {
+	AnswerOnResolve(p.answer, func() {
+		f(p.Struct())
+	})
+}
+
 // PipelineClient implements Client by calling to the pipeline's answer.
 type PipelineClient Pipeline
 
@@ -436,6 +465,18 @@ func (ans immediateAnswer) Struct() (Struct, error) {
 	return ans.s, nil
 }
 
+func (ans immediateAnswer) Done() <-chan struct{} {
+	return closedDone
+}
+
+func (ans immediateAnswer) Peek() Answer {
+	return ans
+}
+
+func (ans immediateAnswer) OnResolve(f func()) {
+	f()
+}
+
 func (ans immediateAnswer) findClient(transform []PipelineOp) Client {
 	p, err := TransformPtr(ans.s.ToPtr(), transform)
 	if err != nil {
@@ -473,6 +514,18 @@ func (ans errorAnswer) Struct() (Struct, error) {
 	return Struct{}, ans.e
 }
 
+func (ans errorAnswer) Done() <-chan struct{} {
+	return closedDone
+}
+
+func (ans errorAnswer) Peek() Answer {
+	return ans
+}
+
+func (ans errorAnswer) OnResolve(f func()) {
+	f()
+}
+
 func (ans errorAnswer) PipelineCall([]PipelineOp, *Call) Answer {
 	return ans
 }
@@ -481,6 +534,13 @@ func (ans errorAnswer) PipelineClose([]PipelineOp) error {
 	return ans.e
 }
 
+// closedDone is the Done channel of answers that are already resolved.
+var closedDone = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
 // IsFixedAnswer reports whether an answer was created by
 // ImmediateAnswer or ErrorAnswer.
 func IsFixedAnswer(ans Answer) bool {
@@ -534,8 +594,89 @@ var ErrUnimplemented = errors.New("capnp: method not implemented")
 
 // IsUnimplemented reports whether e indicates an unimplemented method error.
 func IsUnimplemented(e error) bool {
-	if me, ok := e.(*MethodError); ok {
-		e = me.Err
+	return ErrorTypeOf(e) == Unimplemented
+}
+
+// ErrorType classifies an error by how the caller should respond to
+// it.  The types match those of exceptions in the RPC protocol.
+type ErrorType int
+
+// Error types.
+const (
+	// Failed is the type of most errors.  The caller should not retry.
+	Failed ErrorType = iota
+
+	// Overloaded means the callee is temporarily out of resources.
+	// The caller may retry later, with backoff.
+	Overloaded
+
+	// Disconnected means the capability is no longer reachable, for
+	// example because its connection was lost.  The caller should
+	// obtain the capability again before retrying.
+	Disconnected
+
+	// Unimplemented means the callee doesn't implement the method.
+	Unimplemented
+)
+
+// String returns the type's name as it appears in rpc.capnp.
+func (t ErrorType) String() string {
+	switch t {
+	case Failed:
+		return "failed"
+	case Overloaded:
+		return "overloaded"
+	case Disconnected:
+		return "disconnected"
+	case Unimplemented:
+		return "unimplemented"
+	default:
+		return "ErrorType(" + strconv.Itoa(int(t)) + ")"
+	}
+}
+
+// Error is an error with a type.  The rpc package sends the type along
+// with the error's message, so the caller can tell an overloaded callee
+// apart from a disconnected one.
+type Error struct {
+	Type ErrorType
+	Err  error
+}
+
+// Error returns the underlying error's message, or the type's name if
+// Err is nil.
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return "capnp: " + e.Type.String()
+	}
+	return e.Err.Error()
+}
+
+// ErrorType returns e.Type.
+func (e *Error) ErrorType() ErrorType {
+	return e.Type
+}
+
+// ErrorTypeOf returns the type of err.  An error that has a method
+// ErrorType() ErrorType, like *Error, reports its own type.  The
+// errors in a *MethodError are examined, and ErrUnimplemented is
+// Unimplemented.  Any other error is Failed.  ErrorTypeOf(nil) also
+// returns Failed, the zero ErrorType, since there is no type for the
+// absence of an error; callers should check err against nil first.
+func ErrorTypeOf(err error) ErrorType {
+	for {
+		switch e := err.(type) {
+		case *MethodError:
+			err = e.Err
+		case interface {
+			ErrorType() ErrorType
+		}:
+			return e.ErrorType()
+		default:
+			if err == ErrUnimplemented {
+				return Unimplemented
+			}
+			return Failed
+		}
 	}
-	return e == ErrUnimplemented
 }
@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"errors"
 	"testing"
+	"time"
+
+	"golang.org/x/net/context"
 )
 
 func TestToInterface(t *testing.T) {
@@ -278,3 +281,142 @@ func deepPointerEqual(a, b Pointer) bool {
 	bbytes, _ := msgB.Marshal()
 	return bytes.Equal(abytes, bbytes)
 }
+
+func TestErrorTypeOf(t *testing.T) {
+	overloaded := &Error{Type: Overloaded, Err: errors.New("busy")}
+	tests := []struct {
+		err error
+		typ ErrorType
+	}{
+		{errors.New("foo"), Failed},
+		{ErrUnimplemented, Unimplemented},
+		{&MethodError{Method: new(Method), Err: ErrUnimplemented}, Unimplemented},
+		{overloaded, Overloaded},
+		{&MethodError{Method: new(Method), Err: overloaded}, Overloaded},
+		{&Error{Type: Disconnected, Err: errors.New("gone")}, Disconnected},
+		{&Error{Type: Overloaded}, Overloaded},
+		{nil, Failed},
+		{&MethodError{Method: new(Method)}, Failed},
+	}
+	for _, test := range tests {
+		if typ := ErrorTypeOf(test.err); typ != test.typ {
+			t.Errorf("ErrorTypeOf(%#v) = %v; want %v", test.err, typ, test.typ)
+		}
+	}
+	if s := overloaded.Error(); s != "busy" {
+		t.Errorf("overloaded.Error() = %q; want \"busy\"", s)
+	}
+	if s := (&Error{Type: Overloaded}).Error(); s != "capnp: overloaded" {
+		t.Errorf("(&Error{Type: Overloaded}).Error() = %q; want \"capnp: overloaded\"", s)
+	}
+	if s := ErrorType(42).String(); s != "ErrorType(42)" {
+		t.Errorf("ErrorType(42).String() = %q; want \"ErrorType(42)\"", s)
+	}
+}
+
+// chanAnswer is an Answer that resolves to s when done is closed.
+type chanAnswer struct {
+	done chan struct{}
+	s    Struct
+}
+
+func (ans *chanAnswer) Struct() (Struct, error) {
+	<-ans.done
+	return ans.s, nil
+}
+
+func (ans *chanAnswer) Done() <-chan struct{} {
+	return ans.done
+}
+
+func (ans *chanAnswer) Peek() Answer {
+	select {
+	case <-ans.done:
+		return ImmediateAnswer(ans.s)
+	default:
+		return nil
+	}
+}
+
+func (ans *chanAnswer) OnResolve(f func()) {
+	go func() {
+		<-ans.done
+		f()
+	}()
+}
+
+func (ans *chanAnswer) PipelineCall([]PipelineOp, *Call) Answer {
+	return ErrorAnswer(errors.New("no pipelining"))
+}
+
+func (ans *chanAnswer) PipelineClose([]PipelineOp) error {
+	return errors.New("no pipelining")
+}
+
+func TestPipelineAwait(t *testing.T) {
+	_, seg, _ := NewMessage(SingleSegment(nil))
+	s, _ := NewRootStruct(seg, ObjectSize{DataSize: 8})
+	s.SetUint64(0, 42)
+	ans := &chanAnswer{done: make(chan struct{}), s: s}
+	p := NewPipeline(ans)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if _, err := p.Await(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Await before resolution = %v; want %v", err, context.DeadlineExceeded)
+	}
+	select {
+	case <-p.Done():
+		t.Error("Done closed before resolution")
+	default:
+	}
+
+	then := make(chan uint64, 1)
+	p.Then(func(s Struct, err error) {
+		if err != nil {
+			t.Error("Then error:", err)
+		}
+		then <- s.Uint64(0)
+	})
+	close(ans.done)
+	r, err := p.Await(context.Background())
+	if err != nil || r.Uint64(0) != 42 {
+		t.Errorf("Await after resolution = %v, %v; want struct with 42, <nil>", r, err)
+	}
+	select {
+	case x := <-then:
+		if x != 42 {
+			t.Errorf("Then called with %d; want 42", x)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Then callback not called")
+	}
+}
+
+func TestPipelineThenResolved(t *testing.T) {
+	called := false
+	NewPipeline(ErrorAnswer(errors.New("oops"))).Then(func(_ Struct, err error) {
+		called = err != nil
+	})
+	if !called {
+		t.Error("Then on a resolved answer did not call f with the error")
+	}
+}
+
+func TestPipelineDone(t *testing.T) {
+	tests := []struct {
+		name string
+		ans  Answer
+	}{
+		{"ImmediateAnswer", ImmediateAnswer(Struct{})},
+		{"ErrorAnswer", ErrorAnswer(errors.New("oops"))},
+		{"answer without Done", &recordAnswer{rc: new(recordClient)}},
+	}
+	for _, test := range tests {
+		select {
+		case <-NewPipeline(test.ans).Done():
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s: Done not closed", test.name)
+		}
+	}
+}
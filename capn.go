@@ -116,6 +116,7 @@ func (s *Segment) lookupSegment(id SegmentID) (*Segment, error) {
 }
 
 func (s *Segment) readPtr(off Address, depthLimit uint) (ptr Ptr, err error) {
+	slot := pointerSlot{s.id, off}
 	val := s.readRawPointer(off)
 	s, off, val, err = s.resolveFarPointer(off, val)
 	if err != nil {
@@ -136,7 +137,7 @@ func (s *Segment) readPtr(off Address, depthLimit uint) (ptr Ptr, err error) {
 		if err != nil {
 			return Ptr{}, err
 		}
-		if !s.msg.ReadLimiter().canRead(sp.readSize()) {
+		if !s.msg.canRead(slot, sp.readSize()) {
 			return Ptr{}, errReadLimit
 		}
 		sp.depthLimit = depthLimit - 1
@@ -146,7 +147,7 @@ func (s *Segment) readPtr(off Address, depthLimit uint) (ptr Ptr, err error) {
 		if err != nil {
 			return Ptr{}, err
 		}
-		if !s.msg.ReadLimiter().canRead(lp.readSize()) {
+		if !s.msg.canRead(slot, lp.readSize()) {
 			return Ptr{}, errReadLimit
 		}
 		lp.depthLimit = depthLimit - 1
@@ -375,10 +376,16 @@ func (s *Segment) writePtr(cc copyContext, off Address, src Ptr) error {
 				return err
 			}
 
+			// The far pointer must point at the start of the object,
+			// which for a composite list is its tag word.  The tag in
+			// the landing pad always has a zero offset.
 			srcAddr := src.address()
+			if src.flags.ptrType() == listPtrType && src.List().flags&isCompositeList != 0 {
+				srcAddr -= Address(wordSize)
+			}
 			t.writeRawPointer(dstAddr, rawFarPointer(srcSeg.id, srcAddr))
 			// alloc guarantees that two words are available.
-			t.writeRawPointer(dstAddr+Address(wordSize), src.value(srcAddr-Address(wordSize)))
+			t.writeRawPointer(dstAddr+Address(wordSize), src.value(srcAddr).withOffset(0))
 			s.writeRawPointer(off, rawDoubleFarPointer(t.id, dstAddr))
 			return nil
 		}
@@ -423,7 +430,8 @@ func copyPointer(cc copyContext, dstSeg *Segment, dstAddr Address, src Ptr) erro
 	}
 
 	// No copy nor overlap found, so we need to clone the target
-	newSeg, newAddr, err := alloc(dstSeg, Size((key.bend-key.boff)/8))
+	// Bit lists may end partway through a byte.
+	newSeg, newAddr, err := alloc(dstSeg, Size((key.bend-key.boff+7)/8))
 	if err != nil {
 		return err
 	}
@@ -465,7 +473,7 @@ func copyPointer(cc copyContext, dstSeg *Segment, dstAddr Address, src Ptr) erro
 		cc.copies.Insert(key)
 		// TODO(light): fast path for copying text/data
 		if dst.flags&isBitList != 0 {
-			copy(newSeg.data[newAddr:], l.seg.data[l.off:l.length+7/8])
+			copy(newSeg.data[newAddr:], l.seg.data[l.off:l.off+Address((l.length+7)/8)])
 		} else {
 			for i := 0; i < l.Len(); i++ {
 				err := copyStruct(cc, dst.Struct(i), l.Struct(i))
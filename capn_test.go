@@ -2,6 +2,7 @@ package capnp
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"testing"
 )
@@ -600,3 +601,92 @@ func TestCompare(t *testing.T) {
 		}
 	}
 }
+
+// exactArena is an Arena that allocates every object in a new segment
+// that is exactly large enough to hold it.  Pointers between objects
+// built in an exactArena are always far pointers, and since no segment
+// has room for a landing pad, they are double-far pointers.  Use
+// newExactArena to create one with a root pointer segment.
+type exactArena struct {
+	segs [][]byte
+}
+
+func newExactArena() *exactArena {
+	return &exactArena{segs: [][]byte{make([]byte, 0, wordSize)}}
+}
+
+func (a *exactArena) NumSegments() int64 {
+	return int64(len(a.segs))
+}
+
+func (a *exactArena) Data(id SegmentID) ([]byte, error) {
+	if int64(id) >= int64(len(a.segs)) {
+		return nil, errors.New("segment out of bounds")
+	}
+	return a.segs[id], nil
+}
+
+func (a *exactArena) Allocate(minsz Size, segs map[SegmentID]*Segment) (SegmentID, []byte, error) {
+	id := SegmentID(len(a.segs))
+	a.segs = append(a.segs, make([]byte, 0, minsz))
+	return id, a.segs[id], nil
+}
+
+func TestSetPtrDoubleFar(t *testing.T) {
+	msg, seg, err := NewMessage(newExactArena())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewRootStruct(seg, ObjectSize{PointerCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := NewCompositeList(seg, ObjectSize{DataSize: 8}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < l.Len(); i++ {
+		l.Struct(i).SetUint64(0, uint64(i+1))
+	}
+	if err := root.SetPtr(0, l.ToPtr()); err != nil {
+		t.Fatal("SetPtr(0, composite list):", err)
+	}
+	s, err := NewStruct(seg, ObjectSize{DataSize: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetUint64(0, 42)
+	if err := root.SetPtr(1, s.ToPtr()); err != nil {
+		t.Fatal("SetPtr(1, struct):", err)
+	}
+
+	data, err := msg.Marshal()
+	if err != nil {
+		t.Fatal("Marshal:", err)
+	}
+	msg, err = Unmarshal(data)
+	if err != nil {
+		t.Fatal("Unmarshal:", err)
+	}
+	rp, err := msg.RootPtr()
+	if err != nil {
+		t.Fatal("RootPtr:", err)
+	}
+	root = rp.Struct()
+	if p, err := root.Ptr(0); err != nil {
+		t.Error("root.Ptr(0):", err)
+	} else if l := p.List(); l.Len() != 3 {
+		t.Errorf("root.Ptr(0) has %d elements; want 3", l.Len())
+	} else {
+		for i := 0; i < l.Len(); i++ {
+			if x := l.Struct(i).Uint64(0); x != uint64(i+1) {
+				t.Errorf("root.Ptr(0)[%d] = %d; want %d", i, x, i+1)
+			}
+		}
+	}
+	if p, err := root.Ptr(1); err != nil {
+		t.Error("root.Ptr(1):", err)
+	} else if x := p.Struct().Uint64(0); x != 42 {
+		t.Errorf("root.Ptr(1) = %d; want 42", x)
+	}
+}
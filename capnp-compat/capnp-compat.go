@@ -0,0 +1,105 @@
+/*
+capnp-compat reports wire-incompatible changes between two versions of
+a schema.  Each version is either a .capnp file, which is compiled with
+the compiler package, or a file containing an encoded
+CodeGeneratorRequest, such as the output of capnp-compile:
+
+	capnp-compat -I std old/foo.capnp new/foo.capnp
+
+capnp-compat exits with status 1 if it finds any problems, so it can
+be used to gate changes in CI.  With -json, the problems are written as
+a JSON array.
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/compiler"
+	"zombiezen.com/go/capnproto2/schemas/compat"
+	"zombiezen.com/go/capnproto2/std/capnp/schema"
+)
+
+// stringList is a flag that can be given multiple times.
+type stringList []string
+
+func (sl *stringList) String() string {
+	return strings.Join(*sl, ",")
+}
+
+func (sl *stringList) Set(s string) error {
+	*sl = append(*sl, s)
+	return nil
+}
+
+func main() {
+	var importPath stringList
+	flag.Var(&importPath, "I", "add `dir` to the import path for .capnp files (may be repeated)")
+	asJSON := flag.Bool("json", false, "write problems as JSON")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: capnp-compat [-I dir]... [-json] OLD NEW")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	old, err := load(flag.Arg(0), importPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "capnp-compat:", err)
+		os.Exit(2)
+	}
+	new, err := load(flag.Arg(1), importPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "capnp-compat:", err)
+		os.Exit(2)
+	}
+
+	problems := compat.Check(old, new)
+	if *asJSON {
+		if problems == nil {
+			problems = []compat.Problem{}
+		}
+		enc, _ := json.MarshalIndent(problems, "", "\t")
+		fmt.Printf("%s\n", enc)
+	} else {
+		for _, p := range problems {
+			fmt.Printf("%s: %s\n", p.Kind, p)
+		}
+	}
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+}
+
+// load reads a schema from a .capnp file or an encoded
+// CodeGeneratorRequest.
+func load(name string, importPath []string) (compat.NodeSet, error) {
+	if strings.HasSuffix(name, ".capnp") {
+		req, err := compiler.Compile(&compiler.Options{ImportPath: importPath}, name)
+		if err != nil {
+			return nil, err
+		}
+		return compat.FromRequest(req)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	msg, err := capnp.NewDecoder(f).Decode()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	req, err := schema.ReadRootCodeGeneratorRequest(msg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return compat.FromRequest(req)
+}
@@ -0,0 +1,81 @@
+/*
+capnp-compile compiles Cap'n Proto schema files without the capnp tool.
+It writes a CodeGeneratorRequest for the named files to stdout, or,
+with -o, runs a code generator plugin with the request as its stdin:
+
+	capnp-compile -I std -o capnpc-go foo.capnp
+
+This is the same request that `capnp compile -o` sends to plugins.
+*/
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/compiler"
+)
+
+// stringList is a flag that can be given multiple times.
+type stringList []string
+
+func (sl *stringList) String() string {
+	return strings.Join(*sl, ",")
+}
+
+func (sl *stringList) Set(s string) error {
+	*sl = append(*sl, s)
+	return nil
+}
+
+func main() {
+	var importPath stringList
+	flag.Var(&importPath, "I", "add `dir` to the import path (may be repeated)")
+	plugin := flag.String("o", "", "run `plugin` with the request instead of writing it to stdout")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: capnp-compile [-I dir]... [-o plugin] file.capnp...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	req, err := compiler.Compile(&compiler.Options{ImportPath: importPath}, flag.Args()...)
+	if err != nil {
+		if errs, ok := err.(compiler.ErrorList); ok {
+			for _, e := range errs {
+				fmt.Fprintln(os.Stderr, e)
+			}
+		} else {
+			fmt.Fprintln(os.Stderr, "capnp-compile:", err)
+		}
+		os.Exit(1)
+	}
+	var buf bytes.Buffer
+	if err := capnp.NewEncoder(&buf).Encode(req.Struct.Segment().Message()); err != nil {
+		fmt.Fprintln(os.Stderr, "capnp-compile: encoding request:", err)
+		os.Exit(1)
+	}
+	if *plugin == "" {
+		if _, err := os.Stdout.Write(buf.Bytes()); err != nil {
+			fmt.Fprintln(os.Stderr, "capnp-compile:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	c := exec.Command(*plugin)
+	c.Stdin = &buf
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "capnp-compile: %s: %v\n", *plugin, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"zombiezen.com/go/capnproto2"
+)
+
+// maxDumpDepth is the deepest pointer that dump-segments follows.
+const maxDumpDepth = 64
+
+// dumpMessage writes the segment sizes of msg and then every pointer
+// reachable from its root, without consulting a schema.
+func dumpMessage(w io.Writer, i int, msg *capnp.Message) error {
+	n := msg.NumSegments()
+	d := &dumper{w: w, segs: make([][]byte, n), seen: make(map[[2]uint64]bool)}
+	var total int
+	for j := range d.segs {
+		seg, err := msg.Segment(capnp.SegmentID(j))
+		if err != nil {
+			return err
+		}
+		d.segs[j] = seg.Data()
+		total += len(d.segs[j]) / 8
+	}
+	fmt.Fprintf(w, "message %d: %d segments, %d words\n", i, n, total)
+	for j, data := range d.segs {
+		fmt.Fprintf(w, "  segment %d: %d words\n", j, len(data)/8)
+	}
+	d.pointer("root", 0, 0, 0)
+	return nil
+}
+
+type dumper struct {
+	w    io.Writer
+	segs [][]byte
+	seen map[[2]uint64]bool // objects already shown, by segment and word
+}
+
+func (d *dumper) word(seg uint32, off uint64) (uint64, bool) {
+	if uint64(seg) >= uint64(len(d.segs)) || off >= uint64(len(d.segs[seg]))/8 {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint64(d.segs[seg][off*8:]), true
+}
+
+func (d *dumper) inBounds(seg uint32, off int64, n uint64) bool {
+	if uint64(seg) >= uint64(len(d.segs)) || off < 0 {
+		return false
+	}
+	sz := uint64(len(d.segs[seg])) / 8
+	return uint64(off) <= sz && n <= sz-uint64(off)
+}
+
+func (d *dumper) printf(depth int, format string, args ...interface{}) {
+	fmt.Fprintf(d.w, "%s%s\n", strings.Repeat("  ", depth+1), fmt.Sprintf(format, args...))
+}
+
+// pointer describes the pointer at the given word and the object it
+// points to.
+func (d *dumper) pointer(label string, depth int, seg uint32, off uint64) {
+	loc := fmt.Sprintf("%s @%d:%d", label, seg, off)
+	p, ok := d.word(seg, off)
+	if !ok {
+		d.printf(depth, "%s: out of bounds", loc)
+		return
+	}
+	if depth > maxDumpDepth {
+		d.printf(depth, "%s: too deep", loc)
+		return
+	}
+	switch {
+	case p == 0:
+		d.printf(depth, "%s: null", loc)
+	case p&3 == 2:
+		fseg, pad := uint32(p>>32), p>>3&(1<<29-1)
+		if p&4 == 0 {
+			d.printf(depth, "%s: far -> %d:%d", loc, fseg, pad)
+			d.pointer("landing pad", depth+1, fseg, pad)
+			return
+		}
+		d.printf(depth, "%s: double far -> %d:%d", loc, fseg, pad)
+		far, ok1 := d.word(fseg, pad)
+		tag, ok2 := d.word(fseg, pad+1)
+		if !ok1 || !ok2 {
+			d.printf(depth+1, "landing pad: out of bounds")
+			return
+		}
+		if far&7 != 2 {
+			d.printf(depth+1, "landing pad: %#016x is not a far pointer", far)
+			return
+		}
+		tseg, toff := uint32(far>>32), far>>3&(1<<29-1)
+		d.object(fmt.Sprintf("landing pad -> %d:%d, tag", tseg, toff), depth+1, tseg, int64(toff), tag)
+	case p&3 == 3:
+		if p&(1<<32-1) == 3 {
+			d.printf(depth, "%s: capability %d", loc, p>>32)
+		} else {
+			d.printf(depth, "%s: unknown pointer %#016x", loc, p)
+		}
+	default:
+		target := int64(off) + 1 + int64(int32(uint32(p))>>2)
+		d.object(loc, depth, seg, target, p)
+	}
+}
+
+// object describes the struct or list at the given word, as described
+// by the struct or list pointer p, whose offset is ignored.
+func (d *dumper) object(prefix string, depth int, seg uint32, off int64, p uint64) {
+	if p&3 == 0 {
+		data, ptrs := p>>32&0xffff, p>>48
+		d.printf(depth, "%s: struct -> %d:%d, %d data words, %d pointers", prefix, seg, off, data, ptrs)
+		if !d.inBounds(seg, off, data+ptrs) {
+			d.printf(depth+1, "struct out of bounds")
+			return
+		}
+		if d.visit(depth, seg, off) {
+			for i := uint64(0); i < ptrs; i++ {
+				d.pointer(fmt.Sprintf("ptr[%d]", i), depth+1, seg, uint64(off)+data+i)
+			}
+		}
+		return
+	}
+	if p&3 != 1 {
+		d.printf(depth, "%s: %#016x is not a struct or list pointer", prefix, p)
+		return
+	}
+	et, n := p>>32&7, p>>35
+	if et != 7 {
+		bits := [...]uint64{0, 1, 8, 16, 32, 64, 64}[et]
+		kind := [...]string{"void", "bit", "byte", "2-byte", "4-byte", "8-byte", "pointer"}[et]
+		d.printf(depth, "%s: list -> %d:%d, %d %s elements", prefix, seg, off, n, kind)
+		if !d.inBounds(seg, off, (n*bits+63)/64) {
+			d.printf(depth+1, "list out of bounds")
+			return
+		}
+		if et == 6 && d.visit(depth, seg, off) {
+			for i := uint64(0); i < n; i++ {
+				d.pointer(fmt.Sprintf("[%d]", i), depth+1, seg, uint64(off)+i)
+			}
+		}
+		return
+	}
+	if !d.inBounds(seg, off, n+1) {
+		d.printf(depth, "%s: composite list -> %d:%d, %d words, out of bounds", prefix, seg, off, n)
+		return
+	}
+	tag, _ := d.word(seg, uint64(off))
+	count, data, ptrs := uint64(uint32(tag)>>2), tag>>32&0xffff, tag>>48
+	d.printf(depth, "%s: composite list -> %d:%d, %d words, %d elements of %d data words, %d pointers", prefix, seg, off, n, count, data, ptrs)
+	if tag&3 != 0 || count*(data+ptrs) > n {
+		d.printf(depth+1, "bad tag %#016x", tag)
+		return
+	}
+	if !d.visit(depth, seg, off) {
+		return
+	}
+	for i := uint64(0); i < count; i++ {
+		elem := uint64(off) + 1 + i*(data+ptrs)
+		for j := uint64(0); j < ptrs; j++ {
+			d.pointer(fmt.Sprintf("[%d].ptr[%d]", i, j), depth+1, seg, elem+data+j)
+		}
+	}
+}
+
+// visit reports whether the object at the given word hasn't been shown
+// yet, noting that it has if not.  Objects that are pointed to more
+// than once are only expanded the first time.
+func (d *dumper) visit(depth int, seg uint32, off int64) bool {
+	k := [2]uint64{uint64(seg), uint64(off)}
+	if d.seen[k] {
+		d.printf(depth+1, "(already shown)")
+		return false
+	}
+	d.seen[k] = true
+	return true
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/internal/nodemap"
+	"zombiezen.com/go/capnproto2/std/capnp/schema"
+)
+
+// A jsonEncoder writes structs as JSON objects, following the
+// conventions of the C++ JsonCodec: fields are in code order, only the
+// active member of a union is written, 64-bit integers and non-finite
+// floats are strings, data is an array of bytes, and void, interface,
+// and AnyPointer values and unset struct fields are null.
+type jsonEncoder struct {
+	w     *bufio.Writer
+	tmp   []byte
+	nodes nodemap.Map
+}
+
+func newJSONEncoder(w *bufio.Writer) *jsonEncoder {
+	return &jsonEncoder{w: w}
+}
+
+func (enc *jsonEncoder) encode(typeID uint64, s capnp.Struct) error {
+	return enc.structValue(typeID, s)
+}
+
+func (enc *jsonEncoder) structValue(typeID uint64, s capnp.Struct) error {
+	n, err := enc.nodes.Find(typeID)
+	if err != nil {
+		return err
+	}
+	if !n.IsValid() || n.Which() != schema.Node_Which_structNode {
+		return fmt.Errorf("cannot find struct type %#x", typeID)
+	}
+	var discriminant uint16
+	if n.StructNode().DiscriminantCount() > 0 {
+		discriminant = s.Uint16(capnp.DataOffset(n.StructNode().DiscriminantOffset() * 2))
+	}
+	list, err := n.StructNode().Fields()
+	if err != nil {
+		return err
+	}
+	fields := make([]schema.Field, list.Len())
+	for i := range fields {
+		f := list.At(i)
+		if int(f.CodeOrder()) >= len(fields) {
+			return fmt.Errorf("struct type %#x: bad code order", typeID)
+		}
+		fields[f.CodeOrder()] = f
+	}
+	enc.w.WriteByte('{')
+	first := true
+	for _, f := range fields {
+		if dv := f.DiscriminantValue(); !(dv == schema.Field_noDiscriminant || dv == discriminant) {
+			continue
+		}
+		if !first {
+			enc.w.WriteByte(',')
+		}
+		first = false
+		name, err := f.Name()
+		if err != nil {
+			return err
+		}
+		enc.string(name)
+		enc.w.WriteByte(':')
+		switch f.Which() {
+		case schema.Field_Which_slot:
+			err = enc.field(s, f)
+		case schema.Field_Which_group:
+			err = enc.structValue(f.Group().TypeId(), s)
+		default:
+			enc.w.WriteString("null")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	enc.w.WriteByte('}')
+	return nil
+}
+
+func (enc *jsonEncoder) field(s capnp.Struct, f schema.Field) error {
+	typ, err := f.Slot().Type()
+	if err != nil {
+		return err
+	}
+	dv, err := f.Slot().DefaultValue()
+	if err != nil {
+		return err
+	}
+	off := f.Slot().Offset()
+	switch typ.Which() {
+	case schema.Type_Which_void, schema.Type_Which_interface, schema.Type_Which_anyPointer:
+		enc.w.WriteString("null")
+	case schema.Type_Which_bool:
+		enc.bool(s.Bit(capnp.BitOffset(off)) != dv.Bool())
+	case schema.Type_Which_int8:
+		enc.int(int64(int8(s.Uint8(capnp.DataOffset(off)) ^ uint8(dv.Int8()))))
+	case schema.Type_Which_int16:
+		enc.int(int64(int16(s.Uint16(capnp.DataOffset(off*2)) ^ uint16(dv.Int16()))))
+	case schema.Type_Which_int32:
+		enc.int(int64(int32(s.Uint32(capnp.DataOffset(off*4)) ^ uint32(dv.Int32()))))
+	case schema.Type_Which_int64:
+		enc.int64(int64(s.Uint64(capnp.DataOffset(off*8)) ^ uint64(dv.Int64())))
+	case schema.Type_Which_uint8:
+		enc.uint(uint64(s.Uint8(capnp.DataOffset(off)) ^ dv.Uint8()))
+	case schema.Type_Which_uint16:
+		enc.uint(uint64(s.Uint16(capnp.DataOffset(off*2)) ^ dv.Uint16()))
+	case schema.Type_Which_uint32:
+		enc.uint(uint64(s.Uint32(capnp.DataOffset(off*4)) ^ dv.Uint32()))
+	case schema.Type_Which_uint64:
+		enc.uint64(s.Uint64(capnp.DataOffset(off*8)) ^ dv.Uint64())
+	case schema.Type_Which_float32:
+		v := s.Uint32(capnp.DataOffset(off*4)) ^ math.Float32bits(dv.Float32())
+		enc.float(float64(math.Float32frombits(v)), 32)
+	case schema.Type_Which_float64:
+		v := s.Uint64(capnp.DataOffset(off*8)) ^ math.Float64bits(dv.Float64())
+		enc.float(math.Float64frombits(v), 64)
+	case schema.Type_Which_enum:
+		v := s.Uint16(capnp.DataOffset(off*2)) ^ dv.Enum()
+		return enc.enum(typ.Enum().TypeId(), v)
+	default:
+		p, err := s.Ptr(uint16(off))
+		if err != nil {
+			return err
+		}
+		if !p.IsValid() {
+			switch typ.Which() {
+			case schema.Type_Which_text:
+				t, _ := dv.Text()
+				enc.string(t)
+				return nil
+			case schema.Type_Which_data:
+				d, _ := dv.Data()
+				enc.data(d)
+				return nil
+			case schema.Type_Which_structType:
+				// Expanding a null struct into its field defaults would
+				// never end for a recursive type, so only an explicit
+				// default is written.
+				if p, _ = dv.StructValuePtr(); !p.IsValid() {
+					enc.w.WriteString("null")
+					return nil
+				}
+			case schema.Type_Which_list:
+				p, _ = dv.ListPtr()
+			}
+		}
+		return enc.pointer(typ, p)
+	}
+	return nil
+}
+
+func (enc *jsonEncoder) pointer(typ schema.Type, p capnp.Ptr) error {
+	switch typ.Which() {
+	case schema.Type_Which_text:
+		enc.string(p.Text())
+	case schema.Type_Which_data:
+		enc.data(p.Data())
+	case schema.Type_Which_structType:
+		return enc.structValue(typ.StructType().TypeId(), p.Struct())
+	case schema.Type_Which_list:
+		elem, err := typ.List().ElementType()
+		if err != nil {
+			return err
+		}
+		return enc.list(elem, p.List())
+	case schema.Type_Which_interface, schema.Type_Which_anyPointer:
+		enc.w.WriteString("null")
+	default:
+		return fmt.Errorf("unknown field type %v", typ.Which())
+	}
+	return nil
+}
+
+func (enc *jsonEncoder) list(elem schema.Type, l capnp.List) error {
+	enc.w.WriteByte('[')
+	for i := 0; i < l.Len(); i++ {
+		if i > 0 {
+			enc.w.WriteByte(',')
+		}
+		var err error
+		switch elem.Which() {
+		case schema.Type_Which_void, schema.Type_Which_interface, schema.Type_Which_anyPointer:
+			enc.w.WriteString("null")
+		case schema.Type_Which_bool:
+			enc.bool(capnp.BitList{List: l}.At(i))
+		case schema.Type_Which_int8:
+			enc.int(int64(capnp.Int8List{List: l}.At(i)))
+		case schema.Type_Which_int16:
+			enc.int(int64(capnp.Int16List{List: l}.At(i)))
+		case schema.Type_Which_int32:
+			enc.int(int64(capnp.Int32List{List: l}.At(i)))
+		case schema.Type_Which_int64:
+			enc.int64(capnp.Int64List{List: l}.At(i))
+		case schema.Type_Which_uint8:
+			enc.uint(uint64(capnp.UInt8List{List: l}.At(i)))
+		case schema.Type_Which_uint16:
+			enc.uint(uint64(capnp.UInt16List{List: l}.At(i)))
+		case schema.Type_Which_uint32:
+			enc.uint(uint64(capnp.UInt32List{List: l}.At(i)))
+		case schema.Type_Which_uint64:
+			enc.uint64(capnp.UInt64List{List: l}.At(i))
+		case schema.Type_Which_float32:
+			enc.float(float64(capnp.Float32List{List: l}.At(i)), 32)
+		case schema.Type_Which_float64:
+			enc.float(capnp.Float64List{List: l}.At(i), 64)
+		case schema.Type_Which_enum:
+			err = enc.enum(elem.Enum().TypeId(), capnp.UInt16List{List: l}.At(i))
+		case schema.Type_Which_structType:
+			err = enc.structValue(elem.StructType().TypeId(), l.Struct(i))
+		default:
+			var p capnp.Ptr
+			p, err = capnp.PointerList{List: l}.PtrAt(i)
+			if err == nil {
+				err = enc.pointer(elem, p)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	enc.w.WriteByte(']')
+	return nil
+}
+
+func (enc *jsonEncoder) enum(typeID uint64, v uint16) error {
+	n, err := enc.nodes.Find(typeID)
+	if err != nil {
+		return err
+	}
+	if n.Which() != schema.Node_Which_enum {
+		return fmt.Errorf("encoding enum of type @%#x: type is not an enum", typeID)
+	}
+	enums, err := n.Enum().Enumerants()
+	if err != nil {
+		return err
+	}
+	if int(v) >= enums.Len() {
+		enc.uint(uint64(v))
+		return nil
+	}
+	name, err := enums.At(int(v)).Name()
+	if err != nil {
+		return err
+	}
+	enc.string(name)
+	return nil
+}
+
+func (enc *jsonEncoder) bool(v bool) {
+	enc.w.WriteString(strconv.FormatBool(v))
+}
+
+func (enc *jsonEncoder) int(i int64) {
+	enc.tmp = strconv.AppendInt(enc.tmp[:0], i, 10)
+	enc.w.Write(enc.tmp)
+}
+
+func (enc *jsonEncoder) uint(i uint64) {
+	enc.tmp = strconv.AppendUint(enc.tmp[:0], i, 10)
+	enc.w.Write(enc.tmp)
+}
+
+// int64 writes a 64-bit integer as a string, since JSON readers
+// commonly lose precision past 53 bits.
+func (enc *jsonEncoder) int64(i int64) {
+	enc.w.WriteByte('"')
+	enc.int(i)
+	enc.w.WriteByte('"')
+}
+
+func (enc *jsonEncoder) uint64(i uint64) {
+	enc.w.WriteByte('"')
+	enc.uint(i)
+	enc.w.WriteByte('"')
+}
+
+func (enc *jsonEncoder) float(f float64, bits int) {
+	switch {
+	case math.IsNaN(f):
+		enc.w.WriteString(`"NaN"`)
+	case math.IsInf(f, 1):
+		enc.w.WriteString(`"Infinity"`)
+	case math.IsInf(f, -1):
+		enc.w.WriteString(`"-Infinity"`)
+	default:
+		enc.tmp = strconv.AppendFloat(enc.tmp[:0], f, 'g', -1, bits)
+		enc.w.Write(enc.tmp)
+	}
+}
+
+func (enc *jsonEncoder) string(s string) {
+	b, _ := json.Marshal(s)
+	enc.w.Write(b)
+}
+
+func (enc *jsonEncoder) data(d []byte) {
+	enc.w.WriteByte('[')
+	for i, b := range d {
+		if i > 0 {
+			enc.w.WriteByte(',')
+		}
+		enc.uint(uint64(b))
+	}
+	enc.w.WriteByte(']')
+}
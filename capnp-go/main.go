@@ -0,0 +1,493 @@
+/*
+capnp-go inspects, converts, and validates Cap'n Proto messages without
+the capnp tool:
+
+	capnp-go decode -type Foo -schema foo.capnp < foo.bin
+	capnp-go encode -type Foo -schema foo.capnp < foo.txt > foo.bin
+	capnp-go convert -from packed -to json -type Foo -schema foo.capnp < foo.packed
+	capnp-go dump-segments < foo.bin
+	capnp-go validate -type Foo -schema foo.capnp < foo.bin
+
+Each command reads a stream of messages from the named file or from
+stdin.  Binary messages use the standard framing and packed messages
+use the packing scheme; text is the format used by the encoding/text
+package, one message per line.  JSON output writes 64-bit integers and
+non-finite floats as strings, data as arrays of bytes, and void as null,
+one object per line.  decode -pretty writes indented text instead, shows
+capabilities by their index in the capability table, and falls back to
+the raw pointer structure for AnyPointer values and unknown types.
+
+Schemas come from the packages linked into capnp-go (the schemas in
+std/capnp and RPC recordings) and from -schema files, which are either
+.capnp files, compiled with the compiler package, or files holding an encoded
+CodeGeneratorRequest, such as the output of capnp-compile.  -type
+names a struct by its ID, like @0xa93fc509624c72d9, by its full display
+name, like schema.capnp:Node, or by its name within its file,
+like Node.
+*/
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/compiler"
+	"zombiezen.com/go/capnproto2/encoding/text"
+	"zombiezen.com/go/capnproto2/internal/nodemap"
+	"zombiezen.com/go/capnproto2/schemas"
+	"zombiezen.com/go/capnproto2/schemas/loader"
+	"zombiezen.com/go/capnproto2/std/capnp/schema"
+	"zombiezen.com/go/capnproto2/validate"
+
+	// Linked so that their schemas are in the default registry.
+	_ "zombiezen.com/go/capnproto2/rpc/record"
+	_ "zombiezen.com/go/capnproto2/std/capnp/cxx"
+	_ "zombiezen.com/go/capnproto2/std/capnp/json"
+	_ "zombiezen.com/go/capnproto2/std/capnp/persistent"
+	_ "zombiezen.com/go/capnproto2/std/capnp/rpc"
+	_ "zombiezen.com/go/capnproto2/std/capnp/rpctwoparty"
+)
+
+// stringList is a flag that can be given multiple times.
+type stringList []string
+
+func (sl *stringList) String() string {
+	return strings.Join(*sl, ",")
+}
+
+func (sl *stringList) Set(s string) error {
+	*sl = append(*sl, s)
+	return nil
+}
+
+// Message formats.
+const (
+	binaryFormat = "binary"
+	packedFormat = "packed"
+	textFormat   = "text"
+	jsonFormat   = "json"
+)
+
+var commands = []struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}{
+	{"decode", "[-packed] [-pretty] -type T [file]: binary to text", decode},
+	{"encode", "[-packed] -type T [file]: text to binary", encode},
+	{"convert", "-from FMT -to FMT [-type T] [file]: between binary, packed, text, and json", convert},
+	{"dump-segments", "[-packed] [file]: show segments and pointers", dumpSegments},
+	{"validate", "[-packed] -type T [file]: check messages against the schema", validateCmd},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: capnp-go COMMAND [-I dir]... [-schema file]... [flags] [file]")
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "\t%s %s\n", c.name, c.usage)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	for _, c := range commands {
+		if c.name != os.Args[1] {
+			continue
+		}
+		if err := c.run(os.Args[2:]); err != nil {
+			if err != errInvalid {
+				fmt.Fprintf(os.Stderr, "capnp-go %s: %v\n", c.name, err)
+			}
+			os.Exit(1)
+		}
+		return
+	}
+	usage()
+	os.Exit(2)
+}
+
+// errInvalid is returned by commands that have already reported
+// why the input is invalid.
+var errInvalid = fmt.Errorf("invalid input")
+
+// options are the flags shared by all commands.
+type options struct {
+	importPath stringList
+	schemas    stringList
+	typeName   string
+	packed     bool
+	pretty     bool
+	from, to   string
+}
+
+func newFlagSet(name string, opts *options, formats bool) *flag.FlagSet {
+	fs := flag.NewFlagSet("capnp-go "+name, flag.ExitOnError)
+	fs.Var(&opts.importPath, "I", "add `dir` to the import path for .capnp files (may be repeated)")
+	fs.Var(&opts.schemas, "schema", "load schemas from `file`, a .capnp file or an encoded CodeGeneratorRequest (may be repeated)")
+	fs.StringVar(&opts.typeName, "type", "", "root struct `type`, as an ID or a name")
+	if formats {
+		fs.StringVar(&opts.from, "from", binaryFormat, "input `format`: binary, packed, or text")
+		fs.StringVar(&opts.to, "to", textFormat, "output `format`: binary, packed, text, or json")
+	} else {
+		fs.BoolVar(&opts.packed, "packed", false, "use packed binary messages")
+	}
+	return fs
+}
+
+// parse parses the command's flags, loads the schemas, and opens the
+// input.
+func (opts *options) parse(fs *flag.FlagSet, args []string) (io.ReadCloser, error) {
+	fs.Parse(args)
+	for _, name := range opts.schemas {
+		if err := loadSchema(name, opts.importPath); err != nil {
+			return nil, err
+		}
+	}
+	switch fs.NArg() {
+	case 0:
+		return os.Stdin, nil
+	case 1:
+		return os.Open(fs.Arg(0))
+	default:
+		fs.Usage()
+		os.Exit(2)
+		panic("unreachable")
+	}
+}
+
+// loadSchema adds the nodes in a .capnp file or an encoded
+// CodeGeneratorRequest to the default registry.
+func loadSchema(name string, importPath []string) error {
+	var data []byte
+	if strings.HasSuffix(name, ".capnp") {
+		req, err := compiler.Compile(&compiler.Options{ImportPath: importPath}, name)
+		if err != nil {
+			return err
+		}
+		data, err = req.Struct.Segment().Message().Marshal()
+		if err != nil {
+			return err
+		}
+	} else {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		msg, err := capnp.NewDecoder(f).Decode()
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if data, err = msg.Marshal(); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := loader.RegisterRequest(&schemas.DefaultRegistry, data); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return nil
+}
+
+// resolveType finds the ID of the struct type named by -type.
+func resolveType(name string) (uint64, error) {
+	if name == "" {
+		return 0, fmt.Errorf("-type is required")
+	}
+	if id := strings.TrimPrefix(name, "@"); strings.HasPrefix(id, "0x") {
+		return strconv.ParseUint(id[2:], 16, 64)
+	}
+	var nodes nodemap.Map
+	var matches []string
+	var found uint64
+	for _, id := range schemas.DefaultRegistry.IDs() {
+		n, err := nodes.Find(id)
+		if err != nil {
+			return 0, err
+		}
+		if n.Which() != schema.Node_Which_structNode || n.StructNode().IsGroup() {
+			continue
+		}
+		dn, _ := n.DisplayName()
+		short := dn
+		if i := int(n.DisplayNamePrefixLength()); i <= len(dn) {
+			short = dn[i:]
+		}
+		if dn == name || short == name || strings.HasSuffix(dn, "/"+name) {
+			matches = append(matches, fmt.Sprintf("%s (@%#x)", dn, id))
+			found = id
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return 0, fmt.Errorf("unknown struct type %s; use -schema to load its schema", name)
+	case 1:
+		return found, nil
+	default:
+		return 0, fmt.Errorf("struct type %s is ambiguous: %s", name, strings.Join(matches, ", "))
+	}
+}
+
+// A reader reads a stream of messages in one format.
+type reader struct {
+	format string
+	typeID uint64
+	dec    *capnp.Decoder
+	tdec   *text.Decoder
+}
+
+func newReader(r io.Reader, format string, typeID uint64) (*reader, error) {
+	rd := &reader{format: format, typeID: typeID}
+	switch format {
+	case binaryFormat:
+		rd.dec = capnp.NewDecoder(bufio.NewReader(r))
+	case packedFormat:
+		rd.dec = capnp.NewPackedDecoder(bufio.NewReader(r))
+	case textFormat:
+		rd.tdec = text.NewDecoder(r)
+	default:
+		return nil, fmt.Errorf("unknown input format %q", format)
+	}
+	return rd, nil
+}
+
+// next returns the next message in the stream, or io.EOF.
+func (rd *reader) next() (*capnp.Message, error) {
+	if rd.tdec != nil {
+		s, err := rd.tdec.Decode(rd.typeID)
+		if err != nil {
+			return nil, err
+		}
+		return s.Segment().Message(), nil
+	}
+	msg, err := rd.dec.Decode()
+	if err == io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("truncated message")
+	}
+	return msg, err
+}
+
+// A writer writes a stream of messages in one format.
+type writer struct {
+	w      *bufio.Writer
+	format string
+	typeID uint64
+	enc    *capnp.Encoder
+	tenc   *text.Encoder
+	jenc   *jsonEncoder
+}
+
+func newWriter(w io.Writer, format string, typeID uint64, pretty bool) (*writer, error) {
+	wr := &writer{w: bufio.NewWriter(w), format: format, typeID: typeID}
+	switch format {
+	case binaryFormat:
+		wr.enc = capnp.NewEncoder(wr.w)
+	case packedFormat:
+		wr.enc = capnp.NewPackedEncoder(wr.w)
+	case textFormat:
+		wr.tenc = text.NewEncoder(wr.w)
+		if pretty {
+			wr.tenc.SetIndent("  ")
+			wr.tenc.SetAnnotate(true)
+			wr.tenc.SetRawFallback(true)
+		}
+	case jsonFormat:
+		wr.jenc = newJSONEncoder(wr.w)
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+	return wr, nil
+}
+
+func (wr *writer) write(msg *capnp.Message) error {
+	if wr.enc != nil {
+		return wr.enc.Encode(msg)
+	}
+	root, err := msg.RootPtr()
+	if err != nil {
+		return err
+	}
+	if wr.tenc != nil {
+		err = wr.tenc.Encode(wr.typeID, root.Struct())
+	} else {
+		err = wr.jenc.encode(wr.typeID, root.Struct())
+	}
+	if err != nil {
+		return err
+	}
+	return wr.w.WriteByte('\n')
+}
+
+func (wr *writer) flush() error {
+	return wr.w.Flush()
+}
+
+// needsType reports whether converting between two formats needs
+// a schema.
+func needsType(formats ...string) bool {
+	for _, f := range formats {
+		if f == textFormat || f == jsonFormat {
+			return true
+		}
+	}
+	return false
+}
+
+func copyMessages(in io.Reader, out io.Writer, from, to string, opts *options) error {
+	var typeID uint64
+	if needsType(from, to) {
+		var err error
+		if typeID, err = resolveType(opts.typeName); err != nil {
+			return err
+		}
+	}
+	rd, err := newReader(in, from, typeID)
+	if err != nil {
+		return err
+	}
+	wr, err := newWriter(out, to, typeID, opts.pretty)
+	if err != nil {
+		return err
+	}
+	for i := 0; ; i++ {
+		msg, err := rd.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			wr.flush()
+			return fmt.Errorf("message %d: %v", i, err)
+		}
+		if err := wr.write(msg); err != nil {
+			wr.flush()
+			return fmt.Errorf("message %d: %v", i, err)
+		}
+	}
+	return wr.flush()
+}
+
+func binaryFormatFor(packed bool) string {
+	if packed {
+		return packedFormat
+	}
+	return binaryFormat
+}
+
+func decode(args []string) error {
+	var opts options
+	fs := newFlagSet("decode", &opts, false)
+	fs.BoolVar(&opts.pretty, "pretty", false, "write indented, annotated text")
+	in, err := opts.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return copyMessages(in, os.Stdout, binaryFormatFor(opts.packed), textFormat, &opts)
+}
+
+func encode(args []string) error {
+	var opts options
+	fs := newFlagSet("encode", &opts, false)
+	in, err := opts.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return copyMessages(in, os.Stdout, textFormat, binaryFormatFor(opts.packed), &opts)
+}
+
+func convert(args []string) error {
+	var opts options
+	fs := newFlagSet("convert", &opts, true)
+	in, err := opts.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return copyMessages(in, os.Stdout, opts.from, opts.to, &opts)
+}
+
+func validateCmd(args []string) error {
+	var opts options
+	fs := newFlagSet("validate", &opts, false)
+	in, err := opts.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	typeID, err := resolveType(opts.typeName)
+	if err != nil {
+		return err
+	}
+	rd, err := newReader(in, binaryFormatFor(opts.packed), typeID)
+	if err != nil {
+		return err
+	}
+	invalid := false
+	for i := 0; ; i++ {
+		msg, err := rd.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("message %d: %v", i, err)
+		}
+		root, err := msg.RootPtr()
+		if err == nil {
+			err = validate.Validate(typeID, root.Struct(), nil)
+		}
+		if list, ok := err.(validate.ErrorList); ok {
+			invalid = true
+			for _, e := range list {
+				fmt.Printf("message %d: %v\n", i, e)
+			}
+		} else if err != nil {
+			invalid = true
+			fmt.Printf("message %d: %v\n", i, err)
+		}
+	}
+	if invalid {
+		return errInvalid
+	}
+	return nil
+}
+
+func dumpSegments(args []string) error {
+	var opts options
+	fs := newFlagSet("dump-segments", &opts, false)
+	in, err := opts.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	rd, err := newReader(in, binaryFormatFor(opts.packed), 0)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	for i := 0; ; i++ {
+		msg, err := rd.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("message %d: %v", i, err)
+		}
+		var buf bytes.Buffer
+		err = dumpMessage(&buf, i, msg)
+		out.Write(buf.Bytes())
+		if err != nil {
+			return fmt.Errorf("message %d: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/std/capnp/schema"
+)
+
+const valuesTypeID = 0xe2b2f1c3d4a59687
+
+func loadTestSchema(t *testing.T) {
+	if err := loadSchema("testdata/values.capnp", nil); err != nil {
+		t.Fatal("loading schema:", err)
+	}
+}
+
+func TestResolveType(t *testing.T) {
+	loadTestSchema(t)
+	tests := []struct {
+		name string
+		id   uint64
+		err  string
+	}{
+		{name: "@0xabc", id: 0xabc},
+		{name: "0xabc", id: 0xabc},
+		{name: "Values", id: valuesTypeID},
+		{name: "testdata/values.capnp:Values", id: valuesTypeID},
+		{name: "values.capnp:Values", id: valuesTypeID},
+		{name: "schema.capnp:Node", id: schema.Node_TypeID},
+		{name: "", err: "-type is required"},
+		{name: "Nope", err: "unknown struct type Nope"},
+		{name: "Node", err: "struct type Node is ambiguous"},
+	}
+	for _, test := range tests {
+		id, err := resolveType(test.name)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("resolveType(%q) error = %v; want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("resolveType(%q): %v", test.name, err)
+		} else if id != test.id {
+			t.Errorf("resolveType(%q) = %#x; want %#x", test.name, id, test.id)
+		}
+	}
+}
+
+func TestConvertJSON(t *testing.T) {
+	loadTestSchema(t)
+	const defaults = `"i32":0,"i64":"0","u64":"0","f32":0,"f64":0,"text":"","data":[],"color":"red","ints":[],"choice":{"none":null},"point":{"x":0,"y":0},"def":7,"child":null`
+	tests := []struct {
+		text string
+		json string
+	}{
+		{"()", "{" + defaults + "}"},
+		{
+			"(i32 = -5, i64 = 9007199254740993, u64 = 18446744073709551615)",
+			`{"i32":-5,"i64":"9007199254740993","u64":"18446744073709551615","f32":0,"f64":0,"text":"","data":[],"color":"red","ints":[],"choice":{"none":null},"point":{"x":0,"y":0},"def":7,"child":null}`,
+		},
+		{
+			"(f32 = inf, f64 = -inf)",
+			`{"i32":0,"i64":"0","u64":"0","f32":"Infinity","f64":"-Infinity","text":"","data":[],"color":"red","ints":[],"choice":{"none":null},"point":{"x":0,"y":0},"def":7,"child":null}`,
+		},
+		{
+			"(f32 = 1.5, f64 = nan)",
+			`{"i32":0,"i64":"0","u64":"0","f32":1.5,"f64":"NaN","text":"","data":[],"color":"red","ints":[],"choice":{"none":null},"point":{"x":0,"y":0},"def":7,"child":null}`,
+		},
+		{
+			`(text = "a\"b", data = "Hi\xde", ints = [1, -2])`,
+			`{"i32":0,"i64":"0","u64":"0","f32":0,"f64":0,"text":"a\"b","data":[72,105,222],"color":"red","ints":["1","-2"],"choice":{"none":null},"point":{"x":0,"y":0},"def":7,"child":null}`,
+		},
+		{
+			"(color = green, choice = (flag = true), point = (x = 1, y = -2), def = 0)",
+			`{"i32":0,"i64":"0","u64":"0","f32":0,"f64":0,"text":"","data":[],"color":"green","ints":[],"choice":{"flag":true},"point":{"x":1,"y":-2},"def":0,"child":null}`,
+		},
+		{
+			"(color = 5, child = (id = 1))",
+			`{"i32":0,"i64":"0","u64":"0","f32":0,"f64":0,"text":"","data":[],"color":5,"ints":[],"choice":{"none":null},"point":{"x":0,"y":0},"def":7,"child":{"id":"1"}}`,
+		},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		err := copyMessages(strings.NewReader(test.text+"\n"), &buf, textFormat, jsonFormat, &options{typeName: "Values"})
+		if err != nil {
+			t.Errorf("converting %s to JSON: %v", test.text, err)
+			continue
+		}
+		if got := buf.String(); got != test.json+"\n" {
+			t.Errorf("converting %s to JSON:\ngot  %s\nwant %s", test.text, got, test.json)
+		}
+	}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	loadTestSchema(t)
+	const in = `(i32 = -5, i64 = 9007199254740993, f64 = -inf, text = "hi", data = "Hi\xde", color = green, ints = [1, -2], choice = (flag = true), point = (x = 1), child = (id = 3))` + "\n" + "()\n"
+	opts := &options{typeName: "Values"}
+	var want bytes.Buffer
+	if err := copyMessages(strings.NewReader(in), &want, textFormat, textFormat, opts); err != nil {
+		t.Fatal("text to text:", err)
+	}
+	formats := []string{textFormat, binaryFormat, packedFormat, binaryFormat, textFormat}
+	data := []byte(in)
+	for i := 1; i < len(formats); i++ {
+		var buf bytes.Buffer
+		if err := copyMessages(bytes.NewReader(data), &buf, formats[i-1], formats[i], opts); err != nil {
+			t.Fatalf("%s to %s: %v", formats[i-1], formats[i], err)
+		}
+		data = buf.Bytes()
+	}
+	if !bytes.Equal(data, want.Bytes()) {
+		t.Errorf("after converting through binary and packed, text =\n%s\nwant\n%s", data, want.Bytes())
+	}
+}
+
+// segment builds a segment out of little-endian words.
+func segment(words ...uint64) []byte {
+	b := make([]byte, len(words)*8)
+	for i, w := range words {
+		binary.LittleEndian.PutUint64(b[i*8:], w)
+	}
+	return b
+}
+
+func TestDumpMessage(t *testing.T) {
+	built, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, _ := capnp.NewRootStruct(seg, capnp.ObjectSize{DataSize: 8, PointerCount: 3})
+	txt, _ := capnp.NewText(seg, "hi")
+	root.SetPtr(0, txt.ToPtr())
+	list, _ := capnp.NewCompositeList(seg, capnp.ObjectSize{DataSize: 8, PointerCount: 1}, 2)
+	root.SetPtr(1, list.ToPtr())
+	list.Struct(0).SetPtr(0, txt.ToPtr())
+
+	tests := []struct {
+		name string
+		msg  *capnp.Message
+		want string
+	}{
+		{
+			name: "built",
+			msg:  built,
+			want: "message 0: 1 segments, 11 words\n" +
+				"  segment 0: 11 words\n" +
+				"  root @0:0: struct -> 0:1, 1 data words, 3 pointers\n" +
+				"    ptr[0] @0:2: list -> 0:5, 3 byte elements\n" +
+				"    ptr[1] @0:3: composite list -> 0:6, 4 words, 2 elements of 1 data words, 1 pointers\n" +
+				"      [0].ptr[0] @0:8: list -> 0:5, 3 byte elements\n" +
+				"      [1].ptr[0] @0:10: null\n" +
+				"    ptr[2] @0:4: null\n",
+		},
+		{
+			name: "null root",
+			msg:  &capnp.Message{Arena: capnp.SingleSegment(segment(0))},
+			want: "message 0: 1 segments, 1 words\n" +
+				"  segment 0: 1 words\n" +
+				"  root @0:0: null\n",
+		},
+		{
+			name: "far pointer",
+			msg: &capnp.Message{Arena: capnp.MultiSegment([][]byte{
+				segment(0x0000000100000002),
+				segment(0x0000000100000000, 42),
+			})},
+			want: "message 0: 2 segments, 3 words\n" +
+				"  segment 0: 1 words\n" +
+				"  segment 1: 2 words\n" +
+				"  root @0:0: far -> 1:0\n" +
+				"    landing pad @1:0: struct -> 1:1, 1 data words, 0 pointers\n",
+		},
+		{
+			name: "capability",
+			msg:  &capnp.Message{Arena: capnp.SingleSegment(segment(0x0000000500000003))},
+			want: "message 0: 1 segments, 1 words\n" +
+				"  segment 0: 1 words\n" +
+				"  root @0:0: capability 5\n",
+		},
+		{
+			name: "struct out of bounds",
+			msg:  &capnp.Message{Arena: capnp.SingleSegment(segment(0x0000000400000000))},
+			want: "message 0: 1 segments, 1 words\n" +
+				"  segment 0: 1 words\n" +
+				"  root @0:0: struct -> 0:1, 4 data words, 0 pointers\n" +
+				"    struct out of bounds\n",
+		},
+		{
+			name: "cycle",
+			msg:  &capnp.Message{Arena: capnp.SingleSegment(segment(0x0001000000000000, 0x00010000fffffffc))},
+			want: "message 0: 1 segments, 2 words\n" +
+				"  segment 0: 2 words\n" +
+				"  root @0:0: struct -> 0:1, 0 data words, 1 pointers\n" +
+				"    ptr[0] @0:1: struct -> 0:1, 0 data words, 1 pointers\n" +
+				"      (already shown)\n",
+		},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := dumpMessage(&buf, 0, test.msg); err != nil {
+			t.Errorf("%s: dumpMessage: %v", test.name, err)
+			continue
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%s: dumpMessage wrote:\n%s\nwant:\n%s", test.name, got, test.want)
+		}
+	}
+}
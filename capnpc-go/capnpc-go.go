@@ -42,6 +42,7 @@ type genoptions struct {
 	promises      bool
 	schemas       bool
 	structStrings bool
+	mocks         bool
 }
 
 type renderer interface {
@@ -1057,6 +1058,16 @@ func (g *generator) defineInterface(n *node) error {
 	if err != nil {
 		return fmt.Errorf("interface server %s: %v", n, err)
 	}
+	if g.opts.mocks {
+		err = renderInterfaceMock(g.r, interfaceMockParams{
+			G:       g,
+			Node:    n,
+			Methods: m,
+		})
+		if err != nil {
+			return fmt.Errorf("interface mock %s: %v", n, err)
+		}
+	}
 	return nil
 }
 
@@ -1164,6 +1175,7 @@ func main() {
 	flag.BoolVar(&opts.promises, "promises", true, "generate code for promises")
 	flag.BoolVar(&opts.schemas, "schemas", true, "embed schema information in generated code")
 	flag.BoolVar(&opts.structStrings, "structstrings", true, "generate String() methods for structs (-schemas must be true)")
+	flag.BoolVar(&opts.mocks, "mocks", false, "generate fake implementations of interfaces for tests")
 	flag.Parse()
 
 	msg, err := capnp.NewDecoder(os.Stdin).Decode()
@@ -336,8 +336,20 @@ func TestDefineFile(t *testing.T) {
 			schemas:       true,
 			structStrings: true,
 		}},
+		{0x832bcc6686a26d56, "aircraft.capnp.out", genoptions{
+			promises:      true,
+			schemas:       true,
+			structStrings: true,
+			mocks:         true,
+		}},
 		{0x83c2b5818e83ab19, "group.capnp.out", defaultOptions},
 		{0xb312981b2552a250, "rpc.capnp.out", defaultOptions},
+		{0xb312981b2552a250, "rpc.capnp.out", genoptions{
+			promises:      true,
+			schemas:       true,
+			structStrings: true,
+			mocks:         true,
+		}},
 		{0xd68755941d99d05e, "scopes.capnp.out", defaultOptions},
 		{0xecd50d792c3d9992, "util.capnp.out", defaultOptions},
 	}
@@ -71,6 +71,7 @@ func (i *imports) init() {
 
 	i.reserve(importSpec{path: "math", name: "math"})
 	i.reserve(importSpec{path: "strconv", name: "strconv"})
+	i.reserve(importSpec{path: "sync", name: "sync"})
 }
 
 func (i *imports) Capnp() string {
@@ -101,6 +102,10 @@ func (i *imports) Strconv() string {
 	return i.add(importSpec{path: "strconv", name: "strconv"})
 }
 
+func (i *imports) Sync() string {
+	return i.add(importSpec{path: "sync", name: "sync"})
+}
+
 func (i *imports) usedImports() []importSpec {
 	specs := make([]importSpec, 0, len(i.specs))
 	for _, s := range i.specs {
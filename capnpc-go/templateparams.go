@@ -3,6 +3,9 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"strings"
+
+	"zombiezen.com/go/capnproto2/std/capnp/schema"
 )
 
 type annotationParams struct {
@@ -134,6 +137,27 @@ type promiseParams struct {
 	Fields []field
 }
 
+// HasPromiseField reports whether the promise has a getter for a field
+// with the given name, which would collide with a helper method.
+func (p promiseParams) HasPromiseField(name string) bool {
+	for _, f := range p.Fields {
+		if strings.Title(f.Name) != strings.Title(name) {
+			continue
+		}
+		switch f.Which() {
+		case schema.Field_Which_slot:
+			t, _ := f.Slot().Type()
+			switch t.Which() {
+			case schema.Type_Which_structType, schema.Type_Which_interface, schema.Type_Which_anyPointer:
+				return true
+			}
+		case schema.Field_Which_group:
+			return true
+		}
+	}
+	return false
+}
+
 type promiseGroupParams struct {
 	G     *generator
 	Node  *node
@@ -176,6 +200,12 @@ type interfaceServerParams struct {
 	Methods     []interfaceMethod
 }
 
+type interfaceMockParams struct {
+	G       *generator
+	Node    *node
+	Methods []interfaceMethod
+}
+
 type structValueParams struct {
 	G     *generator
 	Node  *node
@@ -0,0 +1,132 @@
+package capnp
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// A ClientHook intercepts calls made on a client returned by
+// WrapClient.  It is called with the outgoing call and a function that
+// continues the call, and it returns the call's answer.
+//
+// A hook may modify the call before passing it to next: for example,
+// it may replace call.Ctx, add options with call.Options.With, or wrap
+// call.ParamsFunc to fill in additional parameters.  A hook may also
+// inspect the answer that next returns, or return an answer of its own
+// without calling next.  Since Call must not block, a hook that acts on
+// the answer's result should use AnswerOnResolve rather than waiting.
+type ClientHook func(call *Call, next func(*Call) Answer) Answer
+
+// WrapClient returns a client that passes each call through hooks
+// before sending it to c.  The first hook is outermost.  Closing the
+// returned client closes c.
+//
+// Calls made on promised capabilities in an answer from the wrapped
+// client, i.e. pipelined calls, also pass through hooks.  Capabilities
+// obtained from a resolved answer are not wrapped.
+func WrapClient(c Client, hooks ...ClientHook) Client {
+	if len(hooks) == 0 {
+		return c
+	}
+	return &hookClient{client: c, hooks: hooks}
+}
+
+type hookClient struct {
+	client Client
+	hooks  []ClientHook
+}
+
+func (hc *hookClient) Call(call *Call) Answer {
+	return hc.run(call, hc.client.Call)
+}
+
+// run calls each hook in turn with send as the innermost function.
+// The returned answer is wrapped so that pipelined calls also run
+// through the hooks.
+func (hc *hookClient) run(call *Call, send func(*Call) Answer) Answer {
+	var next func(int, *Call) Answer
+	next = func(i int, call *Call) Answer {
+		if i == len(hc.hooks) {
+			return send(call)
+		}
+		return hc.hooks[i](call, func(call *Call) Answer {
+			return next(i+1, call)
+		})
+	}
+	ans := next(0, call)
+	if _, isErr := ans.(errorAnswer); isErr {
+		// Pipelined calls on an error answer fail without being sent.
+		return ans
+	}
+	return &hookAnswer{Answer: ans, hc: hc}
+}
+
+func (hc *hookClient) Close() error {
+	return hc.client.Close()
+}
+
+// hookAnswer is an answer from a hookClient.
+type hookAnswer struct {
+	Answer
+	hc *hookClient
+}
+
+func (ans *hookAnswer) PipelineCall(transform []PipelineOp, call *Call) Answer {
+	return ans.hc.run(call, func(call *Call) Answer {
+		return ans.Answer.PipelineCall(transform, call)
+	})
+}
+
+func (ans *hookAnswer) Done() <-chan struct{} {
+	return AnswerDone(ans.Answer)
+}
+
+func (ans *hookAnswer) Peek() Answer {
+	return PeekAnswer(ans.Answer)
+}
+
+func (ans *hookAnswer) OnResolve(f func()) {
+	AnswerOnResolve(ans.Answer, f)
+}
+
+// DefaultTimeout returns a hook that gives calls without a deadline a
+// deadline of d from when the call is made.  Calls that already have a
+// deadline are passed through unchanged.
+func DefaultTimeout(d time.Duration) ClientHook {
+	return func(call *Call, next func(*Call) Answer) Answer {
+		if _, ok := call.Ctx.Deadline(); ok {
+			return next(call)
+		}
+		ctx, cancel := context.WithTimeout(call.Ctx, d)
+		c := *call
+		c.Ctx = ctx
+		ans := next(&c)
+		AnswerOnResolve(ans, cancel)
+		return ans
+	}
+}
+
+// LogCalls returns a hook that logs each call when it is made and
+// again when its answer resolves, along with the call's duration and
+// any error.  The signature of logf matches rpc.Logger's Infof method.
+// The second message is logged from the goroutine that resolves the
+// answer, so logf should not block.
+func LogCalls(logf func(ctx context.Context, format string, args ...interface{})) ClientHook {
+	return func(call *Call, next func(*Call) Answer) Answer {
+		m := call.Method
+		logf(call.Ctx, "call %v", &m)
+		start := time.Now()
+		ans := next(call)
+		ctx := call.Ctx
+		AnswerOnResolve(ans, func() {
+			// The answer is resolved, so Struct doesn't block.
+			if _, err := ans.Struct(); err != nil {
+				logf(ctx, "%v failed after %v: %v", &m, time.Since(start), err)
+			} else {
+				logf(ctx, "%v returned after %v", &m, time.Since(start))
+			}
+		})
+		return ans
+	}
+}
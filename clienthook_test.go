@@ -0,0 +1,269 @@
+package capnp
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// recordClient is a Client that records the calls made on it.
+type recordClient struct {
+	calls  []*Call
+	params []Struct
+	closed bool
+}
+
+func (rc *recordClient) Call(call *Call) Answer {
+	rc.calls = append(rc.calls, call)
+	p, err := call.PlaceParams(nil)
+	if err != nil {
+		return ErrorAnswer(err)
+	}
+	rc.params = append(rc.params, p)
+	return &recordAnswer{rc: rc}
+}
+
+func (rc *recordClient) Close() error {
+	rc.closed = true
+	return nil
+}
+
+// recordAnswer is an Answer that sends pipelined calls to its client.
+type recordAnswer struct {
+	rc         *recordClient
+	transforms [][]PipelineOp
+}
+
+func (ans *recordAnswer) Struct() (Struct, error) {
+	return Struct{}, nil
+}
+
+func (ans *recordAnswer) PipelineCall(transform []PipelineOp, call *Call) Answer {
+	ans.transforms = append(ans.transforms, transform)
+	return ans.rc.Call(call)
+}
+
+func (ans *recordAnswer) PipelineClose(transform []PipelineOp) error {
+	return nil
+}
+
+// pendingAnswer is an AsyncAnswer that resolves when resolve is called.
+type pendingAnswer struct {
+	done      chan struct{}
+	callbacks []func()
+}
+
+func newPendingAnswer() *pendingAnswer {
+	return &pendingAnswer{done: make(chan struct{})}
+}
+
+func (ans *pendingAnswer) resolve() {
+	close(ans.done)
+	for _, f := range ans.callbacks {
+		f()
+	}
+}
+
+func (ans *pendingAnswer) Struct() (Struct, error) {
+	<-ans.done
+	return Struct{}, nil
+}
+
+func (ans *pendingAnswer) PipelineCall(transform []PipelineOp, call *Call) Answer {
+	return ErrorAnswer(errors.New("pipelining not supported"))
+}
+
+func (ans *pendingAnswer) PipelineClose(transform []PipelineOp) error {
+	return nil
+}
+
+func (ans *pendingAnswer) Done() <-chan struct{} {
+	return ans.done
+}
+
+func (ans *pendingAnswer) Peek() Answer {
+	select {
+	case <-ans.done:
+		return ImmediateAnswer(Struct{})
+	default:
+		return nil
+	}
+}
+
+func (ans *pendingAnswer) OnResolve(f func()) {
+	select {
+	case <-ans.done:
+		f()
+	default:
+		ans.callbacks = append(ans.callbacks, f)
+	}
+}
+
+// pendingClient is a Client that answers calls with a pendingAnswer.
+type pendingClient struct {
+	ans   *pendingAnswer
+	calls []*Call
+}
+
+func (pc *pendingClient) Call(call *Call) Answer {
+	pc.calls = append(pc.calls, call)
+	return pc.ans
+}
+
+func (pc *pendingClient) Close() error {
+	return nil
+}
+
+type hookKey struct{}
+
+func TestWrapClient(t *testing.T) {
+	rc := new(recordClient)
+	var log []string
+	c := WrapClient(rc,
+		func(call *Call, next func(*Call) Answer) Answer {
+			log = append(log, "a "+call.Method.MethodName)
+			call.Options = call.Options.With([]CallOption{SetOptionValue(hookKey{}, "token")})
+			return next(call)
+		},
+		func(call *Call, next func(*Call) Answer) Answer {
+			log = append(log, "b "+call.Method.MethodName)
+			f := call.ParamsFunc
+			call.ParamsFunc = func(s Struct) error {
+				if err := f(s); err != nil {
+					return err
+				}
+				s.SetUint32(4, 2)
+				return nil
+			}
+			return next(call)
+		})
+
+	ans := c.Call(&Call{
+		Ctx:        context.Background(),
+		Method:     Method{MethodName: "foo"},
+		ParamsSize: ObjectSize{DataSize: 8},
+		ParamsFunc: func(s Struct) error {
+			s.SetUint32(0, 1)
+			return nil
+		},
+		Options: NewCallOptions(nil),
+	})
+	if _, err := ans.Struct(); err != nil {
+		t.Fatal("Struct:", err)
+	}
+	if len(rc.calls) != 1 {
+		t.Fatalf("client received %d calls; want 1", len(rc.calls))
+	}
+	if v := rc.calls[0].Options.Value(hookKey{}); v != "token" {
+		t.Errorf("call option = %v; want token", v)
+	}
+	if p := rc.params[0]; p.Uint32(0) != 1 || p.Uint32(4) != 2 {
+		t.Errorf("params = (%d, %d); want (1, 2)", p.Uint32(0), p.Uint32(4))
+	}
+
+	// Pipelined calls go through the hooks, then the original answer.
+	pc := NewPipeline(ans).GetPipeline(3).Client()
+	pc.Call(&Call{
+		Ctx:        context.Background(),
+		Method:     Method{MethodName: "bar"},
+		ParamsSize: ObjectSize{DataSize: 8},
+		ParamsFunc: func(Struct) error { return nil },
+		Options:    NewCallOptions(nil),
+	})
+	if ra := ans.(*hookAnswer).Answer.(*recordAnswer); len(ra.transforms) != 1 || len(ra.transforms[0]) != 1 || ra.transforms[0][0].Field != 3 {
+		t.Errorf("pipelined transforms = %v; want [[get field 3]]", ra.transforms)
+	}
+	if len(rc.calls) != 2 {
+		t.Fatalf("client received %d calls; want 2", len(rc.calls))
+	}
+	if v := rc.calls[1].Options.Value(hookKey{}); v != "token" {
+		t.Errorf("pipelined call option = %v; want token", v)
+	}
+	if want := "[a foo b foo a bar b bar]"; fmt.Sprint(log) != want {
+		t.Errorf("hook log = %v; want %s", log, want)
+	}
+
+	if err := c.Close(); err != nil || !rc.closed {
+		t.Errorf("Close() = %v, closed = %t; want <nil>, true", err, rc.closed)
+	}
+}
+
+func TestWrapClientShortCircuit(t *testing.T) {
+	rc := new(recordClient)
+	errDenied := errors.New("denied")
+	c := WrapClient(rc, func(call *Call, next func(*Call) Answer) Answer {
+		return ErrorAnswer(errDenied)
+	})
+	ans := c.Call(&Call{Ctx: context.Background(), Options: NewCallOptions(nil)})
+	if _, err := ans.Struct(); err != errDenied {
+		t.Errorf("Struct() error = %v; want %v", err, errDenied)
+	}
+	if len(rc.calls) != 0 {
+		t.Errorf("client received %d calls; want 0", len(rc.calls))
+	}
+}
+
+func TestDefaultTimeout(t *testing.T) {
+	rc := new(recordClient)
+	c := WrapClient(rc, DefaultTimeout(time.Minute))
+
+	start := time.Now()
+	c.Call(&Call{Ctx: context.Background(), Options: NewCallOptions(nil)}).Struct()
+	d, ok := rc.calls[0].Ctx.Deadline()
+	if !ok {
+		t.Error("call without deadline: no deadline added")
+	} else if d.Before(start.Add(time.Minute)) || d.After(time.Now().Add(time.Minute)) {
+		t.Errorf("call without deadline: deadline = %v; want about a minute from %v", d, start)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	want, _ := ctx.Deadline()
+	c.Call(&Call{Ctx: ctx, Options: NewCallOptions(nil)}).Struct()
+	if d, _ := rc.calls[1].Ctx.Deadline(); !d.Equal(want) {
+		t.Errorf("call with deadline: deadline = %v; want %v", d, want)
+	}
+}
+
+func TestDefaultTimeoutCancelsOnResolve(t *testing.T) {
+	pc := &pendingClient{ans: newPendingAnswer()}
+	c := WrapClient(pc, DefaultTimeout(time.Minute))
+
+	c.Call(&Call{Ctx: context.Background(), Options: NewCallOptions(nil)})
+	ctx := pc.calls[0].Ctx
+	if err := ctx.Err(); err != nil {
+		t.Fatalf("before answer resolved: ctx.Err() = %v; want <nil>", err)
+	}
+	pc.ans.resolve()
+	if err := ctx.Err(); err != context.Canceled {
+		t.Errorf("after answer resolved: ctx.Err() = %v; want %v", err, context.Canceled)
+	}
+}
+
+func TestLogCalls(t *testing.T) {
+	lines := make(chan string, 2)
+	logf := func(ctx context.Context, format string, args ...interface{}) {
+		lines <- fmt.Sprintf(format, args...)
+	}
+	c := WrapClient(ErrorClient(errors.New("oops")), LogCalls(logf))
+	c.Call(&Call{
+		Ctx:     context.Background(),
+		Method:  Method{InterfaceName: "foo.capnp:Foo", MethodName: "bar"},
+		Options: NewCallOptions(nil),
+	})
+	if line := <-lines; line != "call foo.capnp:Foo.bar" {
+		t.Errorf("first log line = %q; want \"call foo.capnp:Foo.bar\"", line)
+	}
+	select {
+	case line := <-lines:
+		const prefix = "foo.capnp:Foo.bar failed after "
+		if len(line) < len(prefix) || line[:len(prefix)] != prefix {
+			t.Errorf("second log line = %q; want prefix %q", line, prefix)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("answer not logged")
+	}
+}
@@ -0,0 +1,161 @@
+package capnp
+
+// MessageSize is the size of a tree of objects in a message.
+type MessageSize struct {
+	// WordCount is the number of words the objects occupy, not counting
+	// the pointer to the root object or far pointer landing pads.
+	WordCount uint64
+
+	// CapCount is the number of capability pointers in the tree.
+	CapCount uint
+}
+
+func (sz *MessageSize) add(o MessageSize) {
+	sz.WordCount += o.WordCount
+	sz.CapCount += o.CapCount
+}
+
+// TotalSize returns the size of the struct and every object reachable
+// from it.  Objects that are referenced more than once are counted
+// each time.  The result is the space the struct would take up if it
+// were copied into a new message, so it can be compared against the
+// size of the message to decide whether calling Compact is worthwhile.
+//
+// Traversing the struct counts against the message's read limit, just
+// like reading its fields.
+func (p Struct) TotalSize() (MessageSize, error) {
+	if p.seg == nil {
+		return MessageSize{}, nil
+	}
+	sz, err := p.pointersTotalSize()
+	if err != nil {
+		return MessageSize{}, err
+	}
+	sz.WordCount += uint64(p.size.totalSize() / wordSize)
+	return sz, nil
+}
+
+// pointersTotalSize returns the size of the objects reachable from the
+// struct's pointer section, not counting the struct itself.
+func (p Struct) pointersTotalSize() (MessageSize, error) {
+	var sz MessageSize
+	for i := uint16(0); i < p.size.PointerCount; i++ {
+		ptr, err := p.Ptr(i)
+		if err != nil {
+			return MessageSize{}, err
+		}
+		psz, err := ptrTotalSize(ptr)
+		if err != nil {
+			return MessageSize{}, err
+		}
+		sz.add(psz)
+	}
+	return sz, nil
+}
+
+func ptrTotalSize(p Ptr) (MessageSize, error) {
+	switch p.flags.ptrType() {
+	case structPtrType:
+		return p.Struct().TotalSize()
+	case listPtrType:
+		return p.List().totalSize()
+	case interfacePtrType:
+		return MessageSize{CapCount: 1}, nil
+	default:
+		return MessageSize{}, nil
+	}
+}
+
+func (p List) totalSize() (MessageSize, error) {
+	if p.seg == nil {
+		return MessageSize{}, nil
+	}
+	var sz MessageSize
+	switch {
+	case p.flags&isBitList != 0:
+		sz.WordCount = (uint64(p.length) + 63) / 64
+		return sz, nil
+	case p.flags&isCompositeList != 0:
+		// Count the tag word.
+		sz.WordCount = 1 + uint64(p.length)*uint64(p.size.totalSize()/wordSize)
+	default:
+		sz.WordCount = (uint64(p.length)*uint64(p.size.totalSize()) + uint64(wordSize) - 1) / uint64(wordSize)
+	}
+	if p.size.PointerCount == 0 {
+		return sz, nil
+	}
+	for i := 0; i < p.Len(); i++ {
+		esz, err := p.Struct(i).pointersTotalSize()
+		if err != nil {
+			return MessageSize{}, err
+		}
+		sz.add(esz)
+	}
+	return sz, nil
+}
+
+// CopyToNewMessage makes a deep copy of p into the root of a new
+// single-segment message.  Only objects that are reachable from p are
+// copied, so the new message is usually smaller than p's message if
+// fields have been overwritten.  Capabilities referenced by p are added
+// to the new message's capability table.
+func CopyToNewMessage(p Ptr) (*Message, error) {
+	var words uint64
+	if s := p.Struct(); s.IsValid() {
+		sz, err := s.TotalSize()
+		if err != nil {
+			return nil, err
+		}
+		words = sz.WordCount
+	} else if l := p.List(); l.IsValid() {
+		sz, err := l.totalSize()
+		if err != nil {
+			return nil, err
+		}
+		words = sz.WordCount
+	}
+	// Leave room for the root pointer.
+	buf := make([]byte, 0, (words+1)*uint64(wordSize))
+	msg, _, err := NewMessage(SingleSegment(buf))
+	if err != nil {
+		return nil, err
+	}
+	if err := msg.SetRootPtr(p); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Compact rebuilds the message so that it only holds objects that are
+// reachable from its root.  Overwriting a pointer field does not free
+// the object it used to point to, so a message that is edited
+// repeatedly keeps growing until it is compacted.
+//
+// Compact replaces the message's arena and capability table, and
+// resets the read limit.  Like Reset, it invalidates any existing
+// pointers into the message, so callers must read the root again
+// afterward.
+func (m *Message) Compact() error {
+	root, err := m.RootPtr()
+	if err != nil {
+		return err
+	}
+	c, err := CopyToNewMessage(root)
+	if err != nil {
+		return err
+	}
+	seg, err := c.Segment(0)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	// The copy's arena doesn't know how much of its buffer is in use,
+	// so start a new arena from the segment's data.
+	m.Arena = SingleSegment(seg.data)
+	m.CapTable = c.CapTable
+	m.segs = nil
+	m.firstSeg = Segment{}
+	m.mu.Unlock()
+	m.ReadLimiter().Reset(m.traverseLimit())
+	return nil
+}
@@ -0,0 +1,200 @@
+package capnp
+
+import (
+	"testing"
+)
+
+func TestTotalSize(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewRootStruct(seg, ObjectSize{DataSize: 8, PointerCount: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetText(0, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	l, err := NewCompositeList(seg, ObjectSize{DataSize: 8, PointerCount: 1}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Struct(1).SetText(0, "a much longer string"); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetPtr(1, l.ToPtr()); err != nil {
+		t.Fatal(err)
+	}
+	bits, err := NewBitList(seg, 65)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetPtr(2, bits.ToPtr()); err != nil {
+		t.Fatal(err)
+	}
+	id := seg.Message().AddCap(nil)
+	if err := root.SetPtr(3, NewInterface(seg, id).ToPtr()); err != nil {
+		t.Fatal(err)
+	}
+
+	sz, err := root.TotalSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// root (5) + "hello" (1) + list tag (1) + elements (4) +
+	// "a much longer string" (3) + bits (2)
+	want := MessageSize{WordCount: 16, CapCount: 1}
+	if sz != want {
+		t.Errorf("TotalSize() = %+v; want %+v", sz, want)
+	}
+	if sz, err := (Struct{}).TotalSize(); err != nil || sz != (MessageSize{}) {
+		t.Errorf("Struct{}.TotalSize() = %+v, %v; want zero, <nil>", sz, err)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	msg, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewRootStruct(seg, ObjectSize{DataSize: 8, PointerCount: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.SetUint64(0, 0xdeadbeef)
+	for i := 0; i < 100; i++ {
+		if err := root.SetText(0, "some text that gets overwritten"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bits, err := NewBitList(seg, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bits.Set(9, true)
+	if err := root.SetPtr(1, bits.ToPtr()); err != nil {
+		t.Fatal(err)
+	}
+	msg.AddCap(nil)
+	id := msg.AddCap(nil)
+	if err := root.SetPtr(2, NewInterface(seg, id).ToPtr()); err != nil {
+		t.Fatal(err)
+	}
+	before, err := msg.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := msg.Compact(); err != nil {
+		t.Fatal("Compact:", err)
+	}
+	after, err := msg.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// header (1) + root pointer (1) + root (4) + text (4) + bits (1)
+	if want := 11 * int(wordSize); len(after) != want {
+		t.Errorf("len(Marshal()) after Compact = %d (was %d); want %d", len(after), len(before), want)
+	}
+	if n := msg.NumSegments(); n != 1 {
+		t.Errorf("NumSegments() after Compact = %d; want 1", n)
+	}
+
+	p, err := msg.RootPtr()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := p.Struct()
+	if x := s.Uint64(0); x != 0xdeadbeef {
+		t.Errorf("root.Uint64(0) = %#x; want 0xdeadbeef", x)
+	}
+	if p, err := s.Ptr(0); err != nil || p.Text() != "some text that gets overwritten" {
+		t.Errorf("root.Ptr(0).Text() = %q, %v; want %q", p.Text(), err, "some text that gets overwritten")
+	}
+	if p, err := s.Ptr(1); err != nil {
+		t.Error("root.Ptr(1):", err)
+	} else if bl := (BitList{p.List()}); bl.Len() != 10 || !bl.At(9) || bl.At(8) {
+		t.Errorf("root.Ptr(1) = %v; want 10 bits with only bit 9 set", bl)
+	}
+	if p, err := s.Ptr(2); err != nil {
+		t.Error("root.Ptr(2):", err)
+	} else if i := p.Interface(); !i.IsValid() || int(i.Capability()) >= len(msg.CapTable) {
+		t.Errorf("root.Ptr(2) = capability %d; want index into table of %d", i.Capability(), len(msg.CapTable))
+	}
+}
+
+func TestCopyToNewMessage(t *testing.T) {
+	_, seg, err := NewMessage(MultiSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := NewCompositeList(seg, ObjectSize{DataSize: 8, PointerCount: 1}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < l.Len(); i++ {
+		l.Struct(i).SetUint64(0, uint64(i))
+		if err := l.Struct(i).SetText(0, "x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	msg, err := CopyToNewMessage(l.ToPtr())
+	if err != nil {
+		t.Fatal("CopyToNewMessage:", err)
+	}
+	if msg == seg.Message() {
+		t.Fatal("CopyToNewMessage returned the same message")
+	}
+	p, err := msg.RootPtr()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cl := p.List()
+	if cl.Len() != 3 {
+		t.Fatalf("copy has %d elements; want 3", cl.Len())
+	}
+	for i := 0; i < cl.Len(); i++ {
+		s := cl.Struct(i)
+		if x := s.Uint64(0); x != uint64(i) {
+			t.Errorf("copy[%d].Uint64(0) = %d; want %d", i, x, i)
+		}
+		if p, err := s.Ptr(0); err != nil || p.Text() != "x" {
+			t.Errorf("copy[%d].Ptr(0).Text() = %q, %v; want \"x\"", i, p.Text(), err)
+		}
+	}
+
+	// Short bit lists still occupy a word.
+	bl, err := NewBitList(seg, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bl.Set(1, true)
+	bl.Set(5, true)
+	msg, err = CopyToNewMessage(bl.List.ToPtr())
+	if err != nil {
+		t.Fatal("CopyToNewMessage(bit list):", err)
+	}
+	data, err := msg.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg, err = Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := msg.RootPtr(); err != nil {
+		t.Error("bit list copy RootPtr:", err)
+	} else if bl := (BitList{p.List()}); bl.Len() != 6 || !bl.At(1) || !bl.At(5) || bl.At(0) {
+		t.Errorf("bit list copy = %v; want [false, true, false, false, false, true]", bl)
+	}
+
+	// A null pointer copies to a message with a null root.
+	msg, err = CopyToNewMessage(Ptr{})
+	if err != nil {
+		t.Fatal("CopyToNewMessage(Ptr{}):", err)
+	}
+	if p, err := msg.RootPtr(); err != nil || p.IsValid() {
+		t.Errorf("CopyToNewMessage(Ptr{}).RootPtr() = %v, %v; want null", p, err)
+	}
+}
@@ -0,0 +1,269 @@
+// Package compiler parses and compiles Cap'n Proto schema files
+// without the capnp tool.
+//
+// The output of Compile is the same CodeGeneratorRequest that
+// `capnp compile` hands to code generator plugins, so it can be fed to
+// capnpc-go or loaded by the schemas package.  Node IDs, struct layouts,
+// and default values match the reference compiler.
+package compiler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"zombiezen.com/go/capnproto2/std/capnp/schema"
+)
+
+// Options controls how schema files are located.
+type Options struct {
+	// ImportPath is the list of directories searched, in order, for
+	// absolute imports like import "/capnp/c++.capnp".
+	ImportPath []string
+
+	// ReadFile reads the named schema file.  If nil, ioutil.ReadFile
+	// is used.
+	ReadFile func(name string) ([]byte, error)
+}
+
+// Compile parses the named schema files along with any files they
+// import and returns a CodeGeneratorRequest containing the requested
+// files' nodes and every node they depend on.  opts may be nil.
+//
+// If the files contain errors, Compile returns an ErrorList.
+func Compile(opts *Options, files ...string) (schema.CodeGeneratorRequest, error) {
+	c := newCompiler(opts)
+	var requested []*file
+	for _, name := range files {
+		f := c.loadFile(filepath.Clean(name), Pos{})
+		if f == nil {
+			continue
+		}
+		if !f.requested {
+			f.requested = true
+			requested = append(requested, f)
+		}
+	}
+	for _, f := range requested {
+		c.loadImports(f)
+	}
+	for _, f := range requested {
+		c.finishTree(f.node)
+	}
+	if len(c.errs) > 0 {
+		c.errs.sort()
+		return schema.CodeGeneratorRequest{}, c.errs
+	}
+	req, err := c.emit(requested)
+	if err != nil {
+		return schema.CodeGeneratorRequest{}, err
+	}
+	if len(c.errs) > 0 {
+		c.errs.sort()
+		return schema.CodeGeneratorRequest{}, c.errs
+	}
+	return req, nil
+}
+
+// An Error describes a problem in a schema file.
+type Error struct {
+	Pos Pos
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// ErrorList is a list of errors sorted by position.
+type ErrorList []*Error
+
+func (list *ErrorList) add(pos Pos, msg string) {
+	*list = append(*list, &Error{Pos: pos, Msg: msg})
+}
+
+func (list ErrorList) sort() {
+	sort.Stable(errorsByPos(list))
+}
+
+type errorsByPos []*Error
+
+func (list errorsByPos) Len() int      { return len(list) }
+func (list errorsByPos) Swap(i, j int) { list[i], list[j] = list[j], list[i] }
+
+func (list errorsByPos) Less(i, j int) bool {
+	a, b := list[i].Pos, list[j].Pos
+	if a.Filename != b.Filename {
+		return a.Filename < b.Filename
+	}
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Col < b.Col
+}
+
+func (list ErrorList) Error() string {
+	switch len(list) {
+	case 0:
+		return "no errors"
+	case 1:
+		return list[0].Error()
+	case 2:
+		return list[0].Error() + " (and 1 more error)"
+	default:
+		return fmt.Sprintf("%v (and %d more errors)", list[0], len(list)-1)
+	}
+}
+
+// compiler holds the state of a single Compile call.
+type compiler struct {
+	opts  Options
+	errs  ErrorList
+	files map[string]*file
+	nodes map[uint64]*node
+}
+
+// A file is a loaded schema file.
+type file struct {
+	path      string // name passed to ReadFile
+	name      string // display name
+	decl      *decl
+	node      *node
+	imports   []fileImport
+	requested bool
+}
+
+type fileImport struct {
+	name string
+	file *file
+}
+
+func newCompiler(opts *Options) *compiler {
+	c := &compiler{
+		files: make(map[string]*file),
+		nodes: make(map[uint64]*node),
+	}
+	if opts != nil {
+		c.opts = *opts
+	}
+	if c.opts.ReadFile == nil {
+		c.opts.ReadFile = ioutil.ReadFile
+	}
+	return c
+}
+
+func (c *compiler) errorf(pos Pos, format string, args ...interface{}) {
+	c.errs.add(pos, fmt.Sprintf(format, args...))
+}
+
+// loadFile reads and parses a file, returning nil if it can't be read.
+// Files are only loaded once.
+func (c *compiler) loadFile(name string, pos Pos) *file {
+	if f := c.files[name]; f != nil {
+		return f
+	}
+	src, err := c.opts.ReadFile(name)
+	if err != nil {
+		if pos.Filename == "" {
+			pos.Filename = name
+		}
+		c.errorf(pos, "%v", err)
+		return nil
+	}
+	f := &file{path: name, name: filepath.ToSlash(name)}
+	c.files[name] = f
+	f.decl = parseFile(f.name, src, &c.errs)
+	c.newFileNode(f)
+	return f
+}
+
+// importFile resolves an import expression in from.
+func (c *compiler) importFile(from *file, name string, pos Pos) *file {
+	for _, imp := range from.imports {
+		if imp.name == name {
+			return imp.file
+		}
+	}
+	var f *file
+	if strings.HasPrefix(name, "/") {
+		for _, dir := range c.opts.ImportPath {
+			p := filepath.Join(dir, filepath.FromSlash(name[1:]))
+			if f = c.files[p]; f != nil {
+				break
+			}
+			if _, err := c.opts.ReadFile(p); err == nil {
+				f = c.loadFile(p, pos)
+				break
+			}
+		}
+		if f == nil {
+			c.errorf(pos, "import %q not found in import path", name)
+		}
+	} else {
+		p := filepath.Join(filepath.Dir(from.path), filepath.FromSlash(name))
+		f = c.loadFile(p, pos)
+	}
+	if f == nil {
+		return nil
+	}
+	from.imports = append(from.imports, fileImport{name: name, file: f})
+	return f
+}
+
+// loadImports loads every file imported by f, in the order they are
+// first mentioned.
+func (c *compiler) loadImports(f *file) {
+	var walkExpr func(*expr)
+	walkExpr = func(e *expr) {
+		if e == nil {
+			return
+		}
+		if e.kind == exprImport {
+			c.importFile(f, string(e.sval), e.pos)
+			return
+		}
+		walkExpr(e.base)
+		for _, a := range e.args {
+			walkExpr(a.value)
+		}
+		for _, elem := range e.elems {
+			walkExpr(elem)
+		}
+	}
+	var walkDecl func(*decl)
+	walkDecl = func(d *decl) {
+		for _, a := range d.annotations {
+			walkExpr(a.name)
+			walkExpr(a.value)
+		}
+		walkExpr(d.typ)
+		walkExpr(d.value)
+		for _, s := range d.superclasses {
+			walkExpr(s)
+		}
+		for _, pl := range []*paramList{d.paramList, d.resultList} {
+			if pl == nil {
+				continue
+			}
+			walkExpr(pl.typ)
+			for _, p := range pl.params {
+				walkDecl(p)
+			}
+		}
+		for _, m := range d.members {
+			walkDecl(m)
+		}
+	}
+	walkDecl(f.decl)
+}
+
+// fileDisplayPrefix returns the length of a file node's display name
+// prefix, which ends after the last dot of the base name.
+func fileDisplayPrefix(name string) int {
+	base := path.Base(name)
+	i := strings.LastIndexByte(base, '.')
+	return len(name) - len(base) + i + 1
+}
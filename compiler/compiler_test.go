@@ -0,0 +1,230 @@
+package compiler_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/compiler"
+	"zombiezen.com/go/capnproto2/encoding/text"
+	_ "zombiezen.com/go/capnproto2/internal/aircraftlib"
+	_ "zombiezen.com/go/capnproto2/internal/demo/books"
+	_ "zombiezen.com/go/capnproto2/internal/demo/hashes"
+	"zombiezen.com/go/capnproto2/schemas"
+	_ "zombiezen.com/go/capnproto2/std/capnp/cxx"
+	_ "zombiezen.com/go/capnproto2/std/capnp/json"
+	_ "zombiezen.com/go/capnproto2/std/capnp/persistent"
+	_ "zombiezen.com/go/capnproto2/std/capnp/rpc"
+	_ "zombiezen.com/go/capnproto2/std/capnp/rpctwoparty"
+	"zombiezen.com/go/capnproto2/std/capnp/schema"
+)
+
+// rootedOptions returns options that read files relative to dir, the
+// way the capnp tool does when run inside dir.
+func rootedOptions(dir string, importPath ...string) *compiler.Options {
+	return &compiler.Options{
+		ImportPath: importPath,
+		ReadFile: func(name string) ([]byte, error) {
+			return ioutil.ReadFile(filepath.Join(dir, name))
+		},
+	}
+}
+
+// TestCompileMatchesRegistered compiles the schemas that have generated
+// code in this repository and compares every node against the node
+// that the reference compiler produced, as embedded in the generated
+// code.
+func TestCompileMatchesRegistered(t *testing.T) {
+	tests := []struct {
+		dir        string
+		file       string
+		importPath []string
+	}{
+		{"..", "std/go.capnp", nil},
+		{"../std/capnp", "schema.capnp", []string{".."}},
+		{"../std/capnp", "json.capnp", []string{".."}},
+		{"../std/capnp", "persistent.capnp", []string{".."}},
+		{"../std/capnp", "rpc.capnp", []string{".."}},
+		{"../std/capnp", "rpc-twoparty.capnp", []string{".."}},
+		{"../std/capnp", "c++.capnp", []string{".."}},
+		{"../internal/aircraftlib", "aircraft.capnp", []string{"../../std"}},
+		{"../internal/demo/books", "books.capnp", []string{"../../../std"}},
+		{"../internal/demo/hashes", "hash.capnp", []string{"../../../std"}},
+	}
+	for _, test := range tests {
+		req, err := compiler.Compile(rootedOptions(test.dir, test.importPath...), test.file)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", test.file, err)
+			continue
+		}
+		nodes, err := req.Nodes()
+		if err != nil {
+			t.Errorf("%s: Nodes: %v", test.file, err)
+			continue
+		}
+		n := 0
+		for i := 0; i < nodes.Len(); i++ {
+			got := nodes.At(i)
+			name, _ := got.DisplayName()
+			if !strings.HasPrefix(name, test.file+":") && name != test.file {
+				continue
+			}
+			if got.Which() == schema.Node_Which_file {
+				// File nodes aren't registered.
+				continue
+			}
+			n++
+			want, err := findRegistered(got.Id())
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+				continue
+			}
+			if diff := compareStructs("", got.Struct, want.Struct); diff != "" {
+				gotText, _ := text.Marshal(schema.Node_TypeID, got.Struct)
+				wantText, _ := text.Marshal(schema.Node_TypeID, want.Struct)
+				t.Errorf("%s: node differs at %s\n got: %s\nwant: %s", name, diff, gotText, wantText)
+			}
+		}
+		if n == 0 {
+			t.Errorf("%s: no nodes compiled", test.file)
+		}
+	}
+}
+
+func findRegistered(id uint64) (schema.Node, error) {
+	data := schemas.Find(id)
+	if data == nil {
+		return schema.Node{}, fmt.Errorf("node @%#x not registered", id)
+	}
+	msg, err := capnp.Unmarshal(data)
+	if err != nil {
+		return schema.Node{}, err
+	}
+	req, err := schema.ReadRootCodeGeneratorRequest(msg)
+	if err != nil {
+		return schema.Node{}, err
+	}
+	nodes, err := req.Nodes()
+	if err != nil {
+		return schema.Node{}, err
+	}
+	for i := 0; i < nodes.Len(); i++ {
+		if nodes.At(i).Id() == id {
+			return nodes.At(i), nil
+		}
+	}
+	return schema.Node{}, fmt.Errorf("node @%#x not in registered schema", id)
+}
+
+// compareStructs compares two structs bit-for-bit, treating missing
+// data as zero and empty lists as null.  It returns the path of the
+// first difference, or the empty string if they are equal.
+func compareStructs(path string, a, b capnp.Struct) string {
+	asz, bsz := a.Size(), b.Size()
+	dsz := asz.DataSize
+	if bsz.DataSize > dsz {
+		dsz = bsz.DataSize
+	}
+	for i := capnp.DataOffset(0); i < capnp.DataOffset(dsz); i++ {
+		if a.Uint8(i) != b.Uint8(i) {
+			return fmt.Sprintf("%s data byte %d", path, i)
+		}
+	}
+	psz := asz.PointerCount
+	if bsz.PointerCount > psz {
+		psz = bsz.PointerCount
+	}
+	for i := uint16(0); i < psz; i++ {
+		ap, _ := a.Ptr(i)
+		bp, _ := b.Ptr(i)
+		if diff := comparePtrs(fmt.Sprintf("%s ptr %d", path, i), ap, bp); diff != "" {
+			return diff
+		}
+	}
+	return ""
+}
+
+func comparePtrs(path string, a, b capnp.Ptr) string {
+	if isEmpty(a) && isEmpty(b) {
+		return ""
+	}
+	if as, bs := a.Struct(), b.Struct(); as.IsValid() || bs.IsValid() {
+		if !as.IsValid() || !bs.IsValid() {
+			return path + " (struct vs. non-struct)"
+		}
+		return compareStructs(path, as, bs)
+	}
+	al, bl := a.List(), b.List()
+	if !al.IsValid() || !bl.IsValid() {
+		return path + " (list vs. non-list)"
+	}
+	if al.Len() != bl.Len() {
+		return fmt.Sprintf("%s (length %d vs. %d)", path, al.Len(), bl.Len())
+	}
+	for i := 0; i < al.Len(); i++ {
+		ae, be := al.Struct(i), bl.Struct(i)
+		if !ae.IsValid() || !be.IsValid() {
+			if (capnp.BitList{List: al}).At(i) != (capnp.BitList{List: bl}).At(i) {
+				return fmt.Sprintf("%s[%d]", path, i)
+			}
+			continue
+		}
+		if diff := compareStructs(fmt.Sprintf("%s[%d]", path, i), ae, be); diff != "" {
+			return diff
+		}
+	}
+	return ""
+}
+
+func isEmpty(p capnp.Ptr) bool {
+	if !p.IsValid() {
+		return true
+	}
+	l := p.List()
+	return l.IsValid() && l.Len() == 0
+}
+
+func memOptions(files map[string]string) *compiler.Options {
+	return &compiler.Options{
+		ReadFile: func(name string) ([]byte, error) {
+			src, ok := files[name]
+			if !ok {
+				return nil, fmt.Errorf("open %s: no such file", name)
+			}
+			return []byte(src), nil
+		},
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"struct Foo {}", "file has no ID"},
+		{"@0x1234; struct Foo {}", "high bit set"},
+		{"@0xa1b2c3d4e5f60718; struct Foo { a @0 :Text; b @0 :Text; }", "bad.capnp:1:50: duplicate ordinal @0"},
+		{"@0xa1b2c3d4e5f60718; struct Foo { a @0 :Text; b @2 :Text; }", "skipped ordinal @1"},
+		{"@0xa1b2c3d4e5f60718; struct Foo { a @0 :Bar; }", `"Bar" is not defined`},
+		{"@0xa1b2c3d4e5f60718; struct Foo { a @0 :Int8 = 300; }", "out of range"},
+		{"@0xa1b2c3d4e5f60718; struct Foo { union { a @0 :Void; } }", "union must have at least two members"},
+		{"@0xa1b2c3d4e5f60718; struct Foo { a @0 :List(Text, Text); }", "List requires exactly one parameter"},
+		{`@0xa1b2c3d4e5f60718; using import "missing.capnp".Foo;`, "missing.capnp"},
+	}
+	for _, test := range tests {
+		_, err := compiler.Compile(memOptions(map[string]string{"bad.capnp": test.src}), "bad.capnp")
+		if err == nil {
+			t.Errorf("Compile(%q) succeeded; want error containing %q", test.src, test.want)
+			continue
+		}
+		if _, ok := err.(compiler.ErrorList); !ok {
+			t.Errorf("Compile(%q) error is %T; want compiler.ErrorList", test.src, err)
+		}
+		if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Compile(%q) = %v; want error containing %q", test.src, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package compiler
+
+import (
+	"math"
+
+	"zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/std/capnp/schema"
+)
+
+// emit builds the CodeGeneratorRequest for the requested files.
+func (c *compiler) emit(requested []*file) (schema.CodeGeneratorRequest, error) {
+	var order []*node
+	seen := make(map[*node]bool)
+	var add func(n *node)
+	add = func(n *node) {
+		if seen[n] {
+			return
+		}
+		seen[n] = true
+		order = append(order, n)
+		for _, g := range n.groups {
+			add(g)
+		}
+		for _, p := range n.paramStructs {
+			add(p)
+		}
+	}
+	var addTree func(n *node)
+	addTree = func(n *node) {
+		add(n)
+		for _, child := range n.children {
+			addTree(child)
+		}
+	}
+	for _, f := range requested {
+		addTree(f.node)
+	}
+	// Add dependencies and the scopes that contain them.
+	for i := 0; i < len(order); i++ {
+		for _, dep := range order[i].deps {
+			for p := dep; p != nil && !seen[p]; p = p.parent {
+				c.finish(p)
+				add(p)
+			}
+		}
+	}
+
+	_, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		return schema.CodeGeneratorRequest{}, err
+	}
+	req, err := schema.NewRootCodeGeneratorRequest(seg)
+	if err != nil {
+		return schema.CodeGeneratorRequest{}, err
+	}
+	nodes, err := req.NewNodes(int32(len(order)))
+	if err != nil {
+		return schema.CodeGeneratorRequest{}, err
+	}
+	for i, n := range order {
+		if err := c.writeNode(nodes.At(i), n); err != nil {
+			return schema.CodeGeneratorRequest{}, err
+		}
+	}
+	files, err := req.NewRequestedFiles(int32(len(requested)))
+	if err != nil {
+		return schema.CodeGeneratorRequest{}, err
+	}
+	for i, f := range requested {
+		rf := files.At(i)
+		rf.SetId(f.node.id)
+		if err := rf.SetFilename(f.name); err != nil {
+			return schema.CodeGeneratorRequest{}, err
+		}
+		imports, err := rf.NewImports(int32(len(f.imports)))
+		if err != nil {
+			return schema.CodeGeneratorRequest{}, err
+		}
+		for j, imp := range f.imports {
+			imports.At(j).SetId(imp.file.node.id)
+			if err := imports.At(j).SetName(imp.name); err != nil {
+				return schema.CodeGeneratorRequest{}, err
+			}
+		}
+	}
+	return req, nil
+}
+
+func (c *compiler) writeNode(out schema.Node, n *node) error {
+	out.SetId(n.id)
+	if err := out.SetDisplayName(n.displayName); err != nil {
+		return err
+	}
+	out.SetDisplayNamePrefixLength(uint32(n.prefixLen))
+	out.SetScopeId(n.scopeID)
+	out.SetIsGeneric(n.isGeneric)
+	if params := n.params(); len(params) > 0 {
+		pl, err := out.NewParameters(int32(len(params)))
+		if err != nil {
+			return err
+		}
+		for i, p := range params {
+			if err := pl.At(i).SetName(p); err != nil {
+				return err
+			}
+		}
+	}
+	if len(n.children) > 0 {
+		nested, err := out.NewNestedNodes(int32(len(n.children)))
+		if err != nil {
+			return err
+		}
+		for i, child := range n.children {
+			nested.At(i).SetId(child.id)
+			if err := nested.At(i).SetName(child.name); err != nil {
+				return err
+			}
+		}
+	}
+	if len(n.annotations) > 0 {
+		list, err := out.NewAnnotations(int32(len(n.annotations)))
+		if err != nil {
+			return err
+		}
+		if err := c.writeAnnotations(list, n.annotations); err != nil {
+			return err
+		}
+	}
+
+	switch n.kind {
+	case declFile:
+		out.SetFile()
+	case declStruct, declGroup:
+		out.SetStructNode()
+		return c.writeStruct(out.StructNode(), n.st)
+	case declEnum:
+		out.SetEnum()
+		list, err := out.Enum().NewEnumerants(int32(len(n.enumerants)))
+		if err != nil {
+			return err
+		}
+		for i, e := range n.enumerants {
+			en := list.At(i)
+			if err := en.SetName(e.name); err != nil {
+				return err
+			}
+			en.SetCodeOrder(e.codeOrder)
+			if len(e.annotations) > 0 {
+				al, err := en.NewAnnotations(int32(len(e.annotations)))
+				if err != nil {
+					return err
+				}
+				if err := c.writeAnnotations(al, e.annotations); err != nil {
+					return err
+				}
+			}
+		}
+	case declInterface:
+		out.SetInterface()
+		return c.writeInterface(out.Interface(), n)
+	case declConst:
+		out.SetConst()
+		if n.typ == nil {
+			return nil
+		}
+		t, err := out.Const().NewType()
+		if err != nil {
+			return err
+		}
+		if err := c.writeType(t, n.typ); err != nil {
+			return err
+		}
+		v, err := out.Const().NewValue()
+		if err != nil {
+			return err
+		}
+		cv := n.constValue
+		if cv == nil {
+			cv = c.defaultValue(n.typ)
+		}
+		return writeValue(v, cv)
+	case declAnnotation:
+		out.SetAnnotation()
+		an := out.Annotation()
+		if n.typ != nil {
+			t, err := an.NewType()
+			if err != nil {
+				return err
+			}
+			if err := c.writeType(t, n.typ); err != nil {
+				return err
+			}
+		}
+		d := n.decl
+		has := func(target string) bool {
+			return d.targetsAll || containsString(d.targets, target)
+		}
+		an.SetTargetsFile(has("file"))
+		an.SetTargetsConst(has("const"))
+		an.SetTargetsEnum(has("enum"))
+		an.SetTargetsEnumerant(has("enumerant"))
+		an.SetTargetsStruct(has("struct"))
+		an.SetTargetsField(has("field"))
+		an.SetTargetsUnion(has("union"))
+		an.SetTargetsGroup(has("group"))
+		an.SetTargetsInterface(has("interface"))
+		an.SetTargetsMethod(has("method"))
+		an.SetTargetsParam(has("param"))
+		an.SetTargetsAnnotation(has("annotation"))
+	}
+	return nil
+}
+
+func (c *compiler) writeStruct(out schema.Node_structNode, st *structInfo) error {
+	out.SetDataWordCount(st.dataWords)
+	out.SetPointerCount(st.pointers)
+	out.SetPreferredListEncoding(schema.ElementSize_inlineComposite)
+	out.SetIsGroup(st.isGroup)
+	out.SetDiscriminantCount(st.discriminantCount)
+	out.SetDiscriminantOffset(st.discriminantOffset)
+	fields, err := out.NewFields(int32(len(st.fields)))
+	if err != nil {
+		return err
+	}
+	for i, f := range st.fields {
+		ff := fields.At(i)
+		if err := ff.SetName(f.name); err != nil {
+			return err
+		}
+		ff.SetCodeOrder(f.codeOrder)
+		ff.SetDiscriminantValue(f.discriminant)
+		if f.ordinal >= 0 {
+			ff.Ordinal().SetExplicit(uint16(f.ordinal))
+		} else {
+			ff.Ordinal().SetImplicit()
+		}
+		if len(f.annotations) > 0 {
+			al, err := ff.NewAnnotations(int32(len(f.annotations)))
+			if err != nil {
+				return err
+			}
+			if err := c.writeAnnotations(al, f.annotations); err != nil {
+				return err
+			}
+		}
+		if f.group != nil {
+			ff.SetGroup()
+			ff.Group().SetTypeId(f.group.id)
+			continue
+		}
+		ff.SetSlot()
+		slot := ff.Slot()
+		slot.SetOffset(f.offset)
+		if f.typ == nil {
+			continue
+		}
+		t, err := slot.NewType()
+		if err != nil {
+			return err
+		}
+		if err := c.writeType(t, f.typ); err != nil {
+			return err
+		}
+		def := f.def
+		if def == nil {
+			def = c.defaultValue(f.typ)
+		}
+		v, err := slot.NewDefaultValue()
+		if err != nil {
+			return err
+		}
+		if err := writeValue(v, def); err != nil {
+			return err
+		}
+		slot.SetHadExplicitDefault(f.decl.value != nil)
+	}
+	return nil
+}
+
+func (c *compiler) writeInterface(out schema.Node_interface, n *node) error {
+	methods, err := out.NewMethods(int32(len(n.methods)))
+	if err != nil {
+		return err
+	}
+	for i, m := range n.methods {
+		mb := methods.At(i)
+		if err := mb.SetName(m.name); err != nil {
+			return err
+		}
+		mb.SetCodeOrder(m.codeOrder)
+		if len(m.implicit) > 0 {
+			pl, err := mb.NewImplicitParameters(int32(len(m.implicit)))
+			if err != nil {
+				return err
+			}
+			for j, name := range m.implicit {
+				if err := pl.At(j).SetName(name); err != nil {
+					return err
+				}
+			}
+		}
+		mb.SetParamStructType(m.paramID)
+		if err := c.writeBrand(mb.NewParamBrand, m.paramBrand); err != nil {
+			return err
+		}
+		mb.SetResultStructType(m.resultID)
+		if err := c.writeBrand(mb.NewResultBrand, m.resultBrand); err != nil {
+			return err
+		}
+		if len(m.annotations) > 0 {
+			al, err := mb.NewAnnotations(int32(len(m.annotations)))
+			if err != nil {
+				return err
+			}
+			if err := c.writeAnnotations(al, m.annotations); err != nil {
+				return err
+			}
+		}
+	}
+	supers, err := out.NewSuperclasses(int32(len(n.superclasses)))
+	if err != nil {
+		return err
+	}
+	for i, s := range n.superclasses {
+		supers.At(i).SetId(s.node.id)
+		if err := c.writeBrand(supers.At(i).NewBrand, s.brand); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *compiler) writeAnnotations(list schema.Annotation_List, anns []*annotationInfo) error {
+	for i, a := range anns {
+		out := list.At(i)
+		out.SetId(a.node.id)
+		// Unlike other brands, an annotation's brand is always present.
+		b, err := out.NewBrand()
+		if err != nil {
+			return err
+		}
+		newBrand := func() (schema.Brand, error) { return b, nil }
+		if err := c.writeBrand(newBrand, a.brand); err != nil {
+			return err
+		}
+		v, err := out.NewValue()
+		if err != nil {
+			return err
+		}
+		if err := writeValue(v, a.val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeValue fills in a schema.Value, copying any pointer into out's
+// message.
+func writeValue(out schema.Value, v *value) error {
+	switch v.typ.which {
+	case schema.Type_Which_void:
+		out.SetVoid()
+	case schema.Type_Which_bool:
+		out.SetBool(v.bits != 0)
+	case schema.Type_Which_int8:
+		out.SetInt8(int8(v.bits))
+	case schema.Type_Which_int16:
+		out.SetInt16(int16(v.bits))
+	case schema.Type_Which_int32:
+		out.SetInt32(int32(v.bits))
+	case schema.Type_Which_int64:
+		out.SetInt64(int64(v.bits))
+	case schema.Type_Which_uint8:
+		out.SetUint8(uint8(v.bits))
+	case schema.Type_Which_uint16:
+		out.SetUint16(uint16(v.bits))
+	case schema.Type_Which_uint32:
+		out.SetUint32(uint32(v.bits))
+	case schema.Type_Which_uint64:
+		out.SetUint64(v.bits)
+	case schema.Type_Which_float32:
+		out.SetFloat32(math.Float32frombits(uint32(v.bits)))
+	case schema.Type_Which_float64:
+		out.SetFloat64(math.Float64frombits(v.bits))
+	case schema.Type_Which_enum:
+		out.SetEnum(uint16(v.bits))
+	case schema.Type_Which_text:
+		if err := out.SetText(""); err != nil {
+			return err
+		}
+		return out.Struct.SetPtr(0, v.ptr)
+	case schema.Type_Which_data:
+		if err := out.SetData(nil); err != nil {
+			return err
+		}
+		return out.Struct.SetPtr(0, v.ptr)
+	case schema.Type_Which_list:
+		return out.SetListPtr(v.ptr)
+	case schema.Type_Which_structType:
+		return out.SetStructValuePtr(v.ptr)
+	case schema.Type_Which_interface:
+		out.SetInterface()
+	case schema.Type_Which_anyPointer:
+		return out.SetAnyPointerPtr(v.ptr)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package compiler
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+)
+
+// newID returns a random file ID, as suggested by `capnp id`.
+func newID() uint64 {
+	var buf [8]byte
+	rand.Read(buf[:])
+	return binary.LittleEndian.Uint64(buf[:]) | 1<<63
+}
+
+// IDs generated for declarations without an explicit ID follow the
+// reference compiler: the first 8 bytes of an MD5 hash, read as a
+// big-endian integer, with the high bit set.
+
+func hashID(data []byte) uint64 {
+	sum := md5.Sum(data)
+	return binary.BigEndian.Uint64(sum[:8]) | 1<<63
+}
+
+// childID returns the ID of a named declaration nested in parent.
+func childID(parent uint64, name string) uint64 {
+	buf := make([]byte, 8, 8+len(name))
+	binary.LittleEndian.PutUint64(buf, parent)
+	return hashID(append(buf, name...))
+}
+
+// groupID returns the ID of the group or named union at the given
+// index in its parent's field list.
+func groupID(parent uint64, index uint16) uint64 {
+	var buf [10]byte
+	binary.LittleEndian.PutUint64(buf[:8], parent)
+	binary.LittleEndian.PutUint16(buf[8:], index)
+	return hashID(buf[:])
+}
+
+// paramsID returns the ID of the implicit parameter or result struct
+// for the method with the given ordinal.
+func paramsID(parent uint64, ordinal uint16, isResults bool) uint64 {
+	var buf [11]byte
+	binary.LittleEndian.PutUint64(buf[:8], parent)
+	binary.LittleEndian.PutUint16(buf[8:10], ordinal)
+	if isResults {
+		buf[10] = 1
+	}
+	return hashID(buf[:])
+}
@@ -0,0 +1,318 @@
+package compiler
+
+// Struct layout follows the reference compiler exactly, since field
+// offsets are part of the wire format.  Sizes are given as lgSize: the
+// log2 of the size in bits, so 0 is a bit and 6 is a word.  Offsets are
+// in multiples of the field's size.
+
+const lgWordBits = 6
+
+// holeSet tracks unused space inside a word.  holes[i] is the offset of
+// a free slot of size 2^i bits, or zero if there is none.
+type holeSet [lgWordBits]uint32
+
+func (h *holeSet) tryAllocate(lgSize uint) (uint32, bool) {
+	if lgSize >= lgWordBits {
+		return 0, false
+	}
+	if h[lgSize] != 0 {
+		off := h[lgSize]
+		h[lgSize] = 0
+		return off, true
+	}
+	next, ok := h.tryAllocate(lgSize + 1)
+	if !ok {
+		return 0, false
+	}
+	off := next * 2
+	h[lgSize] = off + 1
+	return off, true
+}
+
+// addHolesAtEnd records the space left over after allocating an
+// lgSize-sized slot at the start of a limitLgSize-sized region.
+func (h *holeSet) addHolesAtEnd(lgSize uint, offset uint32, limitLgSize uint) {
+	for lgSize < limitLgSize {
+		h[lgSize] = offset
+		lgSize++
+		offset = (offset + 1) / 2
+	}
+}
+
+// tryExpand grows the slot at oldOffset by combining it with adjacent
+// holes.
+func (h *holeSet) tryExpand(oldLgSize uint, oldOffset uint32, expansionFactor uint) bool {
+	if expansionFactor == 0 {
+		return true
+	}
+	if oldLgSize == lgWordBits {
+		return false
+	}
+	if h[oldLgSize] != oldOffset+1 {
+		return false
+	}
+	if !h.tryExpand(oldLgSize+1, oldOffset>>1, expansionFactor-1) {
+		return false
+	}
+	h[oldLgSize] = 0
+	return true
+}
+
+// smallestAtLeast returns the size of the smallest hole that can fit a
+// value of the given size.
+func (h *holeSet) smallestAtLeast(lgSize uint) (uint, bool) {
+	for i := lgSize; i < lgWordBits; i++ {
+		if h[i] != 0 {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// A layoutScope is a struct or a group that fields are allocated in.
+type layoutScope interface {
+	addData(lgSize uint) uint32
+	addPointer() uint32
+	tryExpandData(oldLgSize uint, oldOffset uint32, expansionFactor uint) bool
+	addVoid()
+}
+
+// topLayout is the layout of a whole struct.
+type topLayout struct {
+	dataWords uint32
+	pointers  uint32
+	holes     holeSet
+}
+
+func (t *topLayout) addData(lgSize uint) uint32 {
+	if off, ok := t.holes.tryAllocate(lgSize); ok {
+		return off
+	}
+	off := t.dataWords << (lgWordBits - lgSize)
+	t.dataWords++
+	t.holes.addHolesAtEnd(lgSize, off+1, lgWordBits)
+	return off
+}
+
+func (t *topLayout) addPointer() uint32 {
+	t.pointers++
+	return t.pointers - 1
+}
+
+func (t *topLayout) tryExpandData(oldLgSize uint, oldOffset uint32, expansionFactor uint) bool {
+	return t.holes.tryExpand(oldLgSize, oldOffset, expansionFactor)
+}
+
+func (t *topLayout) addVoid() {}
+
+// unionLayout is the space shared by the members of a union.
+type unionLayout struct {
+	parent           layoutScope
+	groupCount       int
+	discriminant     uint32
+	hasDiscriminant  bool
+	dataLocations    []dataLocation
+	pointerLocations []uint32
+}
+
+type dataLocation struct {
+	lgSize uint
+	offset uint32
+}
+
+func (loc *dataLocation) tryExpandTo(u *unionLayout, newLgSize uint) bool {
+	if newLgSize <= loc.lgSize {
+		return true
+	}
+	if !u.parent.tryExpandData(loc.lgSize, loc.offset, newLgSize-loc.lgSize) {
+		return false
+	}
+	loc.offset >>= newLgSize - loc.lgSize
+	loc.lgSize = newLgSize
+	return true
+}
+
+func (u *unionLayout) addNewDataLocation(lgSize uint) uint32 {
+	off := u.parent.addData(lgSize)
+	u.dataLocations = append(u.dataLocations, dataLocation{lgSize: lgSize, offset: off})
+	return off
+}
+
+func (u *unionLayout) addNewPointerLocation() uint32 {
+	off := u.parent.addPointer()
+	u.pointerLocations = append(u.pointerLocations, off)
+	return off
+}
+
+func (u *unionLayout) newGroupAddingFirstMember() {
+	u.groupCount++
+	if u.groupCount == 2 {
+		u.addDiscriminant()
+	}
+}
+
+func (u *unionLayout) addDiscriminant() bool {
+	if u.hasDiscriminant {
+		return false
+	}
+	u.discriminant = u.parent.addData(4)
+	u.hasDiscriminant = true
+	return true
+}
+
+// groupLayout is one member of a union.  Fields of different members
+// may overlap.
+type groupLayout struct {
+	parent        *unionLayout
+	hasMembers    bool
+	dataUsage     []dataLocationUsage
+	pointersUsage int
+}
+
+// dataLocationUsage records how much of a union data location a group
+// is using.
+type dataLocationUsage struct {
+	isUsed     bool
+	lgSizeUsed uint
+	holes      holeSet
+}
+
+func (u *dataLocationUsage) smallestHoleAtLeast(loc *dataLocation, lgSize uint) (uint, bool) {
+	switch {
+	case !u.isUsed:
+		// The location is effectively one big hole.
+		if lgSize <= loc.lgSize {
+			return loc.lgSize, true
+		}
+		return 0, false
+	case lgSize >= u.lgSizeUsed:
+		// Won't fit in a hole, but we could expand our usage.
+		if lgSize < loc.lgSize {
+			return lgSize, true
+		}
+		return 0, false
+	}
+	if size, ok := u.holes.smallestAtLeast(lgSize); ok {
+		return size, true
+	}
+	if u.lgSizeUsed < loc.lgSize {
+		return u.lgSizeUsed, true
+	}
+	return 0, false
+}
+
+func (u *dataLocationUsage) allocateFromHole(g *groupLayout, loc *dataLocation, lgSize uint) uint32 {
+	var result uint32
+	switch {
+	case !u.isUsed:
+		u.isUsed = true
+		u.lgSizeUsed = lgSize
+		result = 0
+	case lgSize >= u.lgSizeUsed:
+		// Double our usage and take the second half.
+		u.holes.addHolesAtEnd(u.lgSizeUsed, 1, lgSize)
+		u.lgSizeUsed = lgSize + 1
+		result = 1
+	default:
+		if off, ok := u.holes.tryAllocate(lgSize); ok {
+			result = off
+			break
+		}
+		// Double our usage and allocate from the new space.
+		result = 1 << (u.lgSizeUsed - lgSize)
+		u.holes.addHolesAtEnd(lgSize, result+1, u.lgSizeUsed)
+		u.lgSizeUsed++
+	}
+	return loc.offset<<(loc.lgSize-lgSize) + result
+}
+
+func (u *dataLocationUsage) tryAllocateByExpanding(g *groupLayout, loc *dataLocation, lgSize uint) (uint32, bool) {
+	if u.isUsed {
+		// Double the location past the requested size and take the
+		// upper half, as allocateFromHole does.
+		if lgSize < u.lgSizeUsed || !loc.tryExpandTo(g.parent, lgSize+1) {
+			return 0, false
+		}
+		u.holes.addHolesAtEnd(u.lgSizeUsed, 1, lgSize)
+		u.lgSizeUsed = lgSize + 1
+		return loc.offset<<(loc.lgSize-lgSize) + 1, true
+	}
+	if !loc.tryExpandTo(g.parent, lgSize) {
+		return 0, false
+	}
+	u.isUsed = true
+	u.lgSizeUsed = lgSize
+	return loc.offset << (loc.lgSize - lgSize), true
+}
+
+func (u *dataLocationUsage) tryExpand(g *groupLayout, loc *dataLocation, oldLgSize uint, oldOffset uint32, expansionFactor uint) bool {
+	if oldOffset == 0 && u.lgSizeUsed == oldLgSize {
+		// This location holds exactly the value, so expand it whole.
+		if !loc.tryExpandTo(g.parent, oldLgSize+expansionFactor) {
+			return false
+		}
+		u.lgSizeUsed = oldLgSize + expansionFactor
+		return true
+	}
+	return u.holes.tryExpand(oldLgSize, oldOffset, expansionFactor)
+}
+
+func (g *groupLayout) addMember() {
+	if !g.hasMembers {
+		g.hasMembers = true
+		g.parent.newGroupAddingFirstMember()
+	}
+}
+
+func (g *groupLayout) addData(lgSize uint) uint32 {
+	g.addMember()
+	best, bestSize := -1, uint(lgWordBits+1)
+	for i := range g.parent.dataLocations {
+		if len(g.dataUsage) == i {
+			g.dataUsage = append(g.dataUsage, dataLocationUsage{})
+		}
+		if size, ok := g.dataUsage[i].smallestHoleAtLeast(&g.parent.dataLocations[i], lgSize); ok && size < bestSize {
+			best, bestSize = i, size
+		}
+	}
+	if best >= 0 {
+		return g.dataUsage[best].allocateFromHole(g, &g.parent.dataLocations[best], lgSize)
+	}
+	// No holes big enough: try expanding an existing location.
+	for i := range g.parent.dataLocations {
+		if off, ok := g.dataUsage[i].tryAllocateByExpanding(g, &g.parent.dataLocations[i], lgSize); ok {
+			return off
+		}
+	}
+	off := g.parent.addNewDataLocation(lgSize)
+	g.dataUsage = append(g.dataUsage, dataLocationUsage{isUsed: true, lgSizeUsed: lgSize})
+	return off
+}
+
+func (g *groupLayout) addPointer() uint32 {
+	g.addMember()
+	if g.pointersUsage < len(g.parent.pointerLocations) {
+		g.pointersUsage++
+		return g.parent.pointerLocations[g.pointersUsage-1]
+	}
+	g.pointersUsage++
+	return g.parent.addNewPointerLocation()
+}
+
+func (g *groupLayout) tryExpandData(oldLgSize uint, oldOffset uint32, expansionFactor uint) bool {
+	for i := range g.dataUsage {
+		loc := &g.parent.dataLocations[i]
+		if loc.lgSize >= oldLgSize && oldOffset>>(loc.lgSize-oldLgSize) == loc.offset {
+			localOffset := oldOffset - loc.offset<<(loc.lgSize-oldLgSize)
+			return g.dataUsage[i].tryExpand(g, loc, oldLgSize, localOffset, expansionFactor)
+		}
+	}
+	return false
+}
+
+func (g *groupLayout) addVoid() {
+	g.addMember()
+	// A union nested in a union must still learn that a member was
+	// added so it allocates its discriminant at the right time.
+	g.parent.parent.addVoid()
+}
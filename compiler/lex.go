@@ -0,0 +1,351 @@
+package compiler
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Pos is a position in a schema source file.
+type Pos struct {
+	Filename string
+	Line     int // 1-based
+	Col      int // 1-based, in bytes
+}
+
+// String returns the position in the form "file:line:col".
+func (p Pos) String() string {
+	if p.Line == 0 {
+		return p.Filename
+	}
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Col)
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokInt
+	tokFloat
+	tokString
+	tokBinary
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	pos  Pos
+	text string // identifier name or punctuation
+
+	ival uint64  // tokInt
+	fval float64 // tokFloat
+	sval []byte  // tokString, tokBinary
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of file"
+	case tokIdent:
+		return "'" + t.text + "'"
+	case tokInt:
+		return "integer " + strconv.FormatUint(t.ival, 10)
+	case tokFloat:
+		return "float " + strconv.FormatFloat(t.fval, 'g', -1, 64)
+	case tokString:
+		return "string literal"
+	case tokBinary:
+		return "binary literal"
+	default:
+		return "'" + t.text + "'"
+	}
+}
+
+// lexer splits a schema source file into tokens.
+type lexer struct {
+	src  []byte
+	name string
+	off  int
+	line int
+	col  int
+	errs *ErrorList
+}
+
+func newLexer(name string, src []byte, errs *ErrorList) *lexer {
+	return &lexer{src: src, name: name, line: 1, col: 1, errs: errs}
+}
+
+func (l *lexer) pos() Pos {
+	return Pos{Filename: l.name, Line: l.line, Col: l.col}
+}
+
+func (l *lexer) errorf(pos Pos, format string, args ...interface{}) {
+	l.errs.add(pos, fmt.Sprintf(format, args...))
+}
+
+func (l *lexer) peekByte(i int) byte {
+	if l.off+i >= len(l.src) {
+		return 0
+	}
+	return l.src[l.off+i]
+}
+
+func (l *lexer) advance() byte {
+	c := l.src[l.off]
+	l.off++
+	if c == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	return c
+}
+
+// skipSpace skips whitespace and comments.
+func (l *lexer) skipSpace() {
+	for l.off < len(l.src) {
+		switch c := l.src[l.off]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+			l.advance()
+		case c == '#':
+			for l.off < len(l.src) && l.src[l.off] != '\n' {
+				l.advance()
+			}
+		default:
+			return
+		}
+	}
+}
+
+func isIdentStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// next returns the next token in the source.
+func (l *lexer) next() token {
+	l.skipSpace()
+	pos := l.pos()
+	if l.off >= len(l.src) {
+		return token{kind: tokEOF, pos: pos}
+	}
+	c := l.src[l.off]
+	switch {
+	case isIdentStart(c):
+		start := l.off
+		for l.off < len(l.src) && (isIdentStart(l.src[l.off]) || isDigit(l.src[l.off])) {
+			l.advance()
+		}
+		return token{kind: tokIdent, pos: pos, text: string(l.src[start:l.off])}
+	case isDigit(c):
+		if c == '0' && (l.peekByte(1) == 'x' || l.peekByte(1) == 'X') && l.peekByte(2) == '"' {
+			l.advance()
+			l.advance()
+			return l.binary(pos)
+		}
+		return l.number(pos)
+	case c == '"' || c == '\'':
+		return l.str(pos)
+	case c == '-' && l.peekByte(1) == '>':
+		l.advance()
+		l.advance()
+		return token{kind: tokPunct, pos: pos, text: "->"}
+	case strings.IndexByte("@:;=,.()[]{}$-*", c) >= 0:
+		l.advance()
+		return token{kind: tokPunct, pos: pos, text: string(c)}
+	default:
+		l.advance()
+		l.errorf(pos, "unexpected character %q", c)
+		return l.next()
+	}
+}
+
+func (l *lexer) number(pos Pos) token {
+	start := l.off
+	if l.src[l.off] == '0' && (l.peekByte(1) == 'x' || l.peekByte(1) == 'X') {
+		l.advance()
+		l.advance()
+		for l.off < len(l.src) && isHexDigit(l.src[l.off]) {
+			l.advance()
+		}
+		return l.integer(pos, string(l.src[start+2:l.off]), 16)
+	}
+	for l.off < len(l.src) && isDigit(l.src[l.off]) {
+		l.advance()
+	}
+	isFloat := false
+	if l.peekByte(0) == '.' && isDigit(l.peekByte(1)) {
+		isFloat = true
+		l.advance()
+		for l.off < len(l.src) && isDigit(l.src[l.off]) {
+			l.advance()
+		}
+	}
+	if e := l.peekByte(0); e == 'e' || e == 'E' {
+		i := 1
+		if s := l.peekByte(1); s == '+' || s == '-' {
+			i = 2
+		}
+		if isDigit(l.peekByte(i)) {
+			isFloat = true
+			for ; i > 0; i-- {
+				l.advance()
+			}
+			for l.off < len(l.src) && isDigit(l.src[l.off]) {
+				l.advance()
+			}
+		}
+	}
+	text := string(l.src[start:l.off])
+	if isFloat {
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil && !isRangeErr(err) {
+			l.errorf(pos, "invalid float literal %s", text)
+		}
+		return token{kind: tokFloat, pos: pos, fval: f}
+	}
+	if len(text) > 1 && text[0] == '0' {
+		return l.integer(pos, text[1:], 8)
+	}
+	return l.integer(pos, text, 10)
+}
+
+func isRangeErr(err error) bool {
+	ne, ok := err.(*strconv.NumError)
+	return ok && ne.Err == strconv.ErrRange
+}
+
+func (l *lexer) integer(pos Pos, digits string, base int) token {
+	if digits == "" {
+		l.errorf(pos, "invalid integer literal")
+		return token{kind: tokInt, pos: pos}
+	}
+	v, err := strconv.ParseUint(digits, base, 64)
+	if err != nil {
+		if isRangeErr(err) {
+			l.errorf(pos, "integer literal is too big")
+		} else {
+			l.errorf(pos, "invalid integer literal")
+		}
+		v = math.MaxUint64
+	}
+	return token{kind: tokInt, pos: pos, ival: v}
+}
+
+func (l *lexer) str(pos Pos) token {
+	quote := l.advance()
+	var buf []byte
+	for {
+		if l.off >= len(l.src) || l.src[l.off] == '\n' {
+			l.errorf(pos, "unterminated string literal")
+			return token{kind: tokString, pos: pos, sval: buf}
+		}
+		c := l.advance()
+		if c == quote {
+			break
+		}
+		if c != '\\' {
+			buf = append(buf, c)
+			continue
+		}
+		if l.off >= len(l.src) {
+			continue
+		}
+		epos := l.pos()
+		switch e := l.advance(); e {
+		case 'a':
+			buf = append(buf, '\a')
+		case 'b':
+			buf = append(buf, '\b')
+		case 'f':
+			buf = append(buf, '\f')
+		case 'n':
+			buf = append(buf, '\n')
+		case 'r':
+			buf = append(buf, '\r')
+		case 't':
+			buf = append(buf, '\t')
+		case 'v':
+			buf = append(buf, '\v')
+		case '\\', '\'', '"', '?':
+			buf = append(buf, e)
+		case 'x':
+			var v byte
+			n := 0
+			for ; n < 2 && isHexDigit(l.peekByte(0)); n++ {
+				v = v<<4 | unhex(l.advance())
+			}
+			if n == 0 {
+				l.errorf(epos, "invalid escape sequence")
+			}
+			buf = append(buf, v)
+		default:
+			if e < '0' || e > '7' {
+				l.errorf(epos, "invalid escape sequence")
+				continue
+			}
+			v := int(e - '0')
+			for n := 1; n < 3 && l.peekByte(0) >= '0' && l.peekByte(0) <= '7'; n++ {
+				v = v<<3 | int(l.advance()-'0')
+			}
+			buf = append(buf, byte(v))
+		}
+	}
+	return token{kind: tokString, pos: pos, sval: buf}
+}
+
+func (l *lexer) binary(pos Pos) token {
+	l.advance() // opening quote
+	var buf []byte
+	var digits []byte
+	for {
+		if l.off >= len(l.src) {
+			l.errorf(pos, "unterminated binary literal")
+			break
+		}
+		c := l.advance()
+		if c == '"' {
+			break
+		}
+		switch {
+		case isHexDigit(c):
+			digits = append(digits, c)
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		case c == '#':
+			for l.off < len(l.src) && l.src[l.off] != '\n' {
+				l.advance()
+			}
+		default:
+			l.errorf(pos, "invalid character %q in binary literal", c)
+		}
+	}
+	if len(digits)%2 != 0 {
+		l.errorf(pos, "binary literal has an odd number of hex digits")
+		digits = digits[:len(digits)-1]
+	}
+	for i := 0; i < len(digits); i += 2 {
+		buf = append(buf, unhex(digits[i])<<4|unhex(digits[i+1]))
+	}
+	return token{kind: tokBinary, pos: pos, sval: buf}
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -0,0 +1,510 @@
+package compiler
+
+import (
+	"fmt"
+)
+
+// A node is a declaration that becomes a schema.Node: a file, struct,
+// group, enum, interface, const, or annotation.
+type node struct {
+	kind   declKind // declFile, declStruct, declGroup, declEnum, ...
+	decl   *decl
+	file   *file
+	parent *node // lexical parent, nil for files
+	id     uint64
+
+	name        string
+	displayName string
+	prefixLen   int
+	scopeID     uint64
+	isGeneric   bool
+
+	members  map[string]*entry // named nested nodes and aliases
+	children []*node           // nested nodes, in declaration order
+
+	laidOut, laying     bool
+	finished, finishing bool
+	deps                []*node // nodes this node's schema refers to
+	annotations         []*annotationInfo
+
+	// Struct and group nodes.
+	st     *structInfo
+	groups []*node // group nodes generated for the struct
+
+	// Enum nodes.
+	enumerants []*enumerantInfo
+
+	// Interface nodes.
+	superclasses []superclassInfo
+	methods      []*methodInfo
+	paramStructs []*node
+	implicit     *implicitParams // for method parameter structs
+
+	// Const and annotation nodes.
+	typ          *typeRef
+	constValue   *value
+	constPending bool
+}
+
+// An entry is a named member of a scope: a node or a using alias.
+type entry struct {
+	node  *node
+	alias *alias
+}
+
+type alias struct {
+	decl      *decl
+	scope     *node
+	resolving bool
+}
+
+func (n *node) params() []string {
+	if n.decl == nil || n.kind == declGroup {
+		return nil
+	}
+	return n.decl.params
+}
+
+// newFileNode builds the node tree for a parsed file.
+func (c *compiler) newFileNode(f *file) {
+	d := f.decl
+	n := &node{
+		kind:        declFile,
+		decl:        d,
+		file:        f,
+		displayName: f.name,
+		prefixLen:   fileDisplayPrefix(f.name),
+	}
+	if d.hasID {
+		n.id = d.id
+		c.checkID(d.idPos, n.id)
+	} else {
+		c.errorf(Pos{Filename: f.name}, "file has no ID; add this line to the file: @0x%x;", newID())
+		n.id = childID(0, f.name)
+	}
+	f.node = n
+	c.registerNode(n, d.idPos)
+	c.addMembers(n, d.members)
+}
+
+func (c *compiler) checkID(pos Pos, id uint64) {
+	if id&(1<<63) == 0 {
+		c.errorf(pos, "invalid ID @0x%x: IDs must have the high bit set", id)
+	}
+}
+
+func (c *compiler) registerNode(n *node, pos Pos) {
+	if other := c.nodes[n.id]; other != nil {
+		c.errorf(pos, "ID @0x%x is already used by %s", n.id, other.displayName)
+		return
+	}
+	c.nodes[n.id] = n
+}
+
+// addMembers creates nodes for the nested declarations in decls.
+func (c *compiler) addMembers(parent *node, decls []*decl) {
+	parent.members = make(map[string]*entry)
+	for _, d := range decls {
+		if d.name != "" {
+			if _, dup := parent.members[d.name]; dup {
+				c.errorf(d.pos, "%q is already defined in this scope", d.name)
+				continue
+			}
+		}
+		switch d.kind {
+		case declUsing:
+			parent.members[d.name] = &entry{alias: &alias{decl: d, scope: parent}}
+		case declStruct, declEnum, declInterface, declConst, declAnnotation:
+			n := c.newChildNode(parent, d)
+			parent.members[d.name] = &entry{node: n}
+			parent.children = append(parent.children, n)
+		}
+	}
+}
+
+func (c *compiler) newChildNode(parent *node, d *decl) *node {
+	n := &node{
+		kind:      d.kind,
+		decl:      d,
+		file:      parent.file,
+		parent:    parent,
+		name:      d.name,
+		scopeID:   parent.id,
+		isGeneric: parent.isGeneric || len(d.params) > 0,
+	}
+	if parent.kind == declFile {
+		n.displayName = parent.displayName + ":" + d.name
+	} else {
+		n.displayName = parent.displayName + "." + d.name
+	}
+	n.prefixLen = len(n.displayName) - len(d.name)
+	if d.hasID {
+		n.id = d.id
+		c.checkID(d.idPos, n.id)
+	} else {
+		n.id = childID(parent.id, d.name)
+	}
+	c.registerNode(n, d.pos)
+	if d.kind == declStruct || d.kind == declInterface {
+		c.addMembers(n, d.members)
+	} else {
+		n.members = make(map[string]*entry)
+	}
+	return n
+}
+
+// A brandScope is one level of a brand: the bindings of one generic
+// scope.  A chain of brandScopes runs from the innermost scope out.
+type brandScope struct {
+	parent     *brandScope
+	id         uint64
+	paramCount int
+	inherited  bool        // parameters refer to the scope's own parameters
+	args       []*resolved // bound parameters, nil if unbound
+}
+
+// localBrand returns the brand in effect inside n: every enclosing
+// scope inherits its own parameters.
+func localBrand(n *node) *brandScope {
+	if n == nil {
+		return nil
+	}
+	if n.kind == declGroup {
+		return localBrand(n.parent)
+	}
+	return &brandScope{
+		parent:     localBrand(n.parent),
+		id:         n.id,
+		paramCount: len(n.params()),
+		inherited:  true,
+	}
+}
+
+// pop returns the part of the chain starting at the scope with the
+// given ID, or a fresh unbound scope if it is not in the chain.
+func (b *brandScope) pop(id uint64) *brandScope {
+	for s := b; s != nil; s = s.parent {
+		if s.id == id {
+			return s
+		}
+	}
+	return &brandScope{id: id}
+}
+
+func (b *brandScope) push(n *node) *brandScope {
+	return &brandScope{parent: b, id: n.id, paramCount: len(n.params())}
+}
+
+// lookupParam returns the binding for a generic parameter, or nil if
+// the parameter is not bound in this brand.
+func (b *brandScope) lookupParam(id uint64, index int) *resolved {
+	for s := b; s != nil; s = s.parent {
+		if s.id != id {
+			continue
+		}
+		switch {
+		case index < len(s.args):
+			return s.args[index]
+		case s.inherited:
+			return nil
+		default:
+			return &resolved{kind: resBuiltin, builtin: builtinAnyPointer}
+		}
+	}
+	return nil
+}
+
+// A scope is the context that names are resolved in.
+type scope struct {
+	node     *node
+	brand    *brandScope
+	implicit *implicitParams
+}
+
+// implicitParams are a method's implicit generic parameters.  Inside
+// the method's parameter struct, they are ordinary parameters of the
+// struct (id != 0).
+type implicitParams struct {
+	id    uint64
+	names []string
+}
+
+func nodeScope(n *node) scope {
+	for n.kind == declGroup {
+		n = n.parent
+	}
+	sc := scope{node: n, brand: localBrand(n)}
+	if n.implicit != nil {
+		// Parameter structs resolve names in their interface.
+		sc.node = n.parent
+		sc.brand = localBrand(n.parent)
+		sc.implicit = n.implicit
+	}
+	return sc
+}
+
+type resolvedKind int
+
+const (
+	resNode resolvedKind = iota
+	resBuiltin
+	resParam
+	resImplicit
+	resEnumerant
+)
+
+// resolved is the result of resolving a name expression.
+type resolved struct {
+	kind    resolvedKind
+	pos     Pos
+	node    *node       // resNode, resEnumerant
+	brand   *brandScope // resNode
+	builtin builtinType // resBuiltin
+	elem    *resolved   // List(elem)
+	scopeID uint64      // resParam
+	index   int         // resParam, resImplicit, resEnumerant
+	name    string      // for error messages
+}
+
+func (r *resolved) String() string {
+	return r.name
+}
+
+type builtinType int
+
+const (
+	builtinVoid builtinType = iota
+	builtinBool
+	builtinInt8
+	builtinInt16
+	builtinInt32
+	builtinInt64
+	builtinUint8
+	builtinUint16
+	builtinUint32
+	builtinUint64
+	builtinFloat32
+	builtinFloat64
+	builtinText
+	builtinData
+	builtinList
+	builtinAnyPointer
+	builtinAnyStruct
+	builtinAnyList
+	builtinCapability
+)
+
+var builtins = map[string]builtinType{
+	"Void":       builtinVoid,
+	"Bool":       builtinBool,
+	"Int8":       builtinInt8,
+	"Int16":      builtinInt16,
+	"Int32":      builtinInt32,
+	"Int64":      builtinInt64,
+	"UInt8":      builtinUint8,
+	"UInt16":     builtinUint16,
+	"UInt32":     builtinUint32,
+	"UInt64":     builtinUint64,
+	"Float32":    builtinFloat32,
+	"Float64":    builtinFloat64,
+	"Text":       builtinText,
+	"Data":       builtinData,
+	"List":       builtinList,
+	"AnyPointer": builtinAnyPointer,
+	"AnyStruct":  builtinAnyStruct,
+	"AnyList":    builtinAnyList,
+	"Capability": builtinCapability,
+}
+
+// resolve resolves a name expression.  It reports an error and returns
+// nil on failure.
+func (c *compiler) resolve(e *expr, sc scope) *resolved {
+	switch e.kind {
+	case exprName:
+		return c.lookup(e, sc)
+	case exprAbsName:
+		f := sc.node
+		for f.parent != nil {
+			f = f.parent
+		}
+		ent := f.members[e.name]
+		if ent == nil {
+			c.errorf(e.pos, "%q is not defined", e.String())
+			return nil
+		}
+		return c.resolveEntry(ent, sc.brand.pop(f.id), e)
+	case exprImport:
+		imp := c.importFile(sc.node.file, string(e.sval), e.pos)
+		if imp == nil {
+			return nil
+		}
+		return &resolved{kind: resNode, pos: e.pos, node: imp.node, brand: &brandScope{id: imp.node.id}, name: e.String()}
+	case exprMember:
+		base := c.resolve(e.base, sc)
+		if base == nil {
+			return nil
+		}
+		if base.kind != resNode {
+			c.errorf(e.pos, "%q has no members", e.base.String())
+			return nil
+		}
+		if base.node.kind == declEnum {
+			c.layout(base.node)
+			for i, en := range base.node.enumerants {
+				if en.name == e.name {
+					return &resolved{kind: resEnumerant, pos: e.pos, node: base.node, index: i, name: e.String()}
+				}
+			}
+		}
+		ent := base.node.members[e.name]
+		if ent == nil {
+			c.errorf(e.pos, "%q is not defined", e.String())
+			return nil
+		}
+		return c.resolveEntry(ent, base.brand, e)
+	case exprApply:
+		base := c.resolve(e.base, sc)
+		if base == nil {
+			return nil
+		}
+		return c.applyBrand(base, e, sc)
+	default:
+		c.errorf(e.pos, "expected a name, found %v", e)
+		return nil
+	}
+}
+
+// lookup resolves an unqualified name by searching enclosing scopes.
+func (c *compiler) lookup(e *expr, sc scope) *resolved {
+	if sc.implicit != nil {
+		for i, name := range sc.implicit.names {
+			if name != e.name {
+				continue
+			}
+			if sc.implicit.id != 0 {
+				return &resolved{kind: resParam, pos: e.pos, scopeID: sc.implicit.id, index: i, name: name}
+			}
+			return &resolved{kind: resImplicit, pos: e.pos, index: i, name: name}
+		}
+	}
+	for n := sc.node; n != nil; n = n.parent {
+		if ent := n.members[e.name]; ent != nil {
+			return c.resolveEntry(ent, sc.brand.pop(n.id), e)
+		}
+		for i, p := range n.params() {
+			if p != e.name {
+				continue
+			}
+			if r := sc.brand.lookupParam(n.id, i); r != nil {
+				return r
+			}
+			return &resolved{kind: resParam, pos: e.pos, scopeID: n.id, index: i, name: p}
+		}
+	}
+	if b, ok := builtins[e.name]; ok {
+		return &resolved{kind: resBuiltin, pos: e.pos, builtin: b, name: e.name}
+	}
+	c.errorf(e.pos, "%q is not defined", e.name)
+	return nil
+}
+
+// resolveEntry resolves a scope member found with the given brand for
+// the member's parent scope.
+func (c *compiler) resolveEntry(ent *entry, parentBrand *brandScope, e *expr) *resolved {
+	if ent.node != nil {
+		return &resolved{kind: resNode, pos: e.pos, node: ent.node, brand: parentBrand.push(ent.node), name: e.String()}
+	}
+	a := ent.alias
+	if a.resolving {
+		c.errorf(a.decl.pos, "using declaration %q refers to itself", a.decl.name)
+		return nil
+	}
+	a.resolving = true
+	defer func() { a.resolving = false }()
+	r := c.resolve(a.decl.typ, scope{node: a.scope, brand: parentBrand})
+	if r == nil {
+		return nil
+	}
+	rr := *r
+	rr.pos = e.pos
+	return &rr
+}
+
+// applyBrand binds generic parameters: base(args...).
+func (c *compiler) applyBrand(base *resolved, e *expr, sc scope) *resolved {
+	var args []*resolved
+	for _, a := range e.args {
+		if a.name != "" {
+			c.errorf(a.namePos, "generic parameters cannot be named")
+			return nil
+		}
+		r := c.resolve(a.value, sc)
+		if r == nil {
+			return nil
+		}
+		args = append(args, r)
+	}
+	switch {
+	case base.kind == resBuiltin && base.builtin == builtinList:
+		if len(args) != 1 {
+			c.errorf(e.pos, "List requires exactly one parameter")
+			return nil
+		}
+		if t := c.typeOf(args[0], nil); t == nil {
+			return nil
+		}
+		return &resolved{kind: resBuiltin, pos: e.pos, builtin: builtinList, elem: args[0], name: e.String()}
+	case base.kind != resNode || len(base.node.params()) == 0:
+		c.errorf(e.pos, "%q does not accept generic parameters", e.base.String())
+		return nil
+	case base.brand.args != nil:
+		c.errorf(e.pos, "generic parameters of %q are already bound", e.base.String())
+		return nil
+	case len(args) > len(base.node.params()):
+		c.errorf(e.pos, "too many generic parameters for %q", e.base.String())
+		return nil
+	}
+	for i, a := range args {
+		if !isPointerArg(a) {
+			c.errorf(e.args[i].value.pos, "only pointer types can be used as generic parameters")
+			return nil
+		}
+	}
+	r := *base
+	r.pos = e.pos
+	r.name = e.String()
+	r.brand = &brandScope{
+		parent:     base.brand.parent,
+		id:         base.brand.id,
+		paramCount: base.brand.paramCount,
+		args:       args,
+	}
+	return &r
+}
+
+func isPointerArg(r *resolved) bool {
+	switch r.kind {
+	case resParam, resImplicit:
+		return true
+	case resNode:
+		return r.node.kind == declStruct || r.node.kind == declInterface
+	case resBuiltin:
+		switch r.builtin {
+		case builtinText, builtinData, builtinList, builtinAnyPointer, builtinAnyStruct, builtinAnyList, builtinCapability:
+			return true
+		}
+	}
+	return false
+}
+
+// use records that n's schema refers to dep.
+func (n *node) use(dep *node) {
+	if dep == nil || dep == n {
+		return
+	}
+	n.deps = append(n.deps, dep)
+}
+
+func (n *node) String() string {
+	return fmt.Sprintf("%s %s", n.kind, n.displayName)
+}
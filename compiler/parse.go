@@ -0,0 +1,902 @@
+package compiler
+
+import (
+	"fmt"
+	"math"
+)
+
+type declKind int
+
+const (
+	declFile declKind = iota
+	declStruct
+	declEnum
+	declInterface
+	declConst
+	declAnnotation
+	declUsing
+	declField
+	declUnion
+	declGroup
+	declEnumerant
+	declMethod
+)
+
+var declKindNames = [...]string{
+	declFile:       "file",
+	declStruct:     "struct",
+	declEnum:       "enum",
+	declInterface:  "interface",
+	declConst:      "const",
+	declAnnotation: "annotation",
+	declUsing:      "using",
+	declField:      "field",
+	declUnion:      "union",
+	declGroup:      "group",
+	declEnumerant:  "enumerant",
+	declMethod:     "method",
+}
+
+func (k declKind) String() string {
+	return declKindNames[k]
+}
+
+// A decl is a declaration in the syntax tree.  Which fields are
+// meaningful depends on kind.
+type decl struct {
+	kind declKind
+	name string // empty for unnamed unions
+	pos  Pos
+
+	id    uint64 // explicit @0x... ID
+	hasID bool
+	idPos Pos
+
+	ordinal    uint64 // explicit @N ordinal
+	hasOrdinal bool
+	ordinalPos Pos
+
+	params      []string // generic parameters
+	typ         *expr    // type of a field, const, annotation or param; target of using
+	value       *expr    // default value of a field or param, value of a const
+	annotations []*annotationApp
+	members     []*decl // nested declarations and members, in declaration order
+
+	// annotation declarations
+	targets    []string
+	targetsAll bool
+	targetsPos Pos
+
+	// interfaces and methods
+	superclasses   []*expr
+	implicitParams []string
+	paramList      *paramList
+	resultList     *paramList // nil if the method omits results
+}
+
+// A paramList is a method's parameter or result list.  It is either
+// an explicit struct type or a list of named parameters.
+type paramList struct {
+	pos    Pos
+	typ    *expr   // non-nil if the list is a struct type
+	params []*decl // fields, otherwise
+}
+
+// An annotationApp is an application of an annotation to a
+// declaration.  value is nil if the application omits a value.
+type annotationApp struct {
+	pos   Pos
+	name  *expr
+	value *expr
+}
+
+type exprKind int
+
+const (
+	exprName    exprKind = iota // name
+	exprAbsName                 // .name
+	exprMember                  // base.name
+	exprApply                   // base(args)
+	exprImport                  // import "file"
+	exprEmbed                   // embed "file"
+	exprInt                     // ival, negated if neg
+	exprFloat                   // fval
+	exprString                  // sval
+	exprBinary                  // sval
+	exprList                    // [elems]
+	exprTuple                   // (args)
+)
+
+// An expr is an expression in the syntax tree.
+type expr struct {
+	kind exprKind
+	pos  Pos
+
+	name  string  // exprName, exprAbsName, exprMember
+	base  *expr   // exprMember, exprApply
+	args  []*arg  // exprApply, exprTuple
+	elems []*expr // exprList
+	ival  uint64  // exprInt
+	neg   bool    // exprInt
+	fval  float64 // exprFloat
+	sval  []byte  // exprString, exprBinary, exprImport, exprEmbed
+}
+
+// An arg is an optionally named element of a tuple or an application.
+type arg struct {
+	name    string
+	namePos Pos
+	value   *expr
+}
+
+// String formats the expression for use in error messages.
+func (e *expr) String() string {
+	switch e.kind {
+	case exprName:
+		return e.name
+	case exprAbsName:
+		return "." + e.name
+	case exprMember:
+		return e.base.String() + "." + e.name
+	case exprApply:
+		s := e.base.String() + "("
+		for i, a := range e.args {
+			if i > 0 {
+				s += ", "
+			}
+			s += a.value.String()
+		}
+		return s + ")"
+	case exprImport:
+		return fmt.Sprintf("import %q", e.sval)
+	case exprEmbed:
+		return fmt.Sprintf("embed %q", e.sval)
+	case exprInt:
+		if e.neg {
+			return fmt.Sprintf("-%d", e.ival)
+		}
+		return fmt.Sprintf("%d", e.ival)
+	case exprFloat:
+		return fmt.Sprint(e.fval)
+	case exprString:
+		return fmt.Sprintf("%q", e.sval)
+	case exprBinary:
+		return fmt.Sprintf("0x%q", fmt.Sprintf("%x", e.sval))
+	case exprList:
+		return "[...]"
+	default:
+		return "(...)"
+	}
+}
+
+// parser builds a syntax tree from a token stream.
+type parser struct {
+	toks []token
+	i    int
+	errs *ErrorList
+}
+
+// parseFile parses a schema file into a declaration of kind declFile.
+func parseFile(name string, src []byte, errs *ErrorList) *decl {
+	lex := newLexer(name, src, errs)
+	p := &parser{errs: errs}
+	for {
+		t := lex.next()
+		p.toks = append(p.toks, t)
+		if t.kind == tokEOF {
+			break
+		}
+	}
+	f := &decl{kind: declFile, pos: Pos{Filename: name}}
+	for p.peek().kind != tokEOF {
+		n := len(p.toks) - p.i
+		p.topStatement(f)
+		if len(p.toks)-p.i == n {
+			// Guarantee progress.
+			p.i++
+		}
+	}
+	return f
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.i]
+}
+
+func (p *parser) peekN(n int) token {
+	if p.i+n >= len(p.toks) {
+		return p.toks[len(p.toks)-1]
+	}
+	return p.toks[p.i+n]
+}
+
+func (p *parser) advance() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) isPunct(s string) bool {
+	t := p.peek()
+	return t.kind == tokPunct && t.text == s
+}
+
+func (p *parser) isKeyword(s string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && t.text == s
+}
+
+func (p *parser) errorf(pos Pos, format string, args ...interface{}) {
+	p.errs.add(pos, fmt.Sprintf(format, args...))
+}
+
+// errorSync reports an unexpected token and skips to the end of the
+// current statement.
+func (p *parser) errorSync(what string) {
+	t := p.peek()
+	p.errorf(t.pos, "expected %s, found %v", what, t)
+	p.sync()
+}
+
+// sync skips tokens until the end of the current statement or block.
+func (p *parser) sync() {
+	depth := 0
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokEOF:
+			return
+		case t.kind == tokPunct && (t.text == "{" || t.text == "(" || t.text == "["):
+			depth++
+		case t.kind == tokPunct && (t.text == "}" || t.text == ")" || t.text == "]"):
+			if depth == 0 {
+				return
+			}
+			depth--
+			if depth == 0 && t.text == "}" {
+				p.advance()
+				return
+			}
+		case t.kind == tokPunct && t.text == ";" && depth == 0:
+			p.advance()
+			return
+		}
+		p.advance()
+	}
+}
+
+func (p *parser) expectPunct(s string) bool {
+	if !p.isPunct(s) {
+		p.errorSync("'" + s + "'")
+		return false
+	}
+	p.advance()
+	return true
+}
+
+func (p *parser) ident(what string) (string, Pos, bool) {
+	t := p.peek()
+	if t.kind != tokIdent {
+		p.errorSync(what)
+		return "", t.pos, false
+	}
+	p.advance()
+	return t.text, t.pos, true
+}
+
+func (p *parser) topStatement(f *decl) {
+	switch {
+	case p.isPunct("@"):
+		at := p.advance()
+		t := p.peek()
+		if t.kind != tokInt {
+			p.errorSync("file ID")
+			return
+		}
+		p.advance()
+		if f.hasID {
+			p.errorf(at.pos, "file ID already declared")
+		}
+		f.id, f.hasID, f.idPos = t.ival, true, t.pos
+		p.expectPunct(";")
+	case p.isPunct("$"):
+		a := p.annotationApp()
+		if a == nil {
+			return
+		}
+		f.annotations = append(f.annotations, a)
+		p.expectPunct(";")
+	default:
+		if d := p.declaration(f); d != nil {
+			f.members = append(f.members, d)
+		}
+	}
+}
+
+// declaration parses a nested declaration: struct, enum, interface,
+// const, annotation, or using.
+func (p *parser) declaration(parent *decl) *decl {
+	t := p.peek()
+	if t.kind != tokIdent {
+		p.errorSync("declaration")
+		return nil
+	}
+	switch t.text {
+	case "struct":
+		return p.structDecl()
+	case "enum":
+		return p.enumDecl()
+	case "interface":
+		return p.interfaceDecl()
+	case "const":
+		return p.constDecl()
+	case "annotation":
+		return p.annotationDecl()
+	case "using":
+		return p.usingDecl()
+	default:
+		p.errorSync("declaration")
+		return nil
+	}
+}
+
+// header parses the name, ID, and generic parameters of a node
+// declaration.  The keyword must already be consumed.
+func (p *parser) header(kind declKind, generic bool) *decl {
+	name, pos, ok := p.ident("name")
+	if !ok {
+		return nil
+	}
+	d := &decl{kind: kind, name: name, pos: pos}
+	for {
+		switch {
+		case p.isPunct("@") && !d.hasID:
+			p.advance()
+			t := p.peek()
+			if t.kind != tokInt {
+				p.errorSync("ID")
+				return nil
+			}
+			p.advance()
+			d.id, d.hasID, d.idPos = t.ival, true, t.pos
+			continue
+		case generic && p.isPunct("(") && d.params == nil:
+			d.params = p.identList("(", ")")
+			if d.params == nil {
+				d.params = []string{}
+			}
+			continue
+		}
+		return d
+	}
+}
+
+// identList parses a delimited, comma-separated list of identifiers.
+func (p *parser) identList(open, close string) []string {
+	if !p.expectPunct(open) {
+		return nil
+	}
+	var list []string
+	for !p.isPunct(close) {
+		if len(list) > 0 && !p.expectPunct(",") {
+			return list
+		}
+		name, _, ok := p.ident("identifier")
+		if !ok {
+			return list
+		}
+		list = append(list, name)
+	}
+	p.advance()
+	return list
+}
+
+func (p *parser) structDecl() *decl {
+	p.advance()
+	d := p.header(declStruct, true)
+	if d == nil {
+		return nil
+	}
+	d.annotations = p.annotations()
+	if !p.expectPunct("{") {
+		return d
+	}
+	d.members = p.structBody()
+	return d
+}
+
+// structBody parses members up to and including the closing brace.
+func (p *parser) structBody() []*decl {
+	var members []*decl
+	for !p.isPunct("}") {
+		if p.peek().kind == tokEOF {
+			p.errorf(p.peek().pos, "expected '}', found end of file")
+			return members
+		}
+		start := p.i
+		if m := p.structMember(); m != nil {
+			members = append(members, m)
+		}
+		if p.i == start {
+			p.advance()
+		}
+	}
+	p.advance()
+	return members
+}
+
+func (p *parser) structMember() *decl {
+	t, next := p.peek(), p.peekN(1)
+	if t.kind != tokIdent {
+		p.errorSync("field or declaration")
+		return nil
+	}
+	if t.text == "union" && next.kind == tokPunct && (next.text == "{" || next.text == "$") {
+		p.advance()
+		d := &decl{kind: declUnion, pos: t.pos}
+		d.annotations = p.annotations()
+		if p.expectPunct("{") {
+			d.members = p.structBody()
+		}
+		return d
+	}
+	if next.kind != tokPunct || (next.text != "@" && next.text != ":") {
+		return p.declaration(nil)
+	}
+
+	p.advance()
+	d := &decl{kind: declField, name: t.text, pos: t.pos}
+	if p.isPunct("@") {
+		p.advance()
+		o := p.peek()
+		if o.kind != tokInt {
+			p.errorSync("ordinal")
+			return nil
+		}
+		p.advance()
+		d.ordinal, d.hasOrdinal, d.ordinalPos = o.ival, true, o.pos
+	}
+	if !p.expectPunct(":") {
+		return nil
+	}
+	switch {
+	case p.isKeyword("union") && p.peekN(1).kind == tokPunct && (p.peekN(1).text == "{" || p.peekN(1).text == "$"):
+		p.advance()
+		d.kind = declUnion
+		d.annotations = p.annotations()
+		if p.expectPunct("{") {
+			d.members = p.structBody()
+		}
+		return d
+	case p.isKeyword("group") && p.peekN(1).kind == tokPunct && (p.peekN(1).text == "{" || p.peekN(1).text == "$"):
+		p.advance()
+		d.kind = declGroup
+		d.annotations = p.annotations()
+		if d.hasOrdinal {
+			p.errorf(d.ordinalPos, "groups cannot have ordinals")
+		}
+		if p.expectPunct("{") {
+			d.members = p.structBody()
+		}
+		return d
+	}
+	d.typ = p.expr()
+	if d.typ == nil {
+		p.sync()
+		return nil
+	}
+	if p.isPunct("=") {
+		p.advance()
+		d.value = p.expr()
+	}
+	d.annotations = p.annotations()
+	p.expectPunct(";")
+	return d
+}
+
+func (p *parser) enumDecl() *decl {
+	p.advance()
+	d := p.header(declEnum, false)
+	if d == nil {
+		return nil
+	}
+	d.annotations = p.annotations()
+	if !p.expectPunct("{") {
+		return d
+	}
+	for !p.isPunct("}") {
+		if p.peek().kind == tokEOF {
+			p.errorf(p.peek().pos, "expected '}', found end of file")
+			return d
+		}
+		name, pos, ok := p.ident("enumerant")
+		if !ok {
+			continue
+		}
+		e := &decl{kind: declEnumerant, name: name, pos: pos}
+		if p.isPunct("@") {
+			p.advance()
+			o := p.peek()
+			if o.kind != tokInt {
+				p.errorSync("ordinal")
+				continue
+			}
+			p.advance()
+			e.ordinal, e.hasOrdinal, e.ordinalPos = o.ival, true, o.pos
+		}
+		e.annotations = p.annotations()
+		p.expectPunct(";")
+		d.members = append(d.members, e)
+	}
+	p.advance()
+	return d
+}
+
+func (p *parser) interfaceDecl() *decl {
+	p.advance()
+	d := p.header(declInterface, true)
+	if d == nil {
+		return nil
+	}
+	if p.isKeyword("extends") {
+		p.advance()
+		if p.expectPunct("(") {
+			for !p.isPunct(")") {
+				if len(d.superclasses) > 0 && !p.expectPunct(",") {
+					break
+				}
+				e := p.expr()
+				if e == nil {
+					break
+				}
+				d.superclasses = append(d.superclasses, e)
+			}
+			if p.isPunct(")") {
+				p.advance()
+			}
+		}
+	}
+	d.annotations = p.annotations()
+	if !p.expectPunct("{") {
+		return d
+	}
+	for !p.isPunct("}") {
+		if p.peek().kind == tokEOF {
+			p.errorf(p.peek().pos, "expected '}', found end of file")
+			return d
+		}
+		start := p.i
+		if m := p.interfaceMember(); m != nil {
+			d.members = append(d.members, m)
+		}
+		if p.i == start {
+			p.advance()
+		}
+	}
+	p.advance()
+	return d
+}
+
+func (p *parser) interfaceMember() *decl {
+	t, next := p.peek(), p.peekN(1)
+	if t.kind != tokIdent {
+		p.errorSync("method or declaration")
+		return nil
+	}
+	if next.kind != tokPunct || next.text != "@" {
+		return p.declaration(nil)
+	}
+	p.advance()
+	p.advance()
+	d := &decl{kind: declMethod, name: t.text, pos: t.pos}
+	o := p.peek()
+	if o.kind != tokInt {
+		p.errorSync("ordinal")
+		return nil
+	}
+	p.advance()
+	d.ordinal, d.hasOrdinal, d.ordinalPos = o.ival, true, o.pos
+	if p.isPunct("[") {
+		d.implicitParams = p.identList("[", "]")
+	}
+	d.paramList = p.paramList()
+	if d.paramList == nil {
+		return nil
+	}
+	if p.isPunct("->") {
+		p.advance()
+		d.resultList = p.paramList()
+		if d.resultList == nil {
+			return nil
+		}
+	}
+	d.annotations = p.annotations()
+	p.expectPunct(";")
+	return d
+}
+
+func (p *parser) paramList() *paramList {
+	pl := &paramList{pos: p.peek().pos}
+	if !p.isPunct("(") {
+		pl.typ = p.expr()
+		if pl.typ == nil {
+			p.sync()
+			return nil
+		}
+		return pl
+	}
+	p.advance()
+	pl.params = []*decl{}
+	for !p.isPunct(")") {
+		if len(pl.params) > 0 && !p.expectPunct(",") {
+			return nil
+		}
+		name, pos, ok := p.ident("parameter name")
+		if !ok {
+			return nil
+		}
+		d := &decl{kind: declField, name: name, pos: pos}
+		if !p.expectPunct(":") {
+			return nil
+		}
+		if d.typ = p.expr(); d.typ == nil {
+			p.sync()
+			return nil
+		}
+		if p.isPunct("=") {
+			p.advance()
+			d.value = p.expr()
+		}
+		d.annotations = p.annotations()
+		pl.params = append(pl.params, d)
+	}
+	p.advance()
+	return pl
+}
+
+func (p *parser) constDecl() *decl {
+	p.advance()
+	d := p.header(declConst, false)
+	if d == nil {
+		return nil
+	}
+	if !p.expectPunct(":") {
+		return nil
+	}
+	if d.typ = p.expr(); d.typ == nil {
+		p.sync()
+		return nil
+	}
+	if !p.expectPunct("=") {
+		return nil
+	}
+	if d.value = p.expr(); d.value == nil {
+		p.sync()
+		return nil
+	}
+	d.annotations = p.annotations()
+	p.expectPunct(";")
+	return d
+}
+
+func (p *parser) annotationDecl() *decl {
+	p.advance()
+	d := p.header(declAnnotation, false)
+	if d == nil {
+		return nil
+	}
+	d.targetsPos = p.peek().pos
+	if p.isPunct("(") && p.peekN(1).kind == tokPunct && p.peekN(1).text == "*" {
+		p.advance()
+		p.advance()
+		d.targetsAll = true
+		if !p.expectPunct(")") {
+			return nil
+		}
+	} else {
+		d.targets = p.identList("(", ")")
+	}
+	if !p.expectPunct(":") {
+		return nil
+	}
+	if d.typ = p.expr(); d.typ == nil {
+		p.sync()
+		return nil
+	}
+	d.annotations = p.annotations()
+	p.expectPunct(";")
+	return d
+}
+
+func (p *parser) usingDecl() *decl {
+	p.advance()
+	d := &decl{kind: declUsing, pos: p.peek().pos}
+	if p.peek().kind == tokIdent && p.peekN(1).kind == tokPunct && p.peekN(1).text == "=" {
+		d.name = p.advance().text
+		p.advance()
+	}
+	if d.typ = p.expr(); d.typ == nil {
+		p.sync()
+		return nil
+	}
+	if d.name == "" {
+		switch d.typ.kind {
+		case exprName, exprAbsName, exprMember:
+			d.name = d.typ.name
+		default:
+			p.errorf(d.typ.pos, "using declaration must be named")
+		}
+	}
+	p.expectPunct(";")
+	return d
+}
+
+func (p *parser) annotations() []*annotationApp {
+	var list []*annotationApp
+	for p.isPunct("$") {
+		a := p.annotationApp()
+		if a == nil {
+			break
+		}
+		list = append(list, a)
+	}
+	return list
+}
+
+func (p *parser) annotationApp() *annotationApp {
+	dollar := p.advance()
+	a := &annotationApp{pos: dollar.pos}
+	a.name = p.primary(false)
+	if a.name == nil {
+		return nil
+	}
+	for p.isPunct(".") {
+		p.advance()
+		name, pos, ok := p.ident("name")
+		if !ok {
+			return nil
+		}
+		a.name = &expr{kind: exprMember, pos: pos, base: a.name, name: name}
+	}
+	if p.isPunct("(") {
+		t := p.tuple()
+		if t == nil {
+			return nil
+		}
+		if len(t.args) == 1 && t.args[0].name == "" {
+			a.value = t.args[0].value
+		} else {
+			a.value = t
+		}
+	}
+	return a
+}
+
+// expr parses an expression.  It returns nil after reporting an error.
+func (p *parser) expr() *expr {
+	e := p.primary(true)
+	if e == nil {
+		return nil
+	}
+	for {
+		switch {
+		case p.isPunct("."):
+			p.advance()
+			name, pos, ok := p.ident("name")
+			if !ok {
+				return nil
+			}
+			e = &expr{kind: exprMember, pos: pos, base: e, name: name}
+		case p.isPunct("(") && (e.kind == exprName || e.kind == exprAbsName || e.kind == exprMember):
+			t := p.tuple()
+			if t == nil {
+				return nil
+			}
+			e = &expr{kind: exprApply, pos: e.pos, base: e, args: t.args}
+		default:
+			return e
+		}
+	}
+}
+
+// primary parses a literal, name, import, list, or tuple.  If values is
+// false, only names and imports are accepted.
+func (p *parser) primary(values bool) *expr {
+	t := p.peek()
+	switch {
+	case t.kind == tokIdent && (t.text == "import" || t.text == "embed") && p.peekN(1).kind == tokString:
+		p.advance()
+		s := p.advance()
+		k := exprImport
+		if t.text == "embed" {
+			k = exprEmbed
+		}
+		return &expr{kind: k, pos: t.pos, sval: s.sval}
+	case t.kind == tokIdent:
+		p.advance()
+		return &expr{kind: exprName, pos: t.pos, name: t.text}
+	case t.kind == tokPunct && t.text == ".":
+		p.advance()
+		name, _, ok := p.ident("name")
+		if !ok {
+			return nil
+		}
+		return &expr{kind: exprAbsName, pos: t.pos, name: name}
+	case !values:
+		p.errorSync("name")
+		return nil
+	case t.kind == tokInt:
+		p.advance()
+		return &expr{kind: exprInt, pos: t.pos, ival: t.ival}
+	case t.kind == tokFloat:
+		p.advance()
+		return &expr{kind: exprFloat, pos: t.pos, fval: t.fval}
+	case t.kind == tokString:
+		p.advance()
+		return &expr{kind: exprString, pos: t.pos, sval: t.sval}
+	case t.kind == tokBinary:
+		p.advance()
+		return &expr{kind: exprBinary, pos: t.pos, sval: t.sval}
+	case t.kind == tokPunct && t.text == "-":
+		p.advance()
+		n := p.peek()
+		switch {
+		case n.kind == tokInt:
+			p.advance()
+			return &expr{kind: exprInt, pos: t.pos, ival: n.ival, neg: true}
+		case n.kind == tokFloat:
+			p.advance()
+			return &expr{kind: exprFloat, pos: t.pos, fval: -n.fval}
+		case n.kind == tokIdent && n.text == "inf":
+			p.advance()
+			return &expr{kind: exprFloat, pos: t.pos, fval: math.Inf(-1)}
+		}
+		p.errorSync("number")
+		return nil
+	case t.kind == tokPunct && t.text == "[":
+		p.advance()
+		e := &expr{kind: exprList, pos: t.pos, elems: []*expr{}}
+		for !p.isPunct("]") {
+			if len(e.elems) > 0 && !p.expectPunct(",") {
+				return nil
+			}
+			elem := p.expr()
+			if elem == nil {
+				return nil
+			}
+			e.elems = append(e.elems, elem)
+		}
+		p.advance()
+		return e
+	case t.kind == tokPunct && t.text == "(":
+		return p.tuple()
+	default:
+		p.errorSync("expression")
+		return nil
+	}
+}
+
+// tuple parses a parenthesized list of optionally named expressions.
+func (p *parser) tuple() *expr {
+	open := p.advance()
+	e := &expr{kind: exprTuple, pos: open.pos, args: []*arg{}}
+	for !p.isPunct(")") {
+		if len(e.args) > 0 && !p.expectPunct(",") {
+			return nil
+		}
+		a := new(arg)
+		if t := p.peek(); t.kind == tokIdent && p.peekN(1).kind == tokPunct && p.peekN(1).text == "=" {
+			p.advance()
+			p.advance()
+			a.name, a.namePos = t.text, t.pos
+		}
+		if a.value = p.expr(); a.value == nil {
+			return nil
+		}
+		e.args = append(e.args, a)
+	}
+	p.advance()
+	return e
+}
@@ -0,0 +1,656 @@
+package compiler
+
+import (
+	"sort"
+
+	"zombiezen.com/go/capnproto2/std/capnp/schema"
+)
+
+// structInfo is the compiled form of a struct or group node.
+type structInfo struct {
+	dataWords          uint16
+	pointers           uint16
+	isGroup            bool
+	discriminantCount  uint16
+	discriminantOffset uint32
+	fields             []*fieldInfo // in ordinal order of first member
+}
+
+// field looks up a field by name.
+func (st *structInfo) field(name string) *fieldInfo {
+	for _, f := range st.fields {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+type fieldInfo struct {
+	name         string
+	decl         *decl
+	codeOrder    uint16
+	discriminant uint16
+	ordinal      int // -1 if implicit
+	target       string
+	annotations  []*annotationInfo
+
+	group *node // non-nil for groups and named unions
+
+	// Slots.
+	offset     uint32
+	typ        *typeRef
+	def        *value
+	defPending bool
+}
+
+type enumerantInfo struct {
+	name        string
+	decl        *decl
+	codeOrder   uint16
+	annotations []*annotationInfo
+}
+
+type methodInfo struct {
+	name        string
+	decl        *decl
+	codeOrder   uint16
+	implicit    []string
+	paramID     uint64
+	paramBrand  *brandScope
+	resultID    uint64
+	resultBrand *brandScope
+	annotations []*annotationInfo
+}
+
+type superclassInfo struct {
+	node  *node
+	brand *brandScope
+}
+
+// layout compiles the parts of n that other nodes' values depend on:
+// struct layouts, enumerants, and the types of consts and annotations.
+func (c *compiler) layout(n *node) {
+	if n.laidOut || n.laying {
+		return
+	}
+	n.laying = true
+	switch n.kind {
+	case declStruct:
+		t := newStructTranslator(c, n)
+		t.traverseTopOrGroup(n.decl.members, t.root, t.top)
+		t.translate()
+	case declEnum:
+		c.layoutEnum(n)
+	case declConst, declAnnotation:
+		n.typ = c.compileType(n.decl.typ, nodeScope(n), n)
+	}
+	n.laying = false
+	n.laidOut = true
+}
+
+// finish compiles the rest of n: annotations, default values, and
+// methods.
+func (c *compiler) finish(n *node) {
+	if n.finished || n.finishing {
+		return
+	}
+	n.finishing = true
+	c.layout(n)
+	sc := nodeScope(n)
+	switch n.kind {
+	case declFile:
+		n.annotations = c.compileAnnotations(n.decl.annotations, "file", sc, n)
+	case declStruct:
+		n.annotations = c.compileAnnotations(n.decl.annotations, "struct", sc, n)
+		c.finishFields(n, sc)
+	case declEnum:
+		n.annotations = c.compileAnnotations(n.decl.annotations, "enum", sc, n)
+		for _, e := range n.enumerants {
+			e.annotations = c.compileAnnotations(e.decl.annotations, "enumerant", sc, n)
+		}
+	case declInterface:
+		n.annotations = c.compileAnnotations(n.decl.annotations, "interface", sc, n)
+		c.compileInterface(n, sc)
+	case declConst:
+		n.annotations = c.compileAnnotations(n.decl.annotations, "const", sc, n)
+		c.constVal(n)
+	case declAnnotation:
+		n.annotations = c.compileAnnotations(n.decl.annotations, "annotation", sc, n)
+		c.checkTargets(n.decl)
+	}
+	n.finishing = false
+	n.finished = true
+}
+
+// finishTree finishes n and all nodes nested in it.
+func (c *compiler) finishTree(n *node) {
+	c.finish(n)
+	for _, child := range n.children {
+		c.finishTree(child)
+	}
+}
+
+// finishFields compiles default values and annotations of a struct's
+// fields, including the fields of its groups.
+func (c *compiler) finishFields(n *node, sc scope) {
+	sts := []*structInfo{n.st}
+	for _, g := range n.groups {
+		sts = append(sts, g.st)
+	}
+	for _, st := range sts {
+		for _, f := range st.fields {
+			if f.group == nil {
+				c.fieldDefault(f, sc, n)
+			}
+			f.annotations = c.compileAnnotations(f.decl.annotations, f.target, sc, n)
+		}
+	}
+}
+
+var annotationTargets = map[string]bool{
+	"file": true, "const": true, "enum": true, "enumerant": true,
+	"struct": true, "field": true, "union": true, "group": true,
+	"interface": true, "method": true, "param": true, "annotation": true,
+}
+
+func (c *compiler) checkTargets(d *decl) {
+	for _, t := range d.targets {
+		if !annotationTargets[t] {
+			c.errorf(d.targetsPos, "%q is not a valid annotation target", t)
+		}
+	}
+}
+
+func (c *compiler) layoutEnum(n *node) {
+	var sorted []*enumerantInfo
+	for i, m := range n.decl.members {
+		if !m.hasOrdinal {
+			c.errorf(m.pos, "enumerant %q is missing an ordinal", m.name)
+			continue
+		}
+		sorted = append(sorted, &enumerantInfo{name: m.name, decl: m, codeOrder: uint16(i)})
+	}
+	sort.Stable(enumerantsByOrdinal(sorted))
+	var dups ordinalChecker
+	names := make(map[string]bool)
+	for _, e := range sorted {
+		dups.check(c, e.decl)
+		if names[e.name] {
+			c.errorf(e.decl.pos, "%q is already defined in this enum", e.name)
+		}
+		names[e.name] = true
+	}
+	n.enumerants = sorted
+}
+
+// ordinalChecker requires ordinals to be sequential with no holes.
+type ordinalChecker struct {
+	expected uint64
+}
+
+func (oc *ordinalChecker) check(c *compiler, d *decl) {
+	switch {
+	case d.ordinal < oc.expected:
+		c.errorf(d.ordinalPos, "duplicate ordinal @%d", d.ordinal)
+	case d.ordinal > oc.expected:
+		c.errorf(d.ordinalPos, "skipped ordinal @%d; ordinals must be sequential with no holes", oc.expected)
+		oc.expected = d.ordinal + 1
+	default:
+		oc.expected++
+	}
+}
+
+// memberInfo tracks a struct member while it is being laid out.
+type memberInfo struct {
+	parent                 *memberInfo
+	decl                   *decl
+	codeOrder              int
+	index                  int
+	childCount             int
+	childInitialized       int
+	unionDiscriminantCount int
+	isInUnion              bool
+	isParam                bool
+
+	fieldScope layoutScope  // fields
+	unionScope *unionLayout // unions, and scopes containing an unnamed union
+	node       *node        // groups, unions, and the top-level struct
+	names      map[string]bool
+	field      *fieldInfo
+}
+
+// getField returns the member's field, adding it to the parent's field
+// list the first time it is called.  Fields are added in ordinal order
+// of their first slot, which determines discriminant values.
+func (m *memberInfo) getField() *fieldInfo {
+	if m.field != nil {
+		return m.field
+	}
+	m.index = m.parent.childInitialized
+	f := m.parent.addMemberField()
+	if m.isInUnion {
+		f.discriminant = uint16(m.parent.unionDiscriminantCount)
+		m.parent.unionDiscriminantCount++
+	}
+	f.name = m.decl.name
+	f.decl = m.decl
+	f.codeOrder = uint16(m.codeOrder)
+	m.field = f
+	return f
+}
+
+func (m *memberInfo) addMemberField() *fieldInfo {
+	if m.childInitialized == 0 && m.parent != nil {
+		// Make sure the group exists in its parent once it has a member.
+		m.getField()
+	}
+	f := &fieldInfo{discriminant: schema.Field_noDiscriminant, ordinal: -1}
+	m.node.st.fields = append(m.node.st.fields, f)
+	m.childInitialized++
+	return f
+}
+
+type ordinalEntry struct {
+	ordinal uint64
+	member  *memberInfo
+	decl    *decl
+}
+
+// structTranslator lays out a struct and builds its groups.
+type structTranslator struct {
+	c         *compiler
+	n         *node
+	sc        scope
+	top       *topLayout
+	root      *memberInfo
+	byOrdinal ordinalEntries
+	all       []*memberInfo
+}
+
+func newStructTranslator(c *compiler, n *node) *structTranslator {
+	n.st = &structInfo{}
+	t := &structTranslator{
+		c:    c,
+		n:    n,
+		sc:   nodeScope(n),
+		top:  new(topLayout),
+		root: &memberInfo{node: n, names: make(map[string]bool)},
+	}
+	for name := range n.members {
+		t.root.names[name] = true
+	}
+	return t
+}
+
+func (t *structTranslator) newGroupNode(parent *node, name string) *node {
+	g := &node{
+		kind:        declGroup,
+		file:        parent.file,
+		parent:      parent,
+		name:        name,
+		displayName: parent.displayName + "." + name,
+		isGeneric:   parent.isGeneric,
+		st:          &structInfo{isGroup: true},
+		members:     make(map[string]*entry),
+		laidOut:     true,
+		finished:    true,
+	}
+	g.prefixLen = len(g.displayName) - len(name)
+	t.n.groups = append(t.n.groups, g)
+	return g
+}
+
+func (t *structTranslator) checkName(parent *memberInfo, d *decl) {
+	if d.name == "" {
+		return
+	}
+	if parent.names[d.name] {
+		t.c.errorf(d.pos, "%q is already defined in this scope", d.name)
+		return
+	}
+	parent.names[d.name] = true
+}
+
+func (t *structTranslator) checkOrdinal(d *decl) bool {
+	if !d.hasOrdinal {
+		t.c.errorf(d.pos, "field %q is missing an ordinal", d.name)
+		return false
+	}
+	if d.ordinal >= 65535 {
+		t.c.errorf(d.ordinalPos, "ordinal @%d is too large", d.ordinal)
+		return false
+	}
+	return true
+}
+
+func (t *structTranslator) addOrdinal(d *decl, m *memberInfo) {
+	t.byOrdinal = append(t.byOrdinal, ordinalEntry{ordinal: d.ordinal, member: m, decl: d})
+}
+
+func (t *structTranslator) traverseTopOrGroup(members []*decl, parent *memberInfo, layout layoutScope) {
+	codeOrder := 0
+	for _, d := range members {
+		switch d.kind {
+		case declField:
+			t.checkName(parent, d)
+			parent.childCount++
+			m := &memberInfo{parent: parent, decl: d, codeOrder: codeOrder, fieldScope: layout}
+			codeOrder++
+			t.all = append(t.all, m)
+			if t.checkOrdinal(d) {
+				t.addOrdinal(d, m)
+			}
+		case declUnion:
+			u := &unionLayout{parent: layout}
+			var m *memberInfo
+			if d.name == "" {
+				m = parent
+				m.unionScope = u
+				t.traverseUnion(d, d.members, m, u, &codeOrder)
+			} else {
+				t.checkName(parent, d)
+				parent.childCount++
+				m = &memberInfo{parent: parent, decl: d, codeOrder: codeOrder, node: t.newGroupNode(parent.node, d.name), names: make(map[string]bool)}
+				codeOrder++
+				t.all = append(t.all, m)
+				m.unionScope = u
+				subCodeOrder := 0
+				t.traverseUnion(d, d.members, m, u, &subCodeOrder)
+			}
+			if d.hasOrdinal {
+				t.addOrdinal(d, m)
+			}
+		case declGroup:
+			t.checkName(parent, d)
+			parent.childCount++
+			m := &memberInfo{parent: parent, decl: d, codeOrder: codeOrder, node: t.newGroupNode(parent.node, d.name), names: make(map[string]bool)}
+			codeOrder++
+			t.all = append(t.all, m)
+			// Members of the group are laid out as if they were members
+			// of the parent.
+			t.traverseGroup(d, m, layout)
+		}
+	}
+}
+
+func (t *structTranslator) traverseGroup(d *decl, m *memberInfo, layout layoutScope) {
+	if len(d.members) == 0 {
+		t.c.errorf(d.pos, "group must have at least one member")
+	}
+	t.traverseTopOrGroup(d.members, m, layout)
+}
+
+func (t *structTranslator) traverseUnion(d *decl, members []*decl, parent *memberInfo, layout *unionLayout, codeOrder *int) {
+	n := 0
+	for _, m := range members {
+		if m.kind == declField || m.kind == declUnion || m.kind == declGroup {
+			n++
+		}
+	}
+	if n < 2 {
+		t.c.errorf(d.pos, "union must have at least two members")
+	}
+	for _, md := range members {
+		switch md.kind {
+		case declField:
+			t.checkName(parent, md)
+			parent.childCount++
+			// For layout, the field is a one-member group.
+			g := &groupLayout{parent: layout}
+			m := &memberInfo{parent: parent, decl: md, codeOrder: *codeOrder, fieldScope: g, isInUnion: true}
+			*codeOrder++
+			t.all = append(t.all, m)
+			if t.checkOrdinal(md) {
+				t.addOrdinal(md, m)
+			}
+		case declUnion:
+			if md.name == "" {
+				t.c.errorf(md.pos, "unions cannot contain unnamed unions")
+				continue
+			}
+			t.checkName(parent, md)
+			parent.childCount++
+			g := &groupLayout{parent: layout}
+			u := &unionLayout{parent: g}
+			m := &memberInfo{parent: parent, decl: md, codeOrder: *codeOrder, node: t.newGroupNode(parent.node, md.name), isInUnion: true, names: make(map[string]bool)}
+			*codeOrder++
+			t.all = append(t.all, m)
+			m.unionScope = u
+			subCodeOrder := 0
+			t.traverseUnion(md, md.members, m, u, &subCodeOrder)
+			if md.hasOrdinal {
+				t.addOrdinal(md, m)
+			}
+		case declGroup:
+			t.checkName(parent, md)
+			parent.childCount++
+			g := &groupLayout{parent: layout}
+			m := &memberInfo{parent: parent, decl: md, codeOrder: *codeOrder, node: t.newGroupNode(parent.node, md.name), isInUnion: true, names: make(map[string]bool)}
+			*codeOrder++
+			t.all = append(t.all, m)
+			t.traverseGroup(md, m, g)
+		}
+	}
+}
+
+// traverseParams adds a method's parameter list as the struct's fields.
+func (t *structTranslator) traverseParams(params []*decl) {
+	for i, d := range params {
+		t.checkName(t.root, d)
+		t.root.childCount++
+		m := &memberInfo{parent: t.root, decl: d, codeOrder: i, fieldScope: t.top, isParam: true}
+		t.all = append(t.all, m)
+		t.byOrdinal = append(t.byOrdinal, ordinalEntry{ordinal: uint64(i), member: m})
+	}
+}
+
+// translate assigns offsets in ordinal order and finishes the groups.
+func (t *structTranslator) translate() {
+	sort.Stable(t.byOrdinal)
+	var dups ordinalChecker
+	for _, e := range t.byOrdinal {
+		m := e.member
+		if e.decl != nil {
+			dups.check(t.c, e.decl)
+		}
+		if e.decl != nil && e.decl.kind == declUnion {
+			if e.decl.name != "" {
+				m.getField().ordinal = int(e.ordinal)
+			}
+			if !m.unionScope.addDiscriminant() {
+				t.c.errorf(e.decl.ordinalPos, "union ordinal, if specified, must be greater than no more than one of its member ordinals")
+			}
+			continue
+		}
+		f := m.getField()
+		f.ordinal = int(e.ordinal)
+		if m.isParam {
+			f.target = "param"
+		} else {
+			f.target = "field"
+		}
+		f.typ = t.c.compileType(m.decl.typ, t.sc, t.n)
+		if f.typ == nil {
+			f.typ = &typeRef{which: schema.Type_Which_void}
+		}
+		switch lg := f.typ.lgSize(); lg {
+		case -2:
+			f.offset = m.fieldScope.addPointer()
+		case -1:
+			m.fieldScope.addVoid()
+			f.offset = 0
+		default:
+			f.offset = m.fieldScope.addData(uint(lg))
+		}
+	}
+
+	t.finishGroup(t.root)
+	for _, m := range t.all {
+		if m.node != nil {
+			t.finishGroup(m)
+			if m.decl.kind == declUnion {
+				m.getField().target = "union"
+			} else {
+				m.getField().target = "group"
+			}
+		}
+	}
+
+	st := t.n.st
+	st.dataWords = uint16(t.top.dataWords)
+	st.pointers = uint16(t.top.pointers)
+	for _, g := range t.n.groups {
+		g.st.dataWords = st.dataWords
+		g.st.pointers = st.pointers
+	}
+}
+
+func (t *structTranslator) finishGroup(m *memberInfo) {
+	st := m.node.st
+	if m.unionScope != nil {
+		m.unionScope.addDiscriminant()
+		st.discriminantCount = uint16(m.unionDiscriminantCount)
+		st.discriminantOffset = m.unionScope.discriminant
+	}
+	if m.parent == nil {
+		return
+	}
+	f := m.getField()
+	id := groupID(m.parent.node.id, uint16(m.index))
+	m.node.id = id
+	m.node.scopeID = m.parent.node.id
+	f.group = m.node
+	t.c.registerNode(m.node, m.decl.pos)
+}
+
+// compileInterface compiles an interface's superclasses and methods,
+// creating parameter structs as needed.
+func (c *compiler) compileInterface(n *node, sc scope) {
+	for _, s := range n.decl.superclasses {
+		r := c.resolve(s, sc)
+		if r == nil {
+			continue
+		}
+		if r.kind != resNode || r.node.kind != declInterface {
+			c.errorf(s.pos, "%q is not an interface", s.String())
+			continue
+		}
+		if !c.checkBrand(r.brand, n) {
+			continue
+		}
+		n.use(r.node)
+		n.superclasses = append(n.superclasses, superclassInfo{node: r.node, brand: r.brand})
+	}
+
+	var sorted []*methodInfo
+	codeOrder := 0
+	names := make(map[string]bool)
+	for _, d := range n.decl.members {
+		if d.kind != declMethod {
+			continue
+		}
+		if names[d.name] || n.members[d.name] != nil {
+			c.errorf(d.pos, "%q is already defined in this scope", d.name)
+		}
+		names[d.name] = true
+		sorted = append(sorted, &methodInfo{name: d.name, decl: d, codeOrder: uint16(codeOrder), implicit: d.implicitParams})
+		codeOrder++
+	}
+	sort.Stable(methodsByOrdinal(sorted))
+	var dups ordinalChecker
+	for _, m := range sorted {
+		dups.check(c, m.decl)
+		ordinal := uint16(m.decl.ordinal)
+		m.paramID, m.paramBrand = c.compileParamList(n, sc, m, ordinal, false, m.decl.paramList)
+		results := m.decl.resultList
+		if results == nil {
+			results = &paramList{pos: m.decl.pos, params: []*decl{}}
+		}
+		m.resultID, m.resultBrand = c.compileParamList(n, sc, m, ordinal, true, results)
+		msc := sc
+		msc.implicit = &implicitParams{names: m.implicit}
+		m.annotations = c.compileAnnotations(m.decl.annotations, "method", msc, n)
+	}
+	n.methods = sorted
+}
+
+func (c *compiler) compileParamList(n *node, sc scope, m *methodInfo, ordinal uint16, isResults bool, pl *paramList) (uint64, *brandScope) {
+	if pl.typ != nil {
+		msc := sc
+		msc.implicit = &implicitParams{names: m.implicit}
+		r := c.resolve(pl.typ, msc)
+		if r == nil {
+			return 0, nil
+		}
+		if r.kind != resNode || r.node.kind != declStruct {
+			c.errorf(pl.typ.pos, "%q is not a struct type", pl.typ.String())
+			return 0, nil
+		}
+		if !c.checkBrand(r.brand, n) {
+			return 0, nil
+		}
+		n.use(r.node)
+		return r.node.id, r.brand
+	}
+
+	typeName := m.name + "$Params"
+	if isResults {
+		typeName = m.name + "$Results"
+	}
+	p := &node{
+		kind:        declStruct,
+		decl:        &decl{kind: declStruct, name: typeName, pos: pl.pos},
+		file:        n.file,
+		parent:      n,
+		id:          paramsID(n.id, ordinal, isResults),
+		name:        typeName,
+		displayName: n.displayName + "." + typeName,
+		isGeneric:   n.isGeneric || len(m.implicit) > 0,
+		members:     make(map[string]*entry),
+		laidOut:     true,
+		finishing:   true,
+	}
+	p.prefixLen = len(p.displayName) - len(typeName)
+	p.implicit = &implicitParams{id: p.id, names: m.implicit}
+	p.decl.params = m.implicit
+	c.registerNode(p, pl.pos)
+	n.paramStructs = append(n.paramStructs, p)
+
+	t := newStructTranslator(c, p)
+	t.traverseParams(pl.params)
+	t.translate()
+	for _, f := range p.st.fields {
+		c.fieldDefault(f, t.sc, p)
+		f.annotations = c.compileAnnotations(f.decl.annotations, "param", t.sc, p)
+	}
+	p.finishing = false
+	p.finished = true
+
+	brand := localBrand(n).push(p)
+	if len(m.implicit) > 0 {
+		brand.args = make([]*resolved, len(m.implicit))
+		for i := range m.implicit {
+			brand.args[i] = &resolved{kind: resImplicit, index: i, name: m.implicit[i]}
+		}
+	}
+	return p.id, brand
+}
+
+type enumerantsByOrdinal []*enumerantInfo
+
+func (es enumerantsByOrdinal) Len() int           { return len(es) }
+func (es enumerantsByOrdinal) Less(i, j int) bool { return es[i].decl.ordinal < es[j].decl.ordinal }
+func (es enumerantsByOrdinal) Swap(i, j int)      { es[i], es[j] = es[j], es[i] }
+
+type methodsByOrdinal []*methodInfo
+
+func (ms methodsByOrdinal) Len() int           { return len(ms) }
+func (ms methodsByOrdinal) Less(i, j int) bool { return ms[i].decl.ordinal < ms[j].decl.ordinal }
+func (ms methodsByOrdinal) Swap(i, j int)      { ms[i], ms[j] = ms[j], ms[i] }
+
+type ordinalEntries []ordinalEntry
+
+func (es ordinalEntries) Len() int           { return len(es) }
+func (es ordinalEntries) Less(i, j int) bool { return es[i].ordinal < es[j].ordinal }
+func (es ordinalEntries) Swap(i, j int)      { es[i], es[j] = es[j], es[i] }
@@ -0,0 +1,309 @@
+package compiler
+
+import (
+	"zombiezen.com/go/capnproto2/std/capnp/schema"
+)
+
+// A typeRef is a compiled type.
+type typeRef struct {
+	which schema.Type_Which
+	elem  *typeRef    // list element type
+	node  *node       // enum, struct, or interface
+	brand *brandScope // enum, struct, or interface
+
+	ptrKind schema.Type_anyPointer_Which
+	anyKind schema.Type_anyPointer_unconstrained_Which
+	scopeID uint64 // parameter
+	index   uint16 // parameter, implicitMethodParameter
+}
+
+var builtinTypes = map[builtinType]schema.Type_Which{
+	builtinVoid:    schema.Type_Which_void,
+	builtinBool:    schema.Type_Which_bool,
+	builtinInt8:    schema.Type_Which_int8,
+	builtinInt16:   schema.Type_Which_int16,
+	builtinInt32:   schema.Type_Which_int32,
+	builtinInt64:   schema.Type_Which_int64,
+	builtinUint8:   schema.Type_Which_uint8,
+	builtinUint16:  schema.Type_Which_uint16,
+	builtinUint32:  schema.Type_Which_uint32,
+	builtinUint64:  schema.Type_Which_uint64,
+	builtinFloat32: schema.Type_Which_float32,
+	builtinFloat64: schema.Type_Which_float64,
+	builtinText:    schema.Type_Which_text,
+	builtinData:    schema.Type_Which_data,
+}
+
+var unconstrainedKinds = map[builtinType]schema.Type_anyPointer_unconstrained_Which{
+	builtinAnyPointer: schema.Type_anyPointer_unconstrained_Which_anyKind,
+	builtinAnyStruct:  schema.Type_anyPointer_unconstrained_Which_struct,
+	builtinAnyList:    schema.Type_anyPointer_unconstrained_Which_list,
+	builtinCapability: schema.Type_anyPointer_unconstrained_Which_capability,
+}
+
+// compileType resolves a type expression.  Nodes the type refers to
+// are recorded as dependencies of owner.
+func (c *compiler) compileType(e *expr, sc scope, owner *node) *typeRef {
+	r := c.resolve(e, sc)
+	if r == nil {
+		return nil
+	}
+	return c.typeOf(r, owner)
+}
+
+// typeOf converts a resolved name into a type.  It reports an error and
+// returns nil if r is not a type.
+func (c *compiler) typeOf(r *resolved, owner *node) *typeRef {
+	switch r.kind {
+	case resBuiltin:
+		if w, ok := builtinTypes[r.builtin]; ok {
+			return &typeRef{which: w}
+		}
+		if k, ok := unconstrainedKinds[r.builtin]; ok {
+			return &typeRef{
+				which:   schema.Type_Which_anyPointer,
+				ptrKind: schema.Type_anyPointer_Which_unconstrained,
+				anyKind: k,
+			}
+		}
+		if r.elem == nil {
+			c.errorf(r.pos, "List requires a parameter")
+			return nil
+		}
+		elem := c.typeOf(r.elem, owner)
+		if elem == nil {
+			return nil
+		}
+		return &typeRef{which: schema.Type_Which_list, elem: elem}
+	case resParam:
+		return &typeRef{
+			which:   schema.Type_Which_anyPointer,
+			ptrKind: schema.Type_anyPointer_Which_parameter,
+			scopeID: r.scopeID,
+			index:   uint16(r.index),
+		}
+	case resImplicit:
+		return &typeRef{
+			which:   schema.Type_Which_anyPointer,
+			ptrKind: schema.Type_anyPointer_Which_implicitMethodParameter,
+			index:   uint16(r.index),
+		}
+	case resNode:
+		t := &typeRef{node: r.node, brand: r.brand}
+		switch r.node.kind {
+		case declStruct:
+			t.which = schema.Type_Which_structType
+		case declEnum:
+			t.which = schema.Type_Which_enum
+		case declInterface:
+			t.which = schema.Type_Which_interface
+		default:
+			c.errorf(r.pos, "%q is not a type", r.name)
+			return nil
+		}
+		if owner != nil {
+			owner.use(r.node)
+		}
+		if !c.checkBrand(r.brand, owner) {
+			return nil
+		}
+		return t
+	default:
+		c.errorf(r.pos, "%q is not a type", r.name)
+		return nil
+	}
+}
+
+// checkBrand compiles the bound parameters of a brand, recording their
+// dependencies.
+func (c *compiler) checkBrand(b *brandScope, owner *node) bool {
+	for s := b; s != nil; s = s.parent {
+		for _, arg := range s.args {
+			if c.typeOf(arg, owner) == nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// lgSize returns the log2 of the type's size in bits, -1 for Void, or
+// -2 for pointer types.
+func (t *typeRef) lgSize() int {
+	switch t.which {
+	case schema.Type_Which_void:
+		return -1
+	case schema.Type_Which_bool:
+		return 0
+	case schema.Type_Which_int8, schema.Type_Which_uint8:
+		return 3
+	case schema.Type_Which_int16, schema.Type_Which_uint16, schema.Type_Which_enum:
+		return 4
+	case schema.Type_Which_int32, schema.Type_Which_uint32, schema.Type_Which_float32:
+		return 5
+	case schema.Type_Which_int64, schema.Type_Which_uint64, schema.Type_Which_float64:
+		return 6
+	default:
+		return -2
+	}
+}
+
+func (t *typeRef) isPointer() bool {
+	return t.lgSize() == -2
+}
+
+func typeEqual(a, b *typeRef) bool {
+	if a.which != b.which {
+		return false
+	}
+	switch a.which {
+	case schema.Type_Which_list:
+		return typeEqual(a.elem, b.elem)
+	case schema.Type_Which_enum, schema.Type_Which_structType, schema.Type_Which_interface:
+		return a.node == b.node
+	case schema.Type_Which_anyPointer:
+		return a.ptrKind == b.ptrKind && a.anyKind == b.anyKind && a.scopeID == b.scopeID && a.index == b.index
+	default:
+		return true
+	}
+}
+
+func (t *typeRef) String() string {
+	switch t.which {
+	case schema.Type_Which_list:
+		return "List(" + t.elem.String() + ")"
+	case schema.Type_Which_enum, schema.Type_Which_structType, schema.Type_Which_interface:
+		return t.node.displayName
+	case schema.Type_Which_anyPointer:
+		return "AnyPointer"
+	default:
+		return t.which.String()
+	}
+}
+
+// writeType fills in a schema.Type.
+func (c *compiler) writeType(out schema.Type, t *typeRef) error {
+	switch t.which {
+	case schema.Type_Which_void:
+		out.SetVoid()
+	case schema.Type_Which_bool:
+		out.SetBool()
+	case schema.Type_Which_int8:
+		out.SetInt8()
+	case schema.Type_Which_int16:
+		out.SetInt16()
+	case schema.Type_Which_int32:
+		out.SetInt32()
+	case schema.Type_Which_int64:
+		out.SetInt64()
+	case schema.Type_Which_uint8:
+		out.SetUint8()
+	case schema.Type_Which_uint16:
+		out.SetUint16()
+	case schema.Type_Which_uint32:
+		out.SetUint32()
+	case schema.Type_Which_uint64:
+		out.SetUint64()
+	case schema.Type_Which_float32:
+		out.SetFloat32()
+	case schema.Type_Which_float64:
+		out.SetFloat64()
+	case schema.Type_Which_text:
+		out.SetText()
+	case schema.Type_Which_data:
+		out.SetData()
+	case schema.Type_Which_list:
+		out.SetList()
+		elem, err := out.List().NewElementType()
+		if err != nil {
+			return err
+		}
+		return c.writeType(elem, t.elem)
+	case schema.Type_Which_enum:
+		out.SetEnum()
+		out.Enum().SetTypeId(t.node.id)
+		return c.writeBrand(out.Enum().NewBrand, t.brand)
+	case schema.Type_Which_structType:
+		out.SetStructType()
+		out.StructType().SetTypeId(t.node.id)
+		return c.writeBrand(out.StructType().NewBrand, t.brand)
+	case schema.Type_Which_interface:
+		out.SetInterface()
+		out.Interface().SetTypeId(t.node.id)
+		return c.writeBrand(out.Interface().NewBrand, t.brand)
+	case schema.Type_Which_anyPointer:
+		out.SetAnyPointer()
+		ap := out.AnyPointer()
+		switch t.ptrKind {
+		case schema.Type_anyPointer_Which_unconstrained:
+			ap.SetUnconstrained()
+			switch t.anyKind {
+			case schema.Type_anyPointer_unconstrained_Which_anyKind:
+				ap.Unconstrained().SetAnyKind()
+			case schema.Type_anyPointer_unconstrained_Which_struct:
+				ap.Unconstrained().SetStruct()
+			case schema.Type_anyPointer_unconstrained_Which_list:
+				ap.Unconstrained().SetList()
+			case schema.Type_anyPointer_unconstrained_Which_capability:
+				ap.Unconstrained().SetCapability()
+			}
+		case schema.Type_anyPointer_Which_parameter:
+			ap.SetParameter()
+			ap.Parameter().SetScopeId(t.scopeID)
+			ap.Parameter().SetParameterIndex(t.index)
+		case schema.Type_anyPointer_Which_implicitMethodParameter:
+			ap.SetImplicitMethodParameter()
+			ap.ImplicitMethodParameter().SetParameterIndex(t.index)
+		}
+	}
+	return nil
+}
+
+// writeBrand fills in a schema.Brand allocated with newBrand.  Only
+// scopes that bind or inherit parameters are written, innermost first.
+// Like the reference compiler, a brand with no such scopes is left null.
+func (c *compiler) writeBrand(newBrand func() (schema.Brand, error), b *brandScope) error {
+	var levels []*brandScope
+	for s := b; s != nil; s = s.parent {
+		if len(s.args) > 0 || (s.inherited && s.paramCount > 0) {
+			levels = append(levels, s)
+		}
+	}
+	if len(levels) == 0 {
+		return nil
+	}
+	out, err := newBrand()
+	if err != nil {
+		return err
+	}
+	scopes, err := out.NewScopes(int32(len(levels)))
+	if err != nil {
+		return err
+	}
+	for i, s := range levels {
+		sc := scopes.At(i)
+		sc.SetScopeId(s.id)
+		if s.inherited {
+			sc.SetInherit()
+			continue
+		}
+		bind, err := sc.NewBind(int32(len(s.args)))
+		if err != nil {
+			return err
+		}
+		for j, arg := range s.args {
+			t := c.typeOf(arg, nil)
+			if t == nil {
+				continue
+			}
+			tb, err := bind.At(j).NewType()
+			if err != nil {
+				return err
+			}
+			if err := c.writeType(tb, t); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,520 @@
+package compiler
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+
+	"zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/std/capnp/schema"
+)
+
+// A value is a compiled constant, default value, or annotation value.
+// Scalars are stored in bits; pointers are stored in ptr, which may
+// belong to any message.
+type value struct {
+	typ  *typeRef
+	bits uint64
+	ptr  capnp.Ptr
+}
+
+type annotationInfo struct {
+	node  *node
+	brand *brandScope
+	val   *value
+}
+
+// compileAnnotations compiles annotation applications on a declaration
+// of the given target kind.
+func (c *compiler) compileAnnotations(apps []*annotationApp, target string, sc scope, owner *node) []*annotationInfo {
+	var list []*annotationInfo
+	for _, a := range apps {
+		r := c.resolve(a.name, sc)
+		if r == nil {
+			continue
+		}
+		if r.kind != resNode || r.node.kind != declAnnotation {
+			c.errorf(a.name.pos, "%q is not an annotation", a.name.String())
+			continue
+		}
+		an := r.node
+		c.layout(an)
+		owner.use(an)
+		if !an.decl.targetsAll && !containsString(an.decl.targets, target) {
+			c.errorf(a.name.pos, "%q cannot be applied to this kind of declaration", a.name.String())
+		}
+		info := &annotationInfo{node: an, brand: r.brand}
+		switch {
+		case an.typ == nil:
+			info.val = &value{typ: &typeRef{which: schema.Type_Which_void}}
+		case a.value == nil:
+			if an.typ.which != schema.Type_Which_void {
+				c.errorf(a.name.pos, "%q requires a value", a.name.String())
+			}
+			info.val = c.defaultValue(an.typ)
+		default:
+			info.val = c.compileTopValue(a.value, an.typ, sc, owner)
+		}
+		list = append(list, info)
+	}
+	return list
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldDefault returns the default value of a slot field, compiling it
+// on first use.
+func (c *compiler) fieldDefault(f *fieldInfo, sc scope, owner *node) *value {
+	if f.def != nil {
+		return f.def
+	}
+	if f.defPending {
+		c.errorf(f.decl.value.pos, "default value of %q depends on itself", f.name)
+		return c.defaultValue(f.typ)
+	}
+	f.defPending = true
+	var v *value
+	if f.decl.value != nil {
+		v = c.compileTopValue(f.decl.value, f.typ, sc, owner)
+	} else {
+		v = c.defaultValue(f.typ)
+	}
+	f.defPending = false
+	f.def = v
+	return v
+}
+
+// constVal returns the value of a const node, compiling it on first use.
+func (c *compiler) constVal(n *node) *value {
+	if n.constValue != nil {
+		return n.constValue
+	}
+	c.layout(n)
+	if n.typ == nil {
+		return nil
+	}
+	if n.constPending {
+		c.errorf(n.decl.pos, "value of %q depends on itself", n.name)
+		return nil
+	}
+	n.constPending = true
+	v := c.compileTopValue(n.decl.value, n.typ, nodeScope(n), n)
+	n.constPending = false
+	n.constValue = v
+	return v
+}
+
+// defaultValue returns the value of a field without an explicit
+// default.  Pointer defaults, including Text and Data, are null.
+func (c *compiler) defaultValue(t *typeRef) *value {
+	return &value{typ: t}
+}
+
+// compileTopValue compiles a value into a new message.
+func (c *compiler) compileTopValue(e *expr, t *typeRef, sc scope, owner *node) *value {
+	_, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		c.errorf(e.pos, "%v", err)
+		return c.defaultValue(t)
+	}
+	v := c.compileValue(e, t, sc, owner, seg)
+	if v == nil {
+		return c.defaultValue(t)
+	}
+	return v
+}
+
+// compileValue compiles a value expression of type t, allocating any
+// pointers in seg.  It reports an error and returns nil on failure.
+func (c *compiler) compileValue(e *expr, t *typeRef, sc scope, owner *node, seg *capnp.Segment) *value {
+	switch e.kind {
+	case exprName:
+		switch {
+		case e.name == "void" && t.which == schema.Type_Which_void:
+			return &value{typ: t}
+		case (e.name == "true" || e.name == "false") && t.which == schema.Type_Which_bool:
+			v := &value{typ: t}
+			if e.name == "true" {
+				v.bits = 1
+			}
+			return v
+		case (e.name == "inf" || e.name == "nan") && isFloat(t.which):
+			f := math.Inf(1)
+			if e.name == "nan" {
+				f = math.NaN()
+			}
+			return floatValue(t, f)
+		case t.which == schema.Type_Which_enum:
+			c.layout(t.node)
+			for _, en := range t.node.enumerants {
+				if en.name == e.name {
+					return &value{typ: t, bits: en.decl.ordinal}
+				}
+			}
+		}
+		return c.compileRef(e, t, sc, owner)
+	case exprAbsName, exprMember:
+		return c.compileRef(e, t, sc, owner)
+	case exprInt:
+		return c.intValue(e, t)
+	case exprFloat:
+		if !isFloat(t.which) {
+			c.errorf(e.pos, "type mismatch: expected %v, found float literal", t)
+			return nil
+		}
+		return floatValue(t, e.fval)
+	case exprString, exprBinary, exprEmbed:
+		b := e.sval
+		if e.kind == exprEmbed {
+			var ok bool
+			if b, ok = c.embed(e, sc); !ok {
+				return nil
+			}
+		}
+		switch {
+		case t.which == schema.Type_Which_text && e.kind != exprBinary:
+			txt, err := capnp.NewTextFromBytes(seg, b)
+			if err != nil {
+				c.errorf(e.pos, "%v", err)
+				return nil
+			}
+			return &value{typ: t, ptr: txt.List.ToPtr()}
+		case t.which == schema.Type_Which_data:
+			d, err := capnp.NewData(seg, b)
+			if err != nil {
+				c.errorf(e.pos, "%v", err)
+				return nil
+			}
+			return &value{typ: t, ptr: d.List.ToPtr()}
+		}
+		c.errorf(e.pos, "type mismatch: expected %v, found %v", t, e)
+		return nil
+	case exprList:
+		if t.which != schema.Type_Which_list {
+			c.errorf(e.pos, "type mismatch: expected %v, found list", t)
+			return nil
+		}
+		return c.listValue(e, t, sc, owner, seg)
+	case exprTuple:
+		if t.which != schema.Type_Which_structType {
+			if len(e.args) == 1 && e.args[0].name == "" {
+				return c.compileValue(e.args[0].value, t, sc, owner, seg)
+			}
+			c.errorf(e.pos, "type mismatch: expected %v, found struct", t)
+			return nil
+		}
+		c.layout(t.node)
+		st := t.node.st
+		s, err := capnp.NewStruct(seg, capnp.ObjectSize{DataSize: capnp.Size(st.dataWords) * 8, PointerCount: st.pointers})
+		if err != nil {
+			c.errorf(e.pos, "%v", err)
+			return nil
+		}
+		if !c.fillStruct(s, e, t.node, st, sc, owner) {
+			return nil
+		}
+		return &value{typ: t, ptr: s.ToPtr()}
+	default:
+		c.errorf(e.pos, "%v is not a value", e)
+		return nil
+	}
+}
+
+// compileRef compiles a reference to a constant or an enumerant.
+func (c *compiler) compileRef(e *expr, t *typeRef, sc scope, owner *node) *value {
+	r := c.resolve(e, sc)
+	if r == nil {
+		return nil
+	}
+	switch {
+	case r.kind == resEnumerant:
+		if t.which != schema.Type_Which_enum || t.node != r.node {
+			c.errorf(e.pos, "type mismatch: expected %v, found %v", t, e)
+			return nil
+		}
+		return &value{typ: t, bits: r.node.enumerants[r.index].decl.ordinal}
+	case r.kind == resNode && r.node.kind == declConst:
+		v := c.constVal(r.node)
+		if v == nil {
+			return nil
+		}
+		if !typeEqual(r.node.typ, t) {
+			c.errorf(e.pos, "type mismatch: expected %v, found constant of type %v", t, r.node.typ)
+			return nil
+		}
+		owner.use(r.node)
+		return &value{typ: t, bits: v.bits, ptr: v.ptr}
+	default:
+		c.errorf(e.pos, "%q is not a constant", e.String())
+		return nil
+	}
+}
+
+func (c *compiler) embed(e *expr, sc scope) ([]byte, bool) {
+	name := string(e.sval)
+	var paths []string
+	if strings.HasPrefix(name, "/") {
+		for _, dir := range c.opts.ImportPath {
+			paths = append(paths, filepath.Join(dir, filepath.FromSlash(name[1:])))
+		}
+	} else {
+		paths = append(paths, filepath.Join(filepath.Dir(sc.node.file.path), filepath.FromSlash(name)))
+	}
+	var err error
+	for _, p := range paths {
+		var b []byte
+		if b, err = c.opts.ReadFile(p); err == nil {
+			return b, true
+		}
+	}
+	if err == nil {
+		c.errorf(e.pos, "embed %q not found in import path", name)
+	} else {
+		c.errorf(e.pos, "%v", err)
+	}
+	return nil, false
+}
+
+func isFloat(w schema.Type_Which) bool {
+	return w == schema.Type_Which_float32 || w == schema.Type_Which_float64
+}
+
+func floatValue(t *typeRef, f float64) *value {
+	if t.which == schema.Type_Which_float32 {
+		return &value{typ: t, bits: uint64(math.Float32bits(float32(f)))}
+	}
+	return &value{typ: t, bits: math.Float64bits(f)}
+}
+
+func (c *compiler) intValue(e *expr, t *typeRef) *value {
+	var max uint64
+	signed := false
+	switch t.which {
+	case schema.Type_Which_int8:
+		max, signed = math.MaxInt8, true
+	case schema.Type_Which_int16:
+		max, signed = math.MaxInt16, true
+	case schema.Type_Which_int32:
+		max, signed = math.MaxInt32, true
+	case schema.Type_Which_int64:
+		max, signed = math.MaxInt64, true
+	case schema.Type_Which_uint8:
+		max = math.MaxUint8
+	case schema.Type_Which_uint16:
+		max = math.MaxUint16
+	case schema.Type_Which_uint32:
+		max = math.MaxUint32
+	case schema.Type_Which_uint64:
+		max = math.MaxUint64
+	case schema.Type_Which_float32, schema.Type_Which_float64:
+		f := float64(e.ival)
+		if e.neg {
+			f = -f
+		}
+		return floatValue(t, f)
+	default:
+		c.errorf(e.pos, "type mismatch: expected %v, found integer", t)
+		return nil
+	}
+	switch {
+	case e.neg && !signed && e.ival != 0:
+		c.errorf(e.pos, "integer %v is out of range for %v", e, t)
+		return nil
+	case e.neg && e.ival > max+1, !e.neg && e.ival > max:
+		c.errorf(e.pos, "integer %v is out of range for %v", e, t)
+		return nil
+	}
+	if e.neg {
+		return &value{typ: t, bits: -e.ival}
+	}
+	return &value{typ: t, bits: e.ival}
+}
+
+func (c *compiler) listValue(e *expr, t *typeRef, sc scope, owner *node, seg *capnp.Segment) *value {
+	elemType := t.elem
+	n := int32(len(e.elems))
+	var elems []*value
+	for _, el := range e.elems {
+		if elemType.which == schema.Type_Which_structType && el.kind == exprTuple {
+			// Filled in place below.
+			elems = append(elems, nil)
+			continue
+		}
+		v := c.compileValue(el, elemType, sc, owner, seg)
+		if v == nil {
+			return nil
+		}
+		elems = append(elems, v)
+	}
+	var l capnp.List
+	var err error
+	switch elemType.which {
+	case schema.Type_Which_void:
+		l = capnp.NewVoidList(seg, n).List
+	case schema.Type_Which_bool:
+		var bl capnp.BitList
+		bl, err = capnp.NewBitList(seg, n)
+		for i, v := range elems {
+			bl.Set(i, v.bits != 0)
+		}
+		l = bl.List
+	case schema.Type_Which_int8, schema.Type_Which_uint8:
+		var ul capnp.UInt8List
+		ul, err = capnp.NewUInt8List(seg, n)
+		for i, v := range elems {
+			ul.Set(i, uint8(v.bits))
+		}
+		l = ul.List
+	case schema.Type_Which_int16, schema.Type_Which_uint16, schema.Type_Which_enum:
+		var ul capnp.UInt16List
+		ul, err = capnp.NewUInt16List(seg, n)
+		for i, v := range elems {
+			ul.Set(i, uint16(v.bits))
+		}
+		l = ul.List
+	case schema.Type_Which_int32, schema.Type_Which_uint32, schema.Type_Which_float32:
+		var ul capnp.UInt32List
+		ul, err = capnp.NewUInt32List(seg, n)
+		for i, v := range elems {
+			ul.Set(i, uint32(v.bits))
+		}
+		l = ul.List
+	case schema.Type_Which_int64, schema.Type_Which_uint64, schema.Type_Which_float64:
+		var ul capnp.UInt64List
+		ul, err = capnp.NewUInt64List(seg, n)
+		for i, v := range elems {
+			ul.Set(i, v.bits)
+		}
+		l = ul.List
+	case schema.Type_Which_structType:
+		c.layout(elemType.node)
+		st := elemType.node.st
+		sz := capnp.ObjectSize{DataSize: capnp.Size(st.dataWords) * 8, PointerCount: st.pointers}
+		l, err = capnp.NewCompositeList(seg, sz, n)
+		if err != nil {
+			break
+		}
+		for i, v := range elems {
+			if v != nil {
+				err = l.SetStruct(i, v.ptr.Struct())
+			} else if !c.fillStruct(l.Struct(i), e.elems[i], elemType.node, st, sc, owner) {
+				return nil
+			}
+			if err != nil {
+				break
+			}
+		}
+	default:
+		var pl capnp.PointerList
+		pl, err = capnp.NewPointerList(seg, n)
+		for i, v := range elems {
+			if err != nil {
+				break
+			}
+			err = pl.SetPtr(i, v.ptr)
+		}
+		l = pl.List
+	}
+	if err != nil {
+		c.errorf(e.pos, "%v", err)
+		return nil
+	}
+	return &value{typ: t, ptr: l.ToPtr()}
+}
+
+// fillStruct sets the fields named in a struct literal.
+func (c *compiler) fillStruct(s capnp.Struct, e *expr, n *node, st *structInfo, sc scope, owner *node) bool {
+	seen := make(map[string]bool)
+	unionSet := false
+	for _, a := range e.args {
+		if a.name == "" {
+			c.errorf(a.value.pos, "struct literal fields must be named")
+			return false
+		}
+		f := st.field(a.name)
+		if f == nil {
+			c.errorf(a.namePos, "%s has no field named %q", n.displayName, a.name)
+			return false
+		}
+		if seen[a.name] {
+			c.errorf(a.namePos, "field %q is set more than once", a.name)
+			return false
+		}
+		seen[a.name] = true
+		if f.discriminant != schema.Field_noDiscriminant {
+			if unionSet {
+				c.errorf(a.namePos, "more than one member of the union is set")
+				return false
+			}
+			unionSet = true
+			s.SetUint16(capnp.DataOffset(st.discriminantOffset*2), f.discriminant)
+		}
+		if f.group != nil {
+			if a.value.kind != exprTuple {
+				c.errorf(a.value.pos, "group %q must be set with a struct literal", a.name)
+				return false
+			}
+			if !c.fillStruct(s, a.value, f.group, f.group.st, sc, owner) {
+				return false
+			}
+			continue
+		}
+		if f.typ.which == schema.Type_Which_structType && a.value.kind == exprTuple {
+			c.layout(f.typ.node)
+			fst := f.typ.node.st
+			sub, err := capnp.NewStruct(s.Segment(), capnp.ObjectSize{DataSize: capnp.Size(fst.dataWords) * 8, PointerCount: fst.pointers})
+			if err == nil {
+				err = s.SetPtr(uint16(f.offset), sub.ToPtr())
+			}
+			if err != nil {
+				c.errorf(a.value.pos, "%v", err)
+				return false
+			}
+			if !c.fillStruct(sub, a.value, f.typ.node, fst, sc, owner) {
+				return false
+			}
+			continue
+		}
+		v := c.compileValue(a.value, f.typ, sc, owner, s.Segment())
+		if v == nil {
+			return false
+		}
+		if f.typ.isPointer() {
+			if err := s.SetPtr(uint16(f.offset), v.ptr); err != nil {
+				c.errorf(a.value.pos, "%v", err)
+				return false
+			}
+			continue
+		}
+		root := n
+		for root.kind == declGroup {
+			root = root.parent
+		}
+		def := c.fieldDefault(f, nodeScope(root), root)
+		setData(s, f, v.bits^def.bits)
+	}
+	return true
+}
+
+// setData stores a scalar field's bits, already XORed with its default.
+func setData(s capnp.Struct, f *fieldInfo, bits uint64) {
+	switch f.typ.lgSize() {
+	case 0:
+		s.SetBit(capnp.BitOffset(f.offset), bits&1 != 0)
+	case 3:
+		s.SetUint8(capnp.DataOffset(f.offset), uint8(bits))
+	case 4:
+		s.SetUint16(capnp.DataOffset(f.offset*2), uint16(bits))
+	case 5:
+		s.SetUint32(capnp.DataOffset(f.offset*4), uint32(bits))
+	case 6:
+		s.SetUint64(capnp.DataOffset(f.offset*8), bits)
+	}
+}
@@ -0,0 +1,359 @@
+package capnp
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"zombiezen.com/go/capnproto2/packed"
+)
+
+// A Codec compresses the frames of a compressed stream.  Codecs are
+// shared between goroutines, so they must be safe for concurrent use.
+type Codec interface {
+	// Compress appends the compressed form of src to dst and returns
+	// the extended buffer.
+	Compress(dst, src []byte) ([]byte, error)
+
+	// Decompress decompresses src into dst.  len(dst) is the size of
+	// the uncompressed data that the frame's header records, and it is
+	// an error for src to decompress to any other size.
+	Decompress(dst, src []byte) error
+}
+
+// A CodecID identifies a codec in the header of each compressed frame.
+type CodecID uint8
+
+// Codecs provided by this package.  IDs below 16 are reserved for
+// codecs in this package.
+const (
+	// CodecFlate compresses frames with DEFLATE (RFC 1951).
+	CodecFlate CodecID = 1
+
+	// CodecPackFlate packs frames (see NewPackedEncoder) and then
+	// compresses them with DEFLATE.  Packing first is often smaller
+	// and faster than DEFLATE alone for messages with many zero bytes.
+	CodecPackFlate CodecID = 2
+)
+
+var codecs struct {
+	mu sync.RWMutex
+	m  map[CodecID]Codec
+}
+
+// RegisterCodec makes a codec available to compressed streams under
+// the given ID.  It panics if the ID is already registered.
+func RegisterCodec(id CodecID, c Codec) {
+	codecs.mu.Lock()
+	defer codecs.mu.Unlock()
+	if codecs.m[id] != nil {
+		panic(fmt.Sprintf("capnp: codec %d registered twice", id))
+	}
+	if codecs.m == nil {
+		codecs.m = make(map[CodecID]Codec)
+	}
+	codecs.m[id] = c
+}
+
+func findCodec(id CodecID) Codec {
+	codecs.mu.RLock()
+	c := codecs.m[id]
+	codecs.mu.RUnlock()
+	return c
+}
+
+func init() {
+	RegisterCodec(CodecFlate, flateCodec{})
+	RegisterCodec(CodecPackFlate, packFlateCodec{})
+}
+
+// Compressed frame format:
+//
+//	magic           4 bytes, "CAPZ"
+//	codec           1 byte
+//	reserved        3 bytes, zero
+//	raw size        uint32, size of the uncompressed data
+//	data size       uint32, size of the compressed data
+//	data
+//
+// The magic number can't start a plain stream, since read as a segment
+// count it is far above the limit, so readers can tell the two apart.
+const (
+	frameMagic      = "CAPZ"
+	frameHeaderSize = 16
+
+	// DefaultFrameSize is the size of the uncompressed data in each
+	// frame, unless set in CompressWriter.FrameSize.
+	DefaultFrameSize = 1 << 20
+
+	maxFrameSize = defaultDecodeLimit
+)
+
+var (
+	errFrameMagic     = errors.New("capnp: compressed frame does not start with magic number")
+	errFrameTooLarge  = errors.New("capnp: compressed frame too large")
+	errFrameReserved  = errors.New("capnp: compressed frame header has reserved bits set")
+	errFrameSize      = errors.New("capnp: compressed frame does not match its recorded size")
+	errFrameUnaligned = errors.New("capnp: packed frame is not a whole number of words")
+)
+
+type unknownCodecError CodecID
+
+func (e unknownCodecError) Error() string {
+	return fmt.Sprintf("capnp: unknown compression codec %d", CodecID(e))
+}
+
+// A CompressWriter compresses a message stream, such as the output of
+// an Encoder, into frames.  It buffers its input until it has a full
+// frame, so Flush must be called to write out the last frame.
+type CompressWriter struct {
+	// FrameSize is the size of the uncompressed data in each frame.
+	// It is rounded up to a whole number of words.  If not set,
+	// DefaultFrameSize is used.
+	FrameSize int
+
+	w     io.Writer
+	id    CodecID
+	codec Codec
+	buf   []byte
+	out   []byte
+}
+
+// NewCompressWriter returns a writer that compresses frames with the
+// codec registered as id and writes them to w.
+func NewCompressWriter(w io.Writer, id CodecID) (*CompressWriter, error) {
+	c := findCodec(id)
+	if c == nil {
+		return nil, unknownCodecError(id)
+	}
+	return &CompressWriter{w: w, id: id, codec: c}, nil
+}
+
+func (cw *CompressWriter) frameSize() int {
+	n := cw.FrameSize
+	if n <= 0 {
+		n = DefaultFrameSize
+	}
+	if n > maxFrameSize {
+		n = maxFrameSize
+	}
+	return (n + 7) &^ 7
+}
+
+// Write buffers p, writing out any frames that fill up.
+func (cw *CompressWriter) Write(p []byte) (int, error) {
+	size := cw.frameSize()
+	n := len(p)
+	for len(cw.buf)+len(p) >= size {
+		k := size - len(cw.buf)
+		cw.buf = append(cw.buf, p[:k]...)
+		p = p[k:]
+		if err := cw.writeFrame(); err != nil {
+			return n - len(p), err
+		}
+	}
+	cw.buf = append(cw.buf, p...)
+	return n, nil
+}
+
+// Flush writes out any buffered data as a frame.
+func (cw *CompressWriter) Flush() error {
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	return cw.writeFrame()
+}
+
+// Close flushes the writer.  It does not close the underlying writer.
+func (cw *CompressWriter) Close() error {
+	return cw.Flush()
+}
+
+func (cw *CompressWriter) writeFrame() error {
+	out := append(cw.out[:0], frameMagic...)
+	out = append(out, byte(cw.id), 0, 0, 0)
+	out = appendUint32(out, uint32(len(cw.buf)))
+	out = appendUint32(out, 0) // data size, filled in below
+	out, err := cw.codec.Compress(out, cw.buf)
+	if err != nil {
+		return err
+	}
+	if len(out)-frameHeaderSize > maxFrameSize {
+		return errFrameTooLarge
+	}
+	binary.LittleEndian.PutUint32(out[12:], uint32(len(out)-frameHeaderSize))
+	cw.out = out
+	cw.buf = cw.buf[:0]
+	_, err = cw.w.Write(out)
+	return err
+}
+
+// A CompressReader decompresses a stream written by a CompressWriter.
+// If the stream does not start with a compressed frame, it is passed
+// through unchanged, so a CompressReader can read plain message
+// streams too.
+type CompressReader struct {
+	r       io.Reader
+	started bool
+	plain   bool
+	buf     []byte // decompressed data
+	off     int    // read offset into buf
+	data    []byte // compressed data
+	err     error
+}
+
+// NewCompressReader returns a reader that decompresses the stream r.
+func NewCompressReader(r io.Reader) *CompressReader {
+	return &CompressReader{r: r}
+}
+
+// NewCompressedDecoder creates a new Cap'n Proto framer that reads
+// from a stream written through a CompressWriter, or from a plain
+// stream.
+func NewCompressedDecoder(r io.Reader) *Decoder {
+	return NewDecoder(NewCompressReader(r))
+}
+
+// Read reads decompressed data into p.
+func (cr *CompressReader) Read(p []byte) (int, error) {
+	if !cr.started {
+		cr.started = true
+		var magic [len(frameMagic)]byte
+		n, err := io.ReadFull(cr.r, magic[:])
+		if err != nil || string(magic[:]) != frameMagic {
+			// Not a compressed stream: put back what was read and
+			// pass the rest through.
+			cr.plain = true
+			cr.r = io.MultiReader(bytes.NewReader(magic[:n]), cr.r)
+		} else {
+			cr.err = cr.readFrame(false)
+		}
+	}
+	if cr.plain {
+		return cr.r.Read(p)
+	}
+	for cr.off == len(cr.buf) {
+		if cr.err != nil {
+			return 0, cr.err
+		}
+		cr.err = cr.readFrame(true)
+	}
+	n := copy(p, cr.buf[cr.off:])
+	cr.off += n
+	return n, nil
+}
+
+// readFrame reads the next frame into cr.buf, starting with its magic
+// number if readMagic is true.
+func (cr *CompressReader) readFrame(readMagic bool) error {
+	var hdr [frameHeaderSize]byte
+	if readMagic {
+		if _, err := io.ReadFull(cr.r, hdr[:len(frameMagic)]); err != nil {
+			return err
+		}
+		if string(hdr[:len(frameMagic)]) != frameMagic {
+			return errFrameMagic
+		}
+	}
+	if _, err := io.ReadFull(cr.r, hdr[len(frameMagic):]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if hdr[5] != 0 || hdr[6] != 0 || hdr[7] != 0 {
+		return errFrameReserved
+	}
+	c := findCodec(CodecID(hdr[4]))
+	if c == nil {
+		return unknownCodecError(hdr[4])
+	}
+	rawSize := binary.LittleEndian.Uint32(hdr[8:])
+	dataSize := binary.LittleEndian.Uint32(hdr[12:])
+	if rawSize > maxFrameSize || dataSize > maxFrameSize {
+		return errFrameTooLarge
+	}
+	if uint32(cap(cr.data)) < dataSize {
+		cr.data = make([]byte, dataSize)
+	}
+	cr.data = cr.data[:dataSize]
+	if _, err := io.ReadFull(cr.r, cr.data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if uint32(cap(cr.buf)) < rawSize {
+		cr.buf = make([]byte, rawSize)
+	}
+	cr.buf, cr.off = cr.buf[:rawSize], 0
+	if err := c.Decompress(cr.buf, cr.data); err != nil {
+		cr.buf = cr.buf[:0]
+		return err
+	}
+	return nil
+}
+
+var flateWriters = sync.Pool{
+	New: func() interface{} {
+		w, _ := flate.NewWriter(nil, flate.DefaultCompression)
+		return w
+	},
+}
+
+type flateCodec struct{}
+
+func (flateCodec) Compress(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	w := flateWriters.Get().(*flate.Writer)
+	defer flateWriters.Put(w)
+	w.Reset(buf)
+	if _, err := w.Write(src); err != nil {
+		return dst, err
+	}
+	if err := w.Close(); err != nil {
+		return dst, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (flateCodec) Decompress(dst, src []byte) error {
+	r := flate.NewReader(bytes.NewReader(src))
+	defer r.Close()
+	if _, err := io.ReadFull(r, dst); err != nil {
+		return errFrameSize
+	}
+	var extra [1]byte
+	if n, _ := r.Read(extra[:]); n != 0 {
+		return errFrameSize
+	}
+	return nil
+}
+
+type packFlateCodec struct{}
+
+func (packFlateCodec) Compress(dst, src []byte) ([]byte, error) {
+	if len(src)%int(wordSize) != 0 {
+		return dst, errFrameUnaligned
+	}
+	return flateCodec{}.Compress(dst, packed.Pack(nil, src))
+}
+
+func (packFlateCodec) Decompress(dst, src []byte) error {
+	r := flate.NewReader(bytes.NewReader(src))
+	defer r.Close()
+	pr := packed.NewReader(bufio.NewReader(r))
+	if _, err := io.ReadFull(pr, dst); err != nil {
+		return errFrameSize
+	}
+	var extra [wordSize]byte
+	if n, _ := pr.Read(extra[:]); n != 0 {
+		return errFrameSize
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package capnp
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// xorCodec is a trivial codec that checks that custom codecs can be
+// registered.
+type xorCodec struct{}
+
+const xorCodecID CodecID = 200
+
+func init() {
+	RegisterCodec(xorCodecID, xorCodec{})
+}
+
+func (xorCodec) Compress(dst, src []byte) ([]byte, error) {
+	for _, b := range src {
+		dst = append(dst, b^0x55)
+	}
+	return dst, nil
+}
+
+func (xorCodec) Decompress(dst, src []byte) error {
+	if len(src) != len(dst) {
+		return errFrameSize
+	}
+	for i, b := range src {
+		dst[i] = b ^ 0x55
+	}
+	return nil
+}
+
+func TestCompressRoundTrip(t *testing.T) {
+	var stream []byte
+	var want [][][]byte
+	for _, test := range serializeTests {
+		if test.encodeFails || test.decodeFails {
+			continue
+		}
+		stream = append(stream, test.out...)
+		want = append(want, test.segs)
+	}
+	for _, id := range []CodecID{CodecFlate, CodecPackFlate, xorCodecID} {
+		for _, frameSize := range []int{0, 1, 24} {
+			var buf bytes.Buffer
+			cw, err := NewCompressWriter(&buf, id)
+			if err != nil {
+				t.Fatalf("NewCompressWriter(%d): %v", id, err)
+			}
+			cw.FrameSize = frameSize
+			if _, err := cw.Write(stream); err != nil {
+				t.Errorf("codec %d, frame size %d: Write: %v", id, frameSize, err)
+				continue
+			}
+			if err := cw.Close(); err != nil {
+				t.Errorf("codec %d, frame size %d: Close: %v", id, frameSize, err)
+				continue
+			}
+			dec := NewCompressedDecoder(&buf)
+			for i, segs := range want {
+				msg, err := dec.Decode()
+				if err != nil {
+					t.Errorf("codec %d, frame size %d: Decode #%d: %v", id, frameSize, i, err)
+					break
+				}
+				if !sameSegments(msg, segs) {
+					t.Errorf("codec %d, frame size %d: Decode #%d has wrong segments", id, frameSize, i)
+				}
+			}
+			if _, err := dec.Decode(); err != io.EOF {
+				t.Errorf("codec %d, frame size %d: Decode at end = %v; want io.EOF", id, frameSize, err)
+			}
+		}
+	}
+}
+
+func TestCompressPlainStream(t *testing.T) {
+	var stream []byte
+	var want [][][]byte
+	for _, test := range serializeTests {
+		if test.encodeFails || test.decodeFails {
+			continue
+		}
+		stream = append(stream, test.out...)
+		want = append(want, test.segs)
+	}
+	dec := NewCompressedDecoder(bytes.NewReader(stream))
+	for i, segs := range want {
+		msg, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("Decode #%d: %v", i, err)
+		}
+		if !sameSegments(msg, segs) {
+			t.Errorf("Decode #%d has wrong segments", i)
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("Decode at end = %v; want io.EOF", err)
+	}
+
+	if _, err := NewCompressedDecoder(bytes.NewReader(nil)).Decode(); err != io.EOF {
+		t.Errorf("Decode of empty stream = %v; want io.EOF", err)
+	}
+}
+
+func TestCompressSmaller(t *testing.T) {
+	msg := &Message{Arena: MultiSegment([][]byte{make([]byte, 4096)})}
+	var plain, compressed bytes.Buffer
+	if err := NewEncoder(&plain).Encode(msg); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []CodecID{CodecFlate, CodecPackFlate} {
+		compressed.Reset()
+		cw, err := NewCompressWriter(&compressed, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := NewEncoder(cw).Encode(msg); err != nil {
+			t.Fatal(err)
+		}
+		if err := cw.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if compressed.Len() >= plain.Len()/10 {
+			t.Errorf("codec %d compressed %d bytes of zeroes to %d bytes", id, plain.Len(), compressed.Len())
+		}
+	}
+}
+
+func TestCompressReaderErrors(t *testing.T) {
+	var good bytes.Buffer
+	cw, _ := NewCompressWriter(&good, CodecFlate)
+	for _, test := range serializeTests {
+		if !test.encodeFails && !test.decodeFails {
+			cw.Write(test.out)
+			break
+		}
+	}
+	cw.Flush()
+	frame := good.Bytes()
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  error
+	}{
+		{"truncated header", frame[:10], io.ErrUnexpectedEOF},
+		{"truncated data", frame[:len(frame)-1], io.ErrUnexpectedEOF},
+		{"unknown codec", withByte(frame, 4, 99), unknownCodecError(99)},
+		{"reserved bits", withByte(frame, 5, 1), errFrameReserved},
+		{"wrong raw size", withByte(frame, 8, frame[8]+8), errFrameSize},
+		{"bad second frame", append(append([]byte(nil), frame...), "XXXX"...), errFrameMagic},
+	}
+	for _, test := range tests {
+		_, err := io.Copy(new(bytes.Buffer), NewCompressReader(bytes.NewReader(test.input)))
+		if err != test.want {
+			t.Errorf("%s: read error = %v; want %v", test.name, err, test.want)
+		}
+	}
+	if _, err := NewCompressWriter(new(bytes.Buffer), 99); err != unknownCodecError(99) {
+		t.Errorf("NewCompressWriter with unknown codec = %v; want %v", err, unknownCodecError(99))
+	}
+}
+
+func withByte(b []byte, i int, v byte) []byte {
+	b = append([]byte(nil), b...)
+	b[i] = v
+	return b
+}
+
+func sameSegments(msg *Message, segs [][]byte) bool {
+	if msg.NumSegments() != int64(len(segs)) {
+		return false
+	}
+	for i := range segs {
+		seg, err := msg.Segment(SegmentID(i))
+		if err != nil || !bytes.Equal(seg.Data(), segs[i]) {
+			return false
+		}
+	}
+	return true
+}
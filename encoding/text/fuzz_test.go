@@ -0,0 +1,91 @@
+// +build go1.18
+
+package text
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/schemas"
+	"zombiezen.com/go/capnproto2/std/capnp/schema"
+)
+
+const (
+	keyValueTypeID uint64 = 0x8df8bc5abdc060a6
+	valueTypeID    uint64 = 0xd3602730c572a43b
+)
+
+// FuzzEncode encodes framed messages whose root is a KeyValue or a
+// Value from testdata/txt.capnp.  Seeds are the constants in that file.
+func FuzzEncode(f *testing.F) {
+	data, err := readTestFile("txt.capnp.out")
+	if err != nil {
+		f.Fatal(err)
+	}
+	reg := new(schemas.Registry)
+	err = reg.Register(&schemas.Schema{
+		Bytes: data,
+		Nodes: []uint64{keyValueTypeID, valueTypeID},
+	})
+	if err != nil {
+		f.Fatalf("Adding to registry: %v", err)
+	}
+	msg, err := capnp.Unmarshal(data)
+	if err != nil {
+		f.Fatal("Unmarshaling txt.capnp.out:", err)
+	}
+	req, err := schema.ReadRootCodeGeneratorRequest(msg)
+	if err != nil {
+		f.Fatal("Reading code generator request txt.capnp.out:", err)
+	}
+	nodes, err := req.Nodes()
+	if err != nil {
+		f.Fatal(err)
+	}
+	for i := 0; i < nodes.Len(); i++ {
+		n := nodes.At(i)
+		if n.Which() != schema.Node_Which_const {
+			continue
+		}
+		typ, err := n.Const().Type()
+		if err != nil || typ.Which() != schema.Type_Which_structType {
+			continue
+		}
+		v, err := n.Const().Value()
+		if err != nil || v.Which() != schema.Value_Which_structValue {
+			continue
+		}
+		sv, err := v.StructValuePtr()
+		if err != nil {
+			f.Fatal(err)
+		}
+		cmsg, err := capnp.CopyToNewMessage(sv)
+		if err != nil {
+			f.Fatal(err)
+		}
+		seed, err := cmsg.Marshal()
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(seed, typ.StructType().TypeId() == valueTypeID)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte, isValue bool) {
+		msg, err := capnp.Unmarshal(data)
+		if err != nil {
+			return
+		}
+		root, err := msg.RootPtr()
+		if err != nil {
+			return
+		}
+		tid := keyValueTypeID
+		if isValue {
+			tid = valueTypeID
+		}
+		enc := NewEncoder(ioutil.Discard)
+		enc.UseRegistry(reg)
+		enc.Encode(tid, root.Struct())
+	})
+}
@@ -35,6 +35,15 @@ type Encoder struct {
 	w     errWriter
 	tmp   []byte
 	nodes nodemap.Map
+
+	indent     string
+	omitDefs   bool
+	maxDepth   int
+	maxListLen int
+	annotate   bool
+	raw        bool
+
+	depth int // number of structs and lists being written
 }
 
 // NewEncoder returns a new encoder that writes to w.
@@ -48,11 +57,62 @@ func (enc *Encoder) UseRegistry(reg *schemas.Registry) {
 	enc.nodes.UseRegistry(reg)
 }
 
+// SetIndent makes the encoder write each struct field and each element
+// of a list of pointers on its own line, indented by one copy of indent
+// for each level of nesting.  Lists of numbers, bools, and enums stay
+// on one line.  An empty indent, the default, writes each value on a
+// single line.
+func (enc *Encoder) SetIndent(indent string) {
+	enc.indent = indent
+}
+
+// SetPrintDefaults sets whether the encoder writes fields that hold
+// their default values.  By default it writes every field.  The active
+// member of a union is always written.
+func (enc *Encoder) SetPrintDefaults(print bool) {
+	enc.omitDefs = !print
+}
+
+// SetMaxDepth limits how deeply nested the structs and lists that the
+// encoder writes may be: values nested more than n levels deep are
+// written as "...".  Zero, the default, means no limit.
+func (enc *Encoder) SetMaxDepth(n int) {
+	enc.maxDepth = n
+}
+
+// SetMaxListLen limits the encoder to writing the first n elements of
+// each list, followed by a count of the elements left out.  Zero, the
+// default, means no limit.
+func (enc *Encoder) SetMaxListLen(n int) {
+	enc.maxListLen = n
+}
+
+// SetAnnotate sets whether the encoder adds detail that the text format
+// can't express.  With annotation, capability pointers are written as
+// their index in the message's capability table and the type of the
+// client there, like <capability 2: *rpc.importClient>, and enum values
+// that the schema doesn't name are marked as unknown enumerants instead
+// of being written as bare numbers.  Annotated output can't be decoded.
+func (enc *Encoder) SetAnnotate(annotate bool) {
+	enc.annotate = annotate
+}
+
+// SetRawFallback sets whether the encoder writes values whose type it
+// can't find in its registry, and AnyPointer values, as raw pointer
+// structure: structs as their data section and pointers, and lists as
+// their elements.  Without the fallback, which is the default, a
+// missing struct type is an error and AnyPointer values are written as
+// a placeholder.
+func (enc *Encoder) SetRawFallback(raw bool) {
+	enc.raw = raw
+}
+
 // Encode writes the text representation of s to the stream.
 func (enc *Encoder) Encode(typeID uint64, s capnp.Struct) error {
 	if enc.w.err != nil {
 		return enc.w.err
 	}
+	enc.depth = 0
 	err := enc.marshalStruct(typeID, s)
 	if err != nil {
 		return err
@@ -60,6 +120,53 @@ func (enc *Encoder) Encode(typeID uint64, s capnp.Struct) error {
 	return enc.w.err
 }
 
+// open starts writing a struct or list, which is closed by c.
+func (enc *Encoder) open(c byte) {
+	enc.w.WriteByte(c)
+	enc.depth++
+}
+
+// sep starts the ith item of the innermost struct or list, on its own
+// line if multiline is true.
+func (enc *Encoder) sep(i int, multiline bool) {
+	if i > 0 {
+		if multiline {
+			enc.w.WriteByte(',')
+		} else {
+			enc.w.WriteString(", ")
+		}
+	}
+	if multiline {
+		enc.newline(enc.depth)
+	}
+}
+
+// close finishes writing a struct or list of n items.
+func (enc *Encoder) close(c byte, n int, multiline bool) {
+	enc.depth--
+	if multiline && n > 0 {
+		enc.newline(enc.depth)
+	}
+	enc.w.WriteByte(c)
+}
+
+func (enc *Encoder) newline(depth int) {
+	enc.w.WriteByte('\n')
+	for i := 0; i < depth; i++ {
+		enc.w.WriteString(enc.indent)
+	}
+}
+
+// elide writes "..." and returns true if a struct or list should not
+// be written because it is nested too deeply.
+func (enc *Encoder) elide() bool {
+	if enc.maxDepth <= 0 || enc.depth < enc.maxDepth {
+		return false
+	}
+	enc.w.WriteString("...")
+	return true
+}
+
 func (enc *Encoder) marshalBool(v bool) {
 	if v {
 		enc.w.WriteString("true")
@@ -129,7 +236,7 @@ func (enc *Encoder) marshalText(t []byte) {
 }
 
 func needsEscape(b byte) bool {
-	return b < 0x20 || b >= 0x7f
+	return b < 0x20 || b >= 0x7f || b == '"' || b == '\\'
 }
 
 func hexDigit(b byte) byte {
@@ -139,30 +246,39 @@ func hexDigit(b byte) byte {
 
 func (enc *Encoder) marshalStruct(typeID uint64, s capnp.Struct) error {
 	n, err := enc.nodes.Find(typeID)
+	if enc.raw && (schemas.IsNotFound(err) || err == nil && !n.IsValid()) {
+		return enc.marshalRawStruct(typeID, s)
+	}
 	if err != nil {
 		return err
 	}
 	if !n.IsValid() || n.Which() != schema.Node_Which_structNode {
 		return fmt.Errorf("cannot find struct type %#x", typeID)
 	}
+	if enc.elide() {
+		return nil
+	}
 	var discriminant uint16
 	if n.StructNode().DiscriminantCount() > 0 {
 		discriminant = s.Uint16(capnp.DataOffset(n.StructNode().DiscriminantOffset() * 2))
 	}
-	enc.w.WriteByte('(')
+	multiline := enc.indent != ""
+	enc.open('(')
 	fields := codeOrderFields(n.StructNode())
-	first := true
+	i := 0
 	for _, f := range fields {
 		if !(f.Which() == schema.Field_Which_slot || f.Which() == schema.Field_Which_group) {
 			continue
 		}
-		if dv := f.DiscriminantValue(); !(dv == schema.Field_noDiscriminant || dv == discriminant) {
+		dv := f.DiscriminantValue()
+		if !(dv == schema.Field_noDiscriminant || dv == discriminant) {
 			continue
 		}
-		if !first {
-			enc.w.WriteString(", ")
+		if enc.omitDefs && dv == schema.Field_noDiscriminant && enc.isDefault(s, f) {
+			continue
 		}
-		first = false
+		enc.sep(i, multiline)
+		i++
 		name, err := f.NameBytes()
 		if err != nil {
 			return err
@@ -180,10 +296,55 @@ func (enc *Encoder) marshalStruct(typeID uint64, s capnp.Struct) error {
 			}
 		}
 	}
-	enc.w.WriteByte(')')
+	enc.close(')', i, multiline)
 	return nil
 }
 
+// isDefault reports whether the field f of s holds its default value.
+// Since values are stored XORed with their defaults, this is the same
+// as the field being zero.
+func (enc *Encoder) isDefault(s capnp.Struct, f schema.Field) bool {
+	switch f.Which() {
+	case schema.Field_Which_slot:
+		typ, err := f.Slot().Type()
+		if err != nil {
+			return false
+		}
+		off := f.Slot().Offset()
+		switch typ.Which() {
+		case schema.Type_Which_void:
+			return true
+		case schema.Type_Which_bool:
+			return !s.Bit(capnp.BitOffset(off))
+		case schema.Type_Which_int8, schema.Type_Which_uint8:
+			return s.Uint8(capnp.DataOffset(off)) == 0
+		case schema.Type_Which_int16, schema.Type_Which_uint16, schema.Type_Which_enum:
+			return s.Uint16(capnp.DataOffset(off*2)) == 0
+		case schema.Type_Which_int32, schema.Type_Which_uint32, schema.Type_Which_float32:
+			return s.Uint32(capnp.DataOffset(off*4)) == 0
+		case schema.Type_Which_int64, schema.Type_Which_uint64, schema.Type_Which_float64:
+			return s.Uint64(capnp.DataOffset(off*8)) == 0
+		default:
+			p, err := s.Ptr(uint16(off))
+			return err == nil && !p.IsValid()
+		}
+	case schema.Field_Which_group:
+		n, err := enc.nodes.Find(f.Group().TypeId())
+		if err != nil || n.Which() != schema.Node_Which_structNode {
+			return false
+		}
+		if n.StructNode().DiscriminantCount() > 0 && s.Uint16(capnp.DataOffset(n.StructNode().DiscriminantOffset()*2)) != 0 {
+			return false
+		}
+		for _, gf := range codeOrderFields(n.StructNode()) {
+			if dv := gf.DiscriminantValue(); (dv == schema.Field_noDiscriminant || dv == 0) && !enc.isDefault(s, gf) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 func (enc *Encoder) marshalFieldValue(s capnp.Struct, f schema.Field) error {
 	typ, err := f.Slot().Type()
 	if err != nil {
@@ -292,10 +453,15 @@ func (enc *Encoder) marshalFieldValue(s capnp.Struct, f schema.Field) error {
 		v := s.Uint16(capnp.DataOffset(f.Slot().Offset() * 2))
 		d := dv.Uint16()
 		return enc.marshalEnum(typ.Enum().TypeId(), v^d)
-	case schema.Type_Which_interface:
-		enc.w.WriteString(interfaceMarker)
-	case schema.Type_Which_anyPointer:
-		enc.w.WriteString(anyPointerMarker)
+	case schema.Type_Which_interface, schema.Type_Which_anyPointer:
+		var p capnp.Ptr
+		if enc.showsPtr(typ) {
+			var err error
+			if p, err = s.Ptr(uint16(f.Slot().Offset())); err != nil {
+				return err
+			}
+		}
+		return enc.marshalPtr(typ, p)
 	default:
 		return fmt.Errorf("unknown field type %v", typ.Which())
 	}
@@ -314,188 +480,319 @@ func codeOrderFields(s schema.Node_structNode) []schema.Field {
 }
 
 func (enc *Encoder) marshalList(elem schema.Type, l capnp.List) error {
-	enc.w.WriteByte('[')
+	multiline := false
+	if enc.indent != "" {
+		switch elem.Which() {
+		case schema.Type_Which_text, schema.Type_Which_data, schema.Type_Which_structType,
+			schema.Type_Which_list, schema.Type_Which_interface, schema.Type_Which_anyPointer:
+			multiline = true
+		}
+	}
 	switch elem.Which() {
 	case schema.Type_Which_void:
-		for i := 0; i < l.Len(); i++ {
-			if i > 0 {
-				enc.w.WriteString(", ")
-			}
+		return enc.marshalItems(l.Len(), multiline, func(i int) error {
 			enc.w.WriteString(voidMarker)
-		}
+			return nil
+		})
 	case schema.Type_Which_bool:
 		bl := capnp.BitList{List: l}
-		for i := 0; i < bl.Len(); i++ {
-			if i > 0 {
-				enc.w.WriteString(", ")
-			}
+		return enc.marshalItems(bl.Len(), multiline, func(i int) error {
 			enc.marshalBool(bl.At(i))
-		}
+			return nil
+		})
 	case schema.Type_Which_int8:
 		il := capnp.Int8List{List: l}
-		for i := 0; i < il.Len(); i++ {
-			if i > 0 {
-				enc.w.WriteString(", ")
-			}
+		return enc.marshalItems(il.Len(), multiline, func(i int) error {
 			enc.marshalInt(int64(il.At(i)))
-		}
+			return nil
+		})
 	case schema.Type_Which_int16:
 		il := capnp.Int16List{List: l}
-		for i := 0; i < il.Len(); i++ {
-			if i > 0 {
-				enc.w.WriteString(", ")
-			}
+		return enc.marshalItems(il.Len(), multiline, func(i int) error {
 			enc.marshalInt(int64(il.At(i)))
-		}
+			return nil
+		})
 	case schema.Type_Which_int32:
 		il := capnp.Int32List{List: l}
-		for i := 0; i < il.Len(); i++ {
-			if i > 0 {
-				enc.w.WriteString(", ")
-			}
+		return enc.marshalItems(il.Len(), multiline, func(i int) error {
 			enc.marshalInt(int64(il.At(i)))
-		}
+			return nil
+		})
 	case schema.Type_Which_int64:
 		il := capnp.Int64List{List: l}
-		for i := 0; i < il.Len(); i++ {
-			if i > 0 {
-				enc.w.WriteString(", ")
-			}
+		return enc.marshalItems(il.Len(), multiline, func(i int) error {
 			enc.marshalInt(il.At(i))
-		}
+			return nil
+		})
 	case schema.Type_Which_uint8:
 		il := capnp.UInt8List{List: l}
-		for i := 0; i < il.Len(); i++ {
-			if i > 0 {
-				enc.w.WriteString(", ")
-			}
+		return enc.marshalItems(il.Len(), multiline, func(i int) error {
 			enc.marshalUint(uint64(il.At(i)))
-		}
+			return nil
+		})
 	case schema.Type_Which_uint16:
 		il := capnp.UInt16List{List: l}
-		for i := 0; i < il.Len(); i++ {
-			if i > 0 {
-				enc.w.WriteString(", ")
-			}
+		return enc.marshalItems(il.Len(), multiline, func(i int) error {
 			enc.marshalUint(uint64(il.At(i)))
-		}
+			return nil
+		})
 	case schema.Type_Which_uint32:
 		il := capnp.UInt32List{List: l}
-		for i := 0; i < il.Len(); i++ {
-			if i > 0 {
-				enc.w.WriteString(", ")
-			}
+		return enc.marshalItems(il.Len(), multiline, func(i int) error {
 			enc.marshalUint(uint64(il.At(i)))
-		}
+			return nil
+		})
 	case schema.Type_Which_uint64:
 		il := capnp.UInt64List{List: l}
-		for i := 0; i < il.Len(); i++ {
-			if i > 0 {
-				enc.w.WriteString(", ")
-			}
+		return enc.marshalItems(il.Len(), multiline, func(i int) error {
 			enc.marshalUint(il.At(i))
-		}
+			return nil
+		})
 	case schema.Type_Which_float32:
 		fl := capnp.Float32List{List: l}
-		for i := 0; i < fl.Len(); i++ {
-			if i > 0 {
-				enc.w.WriteString(", ")
-			}
+		return enc.marshalItems(fl.Len(), multiline, func(i int) error {
 			enc.marshalFloat32(fl.At(i))
-		}
+			return nil
+		})
 	case schema.Type_Which_float64:
 		fl := capnp.Float64List{List: l}
-		for i := 0; i < fl.Len(); i++ {
-			if i > 0 {
-				enc.w.WriteString(", ")
-			}
+		return enc.marshalItems(fl.Len(), multiline, func(i int) error {
 			enc.marshalFloat64(fl.At(i))
-		}
+			return nil
+		})
 	case schema.Type_Which_data:
 		dl := capnp.DataList{List: l}
-		for i := 0; i < dl.Len(); i++ {
-			if i > 0 {
-				enc.w.WriteString(", ")
-			}
+		return enc.marshalItems(dl.Len(), multiline, func(i int) error {
 			d, err := dl.At(i)
 			if err != nil {
 				return err
 			}
 			enc.marshalText(d)
-		}
+			return nil
+		})
 	case schema.Type_Which_text:
 		tl := capnp.TextList{List: l}
-		for i := 0; i < tl.Len(); i++ {
-			if i > 0 {
-				enc.w.WriteString(", ")
-			}
+		return enc.marshalItems(tl.Len(), multiline, func(i int) error {
 			t, err := tl.BytesAt(i)
 			if err != nil {
 				return err
 			}
 			enc.marshalText(t)
-		}
+			return nil
+		})
 	case schema.Type_Which_structType:
-		for i := 0; i < l.Len(); i++ {
-			if i > 0 {
-				enc.w.WriteString(", ")
-			}
-			err := enc.marshalStruct(elem.StructType().TypeId(), l.Struct(i))
-			if err != nil {
-				return err
-			}
-		}
+		return enc.marshalItems(l.Len(), multiline, func(i int) error {
+			return enc.marshalStruct(elem.StructType().TypeId(), l.Struct(i))
+		})
 	case schema.Type_Which_list:
 		ee, err := elem.List().ElementType()
 		if err != nil {
 			return err
 		}
-		for i := 0; i < l.Len(); i++ {
-			if i > 0 {
-				enc.w.WriteString(", ")
-			}
+		return enc.marshalItems(l.Len(), multiline, func(i int) error {
 			p, err := capnp.PointerList{List: l}.PtrAt(i)
 			if err != nil {
 				return err
 			}
-			err = enc.marshalList(ee, p.List())
-			if err != nil {
-				return err
-			}
-		}
+			return enc.marshalList(ee, p.List())
+		})
 	case schema.Type_Which_enum:
 		il := capnp.UInt16List{List: l}
 		typ := elem.Enum().TypeId()
 		// TODO(light): only search for node once
-		for i := 0; i < il.Len(); i++ {
-			if i > 0 {
-				enc.w.WriteString(", ")
-			}
-			enc.marshalEnum(typ, il.At(i))
-		}
-	case schema.Type_Which_interface:
-		for i := 0; i < l.Len(); i++ {
-			if i > 0 {
-				enc.w.WriteString(", ")
+		return enc.marshalItems(il.Len(), multiline, func(i int) error {
+			return enc.marshalEnum(typ, il.At(i))
+		})
+	case schema.Type_Which_interface, schema.Type_Which_anyPointer:
+		return enc.marshalItems(l.Len(), multiline, func(i int) error {
+			var p capnp.Ptr
+			if enc.showsPtr(elem) {
+				var err error
+				if p, err = (capnp.PointerList{List: l}).PtrAt(i); err != nil {
+					return err
+				}
 			}
-			enc.w.WriteString(interfaceMarker)
-		}
-	case schema.Type_Which_anyPointer:
-		for i := 0; i < l.Len(); i++ {
-			if i > 0 {
-				enc.w.WriteString(", ")
-			}
-			enc.w.WriteString(anyPointerMarker)
-		}
+			return enc.marshalPtr(elem, p)
+		})
 	default:
 		return fmt.Errorf("unknown list type %v", elem.Which())
 	}
-	enc.w.WriteByte(']')
+}
+
+// marshalItems writes a list of n items, calling f to write each one.
+func (enc *Encoder) marshalItems(n int, multiline bool, f func(i int) error) error {
+	if enc.elide() {
+		return nil
+	}
+	shown := n
+	if enc.maxListLen > 0 && shown > enc.maxListLen {
+		shown = enc.maxListLen
+	}
+	enc.open('[')
+	for i := 0; i < shown; i++ {
+		enc.sep(i, multiline)
+		if err := f(i); err != nil {
+			return err
+		}
+	}
+	if shown < n {
+		enc.sep(shown, multiline)
+		enc.w.WriteString("... (")
+		enc.marshalInt(int64(n - shown))
+		enc.w.WriteString(" more)")
+	}
+	enc.close(']', n, multiline)
 	return nil
 }
 
+// showsPtr reports whether an interface or AnyPointer value of type typ
+// is written from its pointer rather than as a placeholder.  Callers
+// skip reading the pointer otherwise, so that a bad pointer or the read
+// limit doesn't fail output that wouldn't show it.
+func (enc *Encoder) showsPtr(typ schema.Type) bool {
+	if typ.Which() == schema.Type_Which_interface {
+		return enc.annotate
+	}
+	return enc.raw
+}
+
+// marshalPtr writes an interface or AnyPointer value.  p is only used
+// if showsPtr(typ) is true.
+func (enc *Encoder) marshalPtr(typ schema.Type, p capnp.Ptr) error {
+	switch {
+	case typ.Which() == schema.Type_Which_interface && enc.showsPtr(typ):
+		enc.marshalCap(p.Interface())
+	case typ.Which() == schema.Type_Which_interface:
+		enc.w.WriteString(interfaceMarker)
+	case enc.showsPtr(typ):
+		return enc.marshalRaw(p)
+	default:
+		enc.w.WriteString(anyPointerMarker)
+	}
+	return nil
+}
+
+// marshalCap writes a capability pointer as its index in the message's
+// capability table and, if annotating, the type of the client there.
+func (enc *Encoder) marshalCap(i capnp.Interface) {
+	if !i.IsValid() {
+		enc.w.WriteString("null")
+		return
+	}
+	enc.w.WriteString("<capability ")
+	enc.marshalUint(uint64(i.Capability()))
+	if c := i.Client(); c != nil && enc.annotate {
+		enc.w.WriteString(": ")
+		enc.w.WriteString(fmt.Sprintf("%T", c))
+	}
+	enc.w.WriteByte('>')
+}
+
+// marshalRaw writes the pointer structure under p without a schema.
+func (enc *Encoder) marshalRaw(p capnp.Ptr) error {
+	switch {
+	case !p.IsValid():
+		enc.w.WriteString("null")
+	case p.Interface().IsValid():
+		enc.marshalCap(p.Interface())
+	case p.Struct().IsValid():
+		return enc.marshalRawStruct(0, p.Struct())
+	default:
+		return enc.marshalRawList(p.List())
+	}
+	return nil
+}
+
+// marshalRawStruct writes s as its data section and pointers, noting
+// its type ID if it is known.
+func (enc *Encoder) marshalRawStruct(typeID uint64, s capnp.Struct) error {
+	enc.w.WriteString("<struct")
+	if typeID != 0 {
+		enc.w.WriteString(" @0x")
+		enc.tmp = strconv.AppendUint(enc.tmp[:0], typeID, 16)
+		enc.w.Write(enc.tmp)
+	}
+	enc.w.WriteByte('>')
+	if enc.elide() {
+		return nil
+	}
+	multiline := enc.indent != ""
+	sz := s.Size()
+	enc.open('(')
+	enc.sep(0, multiline)
+	enc.w.WriteString("data = 0x\"")
+	for i := capnp.Size(0); i < sz.DataSize; i++ {
+		b := s.Uint8(capnp.DataOffset(i))
+		enc.w.WriteByte(hexDigit(b / 16))
+		enc.w.WriteByte(hexDigit(b % 16))
+	}
+	enc.w.WriteByte('"')
+	enc.sep(1, multiline)
+	enc.w.WriteString("pointers = ")
+	err := enc.marshalItems(int(sz.PointerCount), multiline, func(i int) error {
+		p, err := s.Ptr(uint16(i))
+		if err != nil {
+			return err
+		}
+		return enc.marshalRaw(p)
+	})
+	if err != nil {
+		return err
+	}
+	enc.close(')', 2, multiline)
+	return nil
+}
+
+// marshalRawList writes the elements of l according to its encoding:
+// structs and pointers are written raw, and data elements are written
+// as unsigned integers.
+func (enc *Encoder) marshalRawList(l capnp.List) error {
+	sz := l.ElementSize()
+	multiline := enc.indent != "" && (l.IsComposite() || sz.PointerCount > 0)
+	switch {
+	case l.IsComposite():
+		return enc.marshalItems(l.Len(), multiline, func(i int) error {
+			return enc.marshalRawStruct(0, l.Struct(i))
+		})
+	case l.IsBitList():
+		bl := capnp.BitList{List: l}
+		return enc.marshalItems(bl.Len(), multiline, func(i int) error {
+			enc.marshalBool(bl.At(i))
+			return nil
+		})
+	case sz.PointerCount > 0:
+		pl := capnp.PointerList{List: l}
+		return enc.marshalItems(pl.Len(), multiline, func(i int) error {
+			p, err := pl.PtrAt(i)
+			if err != nil {
+				return err
+			}
+			return enc.marshalRaw(p)
+		})
+	}
+	return enc.marshalItems(l.Len(), multiline, func(i int) error {
+		switch sz.DataSize {
+		case 0:
+			enc.w.WriteString(voidMarker)
+		case 1:
+			enc.marshalUint(uint64(capnp.UInt8List{List: l}.At(i)))
+		case 2:
+			enc.marshalUint(uint64(capnp.UInt16List{List: l}.At(i)))
+		case 4:
+			enc.marshalUint(uint64(capnp.UInt32List{List: l}.At(i)))
+		default:
+			enc.marshalUint(capnp.UInt64List{List: l}.At(i))
+		}
+		return nil
+	})
+}
+
 func (enc *Encoder) marshalEnum(typ uint64, val uint16) error {
 	n, err := enc.nodes.Find(typ)
+	if enc.raw && (schemas.IsNotFound(err) || err == nil && !n.IsValid()) {
+		enc.marshalUint(uint64(val))
+		return nil
+	}
 	if err != nil {
 		return err
 	}
@@ -507,7 +804,13 @@ func (enc *Encoder) marshalEnum(typ uint64, val uint16) error {
 		return err
 	}
 	if int(val) >= enums.Len() {
-		enc.marshalUint(uint64(val))
+		if enc.annotate {
+			enc.w.WriteString("<unknown enumerant ")
+			enc.marshalUint(uint64(val))
+			enc.w.WriteByte('>')
+		} else {
+			enc.marshalUint(uint64(val))
+		}
 		return nil
 	}
 	name, err := enums.At(int(val)).NameBytes()
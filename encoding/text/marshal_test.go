@@ -2,12 +2,17 @@ package text
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"io/ioutil"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/compiler"
 	"zombiezen.com/go/capnproto2/schemas"
+	"zombiezen.com/go/capnproto2/schemas/loader"
 	"zombiezen.com/go/capnproto2/std/capnp/schema"
 )
 
@@ -125,3 +130,246 @@ func TestEncode(t *testing.T) {
 		}
 	}
 }
+
+func TestEncodeOptions(t *testing.T) {
+	const (
+		keyValue = 0x8df8bc5abdc060a6
+		value    = 0xd3602730c572a43b
+	)
+	tests := []struct {
+		name   string
+		opts   func(enc *Encoder)
+		typeID uint64
+		text   string
+		want   string
+	}{
+		{
+			name:   "indent",
+			opts:   func(enc *Encoder) { enc.SetIndent("  ") },
+			typeID: value,
+			text:   `(map = [(key = "foo", value = (int8List = [1, 2])), (key = "bar", value = (map = []))])`,
+			want: "(\n" +
+				"  map = [\n" +
+				"    (\n" +
+				"      key = \"foo\",\n" +
+				"      value = (\n" +
+				"        int8List = [1, 2]\n" +
+				"      )\n" +
+				"    ),\n" +
+				"    (\n" +
+				"      key = \"bar\",\n" +
+				"      value = (\n" +
+				"        map = []\n" +
+				"      )\n" +
+				"    )\n" +
+				"  ]\n" +
+				")",
+		},
+		{
+			name:   "omit defaults",
+			opts:   func(enc *Encoder) { enc.SetPrintDefaults(false) },
+			typeID: keyValue,
+			text:   `(value = (int8 = 0))`,
+			want:   `(value = (int8 = 0))`,
+		},
+		{
+			name:   "omit defaults of empty struct",
+			opts:   func(enc *Encoder) { enc.SetPrintDefaults(false) },
+			typeID: keyValue,
+			text:   `()`,
+			want:   `()`,
+		},
+		{
+			name:   "max depth",
+			opts:   func(enc *Encoder) { enc.SetMaxDepth(2) },
+			typeID: keyValue,
+			text:   `(key = "a", value = (map = [(key = "b")]))`,
+			want:   `(key = "a", value = (map = ...))`,
+		},
+		{
+			name:   "max list length",
+			opts:   func(enc *Encoder) { enc.SetMaxListLen(2) },
+			typeID: value,
+			text:   `(int8List = [1, -2, 3, 4])`,
+			want:   `(int8List = [1, -2, ... (2 more)])`,
+		},
+		{
+			name:   "max list length not reached",
+			opts:   func(enc *Encoder) { enc.SetMaxListLen(2) },
+			typeID: value,
+			text:   `(int8List = [1, -2])`,
+			want:   `(int8List = [1, -2])`,
+		},
+		{
+			name:   "unknown enumerant",
+			opts:   func(enc *Encoder) {},
+			typeID: value,
+			text:   `(cheeseList = [gouda, 7])`,
+			want:   `(cheeseList = [gouda, 7])`,
+		},
+		{
+			name:   "annotated unknown enumerant",
+			opts:   func(enc *Encoder) { enc.SetAnnotate(true) },
+			typeID: value,
+			text:   `(cheeseList = [gouda, 7])`,
+			want:   `(cheeseList = [gouda, <unknown enumerant 7>])`,
+		},
+	}
+	reg := newTestRegistry(t)
+	for _, test := range tests {
+		dec := NewDecoder(strings.NewReader(test.text))
+		dec.UseRegistry(reg)
+		s, err := dec.Decode(test.typeID)
+		if err != nil {
+			t.Errorf("%s: Decode(%q): %v", test.name, test.text, err)
+			continue
+		}
+		buf := new(bytes.Buffer)
+		enc := NewEncoder(buf)
+		enc.UseRegistry(reg)
+		test.opts(enc)
+		if err := enc.Encode(test.typeID, s); err != nil {
+			t.Errorf("%s: Encode: %v", test.name, err)
+			continue
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%s: Encode(%q) = %q; want %q", test.name, test.text, got, test.want)
+		}
+	}
+}
+
+func TestEncodeRawFallback(t *testing.T) {
+	const unknownType = 0xabcdef0123456789
+	_, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := capnp.NewRootStruct(seg, capnp.ObjectSize{DataSize: 8, PointerCount: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetUint32(0, 0xdeadbeef)
+	if err := s.SetText(0, "hi"); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := capnp.NewStruct(seg, capnp.ObjectSize{DataSize: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub.SetUint8(0, 1)
+	if err := s.SetPtr(1, sub.ToPtr()); err != nil {
+		t.Fatal(err)
+	}
+	c := seg.Message().AddCap(capnp.ErrorClient(errors.New("x")))
+	if err := s.SetPtr(2, capnp.NewInterface(seg, c).ToPtr()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts func(enc *Encoder)
+		want string
+	}{
+		{
+			name: "raw",
+			opts: func(enc *Encoder) { enc.SetRawFallback(true) },
+			want: `<struct @0xabcdef0123456789>(data = 0x"efbeadde00000000", pointers = [[104, 105, 0], <struct>(data = 0x"0100000000000000", pointers = []), <capability 0>, null])`,
+		},
+		{
+			name: "raw and annotated",
+			opts: func(enc *Encoder) {
+				enc.SetRawFallback(true)
+				enc.SetAnnotate(true)
+			},
+			want: `<struct @0xabcdef0123456789>(data = 0x"efbeadde00000000", pointers = [[104, 105, 0], <struct>(data = 0x"0100000000000000", pointers = []), <capability 0: capnp.errorClient>, null])`,
+		},
+	}
+	for _, test := range tests {
+		buf := new(bytes.Buffer)
+		enc := NewEncoder(buf)
+		enc.UseRegistry(new(schemas.Registry))
+		test.opts(enc)
+		if err := enc.Encode(unknownType, s); err != nil {
+			t.Errorf("%s: Encode: %v", test.name, err)
+			continue
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%s: Encode = %q; want %q", test.name, got, test.want)
+		}
+	}
+
+	enc := NewEncoder(ioutil.Discard)
+	enc.UseRegistry(new(schemas.Registry))
+	if err := enc.Encode(unknownType, s); !schemas.IsNotFound(err) {
+		t.Errorf("Encode without fallback = %v; want not found error", err)
+	}
+}
+
+func TestEncodePlaceholderSkipsPointer(t *testing.T) {
+	const (
+		holder = 0xf1e2d3c4b5a69788
+		src    = `@0xe8d4d1f2c3b4a596;
+struct Holder @0xf1e2d3c4b5a69788 {
+  any @0 :AnyPointer;
+  cap @1 :Iface;
+  anys @2 :List(AnyPointer);
+}
+interface Iface {}
+`
+	)
+	req, err := compiler.Compile(&compiler.Options{
+		ReadFile: func(string) ([]byte, error) { return []byte(src), nil },
+	}, "holder.capnp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := req.Struct.Segment().Message().Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := new(schemas.Registry)
+	if _, err := loader.RegisterRequest(reg, data); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fill the any field and the list element with pointers to structs
+	// that run past the end of the segment.
+	_, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := capnp.NewRootStruct(seg, capnp.ObjectSize{PointerCount: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := capnp.NewPointerList(seg, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetPtr(2, l.ToPtr()); err != nil {
+		t.Fatal(err)
+	}
+	// A struct pointer with 100 data words.
+	const badPtr = 100 << 32
+
+	binary.LittleEndian.PutUint64(seg.Data()[8:], badPtr)  // any
+	binary.LittleEndian.PutUint64(seg.Data()[32:], badPtr) // anys[0]
+
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf)
+	enc.UseRegistry(reg)
+	if err := enc.Encode(holder, s); err != nil {
+		t.Fatal("Encode:", err)
+	}
+	const want = `(any = <opaque pointer>, cap = <external capability>, anys = [<opaque pointer>])`
+	if got := buf.String(); got != want {
+		t.Errorf("Encode = %q; want %q", got, want)
+	}
+
+	enc = NewEncoder(ioutil.Discard)
+	enc.UseRegistry(reg)
+	enc.SetRawFallback(true)
+	if err := enc.Encode(holder, s); err == nil {
+		t.Error("Encode with raw fallback succeeded; want bad pointer error")
+	}
+}
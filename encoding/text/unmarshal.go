@@ -0,0 +1,883 @@
+package text
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+
+	"zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/internal/nodemap"
+	"zombiezen.com/go/capnproto2/schemas"
+	"zombiezen.com/go/capnproto2/std/capnp/schema"
+)
+
+// Unmarshal parses the text representation of a struct, as written by
+// Marshal, into the root of a new message.
+func Unmarshal(typeID uint64, text string) (capnp.Struct, error) {
+	return NewDecoder(strings.NewReader(text)).Decode(typeID)
+}
+
+// A Decoder reads the text format of Cap'n Proto messages from an
+// input stream.  The stream is a sequence of struct values separated by
+// whitespace or comments, which start with '#' and run to the end of
+// the line.
+//
+// Interface and AnyPointer values can't be represented in text, so the
+// markers that an Encoder writes for them decode as null pointers, as
+// does the identifier null for any pointer field.
+type Decoder struct {
+	r     io.Reader
+	p     *parser
+	nodes nodemap.Map
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// UseRegistry changes the registry that the decoder consults for
+// schemas from the default registry.
+func (dec *Decoder) UseRegistry(reg *schemas.Registry) {
+	dec.nodes.UseRegistry(reg)
+}
+
+// Decode reads the next struct value from the stream and builds it in
+// the root of a new message.  At the end of the stream, Decode returns
+// io.EOF.
+func (dec *Decoder) Decode(typeID uint64) (capnp.Struct, error) {
+	if dec.p == nil {
+		src, err := ioutil.ReadAll(dec.r)
+		if err != nil {
+			return capnp.Struct{}, err
+		}
+		dec.p = &parser{src: src}
+	}
+	dec.p.skipSpace()
+	if dec.p.off >= len(dec.p.src) {
+		return capnp.Struct{}, io.EOF
+	}
+	v, err := dec.p.value()
+	if err != nil {
+		return capnp.Struct{}, err
+	}
+	if v.kind != structValue {
+		return capnp.Struct{}, dec.p.errorf(v.off, "expected struct value")
+	}
+	n, err := dec.findStruct(typeID)
+	if err != nil {
+		return capnp.Struct{}, err
+	}
+	_, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		return capnp.Struct{}, err
+	}
+	s, err := capnp.NewRootStruct(seg, structSize(n))
+	if err != nil {
+		return capnp.Struct{}, err
+	}
+	if err := dec.fillStruct(n, s, v); err != nil {
+		return capnp.Struct{}, err
+	}
+	return s, nil
+}
+
+func (dec *Decoder) findStruct(typeID uint64) (schema.Node, error) {
+	n, err := dec.nodes.Find(typeID)
+	if err != nil {
+		return schema.Node{}, err
+	}
+	if !n.IsValid() || n.Which() != schema.Node_Which_structNode {
+		return schema.Node{}, fmt.Errorf("cannot find struct type %#x", typeID)
+	}
+	return n, nil
+}
+
+func structSize(n schema.Node) capnp.ObjectSize {
+	return capnp.ObjectSize{
+		DataSize:     capnp.Size(n.StructNode().DataWordCount()) * 8,
+		PointerCount: n.StructNode().PointerCount(),
+	}
+}
+
+func (dec *Decoder) fillStruct(n schema.Node, s capnp.Struct, v *textValue) error {
+	if v.kind != structValue {
+		return dec.p.errorf(v.off, "expected struct value")
+	}
+	fields, err := n.StructNode().Fields()
+	if err != nil {
+		return err
+	}
+	var union *textField
+	for i := range v.fields {
+		tf := &v.fields[i]
+		f, ok := findField(fields, tf.name)
+		if !ok {
+			name, _ := n.DisplayName()
+			return dec.p.errorf(tf.off, "%s has no field %q", name, tf.name)
+		}
+		if dv := f.DiscriminantValue(); dv != schema.Field_noDiscriminant {
+			if union != nil {
+				return dec.p.errorf(tf.off, "%s and %s are members of the same union", union.name, tf.name)
+			}
+			union = tf
+			s.SetUint16(capnp.DataOffset(n.StructNode().DiscriminantOffset()*2), dv)
+		}
+		switch f.Which() {
+		case schema.Field_Which_slot:
+			err = dec.fillField(s, f, tf.val)
+		case schema.Field_Which_group:
+			var g schema.Node
+			g, err = dec.findStruct(f.Group().TypeId())
+			if err == nil {
+				err = dec.fillStruct(g, s, tf.val)
+			}
+		default:
+			err = dec.p.errorf(tf.off, "unknown kind of field %s", tf.name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func findField(fields schema.Field_List, name string) (schema.Field, bool) {
+	for i := 0; i < fields.Len(); i++ {
+		f := fields.At(i)
+		if n, _ := f.NameBytes(); string(n) == name {
+			return f, true
+		}
+	}
+	return schema.Field{}, false
+}
+
+func (dec *Decoder) fillField(s capnp.Struct, f schema.Field, v *textValue) error {
+	typ, err := f.Slot().Type()
+	if err != nil {
+		return err
+	}
+	dv, err := f.Slot().DefaultValue()
+	if err != nil {
+		return err
+	}
+	off := f.Slot().Offset()
+	switch typ.Which() {
+	case schema.Type_Which_void:
+		return dec.void(v)
+	case schema.Type_Which_bool:
+		b, err := dec.bool(v)
+		if err != nil {
+			return err
+		}
+		s.SetBit(capnp.BitOffset(off), b != dv.Bool())
+	case schema.Type_Which_int8:
+		i, err := dec.int(v, 8)
+		if err != nil {
+			return err
+		}
+		s.SetUint8(capnp.DataOffset(off), uint8(i)^uint8(dv.Int8()))
+	case schema.Type_Which_int16:
+		i, err := dec.int(v, 16)
+		if err != nil {
+			return err
+		}
+		s.SetUint16(capnp.DataOffset(off*2), uint16(i)^uint16(dv.Int16()))
+	case schema.Type_Which_int32:
+		i, err := dec.int(v, 32)
+		if err != nil {
+			return err
+		}
+		s.SetUint32(capnp.DataOffset(off*4), uint32(i)^uint32(dv.Int32()))
+	case schema.Type_Which_int64:
+		i, err := dec.int(v, 64)
+		if err != nil {
+			return err
+		}
+		s.SetUint64(capnp.DataOffset(off*8), uint64(i)^uint64(dv.Int64()))
+	case schema.Type_Which_uint8:
+		i, err := dec.uint(v, 8)
+		if err != nil {
+			return err
+		}
+		s.SetUint8(capnp.DataOffset(off), uint8(i)^dv.Uint8())
+	case schema.Type_Which_uint16:
+		i, err := dec.uint(v, 16)
+		if err != nil {
+			return err
+		}
+		s.SetUint16(capnp.DataOffset(off*2), uint16(i)^dv.Uint16())
+	case schema.Type_Which_uint32:
+		i, err := dec.uint(v, 32)
+		if err != nil {
+			return err
+		}
+		s.SetUint32(capnp.DataOffset(off*4), uint32(i)^dv.Uint32())
+	case schema.Type_Which_uint64:
+		i, err := dec.uint(v, 64)
+		if err != nil {
+			return err
+		}
+		s.SetUint64(capnp.DataOffset(off*8), i^dv.Uint64())
+	case schema.Type_Which_float32:
+		x, err := dec.float(v, 32)
+		if err != nil {
+			return err
+		}
+		s.SetUint32(capnp.DataOffset(off*4), math.Float32bits(float32(x))^math.Float32bits(dv.Float32()))
+	case schema.Type_Which_float64:
+		x, err := dec.float(v, 64)
+		if err != nil {
+			return err
+		}
+		s.SetUint64(capnp.DataOffset(off*8), math.Float64bits(x)^math.Float64bits(dv.Float64()))
+	case schema.Type_Which_enum:
+		e, err := dec.enum(typ.Enum().TypeId(), v)
+		if err != nil {
+			return err
+		}
+		s.SetUint16(capnp.DataOffset(off*2), e^dv.Uint16())
+	default:
+		if isNull(v) {
+			return nil
+		}
+		p, err := dec.pointer(s.Segment(), typ, v)
+		if err != nil {
+			return err
+		}
+		return s.SetPtr(uint16(off), p)
+	}
+	return nil
+}
+
+// pointer builds a pointer value of the given type in seg.
+func (dec *Decoder) pointer(seg *capnp.Segment, typ schema.Type, v *textValue) (capnp.Ptr, error) {
+	switch typ.Which() {
+	case schema.Type_Which_text:
+		if v.kind != stringValue {
+			return capnp.Ptr{}, dec.p.errorf(v.off, "expected text")
+		}
+		t, err := capnp.NewText(seg, v.s)
+		return t.List.ToPtr(), err
+	case schema.Type_Which_data:
+		if v.kind != stringValue {
+			return capnp.Ptr{}, dec.p.errorf(v.off, "expected data")
+		}
+		d, err := capnp.NewData(seg, []byte(v.s))
+		return d.List.ToPtr(), err
+	case schema.Type_Which_structType:
+		n, err := dec.findStruct(typ.StructType().TypeId())
+		if err != nil {
+			return capnp.Ptr{}, err
+		}
+		s, err := capnp.NewStruct(seg, structSize(n))
+		if err != nil {
+			return capnp.Ptr{}, err
+		}
+		if err := dec.fillStruct(n, s, v); err != nil {
+			return capnp.Ptr{}, err
+		}
+		return s.ToPtr(), nil
+	case schema.Type_Which_list:
+		elem, err := typ.List().ElementType()
+		if err != nil {
+			return capnp.Ptr{}, err
+		}
+		l, err := dec.list(seg, elem, v)
+		return l.ToPtr(), err
+	case schema.Type_Which_interface, schema.Type_Which_anyPointer:
+		return capnp.Ptr{}, dec.p.errorf(v.off, "cannot decode %v value from text", typ.Which())
+	default:
+		return capnp.Ptr{}, fmt.Errorf("unknown field type %v", typ.Which())
+	}
+}
+
+func (dec *Decoder) list(seg *capnp.Segment, elem schema.Type, v *textValue) (capnp.List, error) {
+	if v.kind != listValue {
+		return capnp.List{}, dec.p.errorf(v.off, "expected list")
+	}
+	if int64(len(v.elems)) > math.MaxInt32 {
+		return capnp.List{}, dec.p.errorf(v.off, "list too long")
+	}
+	n := int32(len(v.elems))
+	switch elem.Which() {
+	case schema.Type_Which_void:
+		for _, e := range v.elems {
+			if err := dec.void(e); err != nil {
+				return capnp.List{}, err
+			}
+		}
+		return capnp.NewVoidList(seg, n).List, nil
+	case schema.Type_Which_bool:
+		l, err := capnp.NewBitList(seg, n)
+		if err != nil {
+			return capnp.List{}, err
+		}
+		for i, e := range v.elems {
+			b, err := dec.bool(e)
+			if err != nil {
+				return capnp.List{}, err
+			}
+			l.Set(i, b)
+		}
+		return l.List, nil
+	case schema.Type_Which_int8:
+		l, err := capnp.NewInt8List(seg, n)
+		if err != nil {
+			return capnp.List{}, err
+		}
+		for i, e := range v.elems {
+			x, err := dec.int(e, 8)
+			if err != nil {
+				return capnp.List{}, err
+			}
+			l.Set(i, int8(x))
+		}
+		return l.List, nil
+	case schema.Type_Which_int16:
+		l, err := capnp.NewInt16List(seg, n)
+		if err != nil {
+			return capnp.List{}, err
+		}
+		for i, e := range v.elems {
+			x, err := dec.int(e, 16)
+			if err != nil {
+				return capnp.List{}, err
+			}
+			l.Set(i, int16(x))
+		}
+		return l.List, nil
+	case schema.Type_Which_int32:
+		l, err := capnp.NewInt32List(seg, n)
+		if err != nil {
+			return capnp.List{}, err
+		}
+		for i, e := range v.elems {
+			x, err := dec.int(e, 32)
+			if err != nil {
+				return capnp.List{}, err
+			}
+			l.Set(i, int32(x))
+		}
+		return l.List, nil
+	case schema.Type_Which_int64:
+		l, err := capnp.NewInt64List(seg, n)
+		if err != nil {
+			return capnp.List{}, err
+		}
+		for i, e := range v.elems {
+			x, err := dec.int(e, 64)
+			if err != nil {
+				return capnp.List{}, err
+			}
+			l.Set(i, x)
+		}
+		return l.List, nil
+	case schema.Type_Which_uint8:
+		l, err := capnp.NewUInt8List(seg, n)
+		if err != nil {
+			return capnp.List{}, err
+		}
+		for i, e := range v.elems {
+			x, err := dec.uint(e, 8)
+			if err != nil {
+				return capnp.List{}, err
+			}
+			l.Set(i, uint8(x))
+		}
+		return l.List, nil
+	case schema.Type_Which_uint16:
+		l, err := capnp.NewUInt16List(seg, n)
+		if err != nil {
+			return capnp.List{}, err
+		}
+		for i, e := range v.elems {
+			x, err := dec.uint(e, 16)
+			if err != nil {
+				return capnp.List{}, err
+			}
+			l.Set(i, uint16(x))
+		}
+		return l.List, nil
+	case schema.Type_Which_uint32:
+		l, err := capnp.NewUInt32List(seg, n)
+		if err != nil {
+			return capnp.List{}, err
+		}
+		for i, e := range v.elems {
+			x, err := dec.uint(e, 32)
+			if err != nil {
+				return capnp.List{}, err
+			}
+			l.Set(i, uint32(x))
+		}
+		return l.List, nil
+	case schema.Type_Which_uint64:
+		l, err := capnp.NewUInt64List(seg, n)
+		if err != nil {
+			return capnp.List{}, err
+		}
+		for i, e := range v.elems {
+			x, err := dec.uint(e, 64)
+			if err != nil {
+				return capnp.List{}, err
+			}
+			l.Set(i, x)
+		}
+		return l.List, nil
+	case schema.Type_Which_float32:
+		l, err := capnp.NewFloat32List(seg, n)
+		if err != nil {
+			return capnp.List{}, err
+		}
+		for i, e := range v.elems {
+			x, err := dec.float(e, 32)
+			if err != nil {
+				return capnp.List{}, err
+			}
+			l.Set(i, float32(x))
+		}
+		return l.List, nil
+	case schema.Type_Which_float64:
+		l, err := capnp.NewFloat64List(seg, n)
+		if err != nil {
+			return capnp.List{}, err
+		}
+		for i, e := range v.elems {
+			x, err := dec.float(e, 64)
+			if err != nil {
+				return capnp.List{}, err
+			}
+			l.Set(i, x)
+		}
+		return l.List, nil
+	case schema.Type_Which_enum:
+		l, err := capnp.NewUInt16List(seg, n)
+		if err != nil {
+			return capnp.List{}, err
+		}
+		for i, e := range v.elems {
+			x, err := dec.enum(elem.Enum().TypeId(), e)
+			if err != nil {
+				return capnp.List{}, err
+			}
+			l.Set(i, x)
+		}
+		return l.List, nil
+	case schema.Type_Which_structType:
+		sn, err := dec.findStruct(elem.StructType().TypeId())
+		if err != nil {
+			return capnp.List{}, err
+		}
+		l, err := capnp.NewCompositeList(seg, structSize(sn), n)
+		if err != nil {
+			return capnp.List{}, err
+		}
+		for i, e := range v.elems {
+			if err := dec.fillStruct(sn, l.Struct(i), e); err != nil {
+				return capnp.List{}, err
+			}
+		}
+		return l, nil
+	default:
+		l, err := capnp.NewPointerList(seg, n)
+		if err != nil {
+			return capnp.List{}, err
+		}
+		for i, e := range v.elems {
+			if isNull(e) {
+				continue
+			}
+			p, err := dec.pointer(seg, elem, e)
+			if err != nil {
+				return capnp.List{}, err
+			}
+			if err := l.SetPtr(i, p); err != nil {
+				return capnp.List{}, err
+			}
+		}
+		return l.List, nil
+	}
+}
+
+func (dec *Decoder) void(v *textValue) error {
+	if v.kind != identValue || v.s != voidMarker {
+		return dec.p.errorf(v.off, "expected void")
+	}
+	return nil
+}
+
+func (dec *Decoder) bool(v *textValue) (bool, error) {
+	if v.kind == identValue {
+		switch v.s {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	return false, dec.p.errorf(v.off, "expected true or false")
+}
+
+func (dec *Decoder) int(v *textValue, bits int) (int64, error) {
+	if v.kind != numberValue {
+		return 0, dec.p.errorf(v.off, "expected integer")
+	}
+	i, err := strconv.ParseInt(v.s, 0, bits)
+	if err != nil {
+		return 0, dec.p.errorf(v.off, "%s is not a valid int%d", v.s, bits)
+	}
+	return i, nil
+}
+
+func (dec *Decoder) uint(v *textValue, bits int) (uint64, error) {
+	if v.kind != numberValue {
+		return 0, dec.p.errorf(v.off, "expected integer")
+	}
+	i, err := strconv.ParseUint(v.s, 0, bits)
+	if err != nil {
+		return 0, dec.p.errorf(v.off, "%s is not a valid uint%d", v.s, bits)
+	}
+	return i, nil
+}
+
+func (dec *Decoder) float(v *textValue, bits int) (float64, error) {
+	if v.kind != numberValue && v.kind != identValue {
+		return 0, dec.p.errorf(v.off, "expected number")
+	}
+	// ParseFloat accepts inf and nan in any case, with an optional sign.
+	x, err := strconv.ParseFloat(v.s, bits)
+	if err != nil {
+		if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+			return x, nil
+		}
+		return 0, dec.p.errorf(v.off, "%s is not a valid float%d", v.s, bits)
+	}
+	return x, nil
+}
+
+func (dec *Decoder) enum(typeID uint64, v *textValue) (uint16, error) {
+	if v.kind == numberValue {
+		i, err := dec.uint(v, 16)
+		return uint16(i), err
+	}
+	if v.kind != identValue {
+		return 0, dec.p.errorf(v.off, "expected enumerant")
+	}
+	n, err := dec.nodes.Find(typeID)
+	if err != nil {
+		return 0, err
+	}
+	if n.Which() != schema.Node_Which_enum {
+		return 0, fmt.Errorf("unmarshaling enum of type @%#x: type is not an enum", typeID)
+	}
+	enums, err := n.Enum().Enumerants()
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < enums.Len(); i++ {
+		if name, _ := enums.At(i).NameBytes(); string(name) == v.s {
+			return uint16(i), nil
+		}
+	}
+	return 0, dec.p.errorf(v.off, "unknown enumerant %s", v.s)
+}
+
+func isNull(v *textValue) bool {
+	return v.kind == markerValue || v.kind == identValue && v.s == "null"
+}
+
+type valueKind int
+
+const (
+	identValue valueKind = iota
+	numberValue
+	stringValue
+	markerValue
+	listValue
+	structValue
+)
+
+// A textValue is a parsed value that hasn't been checked against a type.
+type textValue struct {
+	kind   valueKind
+	off    int    // byte offset in the input
+	s      string // identifier, number literal, or decoded string
+	elems  []*textValue
+	fields []textField
+}
+
+type textField struct {
+	name string
+	off  int
+	val  *textValue
+}
+
+// parser reads values from the text format.
+type parser struct {
+	src []byte
+	off int
+}
+
+// maxNesting is the deepest that lists and structs can be nested.
+const maxNesting = 64
+
+func (p *parser) value() (*textValue, error) {
+	return p.nestedValue(0)
+}
+
+func (p *parser) nestedValue(depth int) (*textValue, error) {
+	p.skipSpace()
+	if p.off >= len(p.src) {
+		return nil, p.errorf(p.off, "unexpected end of input")
+	}
+	if depth > maxNesting {
+		return nil, p.errorf(p.off, "values nested too deeply")
+	}
+	start := p.off
+	c := p.src[p.off]
+	switch {
+	case c == '(':
+		p.off++
+		v := &textValue{kind: structValue, off: start}
+		for {
+			p.skipSpace()
+			if p.consume(')') {
+				return v, nil
+			}
+			if len(v.fields) > 0 {
+				if !p.consume(',') {
+					return nil, p.errorf(p.off, "expected ',' or ')'")
+				}
+				p.skipSpace()
+				if p.consume(')') {
+					return v, nil
+				}
+			}
+			foff := p.off
+			name := p.ident()
+			if name == "" {
+				return nil, p.errorf(p.off, "expected field name")
+			}
+			p.skipSpace()
+			if !p.consume('=') {
+				return nil, p.errorf(p.off, "expected '=' after %s", name)
+			}
+			fv, err := p.nestedValue(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			v.fields = append(v.fields, textField{name: name, off: foff, val: fv})
+		}
+	case c == '[':
+		p.off++
+		v := &textValue{kind: listValue, off: start}
+		for {
+			p.skipSpace()
+			if p.consume(']') {
+				return v, nil
+			}
+			if len(v.elems) > 0 {
+				if !p.consume(',') {
+					return nil, p.errorf(p.off, "expected ',' or ']'")
+				}
+				p.skipSpace()
+				if p.consume(']') {
+					return v, nil
+				}
+			}
+			e, err := p.nestedValue(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			v.elems = append(v.elems, e)
+		}
+	case c == '"':
+		s, err := p.str()
+		if err != nil {
+			return nil, err
+		}
+		return &textValue{kind: stringValue, off: start, s: s}, nil
+	case c == '0' && bytes.HasPrefix(p.src[p.off:], []byte(`0x"`)):
+		p.off += 2
+		s, err := p.str()
+		if err != nil {
+			return nil, err
+		}
+		b, err := decodeHex(s)
+		if err != nil {
+			return nil, p.errorf(start, "%v", err)
+		}
+		return &textValue{kind: stringValue, off: start, s: string(b)}, nil
+	case c == '<':
+		end := bytes.IndexByte(p.src[p.off:], '>')
+		if end == -1 {
+			return nil, p.errorf(start, "unterminated marker")
+		}
+		p.off += end + 1
+		m := string(p.src[start:p.off])
+		if m != interfaceMarker && m != anyPointerMarker {
+			return nil, p.errorf(start, "unknown marker %s", m)
+		}
+		return &textValue{kind: markerValue, off: start, s: m}, nil
+	case c == '-' || c == '+' || isDigit(c):
+		p.off++
+		for p.off < len(p.src) {
+			c := p.src[p.off]
+			if isIdentByte(c) || c == '.' {
+				p.off++
+			} else if (c == '+' || c == '-') && isExponent(p.src[start:p.off]) {
+				p.off++
+			} else {
+				break
+			}
+		}
+		return &textValue{kind: numberValue, off: start, s: string(p.src[start:p.off])}, nil
+	case isIdentByte(c):
+		return &textValue{kind: identValue, off: start, s: p.ident()}, nil
+	default:
+		return nil, p.errorf(start, "unexpected %q", c)
+	}
+}
+
+// isExponent reports whether a number literal so far ends with a
+// decimal exponent marker, so a sign may follow.
+func isExponent(lit []byte) bool {
+	if len(lit) == 0 || lit[len(lit)-1] != 'e' && lit[len(lit)-1] != 'E' {
+		return false
+	}
+	lit = bytes.TrimLeft(lit, "+-")
+	return !bytes.HasPrefix(lit, []byte("0x")) && !bytes.HasPrefix(lit, []byte("0X"))
+}
+
+func (p *parser) ident() string {
+	start := p.off
+	for p.off < len(p.src) && isIdentByte(p.src[p.off]) {
+		p.off++
+	}
+	return string(p.src[start:p.off])
+}
+
+// str reads a double-quoted string, undoing the escapes that
+// Encoder.marshalText writes.
+func (p *parser) str() (string, error) {
+	start := p.off
+	p.off++
+	var buf []byte
+	for {
+		if p.off >= len(p.src) {
+			return "", p.errorf(start, "unterminated string")
+		}
+		c := p.src[p.off]
+		p.off++
+		switch c {
+		case '"':
+			return string(buf), nil
+		case '\n':
+			return "", p.errorf(start, "unterminated string")
+		case '\\':
+			if p.off >= len(p.src) {
+				return "", p.errorf(start, "unterminated string")
+			}
+			e := p.src[p.off]
+			p.off++
+			switch e {
+			case 'a':
+				buf = append(buf, '\a')
+			case 'b':
+				buf = append(buf, '\b')
+			case 'f':
+				buf = append(buf, '\f')
+			case 'n':
+				buf = append(buf, '\n')
+			case 'r':
+				buf = append(buf, '\r')
+			case 't':
+				buf = append(buf, '\t')
+			case 'v':
+				buf = append(buf, '\v')
+			case '\'', '"', '\\':
+				buf = append(buf, e)
+			case 'x':
+				if p.off+2 > len(p.src) {
+					return "", p.errorf(p.off-2, "invalid escape")
+				}
+				b, err := strconv.ParseUint(string(p.src[p.off:p.off+2]), 16, 8)
+				if err != nil {
+					return "", p.errorf(p.off-2, "invalid escape")
+				}
+				buf = append(buf, byte(b))
+				p.off += 2
+			default:
+				return "", p.errorf(p.off-2, "invalid escape \\%c", e)
+			}
+		default:
+			buf = append(buf, c)
+		}
+	}
+}
+
+func decodeHex(s string) ([]byte, error) {
+	s = strings.Join(strings.Fields(s), "")
+	if len(s)%2 != 0 {
+		return nil, fmt.Errorf("odd number of hex digits")
+	}
+	b := make([]byte, len(s)/2)
+	for i := range b {
+		x, err := strconv.ParseUint(s[2*i:2*i+2], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex digits %q", s[2*i:2*i+2])
+		}
+		b[i] = byte(x)
+	}
+	return b, nil
+}
+
+func (p *parser) consume(c byte) bool {
+	if p.off < len(p.src) && p.src[p.off] == c {
+		p.off++
+		return true
+	}
+	return false
+}
+
+// skipSpace advances past whitespace and comments.
+func (p *parser) skipSpace() {
+	for p.off < len(p.src) {
+		switch p.src[p.off] {
+		case ' ', '\t', '\n', '\r', '\f', '\v':
+			p.off++
+		case '#':
+			for p.off < len(p.src) && p.src[p.off] != '\n' {
+				p.off++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) errorf(off int, format string, args ...interface{}) error {
+	line, col := 1, 1
+	for _, c := range p.src[:off] {
+		if c == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return fmt.Errorf("text: %d:%d: %s", line, col, fmt.Sprintf(format, args...))
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isIdentByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || isDigit(c) || c == '_'
+}
@@ -0,0 +1,153 @@
+package text
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"zombiezen.com/go/capnproto2/schemas"
+)
+
+func newTestRegistry(t *testing.T) *schemas.Registry {
+	data, err := readTestFile("txt.capnp.out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := new(schemas.Registry)
+	err = reg.Register(&schemas.Schema{
+		Bytes: data,
+		Nodes: []uint64{
+			0x8df8bc5abdc060a6,
+			0xd3602730c572a43b,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Adding to registry: %v", err)
+	}
+	return reg
+}
+
+func TestDecode(t *testing.T) {
+	const (
+		keyValue = 0x8df8bc5abdc060a6
+		value    = 0xd3602730c572a43b
+	)
+	tests := []struct {
+		typeID uint64
+		text   string
+		want   string // if empty, same as text
+	}{
+		{keyValue, `(key = "42", value = (int32 = -123))`, ""},
+		{keyValue, `(key = "float", value = (float64 = 3.14))`, ""},
+		{keyValue, `(key = "bool", value = (bool = false))`, ""},
+		{value, `(map = [(key = "foo", value = (void = void)), (key = "bar", value = (void = void))])`, ""},
+		{value, `(map = [])`, ""},
+		{value, `(data = "Hi\xde\xad\xbe\xef\xca\xfe")`, ""},
+		{value, `(voidList = [void, void])`, ""},
+		{value, `(boolList = [true, false, true, false])`, ""},
+		{value, `(int8List = [1, -2, 3])`, ""},
+		{value, `(int64List = [1, -2, 3])`, ""},
+		{value, `(uint8List = [255, 0, 1])`, ""},
+		{value, `(uint64List = [1, 2, 3])`, ""},
+		{value, `(float32List = [0.5, 3.14, -2])`, ""},
+		{value, `(textList = ["foo", "bar", "baz"])`, ""},
+		{value, `(dataList = ["\xde\xad\xbe\xef", "\xca\xfe"])`, ""},
+		{value, `(cheese = gouda)`, ""},
+		{value, `(cheeseList = [gouda, cheddar])`, ""},
+		{value, `(matrix = [[1, 2, 3], [4, 5, 6]])`, ""},
+		{value, "(text = \"tab\\there\\n\\\"quoted\\\"\")", ""},
+
+		// Syntax that Encoder doesn't write.
+		{value, "  ( int32 = 0x10 ,)  # comment\n", `(int32 = 16)`},
+		{value, `(uint16 = 0o17)`, `(uint16 = 15)`},
+		{value, `(float64 = -inf)`, `(float64 = -Inf)`},
+		{value, `(float64 = 1e-3)`, `(float64 = 0.001)`},
+		{value, `(data = 0x"de ad be ef")`, `(data = "\xde\xad\xbe\xef")`},
+		{value, `(cheese = 0)`, `(cheese = cheddar)`},
+		{value, `(textList = ["a", null])`, `(textList = ["a", ""])`},
+		{keyValue, `(key = null)`, `(key = "", value = (void = void))`},
+	}
+	reg := newTestRegistry(t)
+	for _, test := range tests {
+		dec := NewDecoder(strings.NewReader(test.text))
+		dec.UseRegistry(reg)
+		s, err := dec.Decode(test.typeID)
+		if err != nil {
+			t.Errorf("Decode(%#x, %q): %v", test.typeID, test.text, err)
+			continue
+		}
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.UseRegistry(reg)
+		if err := enc.Encode(test.typeID, s); err != nil {
+			t.Errorf("Encode(Decode(%#x, %q)): %v", test.typeID, test.text, err)
+			continue
+		}
+		want := test.want
+		if want == "" {
+			want = test.text
+		}
+		if got := buf.String(); got != want {
+			t.Errorf("Encode(Decode(%#x, %q)) = %q; want %q", test.typeID, test.text, got, want)
+		}
+	}
+}
+
+func TestDecodeStream(t *testing.T) {
+	const value = 0xd3602730c572a43b
+	reg := newTestRegistry(t)
+	dec := NewDecoder(strings.NewReader("(int8 = 1)\n# second\n(int8 = 2)\n"))
+	dec.UseRegistry(reg)
+	for i, want := range []string{"(int8 = 1)", "(int8 = 2)"} {
+		s, err := dec.Decode(value)
+		if err != nil {
+			t.Fatalf("Decode #%d: %v", i, err)
+		}
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.UseRegistry(reg)
+		if err := enc.Encode(value, s); err != nil {
+			t.Errorf("Encode(Decode #%d): %v", i, err)
+		} else if got := buf.String(); got != want {
+			t.Errorf("Encode(Decode #%d) = %q; want %q", i, got, want)
+		}
+	}
+	if _, err := dec.Decode(value); err != io.EOF {
+		t.Errorf("Decode at end = %v; want io.EOF", err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	const value = 0xd3602730c572a43b
+	tests := []struct {
+		text string
+		msg  string
+	}{
+		{`(int8 = 128)`, "1:9: 128 is not a valid int8"},
+		{`(uint8 = -1)`, "is not a valid uint8"},
+		{`(bogus = 1)`, "has no field \"bogus\""},
+		{`(int8 = 1, int16 = 2)`, "same union"},
+		{`(cheese = brie)`, "unknown enumerant brie"},
+		{`(text = "abc`, "unterminated string"},
+		{`(text = "\q")`, "invalid escape"},
+		{`(int8 = 1`, "expected ',' or ')'"},
+		{"(\n  bool = 1)", "2:10: expected true or false"},
+		{`[1, 2]`, "expected struct value"},
+		{`(textList = "x")`, "expected list"},
+		{strings.Repeat("(matrix = [", 100), "nested too deeply"},
+	}
+	reg := newTestRegistry(t)
+	for _, test := range tests {
+		dec := NewDecoder(strings.NewReader(test.text))
+		dec.UseRegistry(reg)
+		_, err := dec.Decode(value)
+		if err == nil {
+			t.Errorf("Decode(%q) = <nil>; want error containing %q", test.text, test.msg)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.msg) {
+			t.Errorf("Decode(%q) = %v; want error containing %q", test.text, err, test.msg)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package capnp
+
+import (
+	"encoding/binary"
+)
+
+// A Framer splits a stream of unpacked messages into individual
+// messages as bytes arrive, without reading from an io.Reader itself.
+// It suits event-loop driven network code, which can pass whatever
+// bytes it has to Write and then call Next until no complete message
+// is left.
+//
+// The zero value is a Framer that is ready to use.
+type Framer struct {
+	// Maximum number of bytes in a message, including its header.
+	// If not set, a reasonable default is used.
+	MaxMessageSize uint64
+
+	reuse bool
+	buf   []byte // bytes written but not yet returned in a message
+	done  int    // bytes at the start of buf returned in the last message
+	arena multiSegmentArena
+	msg   Message
+}
+
+// ReuseBuffer makes the framer reuse its buffer and Message between
+// messages instead of allocating new ones.  A message returned by Next
+// is then only valid until the next call to Write or Next.  Otherwise,
+// messages are never invalidated.
+func (f *Framer) ReuseBuffer() {
+	f.reuse = true
+}
+
+// Write appends p to the framer's buffer.  It always returns len(p)
+// and a nil error.
+func (f *Framer) Write(p []byte) (int, error) {
+	f.compact()
+	f.buf = append(f.buf, p...)
+	return len(p), nil
+}
+
+// Buffered returns the number of bytes that have been written but not
+// yet returned in a message.
+func (f *Framer) Buffered() int {
+	return len(f.buf) - f.done
+}
+
+// Next returns the next complete message in the buffer.  If the buffer
+// doesn't hold a complete message yet, Next returns nil and a nil
+// error.  Next reports an error as soon as a message's header shows
+// that the message is malformed or larger than MaxMessageSize, without
+// waiting for the rest of the message.  Such an error is permanent:
+// the framer can't find the start of the next message.
+func (f *Framer) Next() (*Message, error) {
+	f.compact()
+	maxSize := f.MaxMessageSize
+	if maxSize == 0 {
+		maxSize = defaultDecodeLimit
+	}
+	if len(f.buf) < msgHeaderSize {
+		return nil, nil
+	}
+	maxSeg := binary.LittleEndian.Uint32(f.buf)
+	if maxSeg > maxStreamSegments {
+		return nil, errTooManySegments
+	}
+	hdrSize := streamHeaderSize(maxSeg)
+	if hdrSize > maxSize {
+		return nil, errDecodeLimit
+	}
+	if uint64(len(f.buf)) < hdrSize {
+		return nil, nil
+	}
+	hdr, body, err := parseStreamHeader(f.buf)
+	if err != nil {
+		return nil, err
+	}
+	total, err := hdr.totalSize()
+	if err != nil {
+		return nil, err
+	}
+	if total > maxSize-hdrSize {
+		return nil, errDecodeLimit
+	}
+	if uint64(len(body)) < total {
+		return nil, nil
+	}
+	body = body[:total:total]
+	if !f.reuse {
+		// Messages keep referring to the buffer's array, so only ever
+		// append past the bytes that have been returned.
+		f.buf = f.buf[hdrSize+total:]
+		arena, err := demuxArena(hdr, body)
+		if err != nil {
+			return nil, err
+		}
+		return &Message{Arena: arena}, nil
+	}
+	f.done = int(hdrSize + total)
+	f.arena, err = demuxSegments(f.arena[:0], hdr, body)
+	if err != nil {
+		return nil, err
+	}
+	f.msg.Reset(&f.arena)
+	return &f.msg, nil
+}
+
+// compact discards the bytes of the last message returned when the
+// framer is reusing its buffer.
+func (f *Framer) compact() {
+	if f.done == 0 {
+		return
+	}
+	n := copy(f.buf, f.buf[f.done:])
+	f.buf = f.buf[:n]
+	f.done = 0
+}
@@ -0,0 +1,117 @@
+package capnp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFramer(t *testing.T) {
+	var stream []byte
+	var want [][][]byte
+	for _, test := range serializeTests {
+		if test.encodeFails || test.decodeFails {
+			continue
+		}
+		stream = append(stream, test.out...)
+		want = append(want, test.segs)
+	}
+	for _, reuse := range []bool{false, true} {
+		f := new(Framer)
+		if reuse {
+			f.ReuseBuffer()
+		}
+		var msgs []*Message
+		check := func(i int, msg *Message) {
+			segs := want[i]
+			if msg.NumSegments() != int64(len(segs)) {
+				t.Errorf("reuse=%t: message #%d NumSegments() = %d; want %d", reuse, i, msg.NumSegments(), len(segs))
+				return
+			}
+			for j := range segs {
+				seg, err := msg.Segment(SegmentID(j))
+				if err != nil {
+					t.Errorf("reuse=%t: message #%d Segment(%d) error: %v", reuse, i, j, err)
+					continue
+				}
+				if !bytes.Equal(seg.Data(), segs[j]) {
+					t.Errorf("reuse=%t: message #%d Segment(%d) = % 02x; want % 02x", reuse, i, j, seg.Data(), segs[j])
+				}
+			}
+		}
+		// Feed the stream a byte at a time, as slowly as it could
+		// possibly arrive.
+		for i := range stream {
+			f.Write(stream[i : i+1])
+			for {
+				msg, err := f.Next()
+				if err != nil {
+					t.Fatalf("reuse=%t: Next after %d bytes: %v", reuse, i+1, err)
+				}
+				if msg == nil {
+					break
+				}
+				if len(msgs) >= len(want) {
+					t.Fatalf("reuse=%t: Next returned more than %d messages", reuse, len(want))
+				}
+				if reuse {
+					check(len(msgs), msg)
+				}
+				msgs = append(msgs, msg)
+			}
+		}
+		if len(msgs) != len(want) {
+			t.Errorf("reuse=%t: got %d messages; want %d", reuse, len(msgs), len(want))
+		}
+		if n := f.Buffered(); n != 0 {
+			t.Errorf("reuse=%t: Buffered() = %d at end; want 0", reuse, n)
+		}
+		if !reuse {
+			// Earlier messages must survive later writes.
+			for i, msg := range msgs {
+				check(i, msg)
+			}
+		}
+	}
+}
+
+func TestFramer_ReuseBufferReadRoot(t *testing.T) {
+	f := new(Framer)
+	f.ReuseBuffer()
+	for i := 0; i < 3; i++ {
+		data, err := rootStructMessage(t, uint64(i)).Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(data)
+		msg, err := f.Next()
+		if err != nil || msg == nil {
+			t.Fatalf("Next #%d = %v, %v; want message", i, msg, err)
+		}
+		p, err := msg.RootPtr()
+		if err != nil {
+			t.Errorf("message #%d RootPtr: %v", i, err)
+			continue
+		}
+		if x := p.Struct().Uint64(0); x != uint64(i) {
+			t.Errorf("message #%d root field = %d; want %d", i, x, i)
+		}
+	}
+}
+
+func TestFramer_MaxMessageSize(t *testing.T) {
+	f := &Framer{MaxMessageSize: 64}
+	// The header alone promises a body that is too large.
+	f.Write([]byte{
+		0x00, 0x00, 0x00, 0x00,
+		0x09, 0x00, 0x00, 0x00,
+	})
+	if msg, err := f.Next(); err != errDecodeLimit {
+		t.Errorf("Next() = %v, %v; want nil, %v", msg, err, errDecodeLimit)
+	}
+
+	f = new(Framer)
+	f.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	if msg, err := f.Next(); err != errTooManySegments {
+		t.Errorf("Next() with too many segments = %v, %v; want nil, %v", msg, err, errTooManySegments)
+	}
+}
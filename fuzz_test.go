@@ -0,0 +1,121 @@
+// +build go1.18
+
+package capnp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// FuzzDecoder fuzzes a stream of framed messages.  Copying any message
+// that decodes must give the same bytes after a marshal round trip.
+func FuzzDecoder(f *testing.F) {
+	names, err := filepath.Glob(filepath.Join("internal", "fuzztest", "corpus", "*"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	var stream []byte
+	for _, name := range names {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		for len(data)%8 != 0 {
+			data = append(data, 0)
+		}
+		msg := &Message{Arena: SingleSegment(data)}
+		framed, err := msg.Marshal()
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(framed)
+		stream = append(stream, framed...)
+	}
+	f.Add(stream)
+	if seed, err := doubleFarSeed(); err != nil {
+		f.Fatal(err)
+	} else {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		dec := NewDecoder(bytes.NewReader(data))
+		dec.MaxMessageSize = 1 << 20
+		for {
+			msg, err := dec.Decode()
+			if err != nil {
+				return
+			}
+			checkDecoded(t, msg)
+		}
+	})
+}
+
+func checkDecoded(t *testing.T, msg *Message) {
+	root, err := msg.RootPtr()
+	if err != nil {
+		return
+	}
+	dup, err := CopyToNewMessage(root)
+	if err != nil {
+		// Copying validates every nested pointer, so it can fail on
+		// input that only had a valid root.
+		return
+	}
+	data, err := dup.Marshal()
+	if err != nil {
+		t.Fatal("Marshal after copy:", err)
+	}
+	msg2, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal("Unmarshal after copy:", err)
+	}
+	root2, err := msg2.RootPtr()
+	if err != nil {
+		t.Fatal("RootPtr after round trip:", err)
+	}
+	dup2, err := CopyToNewMessage(root2)
+	if err != nil {
+		t.Fatal("CopyToNewMessage after round trip:", err)
+	}
+	data2, err := dup2.Marshal()
+	if err != nil {
+		t.Fatal("Marshal after second copy:", err)
+	}
+	if !bytes.Equal(data, data2) {
+		t.Errorf("copy is not stable:\nfirst  = %x\nsecond = %x", data, data2)
+	}
+}
+
+// doubleFarSeed returns a framed message with one object per segment.
+func doubleFarSeed() ([]byte, error) {
+	msg, seg, err := NewMessage(newExactArena())
+	if err != nil {
+		return nil, err
+	}
+	root, err := NewRootStruct(seg, ObjectSize{DataSize: 8, PointerCount: 2})
+	if err != nil {
+		return nil, err
+	}
+	root.SetUint64(0, 0xdeadbeef)
+	l, err := NewCompositeList(seg, ObjectSize{DataSize: 8, PointerCount: 1}, 2)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < l.Len(); i++ {
+		l.Struct(i).SetUint64(0, uint64(i))
+		txt, err := NewText(seg, "hello")
+		if err != nil {
+			return nil, err
+		}
+		if err := l.Struct(i).SetPtr(0, txt.ToPtr()); err != nil {
+			return nil, err
+		}
+	}
+	if err := root.SetPtr(0, l.ToPtr()); err != nil {
+		return nil, err
+	}
+	return msg.Marshal()
+}
@@ -6,6 +6,7 @@ import (
 	context "golang.org/x/net/context"
 	math "math"
 	strconv "strconv"
+	sync "sync"
 	capnp "zombiezen.com/go/capnproto2"
 	text "zombiezen.com/go/capnproto2/encoding/text"
 	schemas "zombiezen.com/go/capnproto2/schemas"
@@ -93,6 +94,22 @@ func (p Zdate_Promise) Struct() (Zdate, error) {
 	return Zdate{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Zdate_Promise) Await(ctx context.Context) (Zdate, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Zdate{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Zdate_Promise) Then(f func(Zdate, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Zdate{s}, err)
+	})
+}
+
 type Zdata struct{ capnp.Struct }
 
 // Zdata_TypeID is the unique identifier for the type Zdata.
@@ -153,6 +170,22 @@ func (p Zdata_Promise) Struct() (Zdata, error) {
 	return Zdata{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Zdata_Promise) Await(ctx context.Context) (Zdata, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Zdata{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Zdata_Promise) Then(f func(Zdata, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Zdata{s}, err)
+	})
+}
+
 type Airport uint16
 
 // Airport_TypeID is the unique identifier for the type Airport.
@@ -355,6 +388,22 @@ func (p PlaneBase_Promise) Struct() (PlaneBase, error) {
 	return PlaneBase{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p PlaneBase_Promise) Await(ctx context.Context) (PlaneBase, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return PlaneBase{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p PlaneBase_Promise) Then(f func(PlaneBase, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(PlaneBase{s}, err)
+	})
+}
+
 type B737 struct{ capnp.Struct }
 
 // B737_TypeID is the unique identifier for the type B737.
@@ -426,6 +475,22 @@ func (p B737_Promise) Struct() (B737, error) {
 	return B737{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p B737_Promise) Await(ctx context.Context) (B737, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return B737{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p B737_Promise) Then(f func(B737, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(B737{s}, err)
+	})
+}
+
 func (p B737_Promise) Base() PlaneBase_Promise {
 	return PlaneBase_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -501,6 +566,22 @@ func (p A320_Promise) Struct() (A320, error) {
 	return A320{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p A320_Promise) Await(ctx context.Context) (A320, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return A320{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p A320_Promise) Then(f func(A320, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(A320{s}, err)
+	})
+}
+
 func (p A320_Promise) Base() PlaneBase_Promise {
 	return PlaneBase_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -576,6 +657,22 @@ func (p F16_Promise) Struct() (F16, error) {
 	return F16{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p F16_Promise) Await(ctx context.Context) (F16, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return F16{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p F16_Promise) Then(f func(F16, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(F16{s}, err)
+	})
+}
+
 func (p F16_Promise) Base() PlaneBase_Promise {
 	return PlaneBase_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -725,6 +822,22 @@ func (p Regression_Promise) Struct() (Regression, error) {
 	return Regression{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Regression_Promise) Await(ctx context.Context) (Regression, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Regression{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Regression_Promise) Then(f func(Regression, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Regression{s}, err)
+	})
+}
+
 func (p Regression_Promise) Base() PlaneBase_Promise {
 	return PlaneBase_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -897,6 +1010,22 @@ func (p Aircraft_Promise) Struct() (Aircraft, error) {
 	return Aircraft{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Aircraft_Promise) Await(ctx context.Context) (Aircraft, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Aircraft{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Aircraft_Promise) Then(f func(Aircraft, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Aircraft{s}, err)
+	})
+}
+
 func (p Aircraft_Promise) B737() B737_Promise {
 	return B737_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -2145,6 +2274,22 @@ func (p Z_Promise) Struct() (Z, error) {
 	return Z{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Z_Promise) Await(ctx context.Context) (Z, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Z{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Z_Promise) Then(f func(Z, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Z{s}, err)
+	})
+}
+
 func (p Z_Promise) Zz() Z_Promise {
 	return Z_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -2191,6 +2336,22 @@ func (p Z_grp_Promise) Struct() (Z_grp, error) {
 	return Z_grp{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Z_grp_Promise) Await(ctx context.Context) (Z_grp, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Z_grp{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Z_grp_Promise) Then(f func(Z_grp, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Z_grp{s}, err)
+	})
+}
+
 func (p Z_Promise) Echo() Echo {
 	return Echo{Client: p.Pipeline.GetPipeline(0).Client()}
 }
@@ -2297,6 +2458,22 @@ func (p Counter_Promise) Struct() (Counter, error) {
 	return Counter{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Counter_Promise) Await(ctx context.Context) (Counter, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Counter{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Counter_Promise) Then(f func(Counter, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Counter{s}, err)
+	})
+}
+
 type Bag struct{ capnp.Struct }
 
 // Bag_TypeID is the unique identifier for the type Bag.
@@ -2368,6 +2545,22 @@ func (p Bag_Promise) Struct() (Bag, error) {
 	return Bag{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Bag_Promise) Await(ctx context.Context) (Bag, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Bag{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Bag_Promise) Then(f func(Bag, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Bag{s}, err)
+	})
+}
+
 func (p Bag_Promise) Counter() Counter_Promise {
 	return Counter_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -2443,6 +2636,22 @@ func (p Zserver_Promise) Struct() (Zserver, error) {
 	return Zserver{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Zserver_Promise) Await(ctx context.Context) (Zserver, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Zserver{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Zserver_Promise) Then(f func(Zserver, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Zserver{s}, err)
+	})
+}
+
 type Zjob struct{ capnp.Struct }
 
 // Zjob_TypeID is the unique identifier for the type Zjob.
@@ -2533,6 +2742,22 @@ func (p Zjob_Promise) Struct() (Zjob, error) {
 	return Zjob{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Zjob_Promise) Await(ctx context.Context) (Zjob, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Zjob{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Zjob_Promise) Then(f func(Zjob, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Zjob{s}, err)
+	})
+}
+
 type VerEmpty struct{ capnp.Struct }
 
 // VerEmpty_TypeID is the unique identifier for the type VerEmpty.
@@ -2579,6 +2804,22 @@ func (p VerEmpty_Promise) Struct() (VerEmpty, error) {
 	return VerEmpty{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p VerEmpty_Promise) Await(ctx context.Context) (VerEmpty, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return VerEmpty{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p VerEmpty_Promise) Then(f func(VerEmpty, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(VerEmpty{s}, err)
+	})
+}
+
 type VerOneData struct{ capnp.Struct }
 
 // VerOneData_TypeID is the unique identifier for the type VerOneData.
@@ -2633,6 +2874,22 @@ func (p VerOneData_Promise) Struct() (VerOneData, error) {
 	return VerOneData{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p VerOneData_Promise) Await(ctx context.Context) (VerOneData, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return VerOneData{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p VerOneData_Promise) Then(f func(VerOneData, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(VerOneData{s}, err)
+	})
+}
+
 type VerTwoData struct{ capnp.Struct }
 
 // VerTwoData_TypeID is the unique identifier for the type VerTwoData.
@@ -2695,6 +2952,22 @@ func (p VerTwoData_Promise) Struct() (VerTwoData, error) {
 	return VerTwoData{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p VerTwoData_Promise) Await(ctx context.Context) (VerTwoData, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return VerTwoData{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p VerTwoData_Promise) Then(f func(VerTwoData, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(VerTwoData{s}, err)
+	})
+}
+
 type VerOnePtr struct{ capnp.Struct }
 
 // VerOnePtr_TypeID is the unique identifier for the type VerOnePtr.
@@ -2766,6 +3039,22 @@ func (p VerOnePtr_Promise) Struct() (VerOnePtr, error) {
 	return VerOnePtr{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p VerOnePtr_Promise) Await(ctx context.Context) (VerOnePtr, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return VerOnePtr{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p VerOnePtr_Promise) Then(f func(VerOnePtr, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(VerOnePtr{s}, err)
+	})
+}
+
 func (p VerOnePtr_Promise) Ptr() VerOneData_Promise {
 	return VerOneData_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -2866,6 +3155,22 @@ func (p VerTwoPtr_Promise) Struct() (VerTwoPtr, error) {
 	return VerTwoPtr{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p VerTwoPtr_Promise) Await(ctx context.Context) (VerTwoPtr, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return VerTwoPtr{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p VerTwoPtr_Promise) Then(f func(VerTwoPtr, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(VerTwoPtr{s}, err)
+	})
+}
+
 func (p VerTwoPtr_Promise) Ptr1() VerOneData_Promise {
 	return VerOneData_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -2988,6 +3293,22 @@ func (p VerTwoDataTwoPtr_Promise) Struct() (VerTwoDataTwoPtr, error) {
 	return VerTwoDataTwoPtr{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p VerTwoDataTwoPtr_Promise) Await(ctx context.Context) (VerTwoDataTwoPtr, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return VerTwoDataTwoPtr{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p VerTwoDataTwoPtr_Promise) Then(f func(VerTwoDataTwoPtr, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(VerTwoDataTwoPtr{s}, err)
+	})
+}
+
 func (p VerTwoDataTwoPtr_Promise) Ptr1() VerOneData_Promise {
 	return VerOneData_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -3071,6 +3392,22 @@ func (p HoldsVerEmptyList_Promise) Struct() (HoldsVerEmptyList, error) {
 	return HoldsVerEmptyList{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p HoldsVerEmptyList_Promise) Await(ctx context.Context) (HoldsVerEmptyList, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return HoldsVerEmptyList{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p HoldsVerEmptyList_Promise) Then(f func(HoldsVerEmptyList, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(HoldsVerEmptyList{s}, err)
+	})
+}
+
 type HoldsVerOneDataList struct{ capnp.Struct }
 
 // HoldsVerOneDataList_TypeID is the unique identifier for the type HoldsVerOneDataList.
@@ -3146,6 +3483,22 @@ func (p HoldsVerOneDataList_Promise) Struct() (HoldsVerOneDataList, error) {
 	return HoldsVerOneDataList{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p HoldsVerOneDataList_Promise) Await(ctx context.Context) (HoldsVerOneDataList, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return HoldsVerOneDataList{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p HoldsVerOneDataList_Promise) Then(f func(HoldsVerOneDataList, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(HoldsVerOneDataList{s}, err)
+	})
+}
+
 type HoldsVerTwoDataList struct{ capnp.Struct }
 
 // HoldsVerTwoDataList_TypeID is the unique identifier for the type HoldsVerTwoDataList.
@@ -3221,6 +3574,22 @@ func (p HoldsVerTwoDataList_Promise) Struct() (HoldsVerTwoDataList, error) {
 	return HoldsVerTwoDataList{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p HoldsVerTwoDataList_Promise) Await(ctx context.Context) (HoldsVerTwoDataList, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return HoldsVerTwoDataList{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p HoldsVerTwoDataList_Promise) Then(f func(HoldsVerTwoDataList, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(HoldsVerTwoDataList{s}, err)
+	})
+}
+
 type HoldsVerOnePtrList struct{ capnp.Struct }
 
 // HoldsVerOnePtrList_TypeID is the unique identifier for the type HoldsVerOnePtrList.
@@ -3296,6 +3665,22 @@ func (p HoldsVerOnePtrList_Promise) Struct() (HoldsVerOnePtrList, error) {
 	return HoldsVerOnePtrList{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p HoldsVerOnePtrList_Promise) Await(ctx context.Context) (HoldsVerOnePtrList, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return HoldsVerOnePtrList{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p HoldsVerOnePtrList_Promise) Then(f func(HoldsVerOnePtrList, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(HoldsVerOnePtrList{s}, err)
+	})
+}
+
 type HoldsVerTwoPtrList struct{ capnp.Struct }
 
 // HoldsVerTwoPtrList_TypeID is the unique identifier for the type HoldsVerTwoPtrList.
@@ -3371,6 +3756,22 @@ func (p HoldsVerTwoPtrList_Promise) Struct() (HoldsVerTwoPtrList, error) {
 	return HoldsVerTwoPtrList{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p HoldsVerTwoPtrList_Promise) Await(ctx context.Context) (HoldsVerTwoPtrList, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return HoldsVerTwoPtrList{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p HoldsVerTwoPtrList_Promise) Then(f func(HoldsVerTwoPtrList, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(HoldsVerTwoPtrList{s}, err)
+	})
+}
+
 type HoldsVerTwoTwoList struct{ capnp.Struct }
 
 // HoldsVerTwoTwoList_TypeID is the unique identifier for the type HoldsVerTwoTwoList.
@@ -3446,6 +3847,22 @@ func (p HoldsVerTwoTwoList_Promise) Struct() (HoldsVerTwoTwoList, error) {
 	return HoldsVerTwoTwoList{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p HoldsVerTwoTwoList_Promise) Await(ctx context.Context) (HoldsVerTwoTwoList, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return HoldsVerTwoTwoList{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p HoldsVerTwoTwoList_Promise) Then(f func(HoldsVerTwoTwoList, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(HoldsVerTwoTwoList{s}, err)
+	})
+}
+
 type HoldsVerTwoTwoPlus struct{ capnp.Struct }
 
 // HoldsVerTwoTwoPlus_TypeID is the unique identifier for the type HoldsVerTwoTwoPlus.
@@ -3521,6 +3938,22 @@ func (p HoldsVerTwoTwoPlus_Promise) Struct() (HoldsVerTwoTwoPlus, error) {
 	return HoldsVerTwoTwoPlus{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p HoldsVerTwoTwoPlus_Promise) Await(ctx context.Context) (HoldsVerTwoTwoPlus, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return HoldsVerTwoTwoPlus{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p HoldsVerTwoTwoPlus_Promise) Then(f func(HoldsVerTwoTwoPlus, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(HoldsVerTwoTwoPlus{s}, err)
+	})
+}
+
 type VerTwoTwoPlus struct{ capnp.Struct }
 
 // VerTwoTwoPlus_TypeID is the unique identifier for the type VerTwoTwoPlus.
@@ -3666,6 +4099,22 @@ func (p VerTwoTwoPlus_Promise) Struct() (VerTwoTwoPlus, error) {
 	return VerTwoTwoPlus{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p VerTwoTwoPlus_Promise) Await(ctx context.Context) (VerTwoTwoPlus, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return VerTwoTwoPlus{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p VerTwoTwoPlus_Promise) Then(f func(VerTwoTwoPlus, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(VerTwoTwoPlus{s}, err)
+	})
+}
+
 func (p VerTwoTwoPlus_Promise) Ptr1() VerTwoDataTwoPtr_Promise {
 	return VerTwoDataTwoPtr_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -3789,6 +4238,22 @@ func (p HoldsText_Promise) Struct() (HoldsText, error) {
 	return HoldsText{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p HoldsText_Promise) Await(ctx context.Context) (HoldsText, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return HoldsText{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p HoldsText_Promise) Then(f func(HoldsText, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(HoldsText{s}, err)
+	})
+}
+
 type WrapEmpty struct{ capnp.Struct }
 
 // WrapEmpty_TypeID is the unique identifier for the type WrapEmpty.
@@ -3860,6 +4325,22 @@ func (p WrapEmpty_Promise) Struct() (WrapEmpty, error) {
 	return WrapEmpty{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p WrapEmpty_Promise) Await(ctx context.Context) (WrapEmpty, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return WrapEmpty{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p WrapEmpty_Promise) Then(f func(WrapEmpty, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(WrapEmpty{s}, err)
+	})
+}
+
 func (p WrapEmpty_Promise) MightNotBeReallyEmpty() VerEmpty_Promise {
 	return VerEmpty_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -3935,6 +4416,22 @@ func (p Wrap2x2_Promise) Struct() (Wrap2x2, error) {
 	return Wrap2x2{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Wrap2x2_Promise) Await(ctx context.Context) (Wrap2x2, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Wrap2x2{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Wrap2x2_Promise) Then(f func(Wrap2x2, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Wrap2x2{s}, err)
+	})
+}
+
 func (p Wrap2x2_Promise) MightNotBeReallyEmpty() VerTwoDataTwoPtr_Promise {
 	return VerTwoDataTwoPtr_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -4010,6 +4507,22 @@ func (p Wrap2x2plus_Promise) Struct() (Wrap2x2plus, error) {
 	return Wrap2x2plus{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Wrap2x2plus_Promise) Await(ctx context.Context) (Wrap2x2plus, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Wrap2x2plus{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Wrap2x2plus_Promise) Then(f func(Wrap2x2plus, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Wrap2x2plus{s}, err)
+	})
+}
+
 func (p Wrap2x2plus_Promise) MightNotBeReallyEmpty() VerTwoTwoPlus_Promise {
 	return VerTwoTwoPlus_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -4091,6 +4604,22 @@ func (p VoidUnion_Promise) Struct() (VoidUnion, error) {
 	return VoidUnion{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p VoidUnion_Promise) Await(ctx context.Context) (VoidUnion, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return VoidUnion{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p VoidUnion_Promise) Then(f func(VoidUnion, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(VoidUnion{s}, err)
+	})
+}
+
 type Nester1Capn struct{ capnp.Struct }
 
 // Nester1Capn_TypeID is the unique identifier for the type Nester1Capn.
@@ -4162,6 +4691,22 @@ func (p Nester1Capn_Promise) Struct() (Nester1Capn, error) {
 	return Nester1Capn{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Nester1Capn_Promise) Await(ctx context.Context) (Nester1Capn, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Nester1Capn{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Nester1Capn_Promise) Then(f func(Nester1Capn, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Nester1Capn{s}, err)
+	})
+}
+
 type RWTestCapn struct{ capnp.Struct }
 
 // RWTestCapn_TypeID is the unique identifier for the type RWTestCapn.
@@ -4233,6 +4778,22 @@ func (p RWTestCapn_Promise) Struct() (RWTestCapn, error) {
 	return RWTestCapn{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p RWTestCapn_Promise) Await(ctx context.Context) (RWTestCapn, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return RWTestCapn{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p RWTestCapn_Promise) Then(f func(RWTestCapn, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(RWTestCapn{s}, err)
+	})
+}
+
 type ListStructCapn struct{ capnp.Struct }
 
 // ListStructCapn_TypeID is the unique identifier for the type ListStructCapn.
@@ -4304,6 +4865,22 @@ func (p ListStructCapn_Promise) Struct() (ListStructCapn, error) {
 	return ListStructCapn{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p ListStructCapn_Promise) Await(ctx context.Context) (ListStructCapn, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return ListStructCapn{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p ListStructCapn_Promise) Then(f func(ListStructCapn, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(ListStructCapn{s}, err)
+	})
+}
+
 type Echo struct{ Client capnp.Client }
 
 // Echo_TypeID is the unique identifier for the type Echo.
@@ -4334,9 +4911,9 @@ type Echo_Server interface {
 	Echo(Echo_echo) error
 }
 
-func Echo_ServerToClient(s Echo_Server) Echo {
+func Echo_ServerToClient(s Echo_Server, opts ...server.Option) Echo {
 	c, _ := s.(server.Closer)
-	return Echo{Client: server.New(Echo_Methods(nil, s), c)}
+	return Echo{Client: server.New(Echo_Methods(nil, s), c, opts...)}
 }
 
 func Echo_Methods(methods []server.Method, s Echo_Server) []server.Method {
@@ -4369,6 +4946,53 @@ type Echo_echo struct {
 	Results Echo_echo_Results
 }
 
+// Echo_Mock is a fake Echo_Server for use in tests.
+// Each method records a copy of its parameters and then calls the
+// function in the corresponding Func field, which should fill in
+// call.Results.  The copy is kept because call.Params is only valid
+// until the call returns.
+// Methods with a nil Func field return capnp.ErrUnimplemented.  Use
+// Echo_ServerToClient to obtain a client for the mock.
+type Echo_Mock struct {
+	EchoFunc func(Echo_echo) error
+
+	mu        sync.Mutex
+	echoCalls []Echo_echo_Params
+}
+
+func (m *Echo_Mock) Echo(call Echo_echo) error {
+	msg, err := capnp.CopyToNewMessage(call.Params.Struct.ToPtr())
+	if err != nil {
+		return err
+	}
+	p, err := msg.RootPtr()
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.echoCalls = append(m.echoCalls, Echo_echo_Params{Struct: p.Struct()})
+	f := m.EchoFunc
+	m.mu.Unlock()
+	if f == nil {
+		return capnp.ErrUnimplemented
+	}
+	return f(call)
+}
+
+// EchoCalls returns the parameters of each call to Echo, in order.
+func (m *Echo_Mock) EchoCalls() []Echo_echo_Params {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Echo_echo_Params(nil), m.echoCalls...)
+}
+
+// EchoCallCount returns the number of calls to Echo.
+func (m *Echo_Mock) EchoCallCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.echoCalls)
+}
+
 type Echo_echo_Params struct{ capnp.Struct }
 
 // Echo_echo_Params_TypeID is the unique identifier for the type Echo_echo_Params.
@@ -4436,6 +5060,22 @@ func (p Echo_echo_Params_Promise) Struct() (Echo_echo_Params, error) {
 	return Echo_echo_Params{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Echo_echo_Params_Promise) Await(ctx context.Context) (Echo_echo_Params, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Echo_echo_Params{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Echo_echo_Params_Promise) Then(f func(Echo_echo_Params, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Echo_echo_Params{s}, err)
+	})
+}
+
 type Echo_echo_Results struct{ capnp.Struct }
 
 // Echo_echo_Results_TypeID is the unique identifier for the type Echo_echo_Results.
@@ -4505,6 +5145,22 @@ func (p Echo_echo_Results_Promise) Struct() (Echo_echo_Results, error) {
 	return Echo_echo_Results{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Echo_echo_Results_Promise) Await(ctx context.Context) (Echo_echo_Results, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Echo_echo_Results{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Echo_echo_Results_Promise) Then(f func(Echo_echo_Results, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Echo_echo_Results{s}, err)
+	})
+}
+
 type Hoth struct{ capnp.Struct }
 
 // Hoth_TypeID is the unique identifier for the type Hoth.
@@ -4576,6 +5232,22 @@ func (p Hoth_Promise) Struct() (Hoth, error) {
 	return Hoth{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Hoth_Promise) Await(ctx context.Context) (Hoth, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Hoth{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Hoth_Promise) Then(f func(Hoth, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Hoth{s}, err)
+	})
+}
+
 func (p Hoth_Promise) Base() EchoBase_Promise {
 	return EchoBase_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -4645,6 +5317,22 @@ func (p EchoBase_Promise) Struct() (EchoBase, error) {
 	return EchoBase{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p EchoBase_Promise) Await(ctx context.Context) (EchoBase, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return EchoBase{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p EchoBase_Promise) Then(f func(EchoBase, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(EchoBase{s}, err)
+	})
+}
+
 func (p EchoBase_Promise) Echo() Echo {
 	return Echo{Client: p.Pipeline.GetPipeline(0).Client()}
 }
@@ -4720,6 +5408,22 @@ func (p EchoBases_Promise) Struct() (EchoBases, error) {
 	return EchoBases{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p EchoBases_Promise) Await(ctx context.Context) (EchoBases, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return EchoBases{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p EchoBases_Promise) Then(f func(EchoBases, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(EchoBases{s}, err)
+	})
+}
+
 type StackingRoot struct{ capnp.Struct }
 
 // StackingRoot_TypeID is the unique identifier for the type StackingRoot.
@@ -4820,6 +5524,22 @@ func (p StackingRoot_Promise) Struct() (StackingRoot, error) {
 	return StackingRoot{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p StackingRoot_Promise) Await(ctx context.Context) (StackingRoot, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return StackingRoot{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p StackingRoot_Promise) Then(f func(StackingRoot, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(StackingRoot{s}, err)
+	})
+}
+
 func (p StackingRoot_Promise) A() StackingA_Promise {
 	return StackingA_Promise{Pipeline: p.Pipeline.GetPipeline(1)}
 }
@@ -4907,6 +5627,22 @@ func (p StackingA_Promise) Struct() (StackingA, error) {
 	return StackingA{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p StackingA_Promise) Await(ctx context.Context) (StackingA, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return StackingA{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p StackingA_Promise) Then(f func(StackingA, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(StackingA{s}, err)
+	})
+}
+
 func (p StackingA_Promise) B() StackingB_Promise {
 	return StackingB_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -4965,6 +5701,22 @@ func (p StackingB_Promise) Struct() (StackingB, error) {
 	return StackingB{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p StackingB_Promise) Await(ctx context.Context) (StackingB, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return StackingB{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p StackingB_Promise) Then(f func(StackingB, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(StackingB{s}, err)
+	})
+}
+
 type CallSequence struct{ Client capnp.Client }
 
 // CallSequence_TypeID is the unique identifier for the type CallSequence.
@@ -4995,9 +5747,9 @@ type CallSequence_Server interface {
 	GetNumber(CallSequence_getNumber) error
 }
 
-func CallSequence_ServerToClient(s CallSequence_Server) CallSequence {
+func CallSequence_ServerToClient(s CallSequence_Server, opts ...server.Option) CallSequence {
 	c, _ := s.(server.Closer)
-	return CallSequence{Client: server.New(CallSequence_Methods(nil, s), c)}
+	return CallSequence{Client: server.New(CallSequence_Methods(nil, s), c, opts...)}
 }
 
 func CallSequence_Methods(methods []server.Method, s CallSequence_Server) []server.Method {
@@ -5030,6 +5782,53 @@ type CallSequence_getNumber struct {
 	Results CallSequence_getNumber_Results
 }
 
+// CallSequence_Mock is a fake CallSequence_Server for use in tests.
+// Each method records a copy of its parameters and then calls the
+// function in the corresponding Func field, which should fill in
+// call.Results.  The copy is kept because call.Params is only valid
+// until the call returns.
+// Methods with a nil Func field return capnp.ErrUnimplemented.  Use
+// CallSequence_ServerToClient to obtain a client for the mock.
+type CallSequence_Mock struct {
+	GetNumberFunc func(CallSequence_getNumber) error
+
+	mu             sync.Mutex
+	getNumberCalls []CallSequence_getNumber_Params
+}
+
+func (m *CallSequence_Mock) GetNumber(call CallSequence_getNumber) error {
+	msg, err := capnp.CopyToNewMessage(call.Params.Struct.ToPtr())
+	if err != nil {
+		return err
+	}
+	p, err := msg.RootPtr()
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.getNumberCalls = append(m.getNumberCalls, CallSequence_getNumber_Params{Struct: p.Struct()})
+	f := m.GetNumberFunc
+	m.mu.Unlock()
+	if f == nil {
+		return capnp.ErrUnimplemented
+	}
+	return f(call)
+}
+
+// GetNumberCalls returns the parameters of each call to GetNumber, in order.
+func (m *CallSequence_Mock) GetNumberCalls() []CallSequence_getNumber_Params {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]CallSequence_getNumber_Params(nil), m.getNumberCalls...)
+}
+
+// GetNumberCallCount returns the number of calls to GetNumber.
+func (m *CallSequence_Mock) GetNumberCallCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.getNumberCalls)
+}
+
 type CallSequence_getNumber_Params struct{ capnp.Struct }
 
 // CallSequence_getNumber_Params_TypeID is the unique identifier for the type CallSequence_getNumber_Params.
@@ -5080,6 +5879,22 @@ func (p CallSequence_getNumber_Params_Promise) Struct() (CallSequence_getNumber_
 	return CallSequence_getNumber_Params{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p CallSequence_getNumber_Params_Promise) Await(ctx context.Context) (CallSequence_getNumber_Params, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return CallSequence_getNumber_Params{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p CallSequence_getNumber_Params_Promise) Then(f func(CallSequence_getNumber_Params, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(CallSequence_getNumber_Params{s}, err)
+	})
+}
+
 type CallSequence_getNumber_Results struct{ capnp.Struct }
 
 // CallSequence_getNumber_Results_TypeID is the unique identifier for the type CallSequence_getNumber_Results.
@@ -5138,6 +5953,22 @@ func (p CallSequence_getNumber_Results_Promise) Struct() (CallSequence_getNumber
 	return CallSequence_getNumber_Results{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p CallSequence_getNumber_Results_Promise) Await(ctx context.Context) (CallSequence_getNumber_Results, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return CallSequence_getNumber_Results{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p CallSequence_getNumber_Results_Promise) Then(f func(CallSequence_getNumber_Results, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(CallSequence_getNumber_Results{s}, err)
+	})
+}
+
 type Defaults struct{ capnp.Struct }
 
 // Defaults_TypeID is the unique identifier for the type Defaults.
@@ -5244,6 +6075,22 @@ func (p Defaults_Promise) Struct() (Defaults, error) {
 	return Defaults{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Defaults_Promise) Await(ctx context.Context) (Defaults, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Defaults{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Defaults_Promise) Then(f func(Defaults, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Defaults{s}, err)
+	})
+}
+
 type BenchmarkA struct{ capnp.Struct }
 
 // BenchmarkA_TypeID is the unique identifier for the type BenchmarkA.
@@ -5360,6 +6207,22 @@ func (p BenchmarkA_Promise) Struct() (BenchmarkA, error) {
 	return BenchmarkA{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p BenchmarkA_Promise) Await(ctx context.Context) (BenchmarkA, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return BenchmarkA{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p BenchmarkA_Promise) Then(f func(BenchmarkA, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(BenchmarkA{s}, err)
+	})
+}
+
 const schema_832bcc6686a26d56 = "x\xda\xacZ{t\x14e\x96\xbf\xb7\xaa\xbb+\xafN" +
 	"w\xe5+\x1e\x09\xc1H\x06$\xc4\xc1\xc9\x03\x032z" +
 	"\x02\x98\x8c\xe8\x01M\xd1 \xe2\x0e#\x95\xa4\x924v" +
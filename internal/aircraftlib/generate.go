@@ -1,3 +1,3 @@
 package aircraftlib
 
-//go:generate capnp compile -I ../../std -ogo aircraft.capnp
+//go:generate sh -c "capnp compile -I ../../std -o- aircraft.capnp | capnpc-go -mocks"
@@ -3,6 +3,7 @@
 package books
 
 import (
+	context "golang.org/x/net/context"
 	capnp "zombiezen.com/go/capnproto2"
 	text "zombiezen.com/go/capnproto2/encoding/text"
 	schemas "zombiezen.com/go/capnproto2/schemas"
@@ -81,6 +82,22 @@ func (p Book_Promise) Struct() (Book, error) {
 	return Book{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Book_Promise) Await(ctx context.Context) (Book, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Book{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Book_Promise) Then(f func(Book, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Book{s}, err)
+	})
+}
+
 const schema_85d3acc39d94e0f8 = "x\xda\x12\x88w`2d\xdd\xcf\xc8\xc0\x10(\xc2\xca" +
 	"\xb6\xbf\xe6\xca\x95\xeb\x1dg\x1a\x03y\x18\x19\xff\xffx" +
 	"0e\xee\xe15\x97[\x19X\x19\xd9\x19\x18\x04\x8fv" +
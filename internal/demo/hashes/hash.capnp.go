@@ -40,9 +40,9 @@ type HashFactory_Server interface {
 	NewSha1(HashFactory_newSha1) error
 }
 
-func HashFactory_ServerToClient(s HashFactory_Server) HashFactory {
+func HashFactory_ServerToClient(s HashFactory_Server, opts ...server.Option) HashFactory {
 	c, _ := s.(server.Closer)
-	return HashFactory{Client: server.New(HashFactory_Methods(nil, s), c)}
+	return HashFactory{Client: server.New(HashFactory_Methods(nil, s), c, opts...)}
 }
 
 func HashFactory_Methods(methods []server.Method, s HashFactory_Server) []server.Method {
@@ -125,6 +125,22 @@ func (p HashFactory_newSha1_Params_Promise) Struct() (HashFactory_newSha1_Params
 	return HashFactory_newSha1_Params{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p HashFactory_newSha1_Params_Promise) Await(ctx context.Context) (HashFactory_newSha1_Params, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return HashFactory_newSha1_Params{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p HashFactory_newSha1_Params_Promise) Then(f func(HashFactory_newSha1_Params, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(HashFactory_newSha1_Params{s}, err)
+	})
+}
+
 type HashFactory_newSha1_Results struct{ capnp.Struct }
 
 // HashFactory_newSha1_Results_TypeID is the unique identifier for the type HashFactory_newSha1_Results.
@@ -194,6 +210,22 @@ func (p HashFactory_newSha1_Results_Promise) Struct() (HashFactory_newSha1_Resul
 	return HashFactory_newSha1_Results{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p HashFactory_newSha1_Results_Promise) Await(ctx context.Context) (HashFactory_newSha1_Results, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return HashFactory_newSha1_Results{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p HashFactory_newSha1_Results_Promise) Then(f func(HashFactory_newSha1_Results, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(HashFactory_newSha1_Results{s}, err)
+	})
+}
+
 func (p HashFactory_newSha1_Results_Promise) Hash() Hash {
 	return Hash{Client: p.Pipeline.GetPipeline(0).Client()}
 }
@@ -250,9 +282,9 @@ type Hash_Server interface {
 	Sum(Hash_sum) error
 }
 
-func Hash_ServerToClient(s Hash_Server) Hash {
+func Hash_ServerToClient(s Hash_Server, opts ...server.Option) Hash {
 	c, _ := s.(server.Closer)
-	return Hash{Client: server.New(Hash_Methods(nil, s), c)}
+	return Hash{Client: server.New(Hash_Methods(nil, s), c, opts...)}
 }
 
 func Hash_Methods(methods []server.Method, s Hash_Server) []server.Method {
@@ -371,6 +403,22 @@ func (p Hash_write_Params_Promise) Struct() (Hash_write_Params, error) {
 	return Hash_write_Params{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Hash_write_Params_Promise) Await(ctx context.Context) (Hash_write_Params, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Hash_write_Params{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Hash_write_Params_Promise) Then(f func(Hash_write_Params, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Hash_write_Params{s}, err)
+	})
+}
+
 type Hash_write_Results struct{ capnp.Struct }
 
 // Hash_write_Results_TypeID is the unique identifier for the type Hash_write_Results.
@@ -421,6 +469,22 @@ func (p Hash_write_Results_Promise) Struct() (Hash_write_Results, error) {
 	return Hash_write_Results{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Hash_write_Results_Promise) Await(ctx context.Context) (Hash_write_Results, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Hash_write_Results{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Hash_write_Results_Promise) Then(f func(Hash_write_Results, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Hash_write_Results{s}, err)
+	})
+}
+
 type Hash_sum_Params struct{ capnp.Struct }
 
 // Hash_sum_Params_TypeID is the unique identifier for the type Hash_sum_Params.
@@ -469,6 +533,22 @@ func (p Hash_sum_Params_Promise) Struct() (Hash_sum_Params, error) {
 	return Hash_sum_Params{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Hash_sum_Params_Promise) Await(ctx context.Context) (Hash_sum_Params, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Hash_sum_Params{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Hash_sum_Params_Promise) Then(f func(Hash_sum_Params, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Hash_sum_Params{s}, err)
+	})
+}
+
 type Hash_sum_Results struct{ capnp.Struct }
 
 // Hash_sum_Results_TypeID is the unique identifier for the type Hash_sum_Results.
@@ -531,6 +611,22 @@ func (p Hash_sum_Results_Promise) Struct() (Hash_sum_Results, error) {
 	return Hash_sum_Results{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Hash_sum_Results_Promise) Await(ctx context.Context) (Hash_sum_Results, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Hash_sum_Results{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Hash_sum_Results_Promise) Then(f func(Hash_sum_Results, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Hash_sum_Results{s}, err)
+	})
+}
+
 const schema_db8274f9144abc7e = "x\xda\x84\x92?h\x13a\x18\xc6\x9f\xe7\xbb;\xaf\xa8" +
 	"G\xfaq\x05\xe9bP\"\x82\xd8\xe2\xb5[\x05\x13\x1c" +
 	"Tt\xb9\x8b\x0e\xe2\xf6QO#$\xb5\xe4.\x14\x11" +
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"sync"
 
+	"golang.org/x/net/context"
 	"zombiezen.com/go/capnproto2"
 	"zombiezen.com/go/capnproto2/internal/queue"
 )
@@ -22,9 +23,10 @@ type Fulfiller struct {
 	resolved chan struct{} // initialized by init()
 
 	// Protected by mu
-	mu     sync.RWMutex
-	answer capnp.Answer
-	queue  []pcall // initialized by init()
+	mu        sync.RWMutex
+	answer    capnp.Answer
+	queue     []pcall // initialized by init()
+	callbacks []func()
 }
 
 // init initializes the Fulfiller.  It is idempotent.
@@ -53,8 +55,13 @@ func (f *Fulfiller) Fulfill(s capnp.Struct) {
 	for capIdx, q := range queues {
 		ctab[capIdx] = newEmbargoClient(ctab[capIdx], q)
 	}
+	callbacks := f.callbacks
+	f.callbacks = nil
 	close(f.resolved)
 	f.mu.Unlock()
+	for _, cb := range callbacks {
+		cb()
+	}
 }
 
 // emptyQueue splits the queue by which capability it targets and
@@ -101,8 +108,13 @@ func (f *Fulfiller) Reject(err error) {
 		f.queue[i].f.Reject(err)
 		f.queue[i] = pcall{}
 	}
+	callbacks := f.callbacks
+	f.callbacks = nil
 	close(f.resolved)
 	f.mu.Unlock()
+	for _, cb := range callbacks {
+		cb()
+	}
 }
 
 // Done returns a channel that is closed once f is resolved.
@@ -121,6 +133,21 @@ func (f *Fulfiller) Peek() capnp.Answer {
 	return a
 }
 
+// OnResolve arranges for cb to be called once f is resolved, or calls
+// cb right away if f is already resolved.  cb is called from Fulfill or
+// Reject after f's lock is released, so it may call f's methods.
+func (f *Fulfiller) OnResolve(cb func()) {
+	f.init()
+	f.mu.Lock()
+	if f.answer != nil {
+		f.mu.Unlock()
+		cb()
+		return
+	}
+	f.callbacks = append(f.callbacks, cb)
+	f.mu.Unlock()
+}
+
 // Struct waits until f is resolved and returns its struct if fulfilled
 // or an error if rejected.
 func (f *Fulfiller) Struct() (capnp.Struct, error) {
@@ -162,9 +189,33 @@ func (f *Fulfiller) PipelineCall(transform []capnp.PipelineOp, call *capnp.Call)
 		},
 	})
 	f.mu.Unlock()
+	if cc.Ctx != nil {
+		go f.watchQueued(g, cc.Ctx)
+	}
 	return g
 }
 
+// watchQueued waits for either f to be resolved or for ctx to be done.
+// If ctx finishes first, the queued call for g is removed from the
+// queue and g is rejected with the context's error.
+func (f *Fulfiller) watchQueued(g *Fulfiller, ctx context.Context) {
+	select {
+	case <-f.resolved:
+		return
+	case <-ctx.Done():
+	}
+	f.mu.Lock()
+	for i := range f.queue {
+		if f.queue[i].f == g {
+			f.queue = append(f.queue[:i], f.queue[i+1:]...)
+			f.mu.Unlock()
+			g.Reject(ctx.Err())
+			return
+		}
+	}
+	f.mu.Unlock()
+}
+
 // PipelineClose waits until f is resolved and then calls PipelineClose
 // on the fulfilled answer.
 func (f *Fulfiller) PipelineClose(transform []capnp.PipelineOp) error {
@@ -223,7 +274,13 @@ func (ec *EmbargoClient) flushQueue() {
 	}
 	ec.mu.Unlock()
 	for c.call != nil {
-		ans := ec.client.Call(c.call)
+		var ans capnp.Answer
+		if c.call.Ctx != nil && c.call.Ctx.Err() != nil {
+			// Don't deliver calls that were canceled while embargoed.
+			ans = capnp.ErrorAnswer(c.call.Ctx.Err())
+		} else {
+			ans = ec.client.Call(c.call)
+		}
 		go func(f *Fulfiller, ans capnp.Answer) {
 			s, err := ans.Struct()
 			if err == nil {
@@ -3,7 +3,9 @@ package fulfiller
 import (
 	"errors"
 	"testing"
+	"time"
 
+	"golang.org/x/net/context"
 	"zombiezen.com/go/capnproto2"
 )
 
@@ -61,6 +63,30 @@ func TestFulfiller_RejectShouldResolve(t *testing.T) {
 	}
 }
 
+func TestFulfiller_OnResolveCalledOnResolution(t *testing.T) {
+	f := new(Fulfiller)
+	e := errors.New("failure and rejection")
+	var calls []error
+	f.OnResolve(func() {
+		_, err := f.Struct()
+		calls = append(calls, err)
+	})
+	if len(calls) != 0 {
+		t.Fatal("OnResolve callback called before Reject")
+	}
+
+	f.Reject(e)
+	if len(calls) != 1 || calls[0] != e {
+		t.Fatalf("after Reject, callback errors = %v; want [%v]", calls, e)
+	}
+	f.OnResolve(func() {
+		calls = append(calls, nil)
+	})
+	if len(calls) != 2 {
+		t.Error("OnResolve on resolved Fulfiller did not call callback")
+	}
+}
+
 func TestFulfiller_QueuedCallsDeliveredInOrder(t *testing.T) {
 	f := new(Fulfiller)
 	oc := new(orderClient)
@@ -90,6 +116,36 @@ func TestFulfiller_QueuedCallsDeliveredInOrder(t *testing.T) {
 	check(ans4, 3)
 }
 
+func TestFulfiller_CanceledQueuedCallIsDropped(t *testing.T) {
+	f := new(Fulfiller)
+	oc := new(orderClient)
+	result := newStruct(t, capnp.ObjectSize{PointerCount: 1})
+	in := result.Segment().Message().AddCap(oc)
+	result.SetPointer(0, capnp.NewInterface(result.Segment(), in))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ans1 := f.PipelineCall([]capnp.PipelineOp{{Field: 0}}, &capnp.Call{Ctx: ctx})
+	ans2 := f.PipelineCall([]capnp.PipelineOp{{Field: 0}}, &capnp.Call{Ctx: context.Background()})
+	cancel()
+	select {
+	case <-ans1.(*Fulfiller).Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("canceled call not rejected before fulfill")
+	}
+	if _, err := ans1.Struct(); err != context.Canceled {
+		t.Errorf("canceled call error = %v; want %v", err, context.Canceled)
+	}
+	f.Fulfill(result)
+
+	r, err := ans2.Struct()
+	if err != nil {
+		t.Fatal("uncanceled call:", err)
+	}
+	if r.Uint64(0) != 0 {
+		t.Errorf("uncanceled call was call #%d; want #0 (canceled call was delivered)", r.Uint64(0))
+	}
+}
+
 func newStruct(t *testing.T, sz capnp.ObjectSize) capnp.Struct {
 	_, s, err := capnp.NewMessage(capnp.SingleSegment(nil))
 	if err != nil {
@@ -0,0 +1,114 @@
+// +build go1.18
+
+package fuzztest
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"zombiezen.com/go/capnproto2"
+)
+
+// FuzzZ fuzzes a single unframed segment, like the go-fuzz harness.
+func FuzzZ(f *testing.F) {
+	for _, data := range readCorpus(f) {
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		readZ(&capnp.Message{Arena: capnp.SingleSegment(padToWord(data))})
+	})
+}
+
+// FuzzZMultiSegment fuzzes framed messages, which may have any number
+// of segments.  The seeds place every object in its own segment, so
+// they are full of far and double-far pointers.
+func FuzzZMultiSegment(f *testing.F) {
+	for _, data := range readCorpus(f) {
+		msg := &capnp.Message{Arena: capnp.SingleSegment(padToWord(data))}
+		if readZ(msg) == 0 {
+			continue
+		}
+		seed, err := spreadSegments(msg)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := capnp.Unmarshal(data)
+		if err != nil {
+			return
+		}
+		readZ(msg)
+	})
+}
+
+func readCorpus(f testing.TB) [][]byte {
+	dir := "corpus"
+	names, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	var corpus [][]byte
+	for _, name := range names {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		corpus = append(corpus, data)
+	}
+	return corpus
+}
+
+func padToWord(data []byte) []byte {
+	if len(data)%8 == 0 {
+		return data
+	}
+	data = append([]byte(nil), data...)
+	for len(data)%8 != 0 {
+		data = append(data, 0)
+	}
+	return data
+}
+
+// spreadSegments copies the root of msg into a new message that has
+// one segment per object, then marshals it.
+func spreadSegments(msg *capnp.Message) ([]byte, error) {
+	root, err := msg.RootPtr()
+	if err != nil {
+		return nil, err
+	}
+	dst, _, err := capnp.NewMessage(&exactArena{segs: [][]byte{make([]byte, 0, 8)}})
+	if err != nil {
+		return nil, err
+	}
+	if err := dst.SetRootPtr(root); err != nil {
+		return nil, err
+	}
+	return dst.Marshal()
+}
+
+// exactArena allocates every object in a new segment that is exactly
+// large enough to hold it.
+type exactArena struct {
+	segs [][]byte
+}
+
+func (a *exactArena) NumSegments() int64 {
+	return int64(len(a.segs))
+}
+
+func (a *exactArena) Data(id capnp.SegmentID) ([]byte, error) {
+	if int64(id) >= int64(len(a.segs)) {
+		return nil, errors.New("segment out of bounds")
+	}
+	return a.segs[id], nil
+}
+
+func (a *exactArena) Allocate(minsz capnp.Size, segs map[capnp.SegmentID]*capnp.Segment) (capnp.SegmentID, []byte, error) {
+	id := capnp.SegmentID(len(a.segs))
+	a.segs = append(a.segs, make([]byte, 0, minsz))
+	return id, a.segs[id], nil
+}
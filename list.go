@@ -1,6 +1,7 @@
 package capnp
 
 import (
+	"encoding/binary"
 	"errors"
 	"math"
 )
@@ -176,6 +177,24 @@ func (p List) Len() int {
 	return int(p.length)
 }
 
+// ElementSize returns the size of each element in the list.  A list
+// of bits has a zero size; use IsBitList to tell it apart from a list
+// of Void.
+func (p List) ElementSize() ObjectSize {
+	return p.size
+}
+
+// IsBitList reports whether the list's elements are single bits.
+func (p List) IsBitList() bool {
+	return p.seg != nil && p.flags&isBitList != 0
+}
+
+// IsComposite reports whether the list uses the inline composite
+// encoding, where each element is a struct of ElementSize.
+func (p List) IsComposite() bool {
+	return p.seg != nil && p.flags&isCompositeList != 0
+}
+
 // primitiveElem returns the address of the segment data for a list element.
 // Calling this on a bit list returns an error.
 func (p List) primitiveElem(i int, expectedSize ObjectSize) (Address, error) {
@@ -193,6 +212,23 @@ func (p List) primitiveElem(i int, expectedSize ObjectSize) (Address, error) {
 	return addr, nil
 }
 
+// primitiveData returns the segment data for the n elements starting
+// at i, or nil if the list isn't a list of primitives of size sz.
+// Reading the data directly avoids checking each element.
+func (p List) primitiveData(i, n int, sz Size) []byte {
+	if n == 0 {
+		return []byte{}
+	}
+	if p.seg == nil || i < 0 || n < 0 || i+n > int(p.length) {
+		// This is programmer error, not input error.
+		panic(errOutOfBounds)
+	}
+	if p.flags&(isBitList|isCompositeList) != 0 || p.size != (ObjectSize{DataSize: sz}) {
+		return nil
+	}
+	return p.seg.slice(p.off+Address(i)*Address(sz), Size(n)*sz)
+}
+
 // Struct returns the i'th element as a struct.
 func (p List) Struct(i int) Struct {
 	if p.seg == nil || i < 0 || i >= int(p.length) {
@@ -241,6 +277,25 @@ func NewBitList(s *Segment, n int32) (BitList, error) {
 	}}, nil
 }
 
+// NewBitListFrom creates a new list of booleans holding the elements
+// of v, preferring placement in s.
+func NewBitListFrom(s *Segment, v []bool) (BitList, error) {
+	if len(v) > maxListElements {
+		return BitList{}, errOverflow
+	}
+	l, err := NewBitList(s, int32(len(v)))
+	if err != nil {
+		return BitList{}, err
+	}
+	b := l.seg.slice(l.off, Size(len(v)+7)/8)
+	for i, x := range v {
+		if x {
+			b[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return l, nil
+}
+
 // At returns the i'th bit.
 func (p BitList) At(i int) bool {
 	if p.seg == nil || i < 0 || i >= int(p.length) {
@@ -275,6 +330,26 @@ func (p BitList) Set(i int, v bool) {
 	}
 }
 
+// CopyTo copies the list's elements into dst and returns the number of
+// elements copied, which is the smaller of p.Len() and len(dst).
+func (p BitList) CopyTo(dst []bool) int {
+	n := p.Len()
+	if n > len(dst) {
+		n = len(dst)
+	}
+	if n == 0 || p.flags&isBitList == 0 {
+		for i := range dst[:n] {
+			dst[i] = false
+		}
+		return n
+	}
+	b := p.seg.slice(p.off, Size(n+7)/8)
+	for i := range dst[:n] {
+		dst[i] = b[i/8]&(1<<uint(i%8)) != 0
+	}
+	return n
+}
+
 // A PointerList is a reference to an array of pointers.
 type PointerList struct{ List }
 
@@ -342,6 +417,24 @@ func NewTextList(s *Segment, n int32) (TextList, error) {
 	return TextList{pl.List}, nil
 }
 
+// NewTextListFrom creates a new list of text holding the elements of
+// v, preferring placement in s.
+func NewTextListFrom(s *Segment, v []string) (TextList, error) {
+	if len(v) > maxListElements {
+		return TextList{}, errOverflow
+	}
+	l, err := NewTextList(s, int32(len(v)))
+	if err != nil {
+		return TextList{}, err
+	}
+	for i, x := range v {
+		if err := l.Set(i, x); err != nil {
+			return TextList{}, err
+		}
+	}
+	return l, nil
+}
+
 // At returns the i'th string in the list.
 func (l TextList) At(i int) (string, error) {
 	addr, err := l.primitiveElem(i, ObjectSize{PointerCount: 1})
@@ -451,6 +544,20 @@ func NewUInt8List(s *Segment, n int32) (UInt8List, error) {
 	return UInt8List{l}, nil
 }
 
+// NewUInt8ListFrom creates a new list of UInt8 holding the elements of v,
+// preferring placement in s.
+func NewUInt8ListFrom(s *Segment, v []uint8) (UInt8List, error) {
+	if len(v) > maxListElements {
+		return UInt8List{}, errOverflow
+	}
+	l, err := NewUInt8List(s, int32(len(v)))
+	if err != nil {
+		return UInt8List{}, err
+	}
+	l.copyFrom(0, v)
+	return l, nil
+}
+
 // NewText creates a new list of UInt8 from a string.
 func NewText(s *Segment, v string) (UInt8List, error) {
 	// TODO(light): error if v is too long
@@ -534,6 +641,65 @@ func (l UInt8List) Set(i int, v uint8) {
 	l.seg.writeUint8(addr, v)
 }
 
+// Slice returns the list's elements.  If the platform is little-endian
+// and the list's data is suitably aligned, the slice refers to the
+// message's memory instead of a copy, so it must not be modified and is
+// only valid while the message is.  Use CopyTo for a copy.
+func (l UInt8List) Slice() []uint8 {
+	b := l.primitiveData(0, l.Len(), 1)
+	if b == nil {
+		v := make([]uint8, l.Len())
+		l.CopyTo(v)
+		return v
+	}
+	return b
+}
+
+// CopyTo copies the list's elements into dst and returns the number of
+// elements copied, which is the smaller of l.Len() and len(dst).
+func (l UInt8List) CopyTo(dst []uint8) int {
+	n := l.Len()
+	if n > len(dst) {
+		n = len(dst)
+	}
+	b := l.primitiveData(0, n, 1)
+	if b == nil {
+		for i := 0; i < n; i++ {
+			dst[i] = l.At(i)
+		}
+		return n
+	}
+	return copy(dst[:n], b)
+}
+
+// CopyFrom sets the list's first elements to the elements of src and
+// returns the number of elements copied, which is the smaller of
+// l.Len() and len(src).
+func (l UInt8List) CopyFrom(src []uint8) int {
+	n := l.Len()
+	if n > len(src) {
+		n = len(src)
+	}
+	if l.primitiveData(0, n, 1) == nil {
+		for i := 0; i < n; i++ {
+			l.Set(i, src[i])
+		}
+		return n
+	}
+	l.copyFrom(0, src[:n])
+	return n
+}
+
+// copyFrom sets the elements starting at i to v.  The list must not be
+// a composite list.
+func (l UInt8List) copyFrom(i int, v []uint8) {
+	b := l.primitiveData(i, len(v), 1)
+	if b == nil {
+		panic(errElementSize)
+	}
+	copy(b, v)
+}
+
 // Int8List is an array of Int8 values.
 type Int8List struct{ List }
 
@@ -546,6 +712,20 @@ func NewInt8List(s *Segment, n int32) (Int8List, error) {
 	return Int8List{l}, nil
 }
 
+// NewInt8ListFrom creates a new list of Int8 holding the elements of v,
+// preferring placement in s.
+func NewInt8ListFrom(s *Segment, v []int8) (Int8List, error) {
+	if len(v) > maxListElements {
+		return Int8List{}, errOverflow
+	}
+	l, err := NewInt8List(s, int32(len(v)))
+	if err != nil {
+		return Int8List{}, err
+	}
+	l.copyFrom(0, v)
+	return l, nil
+}
+
 // At returns the i'th element.
 func (l Int8List) At(i int) int8 {
 	addr, err := l.primitiveElem(i, ObjectSize{DataSize: 1})
@@ -564,6 +744,77 @@ func (l Int8List) Set(i int, v int8) {
 	l.seg.writeUint8(addr, uint8(v))
 }
 
+// Slice returns the list's elements.  If the platform is little-endian
+// and the list's data is suitably aligned, the slice refers to the
+// message's memory instead of a copy, so it must not be modified and is
+// only valid while the message is.  Use CopyTo for a copy.
+func (l Int8List) Slice() []int8 {
+	if v := bytesAsInt8s(l.primitiveData(0, l.Len(), 1)); v != nil {
+		return v
+	}
+	v := make([]int8, l.Len())
+	l.CopyTo(v)
+	return v
+}
+
+// CopyTo copies the list's elements into dst and returns the number of
+// elements copied, which is the smaller of l.Len() and len(dst).
+func (l Int8List) CopyTo(dst []int8) int {
+	n := l.Len()
+	if n > len(dst) {
+		n = len(dst)
+	}
+	b := l.primitiveData(0, n, 1)
+	if b == nil {
+		for i := 0; i < n; i++ {
+			dst[i] = l.At(i)
+		}
+		return n
+	}
+	if db := int8sAsBytes(dst[:n]); db != nil {
+		copy(db, b)
+		return n
+	}
+	for i := range dst[:n] {
+		dst[i] = int8(b[i])
+	}
+	return n
+}
+
+// CopyFrom sets the list's first elements to the elements of src and
+// returns the number of elements copied, which is the smaller of
+// l.Len() and len(src).
+func (l Int8List) CopyFrom(src []int8) int {
+	n := l.Len()
+	if n > len(src) {
+		n = len(src)
+	}
+	if l.primitiveData(0, n, 1) == nil {
+		for i := 0; i < n; i++ {
+			l.Set(i, src[i])
+		}
+		return n
+	}
+	l.copyFrom(0, src[:n])
+	return n
+}
+
+// copyFrom sets the elements starting at i to v.  The list must not be
+// a composite list.
+func (l Int8List) copyFrom(i int, v []int8) {
+	b := l.primitiveData(i, len(v), 1)
+	if b == nil {
+		panic(errElementSize)
+	}
+	if vb := int8sAsBytes(v); vb != nil {
+		copy(b, vb)
+		return
+	}
+	for j, x := range v {
+		b[j] = uint8(x)
+	}
+}
+
 // A UInt16List is an array of UInt16 values.
 type UInt16List struct{ List }
 
@@ -576,6 +827,20 @@ func NewUInt16List(s *Segment, n int32) (UInt16List, error) {
 	return UInt16List{l}, nil
 }
 
+// NewUInt16ListFrom creates a new list of UInt16 holding the elements of v,
+// preferring placement in s.
+func NewUInt16ListFrom(s *Segment, v []uint16) (UInt16List, error) {
+	if len(v) > maxListElements {
+		return UInt16List{}, errOverflow
+	}
+	l, err := NewUInt16List(s, int32(len(v)))
+	if err != nil {
+		return UInt16List{}, err
+	}
+	l.copyFrom(0, v)
+	return l, nil
+}
+
 // At returns the i'th element.
 func (l UInt16List) At(i int) uint16 {
 	addr, err := l.primitiveElem(i, ObjectSize{DataSize: 2})
@@ -594,6 +859,77 @@ func (l UInt16List) Set(i int, v uint16) {
 	l.seg.writeUint16(addr, v)
 }
 
+// Slice returns the list's elements.  If the platform is little-endian
+// and the list's data is suitably aligned, the slice refers to the
+// message's memory instead of a copy, so it must not be modified and is
+// only valid while the message is.  Use CopyTo for a copy.
+func (l UInt16List) Slice() []uint16 {
+	if v := bytesAsUInt16s(l.primitiveData(0, l.Len(), 2)); v != nil {
+		return v
+	}
+	v := make([]uint16, l.Len())
+	l.CopyTo(v)
+	return v
+}
+
+// CopyTo copies the list's elements into dst and returns the number of
+// elements copied, which is the smaller of l.Len() and len(dst).
+func (l UInt16List) CopyTo(dst []uint16) int {
+	n := l.Len()
+	if n > len(dst) {
+		n = len(dst)
+	}
+	b := l.primitiveData(0, n, 2)
+	if b == nil {
+		for i := 0; i < n; i++ {
+			dst[i] = l.At(i)
+		}
+		return n
+	}
+	if db := uint16sAsBytes(dst[:n]); db != nil {
+		copy(db, b)
+		return n
+	}
+	for i := range dst[:n] {
+		dst[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	return n
+}
+
+// CopyFrom sets the list's first elements to the elements of src and
+// returns the number of elements copied, which is the smaller of
+// l.Len() and len(src).
+func (l UInt16List) CopyFrom(src []uint16) int {
+	n := l.Len()
+	if n > len(src) {
+		n = len(src)
+	}
+	if l.primitiveData(0, n, 2) == nil {
+		for i := 0; i < n; i++ {
+			l.Set(i, src[i])
+		}
+		return n
+	}
+	l.copyFrom(0, src[:n])
+	return n
+}
+
+// copyFrom sets the elements starting at i to v.  The list must not be
+// a composite list.
+func (l UInt16List) copyFrom(i int, v []uint16) {
+	b := l.primitiveData(i, len(v), 2)
+	if b == nil {
+		panic(errElementSize)
+	}
+	if vb := uint16sAsBytes(v); vb != nil {
+		copy(b, vb)
+		return
+	}
+	for j, x := range v {
+		binary.LittleEndian.PutUint16(b[j*2:], x)
+	}
+}
+
 // Int16List is an array of Int16 values.
 type Int16List struct{ List }
 
@@ -606,6 +942,20 @@ func NewInt16List(s *Segment, n int32) (Int16List, error) {
 	return Int16List{l}, nil
 }
 
+// NewInt16ListFrom creates a new list of Int16 holding the elements of v,
+// preferring placement in s.
+func NewInt16ListFrom(s *Segment, v []int16) (Int16List, error) {
+	if len(v) > maxListElements {
+		return Int16List{}, errOverflow
+	}
+	l, err := NewInt16List(s, int32(len(v)))
+	if err != nil {
+		return Int16List{}, err
+	}
+	l.copyFrom(0, v)
+	return l, nil
+}
+
 // At returns the i'th element.
 func (l Int16List) At(i int) int16 {
 	addr, err := l.primitiveElem(i, ObjectSize{DataSize: 2})
@@ -624,6 +974,77 @@ func (l Int16List) Set(i int, v int16) {
 	l.seg.writeUint16(addr, uint16(v))
 }
 
+// Slice returns the list's elements.  If the platform is little-endian
+// and the list's data is suitably aligned, the slice refers to the
+// message's memory instead of a copy, so it must not be modified and is
+// only valid while the message is.  Use CopyTo for a copy.
+func (l Int16List) Slice() []int16 {
+	if v := bytesAsInt16s(l.primitiveData(0, l.Len(), 2)); v != nil {
+		return v
+	}
+	v := make([]int16, l.Len())
+	l.CopyTo(v)
+	return v
+}
+
+// CopyTo copies the list's elements into dst and returns the number of
+// elements copied, which is the smaller of l.Len() and len(dst).
+func (l Int16List) CopyTo(dst []int16) int {
+	n := l.Len()
+	if n > len(dst) {
+		n = len(dst)
+	}
+	b := l.primitiveData(0, n, 2)
+	if b == nil {
+		for i := 0; i < n; i++ {
+			dst[i] = l.At(i)
+		}
+		return n
+	}
+	if db := int16sAsBytes(dst[:n]); db != nil {
+		copy(db, b)
+		return n
+	}
+	for i := range dst[:n] {
+		dst[i] = int16(binary.LittleEndian.Uint16(b[i*2:]))
+	}
+	return n
+}
+
+// CopyFrom sets the list's first elements to the elements of src and
+// returns the number of elements copied, which is the smaller of
+// l.Len() and len(src).
+func (l Int16List) CopyFrom(src []int16) int {
+	n := l.Len()
+	if n > len(src) {
+		n = len(src)
+	}
+	if l.primitiveData(0, n, 2) == nil {
+		for i := 0; i < n; i++ {
+			l.Set(i, src[i])
+		}
+		return n
+	}
+	l.copyFrom(0, src[:n])
+	return n
+}
+
+// copyFrom sets the elements starting at i to v.  The list must not be
+// a composite list.
+func (l Int16List) copyFrom(i int, v []int16) {
+	b := l.primitiveData(i, len(v), 2)
+	if b == nil {
+		panic(errElementSize)
+	}
+	if vb := int16sAsBytes(v); vb != nil {
+		copy(b, vb)
+		return
+	}
+	for j, x := range v {
+		binary.LittleEndian.PutUint16(b[j*2:], uint16(x))
+	}
+}
+
 // UInt32List is an array of UInt32 values.
 type UInt32List struct{ List }
 
@@ -636,6 +1057,20 @@ func NewUInt32List(s *Segment, n int32) (UInt32List, error) {
 	return UInt32List{l}, nil
 }
 
+// NewUInt32ListFrom creates a new list of UInt32 holding the elements of v,
+// preferring placement in s.
+func NewUInt32ListFrom(s *Segment, v []uint32) (UInt32List, error) {
+	if len(v) > maxListElements {
+		return UInt32List{}, errOverflow
+	}
+	l, err := NewUInt32List(s, int32(len(v)))
+	if err != nil {
+		return UInt32List{}, err
+	}
+	l.copyFrom(0, v)
+	return l, nil
+}
+
 // At returns the i'th element.
 func (l UInt32List) At(i int) uint32 {
 	addr, err := l.primitiveElem(i, ObjectSize{DataSize: 4})
@@ -654,6 +1089,77 @@ func (l UInt32List) Set(i int, v uint32) {
 	l.seg.writeUint32(addr, v)
 }
 
+// Slice returns the list's elements.  If the platform is little-endian
+// and the list's data is suitably aligned, the slice refers to the
+// message's memory instead of a copy, so it must not be modified and is
+// only valid while the message is.  Use CopyTo for a copy.
+func (l UInt32List) Slice() []uint32 {
+	if v := bytesAsUInt32s(l.primitiveData(0, l.Len(), 4)); v != nil {
+		return v
+	}
+	v := make([]uint32, l.Len())
+	l.CopyTo(v)
+	return v
+}
+
+// CopyTo copies the list's elements into dst and returns the number of
+// elements copied, which is the smaller of l.Len() and len(dst).
+func (l UInt32List) CopyTo(dst []uint32) int {
+	n := l.Len()
+	if n > len(dst) {
+		n = len(dst)
+	}
+	b := l.primitiveData(0, n, 4)
+	if b == nil {
+		for i := 0; i < n; i++ {
+			dst[i] = l.At(i)
+		}
+		return n
+	}
+	if db := uint32sAsBytes(dst[:n]); db != nil {
+		copy(db, b)
+		return n
+	}
+	for i := range dst[:n] {
+		dst[i] = binary.LittleEndian.Uint32(b[i*4:])
+	}
+	return n
+}
+
+// CopyFrom sets the list's first elements to the elements of src and
+// returns the number of elements copied, which is the smaller of
+// l.Len() and len(src).
+func (l UInt32List) CopyFrom(src []uint32) int {
+	n := l.Len()
+	if n > len(src) {
+		n = len(src)
+	}
+	if l.primitiveData(0, n, 4) == nil {
+		for i := 0; i < n; i++ {
+			l.Set(i, src[i])
+		}
+		return n
+	}
+	l.copyFrom(0, src[:n])
+	return n
+}
+
+// copyFrom sets the elements starting at i to v.  The list must not be
+// a composite list.
+func (l UInt32List) copyFrom(i int, v []uint32) {
+	b := l.primitiveData(i, len(v), 4)
+	if b == nil {
+		panic(errElementSize)
+	}
+	if vb := uint32sAsBytes(v); vb != nil {
+		copy(b, vb)
+		return
+	}
+	for j, x := range v {
+		binary.LittleEndian.PutUint32(b[j*4:], x)
+	}
+}
+
 // Int32List is an array of Int32 values.
 type Int32List struct{ List }
 
@@ -666,6 +1172,20 @@ func NewInt32List(s *Segment, n int32) (Int32List, error) {
 	return Int32List{l}, nil
 }
 
+// NewInt32ListFrom creates a new list of Int32 holding the elements of v,
+// preferring placement in s.
+func NewInt32ListFrom(s *Segment, v []int32) (Int32List, error) {
+	if len(v) > maxListElements {
+		return Int32List{}, errOverflow
+	}
+	l, err := NewInt32List(s, int32(len(v)))
+	if err != nil {
+		return Int32List{}, err
+	}
+	l.copyFrom(0, v)
+	return l, nil
+}
+
 // At returns the i'th element.
 func (l Int32List) At(i int) int32 {
 	addr, err := l.primitiveElem(i, ObjectSize{DataSize: 4})
@@ -684,6 +1204,77 @@ func (l Int32List) Set(i int, v int32) {
 	l.seg.writeUint32(addr, uint32(v))
 }
 
+// Slice returns the list's elements.  If the platform is little-endian
+// and the list's data is suitably aligned, the slice refers to the
+// message's memory instead of a copy, so it must not be modified and is
+// only valid while the message is.  Use CopyTo for a copy.
+func (l Int32List) Slice() []int32 {
+	if v := bytesAsInt32s(l.primitiveData(0, l.Len(), 4)); v != nil {
+		return v
+	}
+	v := make([]int32, l.Len())
+	l.CopyTo(v)
+	return v
+}
+
+// CopyTo copies the list's elements into dst and returns the number of
+// elements copied, which is the smaller of l.Len() and len(dst).
+func (l Int32List) CopyTo(dst []int32) int {
+	n := l.Len()
+	if n > len(dst) {
+		n = len(dst)
+	}
+	b := l.primitiveData(0, n, 4)
+	if b == nil {
+		for i := 0; i < n; i++ {
+			dst[i] = l.At(i)
+		}
+		return n
+	}
+	if db := int32sAsBytes(dst[:n]); db != nil {
+		copy(db, b)
+		return n
+	}
+	for i := range dst[:n] {
+		dst[i] = int32(binary.LittleEndian.Uint32(b[i*4:]))
+	}
+	return n
+}
+
+// CopyFrom sets the list's first elements to the elements of src and
+// returns the number of elements copied, which is the smaller of
+// l.Len() and len(src).
+func (l Int32List) CopyFrom(src []int32) int {
+	n := l.Len()
+	if n > len(src) {
+		n = len(src)
+	}
+	if l.primitiveData(0, n, 4) == nil {
+		for i := 0; i < n; i++ {
+			l.Set(i, src[i])
+		}
+		return n
+	}
+	l.copyFrom(0, src[:n])
+	return n
+}
+
+// copyFrom sets the elements starting at i to v.  The list must not be
+// a composite list.
+func (l Int32List) copyFrom(i int, v []int32) {
+	b := l.primitiveData(i, len(v), 4)
+	if b == nil {
+		panic(errElementSize)
+	}
+	if vb := int32sAsBytes(v); vb != nil {
+		copy(b, vb)
+		return
+	}
+	for j, x := range v {
+		binary.LittleEndian.PutUint32(b[j*4:], uint32(x))
+	}
+}
+
 // UInt64List is an array of UInt64 values.
 type UInt64List struct{ List }
 
@@ -696,6 +1287,20 @@ func NewUInt64List(s *Segment, n int32) (UInt64List, error) {
 	return UInt64List{l}, nil
 }
 
+// NewUInt64ListFrom creates a new list of UInt64 holding the elements of v,
+// preferring placement in s.
+func NewUInt64ListFrom(s *Segment, v []uint64) (UInt64List, error) {
+	if len(v) > maxListElements {
+		return UInt64List{}, errOverflow
+	}
+	l, err := NewUInt64List(s, int32(len(v)))
+	if err != nil {
+		return UInt64List{}, err
+	}
+	l.copyFrom(0, v)
+	return l, nil
+}
+
 // At returns the i'th element.
 func (l UInt64List) At(i int) uint64 {
 	addr, err := l.primitiveElem(i, ObjectSize{DataSize: 8})
@@ -714,6 +1319,77 @@ func (l UInt64List) Set(i int, v uint64) {
 	l.seg.writeUint64(addr, v)
 }
 
+// Slice returns the list's elements.  If the platform is little-endian
+// and the list's data is suitably aligned, the slice refers to the
+// message's memory instead of a copy, so it must not be modified and is
+// only valid while the message is.  Use CopyTo for a copy.
+func (l UInt64List) Slice() []uint64 {
+	if v := bytesAsUInt64s(l.primitiveData(0, l.Len(), 8)); v != nil {
+		return v
+	}
+	v := make([]uint64, l.Len())
+	l.CopyTo(v)
+	return v
+}
+
+// CopyTo copies the list's elements into dst and returns the number of
+// elements copied, which is the smaller of l.Len() and len(dst).
+func (l UInt64List) CopyTo(dst []uint64) int {
+	n := l.Len()
+	if n > len(dst) {
+		n = len(dst)
+	}
+	b := l.primitiveData(0, n, 8)
+	if b == nil {
+		for i := 0; i < n; i++ {
+			dst[i] = l.At(i)
+		}
+		return n
+	}
+	if db := uint64sAsBytes(dst[:n]); db != nil {
+		copy(db, b)
+		return n
+	}
+	for i := range dst[:n] {
+		dst[i] = binary.LittleEndian.Uint64(b[i*8:])
+	}
+	return n
+}
+
+// CopyFrom sets the list's first elements to the elements of src and
+// returns the number of elements copied, which is the smaller of
+// l.Len() and len(src).
+func (l UInt64List) CopyFrom(src []uint64) int {
+	n := l.Len()
+	if n > len(src) {
+		n = len(src)
+	}
+	if l.primitiveData(0, n, 8) == nil {
+		for i := 0; i < n; i++ {
+			l.Set(i, src[i])
+		}
+		return n
+	}
+	l.copyFrom(0, src[:n])
+	return n
+}
+
+// copyFrom sets the elements starting at i to v.  The list must not be
+// a composite list.
+func (l UInt64List) copyFrom(i int, v []uint64) {
+	b := l.primitiveData(i, len(v), 8)
+	if b == nil {
+		panic(errElementSize)
+	}
+	if vb := uint64sAsBytes(v); vb != nil {
+		copy(b, vb)
+		return
+	}
+	for j, x := range v {
+		binary.LittleEndian.PutUint64(b[j*8:], x)
+	}
+}
+
 // Int64List is an array of Int64 values.
 type Int64List struct{ List }
 
@@ -726,6 +1402,20 @@ func NewInt64List(s *Segment, n int32) (Int64List, error) {
 	return Int64List{l}, nil
 }
 
+// NewInt64ListFrom creates a new list of Int64 holding the elements of v,
+// preferring placement in s.
+func NewInt64ListFrom(s *Segment, v []int64) (Int64List, error) {
+	if len(v) > maxListElements {
+		return Int64List{}, errOverflow
+	}
+	l, err := NewInt64List(s, int32(len(v)))
+	if err != nil {
+		return Int64List{}, err
+	}
+	l.copyFrom(0, v)
+	return l, nil
+}
+
 // At returns the i'th element.
 func (l Int64List) At(i int) int64 {
 	addr, err := l.primitiveElem(i, ObjectSize{DataSize: 8})
@@ -744,6 +1434,77 @@ func (l Int64List) Set(i int, v int64) {
 	l.seg.writeUint64(addr, uint64(v))
 }
 
+// Slice returns the list's elements.  If the platform is little-endian
+// and the list's data is suitably aligned, the slice refers to the
+// message's memory instead of a copy, so it must not be modified and is
+// only valid while the message is.  Use CopyTo for a copy.
+func (l Int64List) Slice() []int64 {
+	if v := bytesAsInt64s(l.primitiveData(0, l.Len(), 8)); v != nil {
+		return v
+	}
+	v := make([]int64, l.Len())
+	l.CopyTo(v)
+	return v
+}
+
+// CopyTo copies the list's elements into dst and returns the number of
+// elements copied, which is the smaller of l.Len() and len(dst).
+func (l Int64List) CopyTo(dst []int64) int {
+	n := l.Len()
+	if n > len(dst) {
+		n = len(dst)
+	}
+	b := l.primitiveData(0, n, 8)
+	if b == nil {
+		for i := 0; i < n; i++ {
+			dst[i] = l.At(i)
+		}
+		return n
+	}
+	if db := int64sAsBytes(dst[:n]); db != nil {
+		copy(db, b)
+		return n
+	}
+	for i := range dst[:n] {
+		dst[i] = int64(binary.LittleEndian.Uint64(b[i*8:]))
+	}
+	return n
+}
+
+// CopyFrom sets the list's first elements to the elements of src and
+// returns the number of elements copied, which is the smaller of
+// l.Len() and len(src).
+func (l Int64List) CopyFrom(src []int64) int {
+	n := l.Len()
+	if n > len(src) {
+		n = len(src)
+	}
+	if l.primitiveData(0, n, 8) == nil {
+		for i := 0; i < n; i++ {
+			l.Set(i, src[i])
+		}
+		return n
+	}
+	l.copyFrom(0, src[:n])
+	return n
+}
+
+// copyFrom sets the elements starting at i to v.  The list must not be
+// a composite list.
+func (l Int64List) copyFrom(i int, v []int64) {
+	b := l.primitiveData(i, len(v), 8)
+	if b == nil {
+		panic(errElementSize)
+	}
+	if vb := int64sAsBytes(v); vb != nil {
+		copy(b, vb)
+		return
+	}
+	for j, x := range v {
+		binary.LittleEndian.PutUint64(b[j*8:], uint64(x))
+	}
+}
+
 // Float32List is an array of Float32 values.
 type Float32List struct{ List }
 
@@ -756,6 +1517,20 @@ func NewFloat32List(s *Segment, n int32) (Float32List, error) {
 	return Float32List{l}, nil
 }
 
+// NewFloat32ListFrom creates a new list of Float32 holding the elements of v,
+// preferring placement in s.
+func NewFloat32ListFrom(s *Segment, v []float32) (Float32List, error) {
+	if len(v) > maxListElements {
+		return Float32List{}, errOverflow
+	}
+	l, err := NewFloat32List(s, int32(len(v)))
+	if err != nil {
+		return Float32List{}, err
+	}
+	l.copyFrom(0, v)
+	return l, nil
+}
+
 // At returns the i'th element.
 func (l Float32List) At(i int) float32 {
 	addr, err := l.primitiveElem(i, ObjectSize{DataSize: 4})
@@ -774,6 +1549,77 @@ func (l Float32List) Set(i int, v float32) {
 	l.seg.writeUint32(addr, math.Float32bits(v))
 }
 
+// Slice returns the list's elements.  If the platform is little-endian
+// and the list's data is suitably aligned, the slice refers to the
+// message's memory instead of a copy, so it must not be modified and is
+// only valid while the message is.  Use CopyTo for a copy.
+func (l Float32List) Slice() []float32 {
+	if v := bytesAsFloat32s(l.primitiveData(0, l.Len(), 4)); v != nil {
+		return v
+	}
+	v := make([]float32, l.Len())
+	l.CopyTo(v)
+	return v
+}
+
+// CopyTo copies the list's elements into dst and returns the number of
+// elements copied, which is the smaller of l.Len() and len(dst).
+func (l Float32List) CopyTo(dst []float32) int {
+	n := l.Len()
+	if n > len(dst) {
+		n = len(dst)
+	}
+	b := l.primitiveData(0, n, 4)
+	if b == nil {
+		for i := 0; i < n; i++ {
+			dst[i] = l.At(i)
+		}
+		return n
+	}
+	if db := float32sAsBytes(dst[:n]); db != nil {
+		copy(db, b)
+		return n
+	}
+	for i := range dst[:n] {
+		dst[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
+	}
+	return n
+}
+
+// CopyFrom sets the list's first elements to the elements of src and
+// returns the number of elements copied, which is the smaller of
+// l.Len() and len(src).
+func (l Float32List) CopyFrom(src []float32) int {
+	n := l.Len()
+	if n > len(src) {
+		n = len(src)
+	}
+	if l.primitiveData(0, n, 4) == nil {
+		for i := 0; i < n; i++ {
+			l.Set(i, src[i])
+		}
+		return n
+	}
+	l.copyFrom(0, src[:n])
+	return n
+}
+
+// copyFrom sets the elements starting at i to v.  The list must not be
+// a composite list.
+func (l Float32List) copyFrom(i int, v []float32) {
+	b := l.primitiveData(i, len(v), 4)
+	if b == nil {
+		panic(errElementSize)
+	}
+	if vb := float32sAsBytes(v); vb != nil {
+		copy(b, vb)
+		return
+	}
+	for j, x := range v {
+		binary.LittleEndian.PutUint32(b[j*4:], math.Float32bits(x))
+	}
+}
+
 // Float64List is an array of Float64 values.
 type Float64List struct{ List }
 
@@ -786,6 +1632,20 @@ func NewFloat64List(s *Segment, n int32) (Float64List, error) {
 	return Float64List{l}, nil
 }
 
+// NewFloat64ListFrom creates a new list of Float64 holding the elements of v,
+// preferring placement in s.
+func NewFloat64ListFrom(s *Segment, v []float64) (Float64List, error) {
+	if len(v) > maxListElements {
+		return Float64List{}, errOverflow
+	}
+	l, err := NewFloat64List(s, int32(len(v)))
+	if err != nil {
+		return Float64List{}, err
+	}
+	l.copyFrom(0, v)
+	return l, nil
+}
+
 // At returns the i'th element.
 func (l Float64List) At(i int) float64 {
 	addr, err := l.primitiveElem(i, ObjectSize{DataSize: 8})
@@ -804,6 +1664,77 @@ func (l Float64List) Set(i int, v float64) {
 	l.seg.writeUint64(addr, math.Float64bits(v))
 }
 
+// Slice returns the list's elements.  If the platform is little-endian
+// and the list's data is suitably aligned, the slice refers to the
+// message's memory instead of a copy, so it must not be modified and is
+// only valid while the message is.  Use CopyTo for a copy.
+func (l Float64List) Slice() []float64 {
+	if v := bytesAsFloat64s(l.primitiveData(0, l.Len(), 8)); v != nil {
+		return v
+	}
+	v := make([]float64, l.Len())
+	l.CopyTo(v)
+	return v
+}
+
+// CopyTo copies the list's elements into dst and returns the number of
+// elements copied, which is the smaller of l.Len() and len(dst).
+func (l Float64List) CopyTo(dst []float64) int {
+	n := l.Len()
+	if n > len(dst) {
+		n = len(dst)
+	}
+	b := l.primitiveData(0, n, 8)
+	if b == nil {
+		for i := 0; i < n; i++ {
+			dst[i] = l.At(i)
+		}
+		return n
+	}
+	if db := float64sAsBytes(dst[:n]); db != nil {
+		copy(db, b)
+		return n
+	}
+	for i := range dst[:n] {
+		dst[i] = math.Float64frombits(binary.LittleEndian.Uint64(b[i*8:]))
+	}
+	return n
+}
+
+// CopyFrom sets the list's first elements to the elements of src and
+// returns the number of elements copied, which is the smaller of
+// l.Len() and len(src).
+func (l Float64List) CopyFrom(src []float64) int {
+	n := l.Len()
+	if n > len(src) {
+		n = len(src)
+	}
+	if l.primitiveData(0, n, 8) == nil {
+		for i := 0; i < n; i++ {
+			l.Set(i, src[i])
+		}
+		return n
+	}
+	l.copyFrom(0, src[:n])
+	return n
+}
+
+// copyFrom sets the elements starting at i to v.  The list must not be
+// a composite list.
+func (l Float64List) copyFrom(i int, v []float64) {
+	b := l.primitiveData(i, len(v), 8)
+	if b == nil {
+		panic(errElementSize)
+	}
+	if vb := float64sAsBytes(v); vb != nil {
+		copy(b, vb)
+		return
+	}
+	for j, x := range v {
+		binary.LittleEndian.PutUint64(b[j*8:], math.Float64bits(x))
+	}
+}
+
 type listFlags uint8
 
 const (
@@ -0,0 +1,33 @@
+// +build !386,!amd64,!amd64p32,!arm,!arm64,!loong64,!mips64le,!mips64p32le,!mipsle,!ppc64le,!riscv64,!wasm nocapnpunsafe
+
+package capnp
+
+// On big-endian platforms, or when unsafe is disabled, list data is
+// always copied element by element.
+
+func int8sAsBytes(v []int8) []byte { return nil }
+func bytesAsInt8s(b []byte) []int8 { return nil }
+
+func uint16sAsBytes(v []uint16) []byte { return nil }
+func bytesAsUInt16s(b []byte) []uint16 { return nil }
+
+func int16sAsBytes(v []int16) []byte { return nil }
+func bytesAsInt16s(b []byte) []int16 { return nil }
+
+func uint32sAsBytes(v []uint32) []byte { return nil }
+func bytesAsUInt32s(b []byte) []uint32 { return nil }
+
+func int32sAsBytes(v []int32) []byte { return nil }
+func bytesAsInt32s(b []byte) []int32 { return nil }
+
+func uint64sAsBytes(v []uint64) []byte { return nil }
+func bytesAsUInt64s(b []byte) []uint64 { return nil }
+
+func int64sAsBytes(v []int64) []byte { return nil }
+func bytesAsInt64s(b []byte) []int64 { return nil }
+
+func float32sAsBytes(v []float32) []byte { return nil }
+func bytesAsFloat32s(b []byte) []float32 { return nil }
+
+func float64sAsBytes(v []float64) []byte { return nil }
+func bytesAsFloat64s(b []byte) []float64 { return nil }
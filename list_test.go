@@ -2,6 +2,8 @@ package capnp
 
 import (
 	"bytes"
+	"math"
+	"reflect"
 	"testing"
 )
 
@@ -141,3 +143,130 @@ func TestListValue(t *testing.T) {
 		}
 	}
 }
+
+func TestListSlice(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{1.5, -2, 1e100, 0}
+	l, err := NewFloat64ListFrom(seg, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := l.Slice(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Slice() = %v; want %v", got, want)
+	}
+	if n := l.CopyFrom([]float64{7, 8}); n != 2 {
+		t.Errorf("CopyFrom(2 elements) = %d; want 2", n)
+	}
+	want = []float64{7, 8, 1e100, 0}
+	if got := l.Slice(); !reflect.DeepEqual(got, want) {
+		t.Errorf("after CopyFrom, Slice() = %v; want %v", got, want)
+	}
+	if n := l.CopyFrom(make([]float64, 10)); n != 4 {
+		t.Errorf("CopyFrom(10 elements) = %d; want 4", n)
+	}
+	if float64sAsBytes(nil) != nil {
+		// Slices alias the message on this platform.
+		v := l.Slice()
+		l.Set(1, 3.25)
+		if v[1] != 3.25 {
+			t.Errorf("Slice() does not alias the message: v[1] = %v after Set(1, 3.25)", v[1])
+		}
+	}
+
+	i16, err := NewInt16ListFrom(seg, []int16{-1, 2, -3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := i16.Slice(), []int16{-1, 2, -3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Int16List.Slice() = %v; want %v", got, want)
+	}
+	empty, err := NewUInt32List(seg, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := empty.Slice(); len(got) != 0 {
+		t.Errorf("empty Slice() = %v; want []", got)
+	}
+}
+
+func TestListSliceUnaligned(t *testing.T) {
+	// Data that isn't aligned can't be aliased, so it must be copied.
+	buf := make([]byte, 0, 1025)
+	_, seg, err := NewMessage(SingleSegment(buf[1:1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint64{1, 1 << 63, 42}
+	l, err := NewUInt64ListFrom(seg, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := l.Slice(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Slice() = %v; want %v", got, want)
+	}
+	got := make([]uint64, 3)
+	l.CopyTo(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CopyTo = %v; want %v", got, want)
+	}
+}
+
+func TestListSliceComposite(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := NewCompositeList(seg, ObjectSize{DataSize: 8, PointerCount: 1}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fl := Float32List{l}
+	if n := fl.CopyFrom([]float32{0.5, 2.5}); n != 2 {
+		t.Errorf("CopyFrom = %d; want 2", n)
+	}
+	if got, want := fl.Slice(), []float32{0.5, 2.5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Slice() = %v; want %v", got, want)
+	}
+	if v := math.Float32frombits(l.Struct(1).Uint32(0)); v != 2.5 {
+		t.Errorf("second struct's first field = %v; want 2.5", v)
+	}
+}
+
+func benchmarkFloat64List(b *testing.B, sum func(Float64List) float64) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		b.Fatal(err)
+	}
+	l, err := NewFloat64List(seg, 1<<16)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(l.Len()) * 8)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sum(l)
+	}
+}
+
+func BenchmarkFloat64List_At(b *testing.B) {
+	benchmarkFloat64List(b, func(l Float64List) float64 {
+		var sum float64
+		for i := 0; i < l.Len(); i++ {
+			sum += l.At(i)
+		}
+		return sum
+	})
+}
+
+func BenchmarkFloat64List_Slice(b *testing.B) {
+	benchmarkFloat64List(b, func(l Float64List) float64 {
+		var sum float64
+		for _, v := range l.Slice() {
+			sum += v
+		}
+		return sum
+	})
+}
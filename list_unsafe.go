@@ -0,0 +1,198 @@
+// +build 386 amd64 amd64p32 arm arm64 loong64 mips64le mips64p32le mipsle ppc64le riscv64 wasm
+// +build !nocapnpunsafe
+
+package capnp
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// On little-endian platforms, list data has the same layout as a Go
+// slice of the element type, so converting between them only needs a
+// check that the data is aligned.
+
+// unsafeBytes returns the n bytes of memory starting at p.
+func unsafeBytes(p unsafe.Pointer, n int) []byte {
+	var b []byte
+	h := (*reflect.SliceHeader)(unsafe.Pointer(&b))
+	h.Data, h.Len, h.Cap = uintptr(p), n, n
+	return b
+}
+
+// unsafeSlice sets the slice header at h to the n elements starting at
+// b's first byte.  It reports false if b isn't aligned to align bytes.
+func unsafeSlice(h unsafe.Pointer, b []byte, n, align int) bool {
+	if len(b) == 0 {
+		return true
+	}
+	p := unsafe.Pointer(&b[0])
+	if uintptr(p)%uintptr(align) != 0 {
+		return false
+	}
+	sh := (*reflect.SliceHeader)(h)
+	sh.Data, sh.Len, sh.Cap = uintptr(p), n, n
+	return true
+}
+
+func int8sAsBytes(v []int8) []byte {
+	if len(v) == 0 {
+		return []byte{}
+	}
+	return unsafeBytes(unsafe.Pointer(&v[0]), len(v)*1)
+}
+
+func bytesAsInt8s(b []byte) []int8 {
+	if b == nil {
+		return nil
+	}
+	v := []int8{}
+	if !unsafeSlice(unsafe.Pointer(&v), b, len(b)/1, 1) {
+		return nil
+	}
+	return v
+}
+
+func uint16sAsBytes(v []uint16) []byte {
+	if len(v) == 0 {
+		return []byte{}
+	}
+	return unsafeBytes(unsafe.Pointer(&v[0]), len(v)*2)
+}
+
+func bytesAsUInt16s(b []byte) []uint16 {
+	if b == nil {
+		return nil
+	}
+	v := []uint16{}
+	if !unsafeSlice(unsafe.Pointer(&v), b, len(b)/2, 2) {
+		return nil
+	}
+	return v
+}
+
+func int16sAsBytes(v []int16) []byte {
+	if len(v) == 0 {
+		return []byte{}
+	}
+	return unsafeBytes(unsafe.Pointer(&v[0]), len(v)*2)
+}
+
+func bytesAsInt16s(b []byte) []int16 {
+	if b == nil {
+		return nil
+	}
+	v := []int16{}
+	if !unsafeSlice(unsafe.Pointer(&v), b, len(b)/2, 2) {
+		return nil
+	}
+	return v
+}
+
+func uint32sAsBytes(v []uint32) []byte {
+	if len(v) == 0 {
+		return []byte{}
+	}
+	return unsafeBytes(unsafe.Pointer(&v[0]), len(v)*4)
+}
+
+func bytesAsUInt32s(b []byte) []uint32 {
+	if b == nil {
+		return nil
+	}
+	v := []uint32{}
+	if !unsafeSlice(unsafe.Pointer(&v), b, len(b)/4, 4) {
+		return nil
+	}
+	return v
+}
+
+func int32sAsBytes(v []int32) []byte {
+	if len(v) == 0 {
+		return []byte{}
+	}
+	return unsafeBytes(unsafe.Pointer(&v[0]), len(v)*4)
+}
+
+func bytesAsInt32s(b []byte) []int32 {
+	if b == nil {
+		return nil
+	}
+	v := []int32{}
+	if !unsafeSlice(unsafe.Pointer(&v), b, len(b)/4, 4) {
+		return nil
+	}
+	return v
+}
+
+func uint64sAsBytes(v []uint64) []byte {
+	if len(v) == 0 {
+		return []byte{}
+	}
+	return unsafeBytes(unsafe.Pointer(&v[0]), len(v)*8)
+}
+
+func bytesAsUInt64s(b []byte) []uint64 {
+	if b == nil {
+		return nil
+	}
+	v := []uint64{}
+	if !unsafeSlice(unsafe.Pointer(&v), b, len(b)/8, 8) {
+		return nil
+	}
+	return v
+}
+
+func int64sAsBytes(v []int64) []byte {
+	if len(v) == 0 {
+		return []byte{}
+	}
+	return unsafeBytes(unsafe.Pointer(&v[0]), len(v)*8)
+}
+
+func bytesAsInt64s(b []byte) []int64 {
+	if b == nil {
+		return nil
+	}
+	v := []int64{}
+	if !unsafeSlice(unsafe.Pointer(&v), b, len(b)/8, 8) {
+		return nil
+	}
+	return v
+}
+
+func float32sAsBytes(v []float32) []byte {
+	if len(v) == 0 {
+		return []byte{}
+	}
+	return unsafeBytes(unsafe.Pointer(&v[0]), len(v)*4)
+}
+
+func bytesAsFloat32s(b []byte) []float32 {
+	if b == nil {
+		return nil
+	}
+	v := []float32{}
+	if !unsafeSlice(unsafe.Pointer(&v), b, len(b)/4, 4) {
+		return nil
+	}
+	return v
+}
+
+func float64sAsBytes(v []float64) []byte {
+	if len(v) == 0 {
+		return []byte{}
+	}
+	return unsafeBytes(unsafe.Pointer(&v[0]), len(v)*8)
+}
+
+func bytesAsFloat64s(b []byte) []float64 {
+	if b == nil {
+		return nil
+	}
+	v := []float64{}
+	if !unsafeSlice(unsafe.Pointer(&v), b, len(b)/8, 8) {
+		return nil
+	}
+	return v
+}
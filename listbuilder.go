@@ -0,0 +1,762 @@
+package capnp
+
+// maxListElements is the largest number of elements that a list
+// pointer can record.
+const maxListElements = 1<<29 - 1
+
+// defaultListBuilderCap is the number of elements a list builder
+// allocates room for when no capacity is given.
+const defaultListBuilderCap = 8
+
+// A listBuilder appends elements to a list, doubling its capacity when
+// it is full.  The list grows in place when it is the last object in
+// its segment; otherwise its elements are moved to a new, larger
+// allocation, and the old one is zeroed and left unused until the
+// message is compacted.
+type listBuilder struct {
+	l        List    // l.length is the number of elements appended
+	start    Address // start of the allocation, including any tag word
+	size     Size    // size of the allocation
+	capacity int32
+}
+
+func newListBuilder(s *Segment, sz ObjectSize, flags listFlags, capacity int32) (listBuilder, error) {
+	if capacity <= 0 {
+		capacity = defaultListBuilderCap
+	}
+	b := listBuilder{l: List{
+		seg:        s,
+		size:       sz,
+		flags:      flags,
+		depthLimit: maxDepth,
+	}}
+	if err := b.grow(capacity); err != nil {
+		return listBuilder{}, err
+	}
+	return b, nil
+}
+
+// tagSize returns the size of the composite list tag word, if any.
+func (b *listBuilder) tagSize() Size {
+	if b.l.flags&isCompositeList != 0 {
+		return wordSize
+	}
+	return 0
+}
+
+// allocSize returns the size of an allocation that holds n elements.
+func (b *listBuilder) allocSize(n int32) (Size, bool) {
+	var bits uint64
+	if b.l.flags&isBitList != 0 {
+		bits = uint64(n)
+	} else {
+		bits = uint64(n) * uint64(b.l.size.totalSize()) * 8
+	}
+	sz := (bits+63)/64*uint64(wordSize) + uint64(b.tagSize())
+	if sz > uint64(maxSize-wordSize) {
+		return 0, false
+	}
+	return Size(sz), true
+}
+
+// next makes room for n more elements and returns the index of the
+// first one.
+func (b *listBuilder) next(n int) (int, error) {
+	if n > maxListElements-int(b.l.length) {
+		return 0, errOverflow
+	}
+	i := int(b.l.length)
+	if need := int32(i + n); need > b.capacity {
+		c := b.capacity
+		if c > maxListElements/2 {
+			c = maxListElements
+		} else {
+			c *= 2
+		}
+		if c < need {
+			c = need
+		}
+		if err := b.grow(c); err != nil {
+			return 0, err
+		}
+	}
+	b.l.length += int32(n)
+	return i, nil
+}
+
+// grow enlarges the allocation to hold capacity elements.
+func (b *listBuilder) grow(capacity int32) error {
+	sz, ok := b.allocSize(capacity)
+	if !ok {
+		return errOverflow
+	}
+	s := b.l.seg
+	end := b.start + Address(b.size)
+	if b.size > 0 && end == Address(len(s.data)) {
+		// The list is the last object in its segment: try to extend it.
+		// A single-segment arena can do so even when the segment is
+		// full.
+		ts, addr, err := alloc(s, sz-b.size)
+		if err != nil {
+			return err
+		}
+		if ts == s && addr == end {
+			b.size, b.capacity = sz, capacity
+			return nil
+		}
+		// The space was allocated elsewhere, so it's at the end of
+		// another segment and can be given back.
+		ts.data = ts.data[:addr]
+	}
+	ns, addr, err := alloc(s, sz)
+	if err != nil {
+		return err
+	}
+	old, oldStart, oldSize := b.l, b.start, b.size
+	b.l.seg, b.l.off = ns, addr+Address(b.tagSize())
+	b.start, b.size, b.capacity = addr, sz, capacity
+	if oldSize == 0 {
+		return nil
+	}
+	if err := b.move(old); err != nil {
+		return err
+	}
+	if end == Address(len(s.data)) {
+		s.data = s.data[:oldStart]
+	} else {
+		zero := s.slice(oldStart, oldSize)
+		for i := range zero {
+			zero[i] = 0
+		}
+	}
+	return nil
+}
+
+// move copies the elements of old to the builder's list.  Pointers
+// can't be copied as bytes, since they are relative to where they are
+// stored.
+func (b *listBuilder) move(old List) error {
+	switch {
+	case old.flags&isCompositeList != 0 && old.size.PointerCount > 0:
+		for i := 0; i < int(old.length); i++ {
+			if err := copyStruct(copyContext{}, b.l.Struct(i), old.Struct(i)); err != nil {
+				return err
+			}
+		}
+	case old.flags&isCompositeList == 0 && old.size.PointerCount > 0:
+		for i := 0; i < int(old.length); i++ {
+			p, err := PointerList{old}.PtrAt(i)
+			if err != nil {
+				return err
+			}
+			if err := (PointerList{b.l}).SetPtr(i, p); err != nil {
+				return err
+			}
+		}
+	default:
+		n, _ := b.allocSize(old.length)
+		n -= b.tagSize()
+		copy(b.l.seg.slice(b.l.off, n), old.seg.slice(old.off, n))
+	}
+	return nil
+}
+
+// finish trims the allocation to the list's length and returns the
+// list.
+func (b *listBuilder) finish() List {
+	l := b.l
+	s := l.seg
+	used, _ := b.allocSize(l.length)
+	if b.start+Address(b.size) == Address(len(s.data)) {
+		s.data = s.data[:b.start+Address(used)]
+	}
+	if l.flags&isCompositeList != 0 {
+		s.writeRawPointer(b.start, rawStructPointer(pointerOffset(l.length), l.size))
+	}
+	*b = listBuilder{}
+	return l
+}
+
+// A ListBuilder builds a composite list of structs whose length isn't
+// known in advance.  The list grows as elements are appended, so that
+// it never has to be counted first; growing may leave unused space in
+// the message, which Message.Compact reclaims.
+type ListBuilder struct{ b listBuilder }
+
+// NewListBuilder returns a builder for a composite list of structs of
+// size sz with room for capacity elements before it has to grow,
+// preferring placement in s.  If capacity is not positive, a small
+// default is used.
+func NewListBuilder(s *Segment, sz ObjectSize, capacity int32) (ListBuilder, error) {
+	if !sz.isValid() {
+		return ListBuilder{}, errObjectSize
+	}
+	sz.DataSize = sz.DataSize.padToWord()
+	b, err := newListBuilder(s, sz, isCompositeList, capacity)
+	return ListBuilder{b}, err
+}
+
+// Append adds a zeroed struct to the end of the list and returns it.
+// The struct is only valid until the next call to Append or Finish.
+func (b *ListBuilder) Append() (Struct, error) {
+	i, err := b.b.next(1)
+	if err != nil {
+		return Struct{}, err
+	}
+	return b.b.l.Struct(i), nil
+}
+
+// Len returns the number of elements appended so far.
+func (b *ListBuilder) Len() int {
+	return int(b.b.l.length)
+}
+
+// Finish returns the list.  The builder must not be used afterward.
+func (b *ListBuilder) Finish() List {
+	return b.b.finish()
+}
+
+// A PointerListBuilder builds a PointerList whose length isn't known
+// in advance.
+type PointerListBuilder struct{ b listBuilder }
+
+// NewPointerListBuilder returns a builder for a PointerList with room
+// for capacity elements before it has to grow, preferring placement
+// in s.  If capacity is not positive, a small default is used.
+func NewPointerListBuilder(s *Segment, capacity int32) (PointerListBuilder, error) {
+	b, err := newListBuilder(s, ObjectSize{PointerCount: 1}, 0, capacity)
+	return PointerListBuilder{b}, err
+}
+
+// Append adds p to the end of the list.
+func (b *PointerListBuilder) Append(p Ptr) error {
+	i, err := b.b.next(1)
+	if err != nil {
+		return err
+	}
+	return PointerList{b.b.l}.SetPtr(i, p)
+}
+
+// Len returns the number of elements appended so far.
+func (b *PointerListBuilder) Len() int {
+	return int(b.b.l.length)
+}
+
+// Finish returns the list.  The builder must not be used afterward.
+func (b *PointerListBuilder) Finish() PointerList {
+	return PointerList{b.b.finish()}
+}
+
+// A TextListBuilder builds a TextList whose length isn't known in
+// advance.
+type TextListBuilder struct{ b listBuilder }
+
+// NewTextListBuilder returns a builder for a TextList with room for
+// capacity elements before it has to grow, preferring placement in s.
+// If capacity is not positive, a small default is used.
+func NewTextListBuilder(s *Segment, capacity int32) (TextListBuilder, error) {
+	b, err := newListBuilder(s, ObjectSize{PointerCount: 1}, 0, capacity)
+	return TextListBuilder{b}, err
+}
+
+// Append adds v to the end of the list.
+func (b *TextListBuilder) Append(v string) error {
+	i, err := b.b.next(1)
+	if err != nil {
+		return err
+	}
+	return TextList{b.b.l}.Set(i, v)
+}
+
+// Len returns the number of elements appended so far.
+func (b *TextListBuilder) Len() int {
+	return int(b.b.l.length)
+}
+
+// Finish returns the list.  The builder must not be used afterward.
+func (b *TextListBuilder) Finish() TextList {
+	return TextList{b.b.finish()}
+}
+
+// A DataListBuilder builds a DataList whose length isn't known in
+// advance.
+type DataListBuilder struct{ b listBuilder }
+
+// NewDataListBuilder returns a builder for a DataList with room for
+// capacity elements before it has to grow, preferring placement in s.
+// If capacity is not positive, a small default is used.
+func NewDataListBuilder(s *Segment, capacity int32) (DataListBuilder, error) {
+	b, err := newListBuilder(s, ObjectSize{PointerCount: 1}, 0, capacity)
+	return DataListBuilder{b}, err
+}
+
+// Append adds v to the end of the list.
+func (b *DataListBuilder) Append(v []byte) error {
+	i, err := b.b.next(1)
+	if err != nil {
+		return err
+	}
+	return DataList{b.b.l}.Set(i, v)
+}
+
+// Len returns the number of elements appended so far.
+func (b *DataListBuilder) Len() int {
+	return int(b.b.l.length)
+}
+
+// Finish returns the list.  The builder must not be used afterward.
+func (b *DataListBuilder) Finish() DataList {
+	return DataList{b.b.finish()}
+}
+
+// A BitListBuilder builds a BitList whose length isn't known in
+// advance.
+type BitListBuilder struct{ b listBuilder }
+
+// NewBitListBuilder returns a builder for a BitList with room for
+// capacity elements before it has to grow, preferring placement in s.
+// If capacity is not positive, a small default is used.
+func NewBitListBuilder(s *Segment, capacity int32) (BitListBuilder, error) {
+	b, err := newListBuilder(s, ObjectSize{}, isBitList, capacity)
+	return BitListBuilder{b}, err
+}
+
+// Append adds v to the end of the list.
+func (b *BitListBuilder) Append(v bool) error {
+	i, err := b.b.next(1)
+	if err != nil {
+		return err
+	}
+	BitList{b.b.l}.Set(i, v)
+	return nil
+}
+
+// Len returns the number of elements appended so far.
+func (b *BitListBuilder) Len() int {
+	return int(b.b.l.length)
+}
+
+// Finish returns the list.  The builder must not be used afterward.
+func (b *BitListBuilder) Finish() BitList {
+	return BitList{b.b.finish()}
+}
+
+// A UInt8ListBuilder builds a UInt8List whose length isn't known in
+// advance.
+type UInt8ListBuilder struct{ b listBuilder }
+
+// NewUInt8ListBuilder returns a builder for a UInt8List with room for
+// capacity elements before it has to grow, preferring placement in s.
+// If capacity is not positive, a small default is used.
+func NewUInt8ListBuilder(s *Segment, capacity int32) (UInt8ListBuilder, error) {
+	b, err := newListBuilder(s, ObjectSize{DataSize: 1}, 0, capacity)
+	return UInt8ListBuilder{b}, err
+}
+
+// Append adds v to the end of the list.
+func (b *UInt8ListBuilder) Append(v uint8) error {
+	i, err := b.b.next(1)
+	if err != nil {
+		return err
+	}
+	UInt8List{b.b.l}.Set(i, v)
+	return nil
+}
+
+// AppendSlice adds the elements of v to the end of the list.
+func (b *UInt8ListBuilder) AppendSlice(v []uint8) error {
+	i, err := b.b.next(len(v))
+	if err != nil {
+		return err
+	}
+	UInt8List{b.b.l}.copyFrom(i, v)
+	return nil
+}
+
+// Len returns the number of elements appended so far.
+func (b *UInt8ListBuilder) Len() int {
+	return int(b.b.l.length)
+}
+
+// Finish returns the list.  The builder must not be used afterward.
+func (b *UInt8ListBuilder) Finish() UInt8List {
+	return UInt8List{b.b.finish()}
+}
+
+// A Int8ListBuilder builds a Int8List whose length isn't known in
+// advance.
+type Int8ListBuilder struct{ b listBuilder }
+
+// NewInt8ListBuilder returns a builder for a Int8List with room for
+// capacity elements before it has to grow, preferring placement in s.
+// If capacity is not positive, a small default is used.
+func NewInt8ListBuilder(s *Segment, capacity int32) (Int8ListBuilder, error) {
+	b, err := newListBuilder(s, ObjectSize{DataSize: 1}, 0, capacity)
+	return Int8ListBuilder{b}, err
+}
+
+// Append adds v to the end of the list.
+func (b *Int8ListBuilder) Append(v int8) error {
+	i, err := b.b.next(1)
+	if err != nil {
+		return err
+	}
+	Int8List{b.b.l}.Set(i, v)
+	return nil
+}
+
+// AppendSlice adds the elements of v to the end of the list.
+func (b *Int8ListBuilder) AppendSlice(v []int8) error {
+	i, err := b.b.next(len(v))
+	if err != nil {
+		return err
+	}
+	Int8List{b.b.l}.copyFrom(i, v)
+	return nil
+}
+
+// Len returns the number of elements appended so far.
+func (b *Int8ListBuilder) Len() int {
+	return int(b.b.l.length)
+}
+
+// Finish returns the list.  The builder must not be used afterward.
+func (b *Int8ListBuilder) Finish() Int8List {
+	return Int8List{b.b.finish()}
+}
+
+// A UInt16ListBuilder builds a UInt16List whose length isn't known in
+// advance.
+type UInt16ListBuilder struct{ b listBuilder }
+
+// NewUInt16ListBuilder returns a builder for a UInt16List with room for
+// capacity elements before it has to grow, preferring placement in s.
+// If capacity is not positive, a small default is used.
+func NewUInt16ListBuilder(s *Segment, capacity int32) (UInt16ListBuilder, error) {
+	b, err := newListBuilder(s, ObjectSize{DataSize: 2}, 0, capacity)
+	return UInt16ListBuilder{b}, err
+}
+
+// Append adds v to the end of the list.
+func (b *UInt16ListBuilder) Append(v uint16) error {
+	i, err := b.b.next(1)
+	if err != nil {
+		return err
+	}
+	UInt16List{b.b.l}.Set(i, v)
+	return nil
+}
+
+// AppendSlice adds the elements of v to the end of the list.
+func (b *UInt16ListBuilder) AppendSlice(v []uint16) error {
+	i, err := b.b.next(len(v))
+	if err != nil {
+		return err
+	}
+	UInt16List{b.b.l}.copyFrom(i, v)
+	return nil
+}
+
+// Len returns the number of elements appended so far.
+func (b *UInt16ListBuilder) Len() int {
+	return int(b.b.l.length)
+}
+
+// Finish returns the list.  The builder must not be used afterward.
+func (b *UInt16ListBuilder) Finish() UInt16List {
+	return UInt16List{b.b.finish()}
+}
+
+// A Int16ListBuilder builds a Int16List whose length isn't known in
+// advance.
+type Int16ListBuilder struct{ b listBuilder }
+
+// NewInt16ListBuilder returns a builder for a Int16List with room for
+// capacity elements before it has to grow, preferring placement in s.
+// If capacity is not positive, a small default is used.
+func NewInt16ListBuilder(s *Segment, capacity int32) (Int16ListBuilder, error) {
+	b, err := newListBuilder(s, ObjectSize{DataSize: 2}, 0, capacity)
+	return Int16ListBuilder{b}, err
+}
+
+// Append adds v to the end of the list.
+func (b *Int16ListBuilder) Append(v int16) error {
+	i, err := b.b.next(1)
+	if err != nil {
+		return err
+	}
+	Int16List{b.b.l}.Set(i, v)
+	return nil
+}
+
+// AppendSlice adds the elements of v to the end of the list.
+func (b *Int16ListBuilder) AppendSlice(v []int16) error {
+	i, err := b.b.next(len(v))
+	if err != nil {
+		return err
+	}
+	Int16List{b.b.l}.copyFrom(i, v)
+	return nil
+}
+
+// Len returns the number of elements appended so far.
+func (b *Int16ListBuilder) Len() int {
+	return int(b.b.l.length)
+}
+
+// Finish returns the list.  The builder must not be used afterward.
+func (b *Int16ListBuilder) Finish() Int16List {
+	return Int16List{b.b.finish()}
+}
+
+// A UInt32ListBuilder builds a UInt32List whose length isn't known in
+// advance.
+type UInt32ListBuilder struct{ b listBuilder }
+
+// NewUInt32ListBuilder returns a builder for a UInt32List with room for
+// capacity elements before it has to grow, preferring placement in s.
+// If capacity is not positive, a small default is used.
+func NewUInt32ListBuilder(s *Segment, capacity int32) (UInt32ListBuilder, error) {
+	b, err := newListBuilder(s, ObjectSize{DataSize: 4}, 0, capacity)
+	return UInt32ListBuilder{b}, err
+}
+
+// Append adds v to the end of the list.
+func (b *UInt32ListBuilder) Append(v uint32) error {
+	i, err := b.b.next(1)
+	if err != nil {
+		return err
+	}
+	UInt32List{b.b.l}.Set(i, v)
+	return nil
+}
+
+// AppendSlice adds the elements of v to the end of the list.
+func (b *UInt32ListBuilder) AppendSlice(v []uint32) error {
+	i, err := b.b.next(len(v))
+	if err != nil {
+		return err
+	}
+	UInt32List{b.b.l}.copyFrom(i, v)
+	return nil
+}
+
+// Len returns the number of elements appended so far.
+func (b *UInt32ListBuilder) Len() int {
+	return int(b.b.l.length)
+}
+
+// Finish returns the list.  The builder must not be used afterward.
+func (b *UInt32ListBuilder) Finish() UInt32List {
+	return UInt32List{b.b.finish()}
+}
+
+// A Int32ListBuilder builds a Int32List whose length isn't known in
+// advance.
+type Int32ListBuilder struct{ b listBuilder }
+
+// NewInt32ListBuilder returns a builder for a Int32List with room for
+// capacity elements before it has to grow, preferring placement in s.
+// If capacity is not positive, a small default is used.
+func NewInt32ListBuilder(s *Segment, capacity int32) (Int32ListBuilder, error) {
+	b, err := newListBuilder(s, ObjectSize{DataSize: 4}, 0, capacity)
+	return Int32ListBuilder{b}, err
+}
+
+// Append adds v to the end of the list.
+func (b *Int32ListBuilder) Append(v int32) error {
+	i, err := b.b.next(1)
+	if err != nil {
+		return err
+	}
+	Int32List{b.b.l}.Set(i, v)
+	return nil
+}
+
+// AppendSlice adds the elements of v to the end of the list.
+func (b *Int32ListBuilder) AppendSlice(v []int32) error {
+	i, err := b.b.next(len(v))
+	if err != nil {
+		return err
+	}
+	Int32List{b.b.l}.copyFrom(i, v)
+	return nil
+}
+
+// Len returns the number of elements appended so far.
+func (b *Int32ListBuilder) Len() int {
+	return int(b.b.l.length)
+}
+
+// Finish returns the list.  The builder must not be used afterward.
+func (b *Int32ListBuilder) Finish() Int32List {
+	return Int32List{b.b.finish()}
+}
+
+// A UInt64ListBuilder builds a UInt64List whose length isn't known in
+// advance.
+type UInt64ListBuilder struct{ b listBuilder }
+
+// NewUInt64ListBuilder returns a builder for a UInt64List with room for
+// capacity elements before it has to grow, preferring placement in s.
+// If capacity is not positive, a small default is used.
+func NewUInt64ListBuilder(s *Segment, capacity int32) (UInt64ListBuilder, error) {
+	b, err := newListBuilder(s, ObjectSize{DataSize: 8}, 0, capacity)
+	return UInt64ListBuilder{b}, err
+}
+
+// Append adds v to the end of the list.
+func (b *UInt64ListBuilder) Append(v uint64) error {
+	i, err := b.b.next(1)
+	if err != nil {
+		return err
+	}
+	UInt64List{b.b.l}.Set(i, v)
+	return nil
+}
+
+// AppendSlice adds the elements of v to the end of the list.
+func (b *UInt64ListBuilder) AppendSlice(v []uint64) error {
+	i, err := b.b.next(len(v))
+	if err != nil {
+		return err
+	}
+	UInt64List{b.b.l}.copyFrom(i, v)
+	return nil
+}
+
+// Len returns the number of elements appended so far.
+func (b *UInt64ListBuilder) Len() int {
+	return int(b.b.l.length)
+}
+
+// Finish returns the list.  The builder must not be used afterward.
+func (b *UInt64ListBuilder) Finish() UInt64List {
+	return UInt64List{b.b.finish()}
+}
+
+// A Int64ListBuilder builds a Int64List whose length isn't known in
+// advance.
+type Int64ListBuilder struct{ b listBuilder }
+
+// NewInt64ListBuilder returns a builder for a Int64List with room for
+// capacity elements before it has to grow, preferring placement in s.
+// If capacity is not positive, a small default is used.
+func NewInt64ListBuilder(s *Segment, capacity int32) (Int64ListBuilder, error) {
+	b, err := newListBuilder(s, ObjectSize{DataSize: 8}, 0, capacity)
+	return Int64ListBuilder{b}, err
+}
+
+// Append adds v to the end of the list.
+func (b *Int64ListBuilder) Append(v int64) error {
+	i, err := b.b.next(1)
+	if err != nil {
+		return err
+	}
+	Int64List{b.b.l}.Set(i, v)
+	return nil
+}
+
+// AppendSlice adds the elements of v to the end of the list.
+func (b *Int64ListBuilder) AppendSlice(v []int64) error {
+	i, err := b.b.next(len(v))
+	if err != nil {
+		return err
+	}
+	Int64List{b.b.l}.copyFrom(i, v)
+	return nil
+}
+
+// Len returns the number of elements appended so far.
+func (b *Int64ListBuilder) Len() int {
+	return int(b.b.l.length)
+}
+
+// Finish returns the list.  The builder must not be used afterward.
+func (b *Int64ListBuilder) Finish() Int64List {
+	return Int64List{b.b.finish()}
+}
+
+// A Float32ListBuilder builds a Float32List whose length isn't known in
+// advance.
+type Float32ListBuilder struct{ b listBuilder }
+
+// NewFloat32ListBuilder returns a builder for a Float32List with room for
+// capacity elements before it has to grow, preferring placement in s.
+// If capacity is not positive, a small default is used.
+func NewFloat32ListBuilder(s *Segment, capacity int32) (Float32ListBuilder, error) {
+	b, err := newListBuilder(s, ObjectSize{DataSize: 4}, 0, capacity)
+	return Float32ListBuilder{b}, err
+}
+
+// Append adds v to the end of the list.
+func (b *Float32ListBuilder) Append(v float32) error {
+	i, err := b.b.next(1)
+	if err != nil {
+		return err
+	}
+	Float32List{b.b.l}.Set(i, v)
+	return nil
+}
+
+// AppendSlice adds the elements of v to the end of the list.
+func (b *Float32ListBuilder) AppendSlice(v []float32) error {
+	i, err := b.b.next(len(v))
+	if err != nil {
+		return err
+	}
+	Float32List{b.b.l}.copyFrom(i, v)
+	return nil
+}
+
+// Len returns the number of elements appended so far.
+func (b *Float32ListBuilder) Len() int {
+	return int(b.b.l.length)
+}
+
+// Finish returns the list.  The builder must not be used afterward.
+func (b *Float32ListBuilder) Finish() Float32List {
+	return Float32List{b.b.finish()}
+}
+
+// A Float64ListBuilder builds a Float64List whose length isn't known in
+// advance.
+type Float64ListBuilder struct{ b listBuilder }
+
+// NewFloat64ListBuilder returns a builder for a Float64List with room for
+// capacity elements before it has to grow, preferring placement in s.
+// If capacity is not positive, a small default is used.
+func NewFloat64ListBuilder(s *Segment, capacity int32) (Float64ListBuilder, error) {
+	b, err := newListBuilder(s, ObjectSize{DataSize: 8}, 0, capacity)
+	return Float64ListBuilder{b}, err
+}
+
+// Append adds v to the end of the list.
+func (b *Float64ListBuilder) Append(v float64) error {
+	i, err := b.b.next(1)
+	if err != nil {
+		return err
+	}
+	Float64List{b.b.l}.Set(i, v)
+	return nil
+}
+
+// AppendSlice adds the elements of v to the end of the list.
+func (b *Float64ListBuilder) AppendSlice(v []float64) error {
+	i, err := b.b.next(len(v))
+	if err != nil {
+		return err
+	}
+	Float64List{b.b.l}.copyFrom(i, v)
+	return nil
+}
+
+// Len returns the number of elements appended so far.
+func (b *Float64ListBuilder) Len() int {
+	return int(b.b.l.length)
+}
+
+// Finish returns the list.  The builder must not be used afterward.
+func (b *Float64ListBuilder) Finish() Float64List {
+	return Float64List{b.b.finish()}
+}
@@ -0,0 +1,286 @@
+package capnp
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// roundTripList stores l in a root struct, marshals and unmarshals
+// the message, and returns the list read back.
+func roundTripList(t *testing.T, root Struct, l List) List {
+	if err := root.SetPtr(0, l.ToPtr()); err != nil {
+		t.Fatal("SetPtr:", err)
+	}
+	data, err := root.Segment().Message().Marshal()
+	if err != nil {
+		t.Fatal("Marshal:", err)
+	}
+	msg, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal("Unmarshal:", err)
+	}
+	p, err := msg.RootPtr()
+	if err != nil {
+		t.Fatal("RootPtr:", err)
+	}
+	lp, err := p.Struct().Ptr(0)
+	if err != nil {
+		t.Fatal("Ptr(0):", err)
+	}
+	return lp.List()
+}
+
+func newBuilderRoot(t *testing.T, arena Arena) (*Segment, Struct) {
+	_, seg, err := NewMessage(arena)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewRootStruct(seg, ObjectSize{PointerCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return seg, root
+}
+
+func TestInt64ListBuilder(t *testing.T) {
+	seg, root := newBuilderRoot(t, SingleSegment(nil))
+	b, err := NewInt64ListBuilder(seg, 0)
+	if err != nil {
+		t.Fatal("NewInt64ListBuilder:", err)
+	}
+	var want []int64
+	for i := int64(0); i < 1000; i++ {
+		v := i * -3
+		if err := b.Append(v); err != nil {
+			t.Fatalf("Append(%d): %v", v, err)
+		}
+		want = append(want, v)
+	}
+	if err := b.AppendSlice([]int64{7, 8, 9}); err != nil {
+		t.Fatal("AppendSlice:", err)
+	}
+	want = append(want, 7, 8, 9)
+	if b.Len() != len(want) {
+		t.Errorf("Len() = %d; want %d", b.Len(), len(want))
+	}
+	l := b.Finish()
+	// The list is the last object, so it should have grown in place
+	// and been trimmed to its length.
+	if got, wantSize := len(seg.Data()), 16+8*len(want); got != wantSize {
+		t.Errorf("segment size = %d; want %d", got, wantSize)
+	}
+	got := make([]int64, l.Len())
+	l.CopyTo(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Error("list elements differ after Finish")
+	}
+	got = make([]int64, len(want))
+	Int64List{roundTripList(t, root, l.List)}.CopyTo(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Error("list elements differ after round trip")
+	}
+}
+
+func TestTextListBuilder(t *testing.T) {
+	arenas := []struct {
+		name  string
+		arena Arena
+	}{
+		{"single segment", SingleSegment(nil)},
+		{"small segments", NewArena(AllocPolicy{InitialSize: 64})},
+	}
+	for _, a := range arenas {
+		seg, root := newBuilderRoot(t, a.arena)
+		b, err := NewTextListBuilder(seg, 1)
+		if err != nil {
+			t.Fatalf("%s: NewTextListBuilder: %v", a.name, err)
+		}
+		var want []string
+		for i := 0; i < 100; i++ {
+			// Each string is allocated after the list, so the list
+			// has to move to grow.
+			v := fmt.Sprintf("element %d", i)
+			if err := b.Append(v); err != nil {
+				t.Fatalf("%s: Append(%q): %v", a.name, v, err)
+			}
+			want = append(want, v)
+		}
+		l := TextList{roundTripList(t, root, b.Finish().List)}
+		if l.Len() != len(want) {
+			t.Errorf("%s: Len() = %d; want %d", a.name, l.Len(), len(want))
+			continue
+		}
+		for i := range want {
+			if s, err := l.At(i); err != nil || s != want[i] {
+				t.Errorf("%s: At(%d) = %q, %v; want %q", a.name, i, s, err, want[i])
+			}
+		}
+	}
+}
+
+func TestListBuilder(t *testing.T) {
+	seg, root := newBuilderRoot(t, NewArena(AllocPolicy{InitialSize: 128}))
+	b, err := NewListBuilder(seg, ObjectSize{DataSize: 4, PointerCount: 1}, 0)
+	if err != nil {
+		t.Fatal("NewListBuilder:", err)
+	}
+	const n = 50
+	for i := 0; i < n; i++ {
+		s, err := b.Append()
+		if err != nil {
+			t.Fatalf("Append #%d: %v", i, err)
+		}
+		s.SetUint32(0, uint32(i))
+		if err := s.SetText(0, fmt.Sprint(i)); err != nil {
+			t.Fatalf("SetText #%d: %v", i, err)
+		}
+	}
+	l := roundTripList(t, root, b.Finish())
+	if l.Len() != n || !l.IsComposite() {
+		t.Fatalf("list has %d elements, composite=%t; want %d composite", l.Len(), l.IsComposite(), n)
+	}
+	for i := 0; i < n; i++ {
+		s := l.Struct(i)
+		p, err := s.Ptr(0)
+		if err != nil {
+			t.Errorf("element %d: Ptr(0): %v", i, err)
+			continue
+		}
+		if s.Uint32(0) != uint32(i) || p.Text() != fmt.Sprint(i) {
+			t.Errorf("element %d = (%d, %q); want (%d, %q)", i, s.Uint32(0), p.Text(), i, fmt.Sprint(i))
+		}
+	}
+}
+
+func TestBitListBuilder(t *testing.T) {
+	seg, root := newBuilderRoot(t, SingleSegment(nil))
+	b, err := NewBitListBuilder(seg, 0)
+	if err != nil {
+		t.Fatal("NewBitListBuilder:", err)
+	}
+	var want []bool
+	for i := 0; i < 200; i++ {
+		v := i%3 == 0
+		if err := b.Append(v); err != nil {
+			t.Fatalf("Append #%d: %v", i, err)
+		}
+		want = append(want, v)
+	}
+	l := BitList{roundTripList(t, root, b.Finish().List)}
+	got := make([]bool, l.Len())
+	l.CopyTo(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("bits = %v; want %v", got, want)
+	}
+}
+
+func TestPointerListBuilder(t *testing.T) {
+	seg, root := newBuilderRoot(t, SingleSegment(nil))
+	b, err := NewPointerListBuilder(seg, 2)
+	if err != nil {
+		t.Fatal("NewPointerListBuilder:", err)
+	}
+	for i := 0; i < 10; i++ {
+		s, err := NewStruct(seg, ObjectSize{DataSize: 8})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.SetUint64(0, uint64(i)*100)
+		if err := b.Append(s.ToPtr()); err != nil {
+			t.Fatalf("Append #%d: %v", i, err)
+		}
+	}
+	l := PointerList{roundTripList(t, root, b.Finish().List)}
+	if l.Len() != 10 {
+		t.Fatalf("Len() = %d; want 10", l.Len())
+	}
+	for i := 0; i < l.Len(); i++ {
+		p, err := l.PtrAt(i)
+		if err != nil {
+			t.Errorf("PtrAt(%d): %v", i, err)
+		} else if v := p.Struct().Uint64(0); v != uint64(i)*100 {
+			t.Errorf("element %d = %d; want %d", i, v, i*100)
+		}
+	}
+}
+
+func TestNewListFrom(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	i64 := []int64{1, -2, 1 << 40}
+	l, err := NewInt64ListFrom(seg, i64)
+	if err != nil {
+		t.Fatal("NewInt64ListFrom:", err)
+	}
+	for i, v := range i64 {
+		if l.At(i) != v {
+			t.Errorf("Int64List.At(%d) = %d; want %d", i, l.At(i), v)
+		}
+	}
+	short := make([]int64, 2)
+	if n := l.CopyTo(short); n != 2 || short[0] != 1 || short[1] != -2 {
+		t.Errorf("CopyTo(short) = %d, %v; want 2, [1 -2]", n, short)
+	}
+
+	f32 := []float32{1.5, -0.25}
+	fl, err := NewFloat32ListFrom(seg, f32)
+	if err != nil {
+		t.Fatal("NewFloat32ListFrom:", err)
+	}
+	gotf := make([]float32, 3)
+	if n := fl.CopyTo(gotf); n != 2 || !reflect.DeepEqual(gotf[:2], f32) {
+		t.Errorf("Float32List.CopyTo = %d, %v; want 2, %v", n, gotf[:2], f32)
+	}
+
+	u8, err := NewUInt8ListFrom(seg, []uint8{})
+	if err != nil || u8.Len() != 0 {
+		t.Errorf("NewUInt8ListFrom(empty) = %d elements, %v; want 0 elements", u8.Len(), err)
+	}
+
+	bits := []bool{true, false, false, true, true, false, true, false, true}
+	bl, err := NewBitListFrom(seg, bits)
+	if err != nil {
+		t.Fatal("NewBitListFrom:", err)
+	}
+	for i, v := range bits {
+		if bl.At(i) != v {
+			t.Errorf("BitList.At(%d) = %t; want %t", i, bl.At(i), v)
+		}
+	}
+
+	strs := []string{"a", "", "hello"}
+	tl, err := NewTextListFrom(seg, strs)
+	if err != nil {
+		t.Fatal("NewTextListFrom:", err)
+	}
+	for i, v := range strs {
+		if s, err := tl.At(i); err != nil || s != v {
+			t.Errorf("TextList.At(%d) = %q, %v; want %q", i, s, err, v)
+		}
+	}
+}
+
+func TestCopyToComposite(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A struct list can be read as a primitive list of its first
+	// field, so CopyTo has to fall back to reading each element.
+	l, err := NewCompositeList(seg, ObjectSize{DataSize: 16}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < l.Len(); i++ {
+		l.Struct(i).SetUint32(0, uint32(i+1))
+		l.Struct(i).SetUint32(8, 99)
+	}
+	got := make([]uint32, 3)
+	UInt32List{l}.CopyTo(got)
+	if want := []uint32{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CopyTo = %v; want %v", got, want)
+	}
+}
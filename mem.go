@@ -6,8 +6,9 @@ import (
 	"errors"
 	"io"
 	"sync"
+	"sync/atomic"
 
-	"zombiezen.com/go/capnproto2/internal/packed"
+	"zombiezen.com/go/capnproto2/packed"
 )
 
 // Security limits. Matches C++ implementation.
@@ -61,6 +62,11 @@ type Message struct {
 	mu       sync.Mutex
 	segs     map[SegmentID]*Segment
 	firstSeg Segment // Preallocated first segment. msg is non-nil once initialized.
+
+	// trav holds a *traversalTracker once TrackTraversal is called.  It
+	// is loaded without holding mu so that reads don't lock, but it is
+	// only stored while holding mu.
+	trav atomic.Value
 }
 
 // NewMessage creates a message with a new root and returns the first
@@ -100,8 +106,11 @@ func (m *Message) Reset(arena Arena) {
 	m.CapTable = nil
 	m.segs = nil
 	m.firstSeg = Segment{}
+	if m.tracker() != nil {
+		m.trav.Store(new(traversalTracker))
+	}
 	m.mu.Unlock()
-	m.ReadLimiter().Reset(m.TraverseLimit)
+	m.ReadLimiter().Reset(m.traverseLimit())
 }
 
 // Root returns the pointer to the message's root object.
@@ -118,7 +127,11 @@ func (m *Message) RootPtr() (Ptr, error) {
 	if err != nil {
 		return Ptr{}, err
 	}
-	return s.root().PtrAt(0)
+	root := s.root()
+	if !root.IsValid() {
+		return Ptr{}, errSegmentTooSmall
+	}
+	return root.PtrAt(0)
 }
 
 // SetRoot sets the message's root object to p.
@@ -134,7 +147,11 @@ func (m *Message) SetRootPtr(p Ptr) error {
 	if err != nil {
 		return err
 	}
-	return s.root().SetPtr(0, p)
+	root := s.root()
+	if !root.IsValid() {
+		return errSegmentTooSmall
+	}
+	return root.SetPtr(0, p)
 }
 
 // AddCap appends a capability to the message's capability table and
@@ -149,15 +166,18 @@ func (m *Message) AddCap(c Client) CapabilityID {
 // to reset the traversal limit while reading.
 func (m *Message) ReadLimiter() *ReadLimiter {
 	m.rlimitInit.Do(func() {
-		if m.TraverseLimit == 0 {
-			m.rlimit.limit = defaultTraverseLimit
-		} else {
-			m.rlimit.limit = m.TraverseLimit
-		}
+		m.rlimit.limit = m.traverseLimit()
 	})
 	return &m.rlimit
 }
 
+func (m *Message) traverseLimit() uint64 {
+	if m.TraverseLimit != 0 {
+		return m.TraverseLimit
+	}
+	return defaultTraverseLimit
+}
+
 func (m *Message) depthLimit() uint {
 	if m.DepthLimit != 0 {
 		return m.DepthLimit
@@ -368,15 +388,25 @@ func MultiSegment(b [][]byte) Arena {
 
 // demuxArena slices b into a multi-segment arena.
 func demuxArena(hdr streamHeader, data []byte) (Arena, error) {
-	segs := make([][]byte, int(hdr.maxSegment())+1)
-	for i := range segs {
-		sz, err := hdr.segmentSize(uint32(i))
+	segs, err := demuxSegments(make([][]byte, 0, int(hdr.maxSegment())+1), hdr, data)
+	if err != nil {
+		return nil, err
+	}
+	return MultiSegment(segs), nil
+}
+
+// demuxSegments slices data into segments, appending them to segs.
+// Each segment's capacity is its length, so allocating in one segment
+// never overwrites the next.
+func demuxSegments(segs [][]byte, hdr streamHeader, data []byte) ([][]byte, error) {
+	for i := uint32(0); i <= hdr.maxSegment(); i++ {
+		sz, err := hdr.segmentSize(i)
 		if err != nil {
 			return nil, err
 		}
-		segs[i], data = data[:sz:sz], data[sz:]
+		segs, data = append(segs, data[:sz:sz]), data[sz:]
 	}
-	return MultiSegment(segs), nil
+	return segs, nil
 }
 
 func (msa *multiSegmentArena) NumSegments() int64 {
@@ -412,6 +442,104 @@ func (msa *multiSegmentArena) Allocate(sz Size, segs map[SegmentID]*Segment) (Se
 	return id, buf, nil
 }
 
+// An AllocPolicy controls the sizes of the segments that an arena
+// created by NewArena allocates.  The zero value allocates segments of
+// the same size as a MultiSegment arena.
+type AllocPolicy struct {
+	// InitialSize is the size of the first segment.  If not set,
+	// a default of a few kilobytes is used.
+	InitialSize Size
+
+	// GrowthFactor is the ratio between the size of each new segment
+	// and the size of the one before it.  If GrowthFactor is less than
+	// one, every segment is InitialSize.
+	GrowthFactor float64
+
+	// MaxSegmentSize limits the size of new segments.  A single object
+	// larger than MaxSegmentSize still gets a segment large enough to
+	// hold it.  If not set, segments can grow up to the largest size
+	// that a segment can have.
+	MaxSegmentSize Size
+
+	// FirstSegment, if not nil, is called by NewArena to get the
+	// buffer for the first segment, such as from a pool.  size is the
+	// capacity the arena wants.  The buffer's length is ignored, and if
+	// its capacity is too small for the root pointer, the arena
+	// allocates its own buffer instead.
+	FirstSegment func(size Size) []byte
+}
+
+// segmentSize returns the size of the segment to allocate after a
+// segment of size prev, or the first segment if first is true.  The
+// result is at least minsz.
+func (p *AllocPolicy) segmentSize(first bool, prev Size, minsz Size) Size {
+	sz := p.InitialSize
+	if sz == 0 {
+		sz = defaultBufferSize
+	}
+	if !first && p.GrowthFactor > 1 {
+		next := float64(prev) * p.GrowthFactor
+		if next > float64(maxSize) {
+			next = float64(maxSize)
+		}
+		if Size(next) > sz {
+			sz = Size(next)
+		}
+	}
+	if p.MaxSegmentSize > 0 && sz > p.MaxSegmentSize {
+		sz = p.MaxSegmentSize
+	}
+	if sz < minsz {
+		sz = minsz
+	}
+	// Round down so that padding can't overflow.
+	return sz &^ (wordSize - 1)
+}
+
+type policyArena struct {
+	multiSegmentArena
+	policy AllocPolicy
+}
+
+// NewArena returns a new arena that allocates new segments when they
+// are full, sizing them according to p.  Growing by adding segments
+// never copies the message's existing data, unlike a SingleSegment
+// arena.
+func NewArena(p AllocPolicy) Arena {
+	// The first segment is allocated up front so that its size comes
+	// from p rather than from the first allocation, which for a new
+	// message only needs room for the root pointer.
+	n := p.segmentSize(true, 0, wordSize)
+	var buf []byte
+	if p.FirstSegment != nil {
+		buf = p.FirstSegment(n)[:0]
+	}
+	if !hasCapacity(buf, wordSize) {
+		buf = make([]byte, 0, int(n))
+	}
+	return &policyArena{
+		multiSegmentArena: multiSegmentArena{buf},
+		policy:            p,
+	}
+}
+
+func (pa *policyArena) Allocate(sz Size, segs map[SegmentID]*Segment) (SegmentID, []byte, error) {
+	for i, data := range pa.multiSegmentArena {
+		id := SegmentID(i)
+		if s := segs[id]; s != nil {
+			data = s.data
+		}
+		if hasCapacity(data, sz) {
+			return id, data, nil
+		}
+	}
+	prev := Size(cap(pa.multiSegmentArena[len(pa.multiSegmentArena)-1]))
+	buf := make([]byte, 0, int(pa.policy.segmentSize(false, prev, sz.padToWord())))
+	id := SegmentID(len(pa.multiSegmentArena))
+	pa.multiSegmentArena = append(pa.multiSegmentArena, buf)
+	return id, buf, nil
+}
+
 // A Decoder represents a framer that deserializes a particular Cap'n
 // Proto input stream.
 type Decoder struct {
@@ -420,6 +548,12 @@ type Decoder struct {
 	// Maximum number of bytes that can be read per call to Decode.
 	// If not set, a reasonable default is used.
 	MaxMessageSize uint64
+
+	reuse  bool
+	hdrbuf []byte
+	buf    []byte
+	arena  multiSegmentArena
+	msg    Message
 }
 
 // NewDecoder creates a new Cap'n Proto framer that reads from r.
@@ -433,6 +567,14 @@ func NewPackedDecoder(r io.Reader) *Decoder {
 	return NewDecoder(packed.NewReader(bufio.NewReader(r)))
 }
 
+// ReuseBuffer makes the decoder reuse its buffers and Message between
+// calls to Decode instead of allocating new ones for every message.
+// A message returned by Decode is then only valid until the next call
+// to Decode, like the messages an rpc.Transport receives.
+func (d *Decoder) ReuseBuffer() {
+	d.reuse = true
+}
+
 // Decode reads a message from the decoder stream.
 func (d *Decoder) Decode() (*Message, error) {
 	maxSize := d.MaxMessageSize
@@ -451,7 +593,14 @@ func (d *Decoder) Decode() (*Message, error) {
 	if hdrSize > maxSize {
 		return nil, errDecodeLimit
 	}
-	hdrBuf := make([]byte, hdrSize)
+	hdrBuf := d.hdrbuf
+	if uint64(cap(hdrBuf)) < hdrSize {
+		hdrBuf = make([]byte, hdrSize)
+	}
+	hdrBuf = hdrBuf[:hdrSize]
+	if d.reuse {
+		d.hdrbuf = hdrBuf
+	}
 	copy(hdrBuf, maxSegBuf[:])
 	if _, err := io.ReadFull(d.r, hdrBuf[msgHeaderSize:]); err != nil {
 		return nil, err
@@ -467,15 +616,30 @@ func (d *Decoder) Decode() (*Message, error) {
 	if total > maxSize-hdrSize {
 		return nil, errDecodeLimit
 	}
-	buf := make([]byte, int(total))
+	if !d.reuse {
+		buf := make([]byte, int(total))
+		if _, err := io.ReadFull(d.r, buf); err != nil {
+			return nil, err
+		}
+		arena, err := demuxArena(hdr, buf)
+		if err != nil {
+			return nil, err
+		}
+		return &Message{Arena: arena}, nil
+	}
+	if uint64(cap(d.buf)) < total {
+		d.buf = make([]byte, int(total))
+	}
+	buf := d.buf[:total]
 	if _, err := io.ReadFull(d.r, buf); err != nil {
 		return nil, err
 	}
-	arena, err := demuxArena(hdr, buf)
+	d.arena, err = demuxSegments(d.arena[:0], hdr, buf)
 	if err != nil {
 		return nil, err
 	}
-	return &Message{Arena: arena}, nil
+	d.msg.Reset(&d.arena)
+	return &d.msg, nil
 }
 
 // Unmarshal reads an unpacked serialized stream into a message.  No
@@ -550,8 +714,8 @@ type Encoder struct {
 	hdrbuf []byte
 	bufs   [][]byte
 
-	packed  bool
-	packbuf []byte
+	packed bool
+	pw     *packed.Writer
 }
 
 // NewEncoder creates a new Cap'n Proto framer that writes to w.
@@ -601,13 +765,15 @@ func (e *Encoder) Encode(m *Message) error {
 }
 
 func (e *Encoder) writePacked(bufs [][]byte) error {
+	if e.pw == nil {
+		e.pw = packed.NewWriter(e.w)
+	}
 	for _, b := range bufs {
-		e.packbuf = packed.Pack(e.packbuf[:0], b)
-		if _, err := e.w.Write(e.packbuf); err != nil {
+		if _, err := e.pw.Write(b); err != nil {
 			return err
 		}
 	}
-	return nil
+	return e.pw.Flush()
 }
 
 func (m *Message) segmentSizes() ([]Size, error) {
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"reflect"
 	"testing"
 )
 
@@ -48,6 +49,27 @@ func TestNewMessage(t *testing.T) {
 	}
 }
 
+func TestNewMessage_FirstSegmentSize(t *testing.T) {
+	arena := &sizeRecordingArena{Arena: MultiSegment(nil)}
+	if _, _, err := NewMessage(arena); err != nil {
+		t.Fatal("NewMessage:", err)
+	}
+	if arena.minsz != defaultBufferSize {
+		t.Errorf("NewMessage allocated first segment with minsz = %d; want %d", arena.minsz, defaultBufferSize)
+	}
+}
+
+// sizeRecordingArena records the size of the last allocation.
+type sizeRecordingArena struct {
+	Arena
+	minsz Size
+}
+
+func (ra *sizeRecordingArena) Allocate(minsz Size, segs map[SegmentID]*Segment) (SegmentID, []byte, error) {
+	ra.minsz = minsz
+	return ra.Arena.Allocate(minsz, segs)
+}
+
 func TestAlloc(t *testing.T) {
 	type allocTest struct {
 		name string
@@ -376,6 +398,105 @@ func TestMultiSegmentAllocate(t *testing.T) {
 	}
 }
 
+func TestNewArena(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy AllocPolicy
+		allocs []Size
+		caps   []int
+	}{
+		{
+			name:   "zero policy",
+			allocs: []Size{8, 4096, 5000},
+			caps:   []int{defaultBufferSize, defaultBufferSize, 5000},
+		},
+		{
+			name:   "fixed size",
+			policy: AllocPolicy{InitialSize: 64},
+			allocs: []Size{64, 64, 8},
+			caps:   []int{64, 64, 64},
+		},
+		{
+			name:   "growth",
+			policy: AllocPolicy{InitialSize: 64, GrowthFactor: 2},
+			allocs: []Size{64, 64, 64},
+			caps:   []int{64, 128, 256},
+		},
+		{
+			name:   "growth with maximum",
+			policy: AllocPolicy{InitialSize: 64, GrowthFactor: 3, MaxSegmentSize: 256},
+			allocs: []Size{64, 192, 8, 256, 1024},
+			caps:   []int{64, 192, 256, 256, 1024},
+		},
+		{
+			name:   "allocation larger than initial size",
+			policy: AllocPolicy{InitialSize: 64},
+			allocs: []Size{100},
+			caps:   []int{64, 104},
+		},
+	}
+	for _, test := range tests {
+		msg, seg, err := NewMessage(NewArena(test.policy))
+		if err != nil {
+			t.Errorf("%s: NewMessage: %v", test.name, err)
+			continue
+		}
+		for _, sz := range test.allocs {
+			seg, _, err = alloc(seg, sz)
+			if err != nil {
+				t.Errorf("%s: alloc(%d): %v", test.name, sz, err)
+				break
+			}
+		}
+		var caps []int
+		for i := int64(0); i < msg.NumSegments(); i++ {
+			s, _ := msg.Segment(SegmentID(i))
+			caps = append(caps, cap(s.Data()))
+		}
+		if !reflect.DeepEqual(caps, test.caps) {
+			t.Errorf("%s: segment capacities = %v; want %v", test.name, caps, test.caps)
+		}
+	}
+}
+
+func TestNewArena_FirstSegment(t *testing.T) {
+	var requested Size
+	buf := make([]byte, 16, 128)
+	msg, seg, err := NewMessage(NewArena(AllocPolicy{
+		InitialSize: 100,
+		FirstSegment: func(size Size) []byte {
+			requested = size
+			return buf
+		},
+	}))
+	if err != nil {
+		t.Fatal("NewMessage:", err)
+	}
+	if requested != 96 {
+		t.Errorf("FirstSegment called with size %d; want 96", requested)
+	}
+	data := seg.Data()
+	if len(data) != 8 || &data[:1][0] != &buf[:1][0] {
+		t.Errorf("first segment does not use FirstSegment's buffer from the start")
+	}
+
+	// A buffer that is too small is not used.
+	msg, seg, err = NewMessage(NewArena(AllocPolicy{
+		FirstSegment: func(size Size) []byte {
+			return make([]byte, 0, 4)
+		},
+	}))
+	if err != nil {
+		t.Fatal("NewMessage with small buffer:", err)
+	}
+	if c := cap(seg.Data()); c != defaultBufferSize {
+		t.Errorf("with small buffer, first segment capacity = %d; want %d", c, defaultBufferSize)
+	}
+	if msg.NumSegments() != 1 {
+		t.Errorf("with small buffer, NumSegments() = %d; want 1", msg.NumSegments())
+	}
+}
+
 type serializeTest struct {
 	name        string
 	segs        [][]byte
@@ -568,6 +689,19 @@ func TestUnmarshal(t *testing.T) {
 	}
 }
 
+func TestRootPtrEmptySegment(t *testing.T) {
+	msg, err := Unmarshal([]byte{0, 0, 0, 0, 0, 0, 0, 0})
+	if err != nil {
+		t.Fatal("Unmarshal:", err)
+	}
+	if p, err := msg.RootPtr(); err == nil {
+		t.Errorf("RootPtr() = %v, <nil>; want error", p)
+	}
+	if err := msg.SetRootPtr(Ptr{}); err == nil {
+		t.Error("SetRootPtr(Ptr{}) = <nil>; want error")
+	}
+}
+
 func TestEncoder(t *testing.T) {
 	for i, test := range serializeTests {
 		if test.decodeFails {
@@ -630,6 +764,125 @@ func TestDecoder(t *testing.T) {
 	}
 }
 
+func TestDecoder_ReuseBuffer(t *testing.T) {
+	var stream []byte
+	var want [][][]byte
+	for _, test := range serializeTests {
+		if test.encodeFails || test.decodeFails {
+			continue
+		}
+		stream = append(stream, test.out...)
+		want = append(want, test.segs)
+	}
+	dec := NewDecoder(bytes.NewReader(stream))
+	dec.ReuseBuffer()
+	var first *Message
+	for i, segs := range want {
+		msg, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("Decode #%d: %v", i, err)
+		}
+		if first == nil {
+			first = msg
+		} else if msg != first {
+			t.Errorf("Decode #%d returned a new *Message; want the reused one", i)
+		}
+		if msg.NumSegments() != int64(len(segs)) {
+			t.Errorf("Decode #%d NumSegments() = %d; want %d", i, msg.NumSegments(), len(segs))
+			continue
+		}
+		for j := range segs {
+			seg, err := msg.Segment(SegmentID(j))
+			if err != nil {
+				t.Errorf("Decode #%d Segment(%d) error: %v", i, j, err)
+				continue
+			}
+			if !bytes.Equal(seg.Data(), segs[j]) {
+				t.Errorf("Decode #%d Segment(%d) = % 02x; want % 02x", i, j, seg.Data(), segs[j])
+			}
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("Decode at end = %v; want io.EOF", err)
+	}
+}
+
+func TestDecoder_ReuseBufferAllocate(t *testing.T) {
+	// Allocating in a decoded message must not overwrite the next
+	// segment in the decoder's buffer.
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for i := 0; i < 2; i++ {
+		msg := &Message{Arena: MultiSegment([][]byte{incrementingData(8), incrementingData(8)})}
+		if err := enc.Encode(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dec := NewDecoder(&buf)
+	dec.ReuseBuffer()
+	for i := 0; i < 2; i++ {
+		msg, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("Decode #%d: %v", i, err)
+		}
+		seg, err := msg.Segment(0)
+		if err != nil {
+			t.Fatalf("Decode #%d Segment(0): %v", i, err)
+		}
+		if _, err := NewStruct(seg, ObjectSize{DataSize: 8}); err != nil {
+			t.Fatalf("Decode #%d NewStruct: %v", i, err)
+		}
+		seg1, err := msg.Segment(1)
+		if err != nil {
+			t.Fatalf("Decode #%d Segment(1): %v", i, err)
+		}
+		if !bytes.Equal(seg1.Data(), incrementingData(8)) {
+			t.Errorf("Decode #%d Segment(1) = % 02x after allocating; want % 02x", i, seg1.Data(), incrementingData(8))
+		}
+	}
+}
+
+func TestDecoder_ReuseBufferReadRoot(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for i := 0; i < 3; i++ {
+		if err := enc.Encode(rootStructMessage(t, uint64(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dec := NewDecoder(&buf)
+	dec.ReuseBuffer()
+	for i := 0; i < 3; i++ {
+		msg, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("Decode #%d: %v", i, err)
+		}
+		p, err := msg.RootPtr()
+		if err != nil {
+			t.Errorf("Decode #%d RootPtr: %v", i, err)
+			continue
+		}
+		if x := p.Struct().Uint64(0); x != uint64(i) {
+			t.Errorf("Decode #%d root field = %d; want %d", i, x, i)
+		}
+	}
+}
+
+// rootStructMessage returns a message whose root is a struct with x in
+// its first word.
+func rootStructMessage(t *testing.T, x uint64) *Message {
+	msg, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewRootStruct(seg, ObjectSize{DataSize: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetUint64(0, x)
+	return msg
+}
+
 func TestDecoder_MaxMessageSize(t *testing.T) {
 	t.Parallel()
 	zeroWord := []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
@@ -1,8 +1,8 @@
 // +build gofuzz
 
 // Fuzz test harness.  To run:
-// go-fuzz-build zombiezen.com/go/capnproto2/internal/packed
-// go-fuzz -bin=packed-fuzz.zip -workdir=internal/packed/testdata
+// go-fuzz-build zombiezen.com/go/capnproto2/packed
+// go-fuzz -bin=packed-fuzz.zip -workdir=packed/testdata
 
 package packed
 
@@ -0,0 +1,69 @@
+// +build go1.18
+
+package packed
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// FuzzUnpack checks that everything Unpack, Reader.Read, and
+// Reader.ReadWord accept packs back to the same bytes.
+func FuzzUnpack(f *testing.F) {
+	names, err := filepath.Glob(filepath.Join("testdata", "corpus", "*"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, name := range names {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	for _, test := range compressionTests {
+		f.Add(test.compressed)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		unpacked, err := Unpack(nil, data)
+		if err == nil {
+			checkRepackT(t, "Unpack", unpacked)
+		}
+
+		r := NewReader(bufio.NewReader(bytes.NewReader(data)))
+		readAll, err := ioutil.ReadAll(r)
+		if err == nil {
+			checkRepackT(t, "Read", readAll)
+		}
+
+		r = NewReader(bufio.NewReader(bytes.NewReader(data)))
+		var words []byte
+		for {
+			n := len(words)
+			words = append(words, 0, 0, 0, 0, 0, 0, 0, 0)
+			if err = r.ReadWord(words[n:]); err != nil {
+				words = words[:n]
+				break
+			}
+		}
+		if err == io.EOF {
+			checkRepackT(t, "ReadWord", words)
+		}
+	})
+}
+
+func checkRepackT(t *testing.T, name string, unpacked []byte) {
+	packed := Pack(nil, unpacked)
+	unpacked2, err := Unpack(nil, packed)
+	if err != nil {
+		t.Fatalf("%s: unpack, pack, unpack gives error: %v", name, err)
+	}
+	if !bytes.Equal(unpacked, unpacked2) {
+		t.Fatalf("%s: unpack, pack, unpack gives %x; want %x", name, unpacked2, unpacked)
+	}
+}
@@ -1,5 +1,9 @@
 // Package packed provides functions to read and write the "packed"
 // compression scheme described at https://capnproto.org/encoding.html#packing.
+//
+// Pack and Unpack work on whole buffers.  Writer and Reader pack and
+// unpack streams incrementally, so they can be used under framing
+// schemes other than Cap'n Proto's message streams.
 package packed
 
 import (
@@ -332,3 +336,103 @@ func (r *Reader) Read(p []byte) (n int, err error) {
 	}
 	return n, nil
 }
+
+// writeBufferSize is the number of unpacked bytes that a Writer packs
+// at a time.
+const writeBufferSize = 8192
+
+var errPartialWord = errors.New("packed: stream is not a whole number of words")
+
+// A Writer compresses a byte stream with the packed encoding and writes
+// it to an underlying writer.  It buffers a bounded amount of unpacked
+// data, so Flush must be called to write out the rest of the packed
+// stream.
+//
+// Packing works on whole words, so a Writer holds on to a trailing
+// partial word until it is completed by a later Write.
+type Writer struct {
+	w   io.Writer
+	buf []byte // unpacked data not yet written
+	out []byte // packed data
+	err error
+}
+
+// NewWriter returns a writer that compresses a packed stream to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Reset discards any buffered data and errors and makes pw write to w.
+func (pw *Writer) Reset(w io.Writer) {
+	pw.w = w
+	pw.buf = pw.buf[:0]
+	pw.err = nil
+}
+
+// Write buffers p, packing and writing out the buffer whenever it
+// fills up.
+func (pw *Writer) Write(p []byte) (n int, err error) {
+	if pw.buf == nil {
+		pw.buf = make([]byte, 0, writeBufferSize)
+	}
+	for len(p) > 0 && pw.err == nil {
+		if len(pw.buf) == 0 && len(p) >= writeBufferSize {
+			// Pack directly from p to avoid a copy.
+			pw.pack(p[:writeBufferSize])
+			if pw.err != nil {
+				break
+			}
+			n += writeBufferSize
+			p = p[writeBufferSize:]
+			continue
+		}
+		k := copy(pw.buf[len(pw.buf):cap(pw.buf)], p)
+		pw.buf = pw.buf[:len(pw.buf)+k]
+		n += k
+		p = p[k:]
+		if len(pw.buf) == cap(pw.buf) {
+			pw.flush()
+		}
+	}
+	return n, pw.err
+}
+
+// Flush packs and writes out all the whole words that are buffered.
+func (pw *Writer) Flush() error {
+	pw.flush()
+	return pw.err
+}
+
+// Close flushes the writer and reports an error if the stream written
+// ends with a partial word.  It does not close the underlying writer.
+func (pw *Writer) Close() error {
+	if err := pw.Flush(); err != nil {
+		return err
+	}
+	if len(pw.buf) > 0 {
+		return errPartialWord
+	}
+	return nil
+}
+
+func (pw *Writer) flush() {
+	if pw.err != nil {
+		return
+	}
+	n := len(pw.buf) &^ (wordSize - 1)
+	if n == 0 {
+		return
+	}
+	pw.pack(pw.buf[:n])
+	if pw.err != nil {
+		return
+	}
+	pw.buf = pw.buf[:copy(pw.buf, pw.buf[n:])]
+}
+
+// pack writes the packed form of p, which must be a whole number of
+// words, to the underlying writer.
+func (pw *Writer) pack(p []byte) {
+	pw.out = Pack(pw.out[:0], p)
+	_, pw.err = pw.w.Write(pw.out)
+}
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"encoding/hex"
+	"errors"
 	"io"
 	"io/ioutil"
 	"math"
@@ -361,6 +362,109 @@ func TestReader_Fail(t *testing.T) {
 	}
 }
 
+func TestWriter(t *testing.T) {
+	for _, test := range compressionTests {
+		for writeSize := 1; writeSize <= 8+len(test.original); writeSize = nextPrime(writeSize) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf)
+			for p := test.original; len(p) > 0; {
+				n := writeSize
+				if n > len(p) {
+					n = len(p)
+				}
+				if _, err := w.Write(p[:n]); err != nil {
+					t.Fatalf("%s: writeSize=%d: Write: %v", test.name, writeSize, err)
+				}
+				p = p[n:]
+			}
+			if err := w.Close(); err != nil {
+				t.Errorf("%s: writeSize=%d: Close: %v", test.name, writeSize, err)
+				continue
+			}
+			if !bytes.Equal(buf.Bytes(), test.compressed) {
+				t.Errorf("%s: writeSize=%d: wrote\n%s\n; want\n%s", test.name, writeSize, hex.Dump(buf.Bytes()), hex.Dump(test.compressed))
+			}
+		}
+	}
+}
+
+func TestWriter_Large(t *testing.T) {
+	orig := make([]byte, 5*writeBufferSize+3*wordSize)
+	for i := range orig {
+		if i%5 == 0 {
+			orig[i] = byte(i)
+		}
+	}
+	for _, writeSize := range []int{1, 7, writeBufferSize, len(orig)} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		for p := orig; len(p) > 0; {
+			n := writeSize
+			if n > len(p) {
+				n = len(p)
+			}
+			w.Write(p[:n])
+			p = p[n:]
+		}
+		if err := w.Close(); err != nil {
+			t.Errorf("writeSize=%d: Close: %v", writeSize, err)
+			continue
+		}
+		actual, err := Unpack(nil, buf.Bytes())
+		if err != nil {
+			t.Errorf("writeSize=%d: Unpack: %v", writeSize, err)
+		} else if !bytes.Equal(actual, orig) {
+			t.Errorf("writeSize=%d: stream does not unpack to original", writeSize)
+		}
+	}
+}
+
+func TestWriter_PartialWord(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Write([]byte{1, 2, 3, 4, 5, 6, 7, 8, 9})
+	if err := w.Flush(); err != nil {
+		t.Fatal("Flush:", err)
+	}
+	if want := []byte{0xff, 1, 2, 3, 4, 5, 6, 7, 8, 0}; !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("after Flush, wrote %v; want %v", buf.Bytes(), want)
+	}
+	if err := w.Close(); err == nil {
+		t.Error("Close with partial word did not return error")
+	}
+	w.Write([]byte{0, 0, 0, 0, 0, 0, 0})
+	if err := w.Close(); err != nil {
+		t.Error("Close after completing word:", err)
+	}
+}
+
+func TestWriter_Err(t *testing.T) {
+	w := NewWriter(errWriter{})
+	if _, err := w.Write(make([]byte, 2*writeBufferSize)); err != errWrite {
+		t.Errorf("Write error = %v; want %v", err, errWrite)
+	}
+	if err := w.Flush(); err != errWrite {
+		t.Errorf("Flush error = %v; want %v", err, errWrite)
+	}
+	var buf bytes.Buffer
+	w.Reset(&buf)
+	w.Write(make([]byte, wordSize))
+	if err := w.Close(); err != nil {
+		t.Error("Close after Reset:", err)
+	}
+	if want := []byte{0, 0}; !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("after Reset, wrote %v; want %v", buf.Bytes(), want)
+	}
+}
+
+var errWrite = errors.New("write failed")
+
+type errWriter struct{}
+
+func (errWriter) Write(p []byte) (int, error) {
+	return 0, errWrite
+}
+
 var result []byte
 
 func BenchmarkPack(b *testing.B) {
@@ -5,11 +5,11 @@ type pointerOffset int32
 
 // resolve returns the absolute address, given that the pointer is located at paddr.
 func (off pointerOffset) resolve(paddr Address) (addr Address, ok bool) {
-	// TODO(light): verify
-	if off < 0 && Address(-off) > paddr {
+	x := int64(paddr) + int64(off)*int64(wordSize) + int64(wordSize)
+	if x < 0 || x > int64(maxSize) {
 		return 0, false
 	}
-	return paddr + Address(off*8) + 8, true
+	return Address(x), true
 }
 
 // makePointerOffset computes the offset for a pointer at paddr to point to addr.
@@ -56,12 +56,10 @@ func rawDoubleFarPointer(segID SegmentID, off Address) rawPointer {
 // a near pointer in the destination segment.  Its offset will be
 // relative to the beginning of the segment.
 func landingPadNearPointer(far, tag rawPointer) rawPointer {
-	// remove 1 word and we'll get the offset to the start of the data section
-	rawFarFarPointer := rawPointer(far.farAddress() / Address(wordSize) - 1)
-	
-	// finally to get an actual pointer do an OR with the tag after moving rawFarFarPointer
-	// by 2 bits which are the type indicator bits
-	return tag | rawFarFarPointer << 2
+	// The tag is treated as if it were located one word before the
+	// landing pad address, so the offset from the beginning of the
+	// segment is one less than the landing pad's word index.
+	return tag.withOffset(pointerOffset(far.farAddress()/Address(wordSize)) - 1)
 }
 
 // Raw pointer types.
@@ -186,6 +184,12 @@ func (p rawPointer) offset() pointerOffset {
 	return pointerOffset(s32)
 }
 
+// withOffset returns p with its offset replaced by off.  p must be a
+// struct or list pointer.
+func (p rawPointer) withOffset(off pointerOffset) rawPointer {
+	return p&^(zerohi32&^3) | orable30BitOffsetPart(off)
+}
+
 // otherPointerType returns the type of "other pointer" from p.
 func (p rawPointer) otherPointerType() uint32 {
 	return uint32(p & zerohi32 >> 2)
@@ -239,6 +239,8 @@ func TestLandingPadNearPointer(t *testing.T) {
 		tag     rawPointer
 		landing rawPointer
 	}{
+		{rawFarPointer(0, 0), rawStructPointer(0, ObjectSize{16, 2}), rawStructPointer(-1, ObjectSize{16, 2})},
+		{rawFarPointer(0, 0), rawListPointer(0, compositeList, 6), rawListPointer(-1, compositeList, 6)},
 		{rawFarPointer(0, 8), rawStructPointer(0, ObjectSize{16, 2}), rawStructPointer(0, ObjectSize{16, 2})},
 		{rawFarPointer(0, 160), rawStructPointer(0, ObjectSize{16, 2}), rawStructPointer(19, ObjectSize{16, 2})},
 		{rawFarPointer(0, 2834), rawStructPointer(0, ObjectSize{16, 2}), rawStructPointer(353, ObjectSize{16, 2})},
@@ -252,3 +254,26 @@ func TestLandingPadNearPointer(t *testing.T) {
 		}
 	}
 }
+
+func TestPointerOffsetResolve(t *testing.T) {
+	tests := []struct {
+		off   pointerOffset
+		paddr Address
+		addr  Address
+		ok    bool
+	}{
+		{0, 0, 8, true},
+		{-1, 0, 0, true},
+		{-2, 0, 0, false},
+		{-3, 8, 0, false},
+		{-3, 16, 0, true},
+		{2, 16, 40, true},
+		{0, Address(maxSize) - 7, 0, false},
+	}
+	for _, test := range tests {
+		addr, ok := test.off.resolve(test.paddr)
+		if ok != test.ok || (ok && addr != test.addr) {
+			t.Errorf("pointerOffset(%d).resolve(%d) = %d, %t; want %d, %t", test.off, test.paddr, addr, ok, test.addr, test.ok)
+		}
+	}
+}
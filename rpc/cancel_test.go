@@ -50,3 +50,157 @@ func (h Hanger) Hang(call testcapnp.Hanger_hang) error {
 	close(h.notify)
 	return nil
 }
+
+func TestCancelMultiHop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	log := testLogger{t}
+
+	// A calls B, which forwards the call to C.
+	pAB, qAB := pipetransport.New()
+	pBC, qBC := pipetransport.New()
+	if *logMessages {
+		pAB = logtransport.New(nil, pAB)
+		pBC = logtransport.New(nil, pBC)
+	}
+	notify := make(chan struct{})
+	hanger := testcapnp.Hanger_ServerToClient(Hanger{notify: notify})
+	c := rpc.NewConn(qBC, rpc.MainInterface(hanger.Client), rpc.ConnLog(log))
+	defer c.Wait()
+	bc := rpc.NewConn(pBC, rpc.ConnLog(log))
+	defer bc.Close()
+	b := rpc.NewConn(qAB, rpc.MainInterface(bc.Bootstrap(ctx)), rpc.ConnLog(log))
+	defer b.Wait()
+	a := rpc.NewConn(pAB, rpc.ConnLog(log))
+	defer a.Close()
+	client := testcapnp.Hanger{Client: a.Bootstrap(ctx)}
+
+	subctx, subcancel := context.WithCancel(ctx)
+	promise := client.Hang(subctx, nil)
+	<-notify
+	subcancel()
+	_, err := promise.Struct()
+	<-notify // test will deadlock if cancel not delivered to C
+
+	if err != context.Canceled {
+		t.Errorf("promise.Get() error: %v; want %v", err, context.Canceled)
+	}
+}
+
+func TestCancelPipelinedCall(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p, q := pipetransport.New()
+	if *logMessages {
+		p = logtransport.New(nil, p)
+	}
+	log := testLogger{t}
+	c := rpc.NewConn(p, rpc.ConnLog(log))
+	delay := make(chan struct{})
+	notify := make(chan struct{})
+	methods := testcapnp.Echoer_Methods(nil, &DelayEchoer{delay: delay})
+	methods = testcapnp.Hanger_Methods(methods, Hanger{notify: notify})
+	d := rpc.NewConn(q, rpc.MainInterface(server.New(methods, nil)), rpc.ConnLog(log))
+	defer d.Wait()
+	defer c.Close()
+	client := testcapnp.Echoer{Client: c.Bootstrap(ctx)}
+
+	// Make a call on the promised capability while echo is blocked,
+	// then cancel it once it starts.
+	echo := client.Echo(ctx, func(p testcapnp.Echoer_echo_Params) error {
+		return p.SetCap(testcapnp.CallOrder{Client: client.Client})
+	})
+	subctx, subcancel := context.WithCancel(ctx)
+	promise := testcapnp.Hanger{Client: echo.Cap().Client}.Hang(subctx, nil)
+	close(delay)
+	<-notify
+	subcancel()
+	_, err := promise.Struct()
+	<-notify // test will deadlock if cancel not delivered
+
+	if err != context.Canceled {
+		t.Errorf("promise.Get() error: %v; want %v", err, context.Canceled)
+	}
+}
+
+func TestCancelPipelinedCallLocal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	delay := make(chan struct{})
+	client := testcapnp.Echoer_ServerToClient(&DelayEchoer{delay: delay})
+	defer client.Client.Close()
+
+	// Cancel a call on the promised capability while echo is blocked.
+	// The canceled call should fail right away and never be delivered.
+	echo := client.Echo(ctx, func(p testcapnp.Echoer_echo_Params) error {
+		return p.SetCap(testcapnp.CallOrder{Client: client.Client})
+	})
+	pipeline := echo.Cap()
+	subctx, subcancel := context.WithCancel(ctx)
+	call0 := callseq(subctx, pipeline.Client, 0)
+	subcancel()
+	if _, err := call0.Struct(); err != context.Canceled {
+		t.Errorf("canceled call error: %v; want %v", err, context.Canceled)
+	}
+	close(delay)
+	if _, err := echo.Struct(); err != nil {
+		t.Fatal("echo error:", err)
+	}
+
+	r, err := callseq(ctx, pipeline.Client, 0).Struct()
+	if err != nil {
+		t.Fatal("call after cancel error:", err)
+	}
+	if r.N() != 0 {
+		t.Errorf("call after cancel = %d; want 0 (canceled call was delivered)", r.N())
+	}
+}
+
+func TestCancelQueuedPipelinedCall(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p, q := pipetransport.New()
+	if *logMessages {
+		p = logtransport.New(nil, p)
+	}
+	log := testLogger{t}
+	c := rpc.NewConn(p, rpc.ConnLog(log))
+	delay := make(chan struct{})
+	echoSrv := testcapnp.Echoer_ServerToClient(&DelayEchoer{delay: delay})
+	d := rpc.NewConn(q, rpc.MainInterface(echoSrv.Client), rpc.ConnLog(log))
+	defer d.Wait()
+	defer c.Close()
+	client := testcapnp.Echoer{Client: c.Bootstrap(ctx)}
+
+	// Cancel a call on the promised capability while echo is blocked.
+	// The canceled call should never be delivered.
+	echo := client.Echo(ctx, func(p testcapnp.Echoer_echo_Params) error {
+		return p.SetCap(testcapnp.CallOrder{Client: client.Client})
+	})
+	pipeline := echo.Cap()
+	subctx, subcancel := context.WithCancel(ctx)
+	call0 := callseq(subctx, pipeline.Client, 0)
+	subcancel()
+	if _, err := call0.Struct(); err != context.Canceled {
+		t.Errorf("canceled call error: %v; want %v", err, context.Canceled)
+	}
+	// Messages are handled in order, so once this call returns, the
+	// other side has seen the cancellation.
+	if r, err := callseq(ctx, client.Client, 0).Struct(); err != nil {
+		t.Fatal("call on bootstrap error:", err)
+	} else if r.N() != 0 {
+		t.Fatalf("call on bootstrap = %d; want 0 (canceled call was delivered)", r.N())
+	}
+	close(delay)
+	if _, err := echo.Struct(); err != nil {
+		t.Fatal("echo error:", err)
+	}
+
+	r, err := callseq(ctx, pipeline.Client, 1).Struct()
+	if err != nil {
+		t.Fatal("call after cancel error:", err)
+	}
+	if r.N() != 1 {
+		t.Errorf("call after cancel = %d; want 1 (canceled call was delivered)", r.N())
+	}
+}
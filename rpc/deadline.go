@@ -0,0 +1,138 @@
+package rpc
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+	"zombiezen.com/go/capnproto2"
+	rpccapnp "zombiezen.com/go/capnproto2/std/capnp/rpc"
+)
+
+// Deadlines are sent in a side message right before the call they
+// apply to, so the Call struct is sent exactly as rpc.capnp defines it.
+// The side message is a Message whose union discriminant is 0xffff.  A
+// union's members are numbered from zero and there can be at most
+// 0xffff of them, so no message type added to rpc.capnp can ever use
+// this discriminant.  The message's pointer holds a struct with the
+// call's question ID as a UInt32 at offset 0 and the time remaining
+// before the caller's deadline in nanoseconds as an Int64 at offset 8.
+//
+// rpc.capnp requires peers to echo messages they don't understand back
+// as unimplemented.  Once a deadline message is echoed back, the
+// connection stops sending them.  A deadline message only applies to
+// the message that immediately follows it, and only if that message is
+// a call with the same question ID.
+const (
+	deadlineMessageWhich   = rpccapnp.Message_Which(0xffff)
+	deadlineQuestionOffset = 0
+	deadlineTimeoutOffset  = 8
+)
+
+var deadlineSize = capnp.ObjectSize{DataSize: 16}
+
+// PropagateDeadlines sets whether the connection sends the deadlines
+// of outgoing calls and applies the deadlines of incoming calls to the
+// contexts passed to their implementations.  It is disabled by
+// default.
+//
+// The deadline is transmitted as the duration remaining at the time the
+// call is sent, so the receiver's deadline is later than the caller's
+// by the time the message takes to arrive.  This is a Go-specific
+// extension to the protocol: other implementations reply that they
+// don't understand it, after which the connection stops sending
+// deadlines.
+func PropagateDeadlines(enabled bool) ConnOption {
+	return ConnOption{func(c *connParams) {
+		c.propagateDeadlines = enabled
+	}}
+}
+
+// A callDeadline is a deadline received for an incoming call.
+type callDeadline struct {
+	id      answerID
+	timeout time.Duration
+}
+
+// sendCall sends msg, a call for question id, preceded by a deadline
+// message if deadline propagation is enabled and ctx has a deadline.
+// It returns an error if ctx is done or the connection is closed before
+// the messages could be queued.  The caller must be holding onto c.mu.
+func (c *Conn) sendCall(ctx context.Context, id questionID, msg rpccapnp.Message) error {
+	if d, ok := ctx.Deadline(); ok && c.propagateDeadlines && !c.peerIgnoresDeadlines {
+		if err := c.sendCallMessage(ctx, newDeadlineMessage(nil, id, d.Sub(time.Now()))); err != nil {
+			return err
+		}
+		// Once the deadline message is queued, the call must follow it,
+		// even if ctx is done: otherwise the deadline would apply to the
+		// next call that reuses the question ID.  The question's start
+		// goroutine cancels the call if ctx is done.
+		return c.sendMessage(msg)
+	}
+	return c.sendCallMessage(ctx, msg)
+}
+
+func (c *Conn) sendCallMessage(ctx context.Context, msg rpccapnp.Message) error {
+	select {
+	case c.out <- msg:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.bg.Done():
+		return ErrConnClosed
+	}
+}
+
+func newDeadlineMessage(buf []byte, id questionID, timeout time.Duration) rpccapnp.Message {
+	if timeout <= 0 {
+		// Zero means no deadline, so send the smallest timeout instead.
+		timeout = 1
+	}
+	m := newMessage(buf)
+	s, _ := capnp.NewStruct(m.Segment(), deadlineSize)
+	s.SetUint32(deadlineQuestionOffset, uint32(id))
+	s.SetUint64(deadlineTimeoutOffset, uint64(timeout))
+	m.Struct.SetUint16(0, uint16(deadlineMessageWhich))
+	m.Struct.SetPtr(0, s.ToPtr())
+	return m
+}
+
+// handleDeadlineMessage handles a received deadline message.  It is
+// run from the receive goroutine.
+func (c *Conn) handleDeadlineMessage(m rpccapnp.Message) {
+	if !c.propagateDeadlines {
+		c.sendMessage(newUnimplementedMessage(nil, m))
+		return
+	}
+	p, err := m.Struct.Ptr(0)
+	if err != nil {
+		c.errorf("decode deadline: %v", err)
+		return
+	}
+	s := p.Struct()
+	if timeout := time.Duration(s.Uint64(deadlineTimeoutOffset)); timeout > 0 {
+		c.nextDeadline = &callDeadline{
+			id:      answerID(s.Uint32(deadlineQuestionOffset)),
+			timeout: timeout,
+		}
+	}
+}
+
+// handleUnimplementedDeadline stops sending deadlines to a peer that
+// echoed one back.
+func (c *Conn) handleUnimplementedDeadline() {
+	c.mu.Lock()
+	if !c.peerIgnoresDeadlines {
+		c.infof("remote vat does not support deadlines; no longer sending them")
+		c.peerIgnoresDeadlines = true
+	}
+	c.mu.Unlock()
+}
+
+// newCallContext returns the context for an incoming call.  dl is the
+// deadline received right before the call, if any.
+func (c *Conn) newCallContext(mcall rpccapnp.Call, dl *callDeadline) (context.Context, context.CancelFunc) {
+	if dl != nil && dl.id == answerID(mcall.QuestionId()) {
+		return context.WithTimeout(c.bg, dl.timeout)
+	}
+	return c.newContext()
+}
@@ -0,0 +1,223 @@
+package rpc_test
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/rpc"
+	"zombiezen.com/go/capnproto2/rpc/internal/logtransport"
+	"zombiezen.com/go/capnproto2/rpc/internal/pipetransport"
+	"zombiezen.com/go/capnproto2/rpc/internal/testcapnp"
+	rpccapnp "zombiezen.com/go/capnproto2/std/capnp/rpc"
+)
+
+// deadlineHanger is a Hanger that reports the deadline of each call's
+// context and returns immediately.
+type deadlineHanger struct {
+	deadlines chan time.Time
+}
+
+func (h deadlineHanger) Hang(call testcapnp.Hanger_hang) error {
+	d, _ := call.Ctx.Deadline()
+	h.deadlines <- d
+	return nil
+}
+
+func TestPropagateDeadlines(t *testing.T) {
+	tests := []struct {
+		name             string
+		sender, receiver bool
+		want             bool
+	}{
+		{"both", true, true, true},
+		{"sender only", true, false, false},
+		{"receiver only", false, true, false},
+		{"neither", false, false, false},
+	}
+	for _, test := range tests {
+		start := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+		p, q := pipetransport.New()
+		if *logMessages {
+			p = logtransport.New(nil, p)
+		}
+		log := testLogger{t}
+		c := rpc.NewConn(p, rpc.ConnLog(log), rpc.PropagateDeadlines(test.sender))
+		impl := deadlineHanger{deadlines: make(chan time.Time, 1)}
+		hanger := testcapnp.Hanger_ServerToClient(impl)
+		d := rpc.NewConn(q, rpc.MainInterface(hanger.Client), rpc.ConnLog(log), rpc.PropagateDeadlines(test.receiver))
+		client := testcapnp.Hanger{Client: c.Bootstrap(ctx)}
+
+		_, err := client.Hang(ctx, nil).Struct()
+		end := time.Now()
+		if err != nil {
+			t.Errorf("%s: hang error: %v", test.name, err)
+		} else if dl := <-impl.deadlines; !test.want && !dl.IsZero() {
+			t.Errorf("%s: implementation deadline = %v; want none", test.name, dl)
+		} else if test.want && (dl.Before(start.Add(time.Hour)) || dl.After(end.Add(time.Hour))) {
+			t.Errorf("%s: implementation deadline = %v; want about an hour from %v", test.name, dl, start)
+		}
+
+		c.Close()
+		d.Wait()
+		cancel()
+	}
+}
+
+func TestPropagateDeadlinesUnimplemented(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	conn, p := newUnpairedConn(t, rpc.PropagateDeadlines(true))
+	defer conn.Close()
+	defer p.Close()
+	client := bootstrapAndFulfill(t, ctx, conn, p, false)
+	call := func() capnp.Answer {
+		return client.Call(&capnp.Call{
+			Ctx: ctx,
+			Method: capnp.Method{
+				InterfaceID: interfaceID,
+				MethodID:    methodID,
+			},
+			ParamsSize: capnp.ObjectSize{DataSize: 8},
+		})
+	}
+
+	// The first call is sent as a standard call, right after a side
+	// message with the deadline.
+	ans := call()
+	dl, err := p.RecvMessage(ctx)
+	if err != nil {
+		t.Fatal("reading deadline:", err)
+	}
+	if dl.Which() == rpccapnp.Message_Which_call {
+		t.Fatal("call sent without a deadline message")
+	}
+	// A peer that doesn't know about deadlines echoes the message back.
+	// The received message is only valid until the next receive.
+	_, seg, _ := capnp.NewMessage(capnp.SingleSegment(nil))
+	echo, _ := rpccapnp.NewRootMessage(seg)
+	if err := echo.SetUnimplemented(dl); err != nil {
+		t.Fatal("copying deadline:", err)
+	}
+	msg, err := p.RecvMessage(ctx)
+	if err != nil {
+		t.Fatal("reading call:", err)
+	}
+	if msg.Which() != rpccapnp.Message_Which_call {
+		t.Fatalf("message after deadline is %v; want call", msg.Which())
+	}
+	mcall, err := msg.Call()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sz, want := mcall.Struct.Size(), (capnp.ObjectSize{DataSize: 24, PointerCount: 3}); sz != want {
+		t.Errorf("call size = %v; want %v", sz, want)
+	}
+
+	if err := p.SendMessage(ctx, echo); err != nil {
+		t.Fatal("sending unimplemented:", err)
+	}
+	if err := sendMessage(ctx, p, func(m rpccapnp.Message) error {
+		ret, err := m.NewReturn()
+		if err != nil {
+			return err
+		}
+		ret.SetAnswerId(mcall.QuestionId())
+		payload, err := ret.NewResults()
+		if err != nil {
+			return err
+		}
+		s, err := capnp.NewStruct(m.Segment(), capnp.ObjectSize{})
+		if err != nil {
+			return err
+		}
+		return payload.SetContentPtr(s.ToPtr())
+	}); err != nil {
+		t.Fatal("sending return:", err)
+	}
+	if _, err := ans.Struct(); err != nil {
+		t.Fatal("first call:", err)
+	}
+	if fin, err := p.RecvMessage(ctx); err != nil {
+		t.Fatal("reading finish:", err)
+	} else if fin.Which() != rpccapnp.Message_Which_finish {
+		t.Fatalf("message after return is %v; want finish", fin.Which())
+	}
+
+	// Later calls are sent without a deadline.
+	call()
+	if msg, err := p.RecvMessage(ctx); err != nil {
+		t.Fatal("reading second call:", err)
+	} else if msg.Which() != rpccapnp.Message_Which_call {
+		t.Errorf("second call sent %v message first; want call", msg.Which())
+	}
+}
+
+func TestPropagateDeadlinesCallFollowsDeadline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conn, p := newUnpairedConn(t, rpc.PropagateDeadlines(true), rpc.SendBufferSize(1))
+	defer conn.Close()
+	defer p.Close()
+	client := bootstrapAndFulfill(t, ctx, conn, p, false)
+	call := func(ctx context.Context, n uint64) capnp.Answer {
+		return client.Call(&capnp.Call{
+			Ctx: ctx,
+			Method: capnp.Method{
+				InterfaceID: interfaceID,
+				MethodID:    methodID,
+			},
+			ParamsSize: capnp.ObjectSize{DataSize: 8},
+			ParamsFunc: func(s capnp.Struct) error {
+				s.SetUint64(0, n)
+				return nil
+			},
+		})
+	}
+
+	// Nothing is read from p until both calls are made, so the first
+	// call holds up the connection's sender and the deadline message
+	// fills its buffer.  The second call's context expires while the
+	// call is waiting to be queued.
+	call(ctx, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		shortCtx, shortCancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer shortCancel()
+		call(shortCtx, 2)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	if msg, err := p.RecvMessage(ctx); err != nil {
+		t.Fatal("reading first call:", err)
+	} else if msg.Which() != rpccapnp.Message_Which_call {
+		t.Fatalf("first message is %v; want call", msg.Which())
+	}
+	dl, err := p.RecvMessage(ctx)
+	if err != nil {
+		t.Fatal("reading deadline:", err)
+	}
+	if dl.Which() == rpccapnp.Message_Which_call {
+		t.Fatal("second call sent without a deadline message")
+	}
+	<-done
+	// If the second call wasn't sent, this call could reuse its
+	// question ID and pick up its deadline.
+	go call(ctx, 3)
+	msg, err := p.RecvMessage(ctx)
+	if err != nil {
+		t.Fatal("reading message after deadline:", err)
+	}
+	if msg.Which() != rpccapnp.Message_Which_call {
+		t.Fatalf("message after deadline is %v; want call", msg.Which())
+	}
+	mcall, _ := msg.Call()
+	payload, _ := mcall.Params()
+	content, _ := payload.ContentPtr()
+	if n := content.Struct().Uint64(0); n != 2 {
+		t.Errorf("call after deadline is call %d; want 2", n)
+	}
+}
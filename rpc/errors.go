@@ -22,6 +22,20 @@ func (e Exception) Error() string {
 	return "rpc exception: " + r
 }
 
+// ErrorType returns the exception's type.
+func (e Exception) ErrorType() capnp.ErrorType {
+	switch e.Type() {
+	case rpccapnp.Exception_Type_overloaded:
+		return capnp.Overloaded
+	case rpccapnp.Exception_Type_disconnected:
+		return capnp.Disconnected
+	case rpccapnp.Exception_Type_unimplemented:
+		return capnp.Unimplemented
+	default:
+		return capnp.Failed
+	}
+}
+
 // An Abort is a hang-up by a remote vat.
 type Abort Exception
 
@@ -50,6 +64,12 @@ func (a Abort) Error() string {
 	return "rpc: aborted by remote: " + r
 }
 
+// ErrorType returns capnp.Disconnected, since the remote vat has hung
+// up.
+func (a Abort) ErrorType() capnp.ErrorType {
+	return capnp.Disconnected
+}
+
 // toException sets fields on exc to match err.
 func toException(exc rpccapnp.Exception, err error) {
 	if ee, ok := err.(Exception); ok {
@@ -63,12 +83,25 @@ func toException(exc rpccapnp.Exception, err error) {
 	}
 
 	exc.SetReason(err.Error())
-	exc.SetType(rpccapnp.Exception_Type_failed)
+	exc.SetType(exceptionType(capnp.ErrorTypeOf(err)))
+}
+
+func exceptionType(t capnp.ErrorType) rpccapnp.Exception_Type {
+	switch t {
+	case capnp.Overloaded:
+		return rpccapnp.Exception_Type_overloaded
+	case capnp.Disconnected:
+		return rpccapnp.Exception_Type_disconnected
+	case capnp.Unimplemented:
+		return rpccapnp.Exception_Type_unimplemented
+	default:
+		return rpccapnp.Exception_Type_failed
+	}
 }
 
 // Errors
 var (
-	ErrConnClosed = errors.New("rpc: connection closed")
+	ErrConnClosed error = &capnp.Error{Type: capnp.Disconnected, Err: errors.New("rpc: connection closed")}
 )
 
 // Internal errors
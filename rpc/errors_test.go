@@ -0,0 +1,93 @@
+package rpc_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+	"zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/rpc"
+	"zombiezen.com/go/capnproto2/rpc/internal/logtransport"
+	"zombiezen.com/go/capnproto2/rpc/internal/pipetransport"
+	"zombiezen.com/go/capnproto2/rpc/internal/testcapnp"
+	"zombiezen.com/go/capnproto2/server"
+)
+
+// errPingPong is a PingPong whose echoNum returns an error or panics,
+// depending on n.
+type errPingPong struct{}
+
+func (errPingPong) EchoNum(call testcapnp.PingPong_echoNum) error {
+	switch call.Params.N() {
+	case 1:
+		return &capnp.Error{Type: capnp.Overloaded, Err: errors.New("too busy")}
+	case 2:
+		return &capnp.Error{Type: capnp.Disconnected, Err: errors.New("gone")}
+	case 3:
+		panic("boom")
+	default:
+		return errors.New("plain")
+	}
+}
+
+func TestExceptionTypes(t *testing.T) {
+	ctx := context.Background()
+	p, q := pipetransport.New()
+	if *logMessages {
+		p = logtransport.New(nil, p)
+	}
+	log := testLogger{t}
+	c := rpc.NewConn(p, rpc.ConnLog(log))
+	srv := testcapnp.PingPong_ServerToClient(errPingPong{})
+	d := rpc.NewConn(q, rpc.MainInterface(srv.Client), rpc.ConnLog(log))
+	defer d.Wait()
+	defer c.Close()
+	client := testcapnp.PingPong{Client: c.Bootstrap(ctx)}
+
+	tests := []struct {
+		n      int32
+		typ    capnp.ErrorType
+		reason string
+	}{
+		{0, capnp.Failed, "plain"},
+		{1, capnp.Overloaded, "too busy"},
+		{2, capnp.Disconnected, "gone"},
+		{3, capnp.Failed, "panic: boom"},
+	}
+	for _, test := range tests {
+		_, err := client.EchoNum(ctx, func(p testcapnp.PingPong_echoNum_Params) error {
+			p.SetN(test.n)
+			return nil
+		}).Struct()
+		if err == nil {
+			t.Errorf("echoNum(%d) succeeded; want error", test.n)
+			continue
+		}
+		if typ := capnp.ErrorTypeOf(err); typ != test.typ {
+			t.Errorf("echoNum(%d) error = %v with type %v; want type %v", test.n, err, typ, test.typ)
+		}
+		if !strings.Contains(err.Error(), test.reason) {
+			t.Errorf("echoNum(%d) error = %v; want it to contain %q", test.n, err, test.reason)
+		}
+	}
+}
+
+func TestExceptionUnimplemented(t *testing.T) {
+	ctx := context.Background()
+	p, q := pipetransport.New()
+	if *logMessages {
+		p = logtransport.New(nil, p)
+	}
+	log := testLogger{t}
+	c := rpc.NewConn(p, rpc.ConnLog(log))
+	d := rpc.NewConn(q, rpc.MainInterface(server.New(nil, nil)), rpc.ConnLog(log))
+	defer d.Wait()
+	defer c.Close()
+	client := testcapnp.PingPong{Client: c.Bootstrap(ctx)}
+
+	_, err := client.EchoNum(ctx, nil).Struct()
+	if !capnp.IsUnimplemented(err) {
+		t.Errorf("echoNum on empty server error = %v; want unimplemented", err)
+	}
+}
@@ -0,0 +1,152 @@
+// +build go1.18
+
+package rpc_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/rpc"
+	"zombiezen.com/go/capnproto2/rpc/internal/pipetransport"
+	rpccapnp "zombiezen.com/go/capnproto2/std/capnp/rpc"
+)
+
+// FuzzConn feeds a stream of framed messages to a Conn over a pipe
+// transport.  The Conn must not panic and must shut down promptly.
+func FuzzConn(f *testing.F) {
+	bootstrap := func(msg rpccapnp.Message) error {
+		b, err := msg.NewBootstrap()
+		if err != nil {
+			return err
+		}
+		b.SetQuestionId(0)
+		return nil
+	}
+	call := func(msg rpccapnp.Message) error {
+		c, err := msg.NewCall()
+		if err != nil {
+			return err
+		}
+		c.SetQuestionId(1)
+		c.SetInterfaceId(interfaceID)
+		c.SetMethodId(methodID)
+		target, err := c.NewTarget()
+		if err != nil {
+			return err
+		}
+		pa, err := target.NewPromisedAnswer()
+		if err != nil {
+			return err
+		}
+		pa.SetQuestionId(0)
+		payload, err := c.NewParams()
+		if err != nil {
+			return err
+		}
+		s, err := capnp.NewStruct(payload.Segment(), capnp.ObjectSize{DataSize: 8})
+		if err != nil {
+			return err
+		}
+		s.SetUint64(0, 42)
+		return payload.SetContentPtr(s.ToPtr())
+	}
+	finish := func(msg rpccapnp.Message) error {
+		fin, err := msg.NewFinish()
+		if err != nil {
+			return err
+		}
+		fin.SetQuestionId(0)
+		return nil
+	}
+	release := func(msg rpccapnp.Message) error {
+		rel, err := msg.NewRelease()
+		if err != nil {
+			return err
+		}
+		rel.SetId(0)
+		rel.SetReferenceCount(1)
+		return nil
+	}
+	f.Add(framedMessages(f, bootstrap))
+	f.Add(framedMessages(f, bootstrap, call, finish))
+	f.Add(framedMessages(f, bootstrap, call, finish, release))
+	f.Add(framedMessages(f, call, release))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		p, q := pipetransport.New()
+		defer q.Close()
+		main := stubClient(func(ctx context.Context, params capnp.Struct) (capnp.Struct, error) {
+			_, s, err := capnp.NewMessage(capnp.SingleSegment(nil))
+			if err != nil {
+				return capnp.Struct{}, err
+			}
+			return capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 8})
+		})
+		conn := rpc.NewConn(p, rpc.MainInterface(main), rpc.ConnLog(nil))
+		go func() {
+			for {
+				if _, err := q.RecvMessage(ctx); err != nil {
+					return
+				}
+			}
+		}()
+
+		dec := capnp.NewDecoder(bytes.NewReader(data))
+		dec.MaxMessageSize = 1 << 16
+		for {
+			m, err := dec.Decode()
+			if err != nil {
+				break
+			}
+			msg, err := rpccapnp.ReadRootMessage(m)
+			if err != nil || !msg.IsValid() {
+				// The pipe transport can't send a null message.
+				continue
+			}
+			sendCtx, cancelSend := context.WithTimeout(ctx, 100*time.Millisecond)
+			err = q.SendMessage(sendCtx, msg)
+			cancelSend()
+			if err != nil {
+				break
+			}
+		}
+
+		done := make(chan struct{})
+		go func() {
+			conn.Close()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Conn.Close did not return")
+		}
+	})
+}
+
+func framedMessages(f *testing.F, fns ...func(rpccapnp.Message) error) []byte {
+	var buf bytes.Buffer
+	enc := capnp.NewEncoder(&buf)
+	for _, fn := range fns {
+		msg, s, err := capnp.NewMessage(capnp.SingleSegment(nil))
+		if err != nil {
+			f.Fatal(err)
+		}
+		m, err := rpccapnp.NewRootMessage(s)
+		if err != nil {
+			f.Fatal(err)
+		}
+		if err := fn(m); err != nil {
+			f.Fatal(err)
+		}
+		if err := enc.Encode(msg); err != nil {
+			f.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
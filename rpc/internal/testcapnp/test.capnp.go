@@ -18,9 +18,9 @@ const Handle_TypeID = 0x8161ddf3e74bd0d1
 type Handle_Server interface {
 }
 
-func Handle_ServerToClient(s Handle_Server) Handle {
+func Handle_ServerToClient(s Handle_Server, opts ...server.Option) Handle {
 	c, _ := s.(server.Closer)
-	return Handle{Client: server.New(Handle_Methods(nil, s), c)}
+	return Handle{Client: server.New(Handle_Methods(nil, s), c, opts...)}
 }
 
 func Handle_Methods(methods []server.Method, s Handle_Server) []server.Method {
@@ -61,9 +61,9 @@ type HandleFactory_Server interface {
 	NewHandle(HandleFactory_newHandle) error
 }
 
-func HandleFactory_ServerToClient(s HandleFactory_Server) HandleFactory {
+func HandleFactory_ServerToClient(s HandleFactory_Server, opts ...server.Option) HandleFactory {
 	c, _ := s.(server.Closer)
-	return HandleFactory{Client: server.New(HandleFactory_Methods(nil, s), c)}
+	return HandleFactory{Client: server.New(HandleFactory_Methods(nil, s), c, opts...)}
 }
 
 func HandleFactory_Methods(methods []server.Method, s HandleFactory_Server) []server.Method {
@@ -146,6 +146,22 @@ func (p HandleFactory_newHandle_Params_Promise) Struct() (HandleFactory_newHandl
 	return HandleFactory_newHandle_Params{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p HandleFactory_newHandle_Params_Promise) Await(ctx context.Context) (HandleFactory_newHandle_Params, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return HandleFactory_newHandle_Params{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p HandleFactory_newHandle_Params_Promise) Then(f func(HandleFactory_newHandle_Params, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(HandleFactory_newHandle_Params{s}, err)
+	})
+}
+
 type HandleFactory_newHandle_Results struct{ capnp.Struct }
 
 // HandleFactory_newHandle_Results_TypeID is the unique identifier for the type HandleFactory_newHandle_Results.
@@ -215,6 +231,22 @@ func (p HandleFactory_newHandle_Results_Promise) Struct() (HandleFactory_newHand
 	return HandleFactory_newHandle_Results{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p HandleFactory_newHandle_Results_Promise) Await(ctx context.Context) (HandleFactory_newHandle_Results, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return HandleFactory_newHandle_Results{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p HandleFactory_newHandle_Results_Promise) Then(f func(HandleFactory_newHandle_Results, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(HandleFactory_newHandle_Results{s}, err)
+	})
+}
+
 func (p HandleFactory_newHandle_Results_Promise) Handle() Handle {
 	return Handle{Client: p.Pipeline.GetPipeline(0).Client()}
 }
@@ -249,9 +281,9 @@ type Hanger_Server interface {
 	Hang(Hanger_hang) error
 }
 
-func Hanger_ServerToClient(s Hanger_Server) Hanger {
+func Hanger_ServerToClient(s Hanger_Server, opts ...server.Option) Hanger {
 	c, _ := s.(server.Closer)
-	return Hanger{Client: server.New(Hanger_Methods(nil, s), c)}
+	return Hanger{Client: server.New(Hanger_Methods(nil, s), c, opts...)}
 }
 
 func Hanger_Methods(methods []server.Method, s Hanger_Server) []server.Method {
@@ -334,6 +366,22 @@ func (p Hanger_hang_Params_Promise) Struct() (Hanger_hang_Params, error) {
 	return Hanger_hang_Params{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Hanger_hang_Params_Promise) Await(ctx context.Context) (Hanger_hang_Params, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Hanger_hang_Params{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Hanger_hang_Params_Promise) Then(f func(Hanger_hang_Params, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Hanger_hang_Params{s}, err)
+	})
+}
+
 type Hanger_hang_Results struct{ capnp.Struct }
 
 // Hanger_hang_Results_TypeID is the unique identifier for the type Hanger_hang_Results.
@@ -384,6 +432,22 @@ func (p Hanger_hang_Results_Promise) Struct() (Hanger_hang_Results, error) {
 	return Hanger_hang_Results{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Hanger_hang_Results_Promise) Await(ctx context.Context) (Hanger_hang_Results, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Hanger_hang_Results{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Hanger_hang_Results_Promise) Then(f func(Hanger_hang_Results, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Hanger_hang_Results{s}, err)
+	})
+}
+
 type CallOrder struct{ Client capnp.Client }
 
 // CallOrder_TypeID is the unique identifier for the type CallOrder.
@@ -414,9 +478,9 @@ type CallOrder_Server interface {
 	GetCallSequence(CallOrder_getCallSequence) error
 }
 
-func CallOrder_ServerToClient(s CallOrder_Server) CallOrder {
+func CallOrder_ServerToClient(s CallOrder_Server, opts ...server.Option) CallOrder {
 	c, _ := s.(server.Closer)
-	return CallOrder{Client: server.New(CallOrder_Methods(nil, s), c)}
+	return CallOrder{Client: server.New(CallOrder_Methods(nil, s), c, opts...)}
 }
 
 func CallOrder_Methods(methods []server.Method, s CallOrder_Server) []server.Method {
@@ -507,6 +571,22 @@ func (p CallOrder_getCallSequence_Params_Promise) Struct() (CallOrder_getCallSeq
 	return CallOrder_getCallSequence_Params{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p CallOrder_getCallSequence_Params_Promise) Await(ctx context.Context) (CallOrder_getCallSequence_Params, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return CallOrder_getCallSequence_Params{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p CallOrder_getCallSequence_Params_Promise) Then(f func(CallOrder_getCallSequence_Params, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(CallOrder_getCallSequence_Params{s}, err)
+	})
+}
+
 type CallOrder_getCallSequence_Results struct{ capnp.Struct }
 
 // CallOrder_getCallSequence_Results_TypeID is the unique identifier for the type CallOrder_getCallSequence_Results.
@@ -565,6 +645,22 @@ func (p CallOrder_getCallSequence_Results_Promise) Struct() (CallOrder_getCallSe
 	return CallOrder_getCallSequence_Results{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p CallOrder_getCallSequence_Results_Promise) Await(ctx context.Context) (CallOrder_getCallSequence_Results, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return CallOrder_getCallSequence_Results{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p CallOrder_getCallSequence_Results_Promise) Then(f func(CallOrder_getCallSequence_Results, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(CallOrder_getCallSequence_Results{s}, err)
+	})
+}
+
 type Echoer struct{ Client capnp.Client }
 
 // Echoer_TypeID is the unique identifier for the type Echoer.
@@ -617,9 +713,9 @@ type Echoer_Server interface {
 	GetCallSequence(CallOrder_getCallSequence) error
 }
 
-func Echoer_ServerToClient(s Echoer_Server) Echoer {
+func Echoer_ServerToClient(s Echoer_Server, opts ...server.Option) Echoer {
 	c, _ := s.(server.Closer)
-	return Echoer{Client: server.New(Echoer_Methods(nil, s), c)}
+	return Echoer{Client: server.New(Echoer_Methods(nil, s), c, opts...)}
 }
 
 func Echoer_Methods(methods []server.Method, s Echoer_Server) []server.Method {
@@ -735,6 +831,22 @@ func (p Echoer_echo_Params_Promise) Struct() (Echoer_echo_Params, error) {
 	return Echoer_echo_Params{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Echoer_echo_Params_Promise) Await(ctx context.Context) (Echoer_echo_Params, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Echoer_echo_Params{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Echoer_echo_Params_Promise) Then(f func(Echoer_echo_Params, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Echoer_echo_Params{s}, err)
+	})
+}
+
 func (p Echoer_echo_Params_Promise) Cap() CallOrder {
 	return CallOrder{Client: p.Pipeline.GetPipeline(0).Client()}
 }
@@ -808,6 +920,22 @@ func (p Echoer_echo_Results_Promise) Struct() (Echoer_echo_Results, error) {
 	return Echoer_echo_Results{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Echoer_echo_Results_Promise) Await(ctx context.Context) (Echoer_echo_Results, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Echoer_echo_Results{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Echoer_echo_Results_Promise) Then(f func(Echoer_echo_Results, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Echoer_echo_Results{s}, err)
+	})
+}
+
 func (p Echoer_echo_Results_Promise) Cap() CallOrder {
 	return CallOrder{Client: p.Pipeline.GetPipeline(0).Client()}
 }
@@ -842,9 +970,9 @@ type PingPong_Server interface {
 	EchoNum(PingPong_echoNum) error
 }
 
-func PingPong_ServerToClient(s PingPong_Server) PingPong {
+func PingPong_ServerToClient(s PingPong_Server, opts ...server.Option) PingPong {
 	c, _ := s.(server.Closer)
-	return PingPong{Client: server.New(PingPong_Methods(nil, s), c)}
+	return PingPong{Client: server.New(PingPong_Methods(nil, s), c, opts...)}
 }
 
 func PingPong_Methods(methods []server.Method, s PingPong_Server) []server.Method {
@@ -935,6 +1063,22 @@ func (p PingPong_echoNum_Params_Promise) Struct() (PingPong_echoNum_Params, erro
 	return PingPong_echoNum_Params{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p PingPong_echoNum_Params_Promise) Await(ctx context.Context) (PingPong_echoNum_Params, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return PingPong_echoNum_Params{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p PingPong_echoNum_Params_Promise) Then(f func(PingPong_echoNum_Params, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(PingPong_echoNum_Params{s}, err)
+	})
+}
+
 type PingPong_echoNum_Results struct{ capnp.Struct }
 
 // PingPong_echoNum_Results_TypeID is the unique identifier for the type PingPong_echoNum_Results.
@@ -993,6 +1137,22 @@ func (p PingPong_echoNum_Results_Promise) Struct() (PingPong_echoNum_Results, er
 	return PingPong_echoNum_Results{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p PingPong_echoNum_Results_Promise) Await(ctx context.Context) (PingPong_echoNum_Results, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return PingPong_echoNum_Results{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p PingPong_echoNum_Results_Promise) Then(f func(PingPong_echoNum_Results, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(PingPong_echoNum_Results{s}, err)
+	})
+}
+
 type Adder struct{ Client capnp.Client }
 
 // Adder_TypeID is the unique identifier for the type Adder.
@@ -1023,9 +1183,9 @@ type Adder_Server interface {
 	Add(Adder_add) error
 }
 
-func Adder_ServerToClient(s Adder_Server) Adder {
+func Adder_ServerToClient(s Adder_Server, opts ...server.Option) Adder {
 	c, _ := s.(server.Closer)
-	return Adder{Client: server.New(Adder_Methods(nil, s), c)}
+	return Adder{Client: server.New(Adder_Methods(nil, s), c, opts...)}
 }
 
 func Adder_Methods(methods []server.Method, s Adder_Server) []server.Method {
@@ -1122,6 +1282,22 @@ func (p Adder_add_Params_Promise) Struct() (Adder_add_Params, error) {
 	return Adder_add_Params{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Adder_add_Params_Promise) Await(ctx context.Context) (Adder_add_Params, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Adder_add_Params{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Adder_add_Params_Promise) Then(f func(Adder_add_Params, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Adder_add_Params{s}, err)
+	})
+}
+
 type Adder_add_Results struct{ capnp.Struct }
 
 // Adder_add_Results_TypeID is the unique identifier for the type Adder_add_Results.
@@ -1180,6 +1356,22 @@ func (p Adder_add_Results_Promise) Struct() (Adder_add_Results, error) {
 	return Adder_add_Results{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Adder_add_Results_Promise) Await(ctx context.Context) (Adder_add_Results, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Adder_add_Results{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Adder_add_Results_Promise) Then(f func(Adder_add_Results, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Adder_add_Results{s}, err)
+	})
+}
+
 const schema_ef12a34b9807e19c = "x\xda\x9cU_h\x1c\xd5\x17>g\xee\xbd\xbfIH" +
 	"\x7f\x84\x9b\x1b\x8d\xb5Bi\x88\xda\x16\xb3Tj\x1f\x1a" +
 	"\xb0\x89\xda\xed\x8a\xd5\xba\x93\x12\xf1?Lw.\x9b\x96" +
@@ -1,9 +1,12 @@
 package rpc_test
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"golang.org/x/net/context"
+	"zombiezen.com/go/capnproto2"
 	"zombiezen.com/go/capnproto2/rpc"
 	"zombiezen.com/go/capnproto2/rpc/internal/logtransport"
 	"zombiezen.com/go/capnproto2/rpc/internal/pipetransport"
@@ -58,3 +61,68 @@ func (de *DelayEchoer) Echo(call testcapnp.Echoer_echo) error {
 	<-de.delay
 	return de.Echoer.Echo(call)
 }
+
+func TestPromiseAwait(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p, q := pipetransport.New()
+	if *logMessages {
+		p = logtransport.New(nil, p)
+	}
+	log := testLogger{t}
+	c := rpc.NewConn(p, rpc.ConnLog(log))
+	delay := make(chan struct{})
+	echoSrv := testcapnp.Echoer_ServerToClient(&DelayEchoer{delay: delay})
+	d := rpc.NewConn(q, rpc.MainInterface(echoSrv.Client), rpc.ConnLog(log))
+	defer d.Wait()
+	defer c.Close()
+	client := testcapnp.Echoer{Client: c.Bootstrap(ctx)}
+
+	echo := client.Echo(ctx, func(p testcapnp.Echoer_echo_Params) error {
+		return p.SetCap(testcapnp.CallOrder{Client: client.Client})
+	})
+	waitCtx, waitCancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	_, err := echo.Await(waitCtx)
+	waitCancel()
+	if err != context.DeadlineExceeded {
+		t.Errorf("echo.Await before return = %v; want %v", err, context.DeadlineExceeded)
+	}
+
+	if a := capnp.PeekAnswer(echo.Answer()); a != nil {
+		t.Errorf("PeekAnswer(echo) before return = %v; want nil", a)
+	}
+
+	results := make(chan error, 1)
+	echo.Then(func(r testcapnp.Echoer_echo_Results, err error) {
+		if err == nil && !r.HasCap() {
+			err = errors.New("no capability in results")
+		}
+		results <- err
+	})
+	close(delay)
+	select {
+	case <-echo.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("echo.Done not closed after return")
+	}
+	if _, err := echo.Await(ctx); err != nil {
+		t.Error("echo.Await after return:", err)
+	}
+	if a := capnp.PeekAnswer(echo.Answer()); a == nil {
+		t.Error("PeekAnswer(echo) after return = nil")
+	}
+	if err := <-results; err != nil {
+		t.Error("echo.Then:", err)
+	}
+	echo.Then(func(_ testcapnp.Echoer_echo_Results, err error) {
+		results <- err
+	})
+	select {
+	case err := <-results:
+		if err != nil {
+			t.Error("echo.Then after return:", err)
+		}
+	default:
+		t.Error("echo.Then after return did not call f")
+	}
+}
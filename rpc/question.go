@@ -58,10 +58,12 @@ type question struct {
 	derived [][]capnp.PipelineOp
 
 	// Fields below are protected by mu.
-	mu    sync.RWMutex
-	obj   capnp.Ptr
-	err   error
-	state questionState
+	mu        sync.RWMutex
+	obj       capnp.Ptr
+	err       error
+	state     questionState
+	callbacks []func()
+	notified  bool // callbacks have been called
 }
 
 type questionState uint8
@@ -73,9 +75,13 @@ const (
 	questionCanceled
 )
 
-// start signals that the question has been sent.
+// start signals that the question has been sent.  It starts a
+// goroutine that cancels the question if its context is done and calls
+// the OnResolve callbacks once the question is resolved or the
+// connection is closed.
 func (q *question) start() {
 	go func() {
+		defer q.notify()
 		select {
 		case <-q.resolved:
 			// Resolved naturally, nothing to do.
@@ -221,6 +227,48 @@ func (q *question) Struct() (capnp.Struct, error) {
 	return s, err
 }
 
+// Done returns a channel that is closed once q is resolved.
+func (q *question) Done() <-chan struct{} {
+	return q.resolved
+}
+
+// Peek returns q's resolved answer or nil if q has not been resolved.
+func (q *question) Peek() capnp.Answer {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.state == questionInProgress {
+		return nil
+	}
+	if q.err != nil {
+		return capnp.ErrorAnswer(q.err)
+	}
+	return capnp.ImmediateAnswer(q.obj.Struct())
+}
+
+// OnResolve arranges for f to be called once q is resolved or the
+// connection is closed.  f is called from q's start goroutine.
+func (q *question) OnResolve(f func()) {
+	q.mu.Lock()
+	if q.notified {
+		q.mu.Unlock()
+		f()
+		return
+	}
+	q.callbacks = append(q.callbacks, f)
+	q.mu.Unlock()
+}
+
+// notify calls the callbacks registered with OnResolve.
+func (q *question) notify() {
+	q.mu.Lock()
+	callbacks := q.callbacks
+	q.callbacks, q.notified = nil, true
+	q.mu.Unlock()
+	for _, f := range callbacks {
+		f()
+	}
+}
+
 func (q *question) PipelineCall(transform []capnp.PipelineOp, ccall *capnp.Call) capnp.Answer {
 	select {
 	case <-q.conn.mu:
@@ -273,14 +321,9 @@ func (q *question) lockedPipelineCall(transform []capnp.PipelineOp, ccall *capnp
 		return capnp.ErrorAnswer(err)
 	}
 
-	select {
-	case q.conn.out <- msg:
-	case <-ccall.Ctx.Done():
-		q.conn.popQuestion(pipeq.id)
-		return capnp.ErrorAnswer(ccall.Ctx.Err())
-	case <-q.conn.bg.Done():
+	if err := q.conn.sendCall(ccall.Ctx, pipeq.id, msg); err != nil {
 		q.conn.popQuestion(pipeq.id)
-		return capnp.ErrorAnswer(ErrConnClosed)
+		return capnp.ErrorAnswer(err)
 	}
 	q.addPromise(transform)
 	pipeq.start()
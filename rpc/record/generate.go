@@ -0,0 +1,3 @@
+package record
+
+//go:generate capnp compile -I ../../std -ogo record.capnp
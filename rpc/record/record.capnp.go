@@ -0,0 +1,197 @@
+// Code generated by capnpc-go. DO NOT EDIT.
+
+package record
+
+import (
+	context "golang.org/x/net/context"
+	capnp "zombiezen.com/go/capnproto2"
+	text "zombiezen.com/go/capnproto2/encoding/text"
+	schemas "zombiezen.com/go/capnproto2/schemas"
+	rpc "zombiezen.com/go/capnproto2/std/capnp/rpc"
+)
+
+type Entry struct{ capnp.Struct }
+
+// Entry_TypeID is the unique identifier for the type Entry.
+const Entry_TypeID = 0x8913965bb4777a81
+
+func NewEntry(s *capnp.Segment) (Entry, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 1})
+	return Entry{st}, err
+}
+
+func NewRootEntry(s *capnp.Segment) (Entry, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 1})
+	return Entry{st}, err
+}
+
+func ReadRootEntry(msg *capnp.Message) (Entry, error) {
+	root, err := msg.RootPtr()
+	return Entry{root.Struct()}, err
+}
+
+func (s Entry) String() string {
+	str, _ := text.Marshal(0x8913965bb4777a81, s.Struct)
+	return str
+}
+
+func (s Entry) Time() int64 {
+	return int64(s.Struct.Uint64(0))
+}
+
+func (s Entry) SetTime(v int64) {
+	s.Struct.SetUint64(0, uint64(v))
+}
+
+func (s Entry) Direction() Direction {
+	return Direction(s.Struct.Uint16(8))
+}
+
+func (s Entry) SetDirection(v Direction) {
+	s.Struct.SetUint16(8, uint16(v))
+}
+
+func (s Entry) Message() (rpc.Message, error) {
+	p, err := s.Struct.Ptr(0)
+	return rpc.Message{Struct: p.Struct()}, err
+}
+
+func (s Entry) HasMessage() bool {
+	p, err := s.Struct.Ptr(0)
+	return p.IsValid() || err != nil
+}
+
+func (s Entry) SetMessage(v rpc.Message) error {
+	return s.Struct.SetPtr(0, v.Struct.ToPtr())
+}
+
+// NewMessage sets the message field to a newly
+// allocated rpc.Message struct, preferring placement in s's segment.
+func (s Entry) NewMessage() (rpc.Message, error) {
+	ss, err := rpc.NewMessage(s.Struct.Segment())
+	if err != nil {
+		return rpc.Message{}, err
+	}
+	err = s.Struct.SetPtr(0, ss.Struct.ToPtr())
+	return ss, err
+}
+
+// Entry_List is a list of Entry.
+type Entry_List struct{ capnp.List }
+
+// NewEntry creates a new list of Entry.
+func NewEntry_List(s *capnp.Segment, sz int32) (Entry_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 16, PointerCount: 1}, sz)
+	return Entry_List{l}, err
+}
+
+func (s Entry_List) At(i int) Entry { return Entry{s.List.Struct(i)} }
+
+func (s Entry_List) Set(i int, v Entry) error { return s.List.SetStruct(i, v.Struct) }
+
+// Entry_Promise is a wrapper for a Entry promised by a client call.
+type Entry_Promise struct{ *capnp.Pipeline }
+
+func (p Entry_Promise) Struct() (Entry, error) {
+	s, err := p.Pipeline.Struct()
+	return Entry{s}, err
+}
+
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Entry_Promise) Await(ctx context.Context) (Entry, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Entry{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Entry_Promise) Then(f func(Entry, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Entry{s}, err)
+	})
+}
+
+func (p Entry_Promise) Message() rpc.Message_Promise {
+	return rpc.Message_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
+}
+
+type Direction uint16
+
+// Direction_TypeID is the unique identifier for the type Direction.
+const Direction_TypeID = 0x89f3811269fca506
+
+// Values of Direction.
+const (
+	Direction_send Direction = 0
+	Direction_recv Direction = 1
+)
+
+// String returns the enum's constant name.
+func (c Direction) String() string {
+	switch c {
+	case Direction_send:
+		return "send"
+	case Direction_recv:
+		return "recv"
+
+	default:
+		return ""
+	}
+}
+
+// DirectionFromString returns the enum value with a name,
+// or the zero value if there's no such value.
+func DirectionFromString(c string) Direction {
+	switch c {
+	case "send":
+		return Direction_send
+	case "recv":
+		return Direction_recv
+
+	default:
+		return 0
+	}
+}
+
+type Direction_List struct{ capnp.List }
+
+func NewDirection_List(s *capnp.Segment, sz int32) (Direction_List, error) {
+	l, err := capnp.NewUInt16List(s, sz)
+	return Direction_List{l.List}, err
+}
+
+func (l Direction_List) At(i int) Direction {
+	ul := capnp.UInt16List{List: l.List}
+	return Direction(ul.At(i))
+}
+
+func (l Direction_List) Set(i int, v Direction) {
+	ul := capnp.UInt16List{List: l.List}
+	ul.Set(i, uint16(v))
+}
+
+const schema_83118d8de82bcb86 = "x\xda\\\x8e?K\xf3P\x18\xc5\xcf\xb9\xc9\xed\xfb*" +
+	"\x96\xf4\x9a\xee\xee\x11D\x05\x11\\,bA\xa1\xcb\x15" +
+	"A\xc1\xa9$\x17\xc9\xd0\xb4\xdc\x04\xff-Z\x04\xa1`" +
+	"\x06\x17\x07\x17'G\xd1A\x10\xfc\x0a~\x11wW\xe1" +
+	"J\xb2(.\xcf\xf0\x9c\x1f\xe7\xfc\x16\xaf\xd9\x11Kr" +
+	"o\x1a\xd0'\xb2\xe1\xc6g\xc7/\x07\xb7\xe1\x04\xbaI" +
+	"\xe1\xae\xde\xe7?\xcaR]B\xf2\x1f\x10*y\x07\x86" +
+	"J>\x81\xae\xf1\xf0\x95\xce\x8e?'P\xcd_\x1c\x18" +
+	">\xca\xb7\xfa\xae\xc2a\xc5Y\x13\x0fm\xb2\x10\xb3?" +
+	"\xcaFk\xdd\xac\xf0\xec\xa9\x9e\xf1|\xc0'\xa0\xba\x11" +
+	"\xa0;\x1euOP\xd1o\xb3zn\xef\x00z\xcb\xa3" +
+	"\xde\x15\xa4hS\x00Jo\x00\xba\xe7Q\xef\x0b\x06E" +
+	":0\x94\x10\x94\xa0KRk\xe2\"\x1d\x82\x19\x83\x1f" +
+	"-\x90\x01x>0y\xde?4l\xb9\xe5\xe7\xf2b" +
+	"\xee\xfe\xf5\xa6JZ\xe0\x1f\xb3\xcd\xd4\xae\xd75\x99\xfe" +
+	"_\x0f\xaa\xa8\x02\xd5T\x04\x04\xb9\xc9\x92\xc0\x9a\xf8\xe8" +
+	"{\x00\xa0sM\x16"
+
+func init() {
+	schemas.Register(schema_83118d8de82bcb86,
+		0x8913965bb4777a81,
+		0x89f3811269fca506)
+}
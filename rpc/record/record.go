@@ -0,0 +1,462 @@
+// Package record provides an rpc.Transport that records the messages
+// it sends and receives, and a transport that plays a recording back,
+// so that a conversation seen in production can be reproduced in a
+// test.
+//
+// A recording is a stream of Entry messages in the standard Cap'n
+// Proto stream framing, so it can also be inspected with capnp-go:
+//
+//	capnp-go decode -type Entry recording.bin
+package record
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/rpc"
+	rpccapnp "zombiezen.com/go/capnproto2/std/capnp/rpc"
+)
+
+// A Recorder is an rpc.Transport that writes every message it sends or
+// receives to a recording.  It is safe to send and receive at the same
+// time, as a Conn does.
+type Recorder struct {
+	t   rpc.Transport
+	now func() time.Time
+
+	mu  sync.Mutex
+	enc *capnp.Encoder
+}
+
+// NewRecorder returns a transport that sends and receives messages on
+// t and writes each one to w.  Closing the recorder closes t, but not
+// w.
+func NewRecorder(t rpc.Transport, w io.Writer) *Recorder {
+	return &Recorder{t: t, now: time.Now, enc: capnp.NewEncoder(w)}
+}
+
+// SendMessage records msg and then sends it on the underlying
+// transport.  msg must be the root of its message, as it is for every
+// message that a Conn sends.
+func (r *Recorder) SendMessage(ctx context.Context, msg rpccapnp.Message) error {
+	if err := r.record(Direction_send, msg); err != nil {
+		return err
+	}
+	return r.t.SendMessage(ctx, msg)
+}
+
+// RecvMessage receives a message from the underlying transport and
+// records it.
+func (r *Recorder) RecvMessage(ctx context.Context) (rpccapnp.Message, error) {
+	msg, err := r.t.RecvMessage(ctx)
+	if err != nil {
+		return msg, err
+	}
+	if err := r.record(Direction_recv, msg); err != nil {
+		return rpccapnp.Message{}, err
+	}
+	return msg, nil
+}
+
+// Close closes the underlying transport.
+func (r *Recorder) Close() error {
+	return r.t.Close()
+}
+
+func (r *Recorder) record(dir Direction, msg rpccapnp.Message) error {
+	m, err := newEntryMessage(r.now(), dir, msg)
+	if err != nil {
+		return fmt.Errorf("record: %v", err)
+	}
+	r.mu.Lock()
+	err = r.enc.Encode(m)
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("record: %v", err)
+	}
+	return nil
+}
+
+// newEntryMessage builds an entry for msg in a copy of msg's segments.
+// Copying the RPC message struct into a new message instead would
+// renumber its capability pointers, which must keep matching the
+// payload's capability table.
+func newEntryMessage(t time.Time, dir Direction, msg rpccapnp.Message) (*capnp.Message, error) {
+	m, err := copySegments(msg.Segment().Message())
+	if err != nil {
+		return nil, err
+	}
+	root, err := m.RootPtr()
+	if err != nil {
+		return nil, err
+	}
+	seg, err := m.Segment(0)
+	if err != nil {
+		return nil, err
+	}
+	e, err := NewRootEntry(seg)
+	if err != nil {
+		return nil, err
+	}
+	e.SetTime(t.UnixNano())
+	e.SetDirection(dir)
+	if err := e.SetMessage(rpccapnp.Message{Struct: root.Struct()}); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// rootMessage returns a copy of the entry's message as the root of a
+// new message, as a Conn expects of the messages it receives.
+func rootMessage(e Entry) (rpccapnp.Message, error) {
+	m, err := copySegments(e.Segment().Message())
+	if err != nil {
+		return rpccapnp.Message{}, err
+	}
+	root, err := m.RootPtr()
+	if err != nil {
+		return rpccapnp.Message{}, err
+	}
+	msg, err := Entry{root.Struct()}.Message()
+	if err != nil {
+		return rpccapnp.Message{}, err
+	}
+	if err := m.SetRootPtr(msg.ToPtr()); err != nil {
+		return rpccapnp.Message{}, err
+	}
+	return msg, nil
+}
+
+func copySegments(msg *capnp.Message) (*capnp.Message, error) {
+	segs := make([][]byte, msg.NumSegments())
+	for i := range segs {
+		s, err := msg.Segment(capnp.SegmentID(i))
+		if err != nil {
+			return nil, err
+		}
+		segs[i] = append([]byte(nil), s.Data()...)
+	}
+	return &capnp.Message{Arena: capnp.MultiSegment(segs)}, nil
+}
+
+// A Reader reads entries from a recording.
+type Reader struct {
+	dec *capnp.Decoder
+}
+
+// NewReader returns a reader that reads a recording from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{dec: capnp.NewDecoder(r)}
+}
+
+// Next reads the next entry.  It returns io.EOF at the end of the
+// recording.
+func (r *Reader) Next() (Entry, error) {
+	msg, err := r.dec.Decode()
+	if err != nil {
+		return Entry{}, err
+	}
+	return ReadRootEntry(msg)
+}
+
+// ReadAll reads every entry in the recording from r.
+func ReadAll(r io.Reader) ([]Entry, error) {
+	rd := NewReader(r)
+	var entries []Entry
+	for {
+		e, err := rd.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, e)
+	}
+}
+
+var errClosed = errors.New("record: replayer closed")
+
+// A Replayer is an rpc.Transport that plays back the messages that a
+// recorded transport received, so that a Conn using it goes through the
+// same conversation as the recorded one.  Messages are delivered as
+// fast as the Conn reads them, except that a message is held back until
+// the Conn has sent every Return that was recorded before it.
+//
+// The Replayer also compares each Return that the Conn sends with the
+// recorded Return for the same question, so a test can check that a
+// server still answers the recorded calls the same way.  Err reports
+// the first difference.
+type Replayer struct {
+	recv []replayMessage
+	rets []rpccapnp.Return // recorded Returns, in order
+
+	mu       sync.Mutex
+	next     int              // index into recv
+	pending  map[uint32][]int // answer ID -> unmatched indices into rets
+	matched  []bool           // indexed like rets
+	nmatched int              // length of the matched prefix of rets
+	changed  chan struct{}    // closed and replaced on every change
+	done     chan struct{}
+	err      error
+	closed   bool
+}
+
+type replayMessage struct {
+	msg   rpccapnp.Message
+	after int // number of recorded Returns that must be matched first
+}
+
+// NewReplayer returns a transport that replays entries, usually read
+// from a recording with ReadAll.
+func NewReplayer(entries []Entry) (*Replayer, error) {
+	r := &Replayer{
+		pending: make(map[uint32][]int),
+		changed: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for i, e := range entries {
+		switch e.Direction() {
+		case Direction_recv:
+			msg, err := rootMessage(e)
+			if err != nil {
+				return nil, fmt.Errorf("record: entry %d: %v", i, err)
+			}
+			r.recv = append(r.recv, replayMessage{msg: msg, after: len(r.rets)})
+		case Direction_send:
+			msg, err := e.Message()
+			if err != nil {
+				return nil, fmt.Errorf("record: entry %d: %v", i, err)
+			}
+			if msg.Which() != rpccapnp.Message_Which_return {
+				continue
+			}
+			ret, err := msg.Return()
+			if err != nil {
+				return nil, fmt.Errorf("record: entry %d: %v", i, err)
+			}
+			id := ret.AnswerId()
+			r.pending[id] = append(r.pending[id], len(r.rets))
+			r.rets = append(r.rets, ret)
+		}
+	}
+	r.matched = make([]bool, len(r.rets))
+	r.checkDone()
+	return r, nil
+}
+
+// SendMessage checks msg against the recording if it is a Return.
+// Other messages are discarded.
+func (r *Replayer) SendMessage(ctx context.Context, msg rpccapnp.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return errClosed
+	}
+	if msg.Which() != rpccapnp.Message_Which_return {
+		return nil
+	}
+	ret, err := msg.Return()
+	if err != nil {
+		return err
+	}
+	id := ret.AnswerId()
+	q := r.pending[id]
+	if len(q) == 0 {
+		r.fail(fmt.Errorf("record: unexpected return for question %d", id))
+		return nil
+	}
+	i := q[0]
+	if len(q) == 1 {
+		delete(r.pending, id)
+	} else {
+		r.pending[id] = q[1:]
+	}
+	if err := compareReturns(ret, r.rets[i]); err != nil {
+		r.fail(fmt.Errorf("record: return for question %d: %v", id, err))
+	}
+	r.matched[i] = true
+	for r.nmatched < len(r.matched) && r.matched[r.nmatched] {
+		r.nmatched++
+	}
+	r.notify()
+	return nil
+}
+
+// RecvMessage returns the next recorded message that the transport
+// received.  After the last one, it blocks until ctx is done or the
+// replayer is closed.
+func (r *Replayer) RecvMessage(ctx context.Context) (rpccapnp.Message, error) {
+	r.mu.Lock()
+	for {
+		if r.closed {
+			r.mu.Unlock()
+			return rpccapnp.Message{}, errClosed
+		}
+		if r.next < len(r.recv) && r.recv[r.next].after <= r.nmatched {
+			msg := r.recv[r.next].msg
+			r.next++
+			r.notify()
+			r.mu.Unlock()
+			return msg, nil
+		}
+		changed := r.changed
+		r.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return rpccapnp.Message{}, ctx.Err()
+		}
+		r.mu.Lock()
+	}
+}
+
+// Close stops the replay.  Pending and future calls to RecvMessage
+// return an error.
+func (r *Replayer) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return errClosed
+	}
+	r.closed = true
+	r.notify()
+	return nil
+}
+
+// Done returns a channel that is closed once every recorded message has
+// been delivered and every recorded Return has been sent.
+func (r *Replayer) Done() <-chan struct{} {
+	return r.done
+}
+
+// Err returns the first difference found between the Returns that the
+// Conn sent and the recorded ones, or nil if there has been none.
+func (r *Replayer) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Replayer) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// notify wakes up RecvMessage.  The caller must be holding r.mu.
+func (r *Replayer) notify() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+	r.checkDone()
+}
+
+// checkDone closes r.done if the replay is finished.  The caller must
+// be holding r.mu.
+func (r *Replayer) checkDone() {
+	if r.next < len(r.recv) || r.nmatched < len(r.rets) {
+		return
+	}
+	select {
+	case <-r.done:
+	default:
+		close(r.done)
+	}
+}
+
+// compareReturns reports how a differs from the recorded Return b.
+// Results are compared by their content and the kinds of capabilities
+// in their tables, since capability IDs are chosen by the Conn.
+func compareReturns(a, b rpccapnp.Return) error {
+	if a.Which() != b.Which() {
+		return fmt.Errorf("got %v; recorded %v", a.Which(), b.Which())
+	}
+	switch a.Which() {
+	case rpccapnp.Return_Which_results:
+		ap, err := a.Results()
+		if err != nil {
+			return err
+		}
+		bp, err := b.Results()
+		if err != nil {
+			return err
+		}
+		return comparePayloads(ap, bp)
+	case rpccapnp.Return_Which_exception:
+		ae, err := a.Exception()
+		if err != nil {
+			return err
+		}
+		be, err := b.Exception()
+		if err != nil {
+			return err
+		}
+		ar, _ := ae.Reason()
+		br, _ := be.Reason()
+		if ae.Type() != be.Type() || ar != br {
+			return fmt.Errorf("got %v exception %q; recorded %v exception %q", ae.Type(), ar, be.Type(), br)
+		}
+	case rpccapnp.Return_Which_takeFromOtherQuestion:
+		if a.TakeFromOtherQuestion() != b.TakeFromOtherQuestion() {
+			return fmt.Errorf("got results from question %d; recorded question %d", a.TakeFromOtherQuestion(), b.TakeFromOtherQuestion())
+		}
+	}
+	return nil
+}
+
+func comparePayloads(a, b rpccapnp.Payload) error {
+	ac, err := a.ContentPtr()
+	if err != nil {
+		return err
+	}
+	bc, err := b.ContentPtr()
+	if err != nil {
+		return err
+	}
+	ab, err := marshalPtr(ac)
+	if err != nil {
+		return err
+	}
+	bb, err := marshalPtr(bc)
+	if err != nil {
+		return err
+	}
+	if string(ab) != string(bb) {
+		return errors.New("results differ from recording")
+	}
+	at, err := a.CapTable()
+	if err != nil {
+		return err
+	}
+	bt, err := b.CapTable()
+	if err != nil {
+		return err
+	}
+	if at.Len() != bt.Len() {
+		return fmt.Errorf("got %d capabilities; recorded %d", at.Len(), bt.Len())
+	}
+	for i := 0; i < at.Len(); i++ {
+		if aw, bw := at.At(i).Which(), bt.At(i).Which(); aw != bw {
+			return fmt.Errorf("capability %d is %v; recorded %v", i, aw, bw)
+		}
+	}
+	return nil
+}
+
+// marshalPtr serializes a copy of p, so that pointers that are laid out
+// differently but have the same content compare equal.
+func marshalPtr(p capnp.Ptr) ([]byte, error) {
+	if !p.IsValid() {
+		return nil, nil
+	}
+	m, err := capnp.CopyToNewMessage(p)
+	if err != nil {
+		return nil, err
+	}
+	return m.Marshal()
+}
@@ -0,0 +1,160 @@
+package record_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/rpc"
+	"zombiezen.com/go/capnproto2/rpc/internal/pipetransport"
+	"zombiezen.com/go/capnproto2/rpc/internal/testcapnp"
+	"zombiezen.com/go/capnproto2/rpc/record"
+	"zombiezen.com/go/capnproto2/server"
+	rpccapnp "zombiezen.com/go/capnproto2/std/capnp/rpc"
+)
+
+type adder struct {
+	sign int32
+}
+
+func (a adder) Add(call testcapnp.Adder_add) error {
+	server.Ack(call.Options)
+	call.Results.SetResult(call.Params.A() + a.sign*call.Params.B())
+	return nil
+}
+
+// recordAdds records a server Conn answering two Add calls.
+func recordAdds(t *testing.T) []byte {
+	ctx := context.Background()
+	p, q := pipetransport.New()
+	var buf bytes.Buffer
+	srv := testcapnp.Adder_ServerToClient(adder{sign: 1})
+	serverConn := rpc.NewConn(record.NewRecorder(p, &buf), rpc.MainInterface(srv.Client))
+	clientConn := rpc.NewConn(q)
+	a := testcapnp.Adder{Client: clientConn.Bootstrap(ctx)}
+	for _, args := range [][2]int32{{5, 2}, {10, 20}} {
+		res, err := a.Add(ctx, func(p testcapnp.Adder_add_Params) error {
+			p.SetA(args[0])
+			p.SetB(args[1])
+			return nil
+		}).Struct()
+		if err != nil {
+			t.Fatal("Add:", err)
+		}
+		if want := args[0] + args[1]; res.Result() != want {
+			t.Fatalf("Add(%d, %d) = %d; want %d", args[0], args[1], res.Result(), want)
+		}
+	}
+	if err := clientConn.Close(); err != nil {
+		t.Error("clientConn.Close:", err)
+	}
+	serverConn.Wait()
+	return buf.Bytes()
+}
+
+func TestRecorder(t *testing.T) {
+	entries, err := record.ReadAll(bytes.NewReader(recordAdds(t)))
+	if err != nil {
+		t.Fatal("ReadAll:", err)
+	}
+	got := make(map[string]int)
+	var last int64
+	for i, e := range entries {
+		if e.Time() < last {
+			t.Errorf("entries[%d].Time() = %d; before previous entry (%d)", i, e.Time(), last)
+		}
+		last = e.Time()
+		msg, err := e.Message()
+		if err != nil {
+			t.Fatalf("entries[%d].Message(): %v", i, err)
+		}
+		got[e.Direction().String()+" "+msg.Which().String()]++
+	}
+	want := map[string]int{
+		"recv bootstrap": 1,
+		"recv call":      2,
+		"send return":    3,
+	}
+	for k, n := range want {
+		if got[k] != n {
+			t.Errorf("recorded %d %q messages; want %d", got[k], k, n)
+		}
+	}
+}
+
+func TestReplayer(t *testing.T) {
+	rec := recordAdds(t)
+	tests := []struct {
+		name   string
+		sign   int32
+		errMsg string
+	}{
+		{"same server", 1, ""},
+		{"changed server", -1, "results differ"},
+	}
+	for _, test := range tests {
+		entries, err := record.ReadAll(bytes.NewReader(rec))
+		if err != nil {
+			t.Fatal("ReadAll:", err)
+		}
+		r, err := record.NewReplayer(entries)
+		if err != nil {
+			t.Fatal("NewReplayer:", err)
+		}
+		srv := testcapnp.Adder_ServerToClient(adder{sign: test.sign})
+		conn := rpc.NewConn(r, rpc.MainInterface(srv.Client))
+		select {
+		case <-r.Done():
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s: replay did not finish", test.name)
+		}
+		conn.Close()
+		err = r.Err()
+		switch {
+		case test.errMsg == "" && err != nil:
+			t.Errorf("%s: Err() = %v; want <nil>", test.name, err)
+		case test.errMsg != "" && (err == nil || !strings.Contains(err.Error(), test.errMsg)):
+			t.Errorf("%s: Err() = %v; want error containing %q", test.name, err, test.errMsg)
+		}
+	}
+}
+
+func TestReplayerUnexpectedReturn(t *testing.T) {
+	r, err := record.NewReplayer(nil)
+	if err != nil {
+		t.Fatal("NewReplayer:", err)
+	}
+	defer r.Close()
+	select {
+	case <-r.Done():
+	default:
+		t.Error("empty replay is not done")
+	}
+	msg, err := rpccapnp.NewRootMessage(newSegment(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret, err := msg.NewReturn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret.SetAnswerId(7)
+	ret.SetCanceled()
+	if err := r.SendMessage(context.Background(), msg); err != nil {
+		t.Fatal("SendMessage:", err)
+	}
+	if err := r.Err(); err == nil || !strings.Contains(err.Error(), "unexpected return for question 7") {
+		t.Errorf("Err() = %v; want unexpected return for question 7", err)
+	}
+}
+
+func newSegment(t *testing.T) *capnp.Segment {
+	_, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return seg
+}
@@ -21,6 +21,12 @@ type Conn struct {
 	mainCloser io.Closer
 	death      chan struct{} // closed after state is connDead
 
+	propagateDeadlines bool
+
+	// nextDeadline is the deadline for the next message, if it is a
+	// call.  It is only used by the receive goroutine.
+	nextDeadline *callDeadline
+
 	out chan rpccapnp.Message
 
 	bg       context.Context
@@ -43,6 +49,8 @@ type Conn struct {
 	embargoID  idgen
 	answers    map[answerID]*answer
 	imports    map[importID]*impent
+
+	peerIgnoresDeadlines bool
 }
 
 type connParams struct {
@@ -50,6 +58,8 @@ type connParams struct {
 	mainFunc       func(context.Context) (capnp.Client, error)
 	mainCloser     io.Closer
 	sendBufferSize int
+
+	propagateDeadlines bool
 }
 
 // A ConnOption is an option for opening a connection.
@@ -108,6 +118,8 @@ func NewConn(t Transport, options ...ConnOption) *Conn {
 		log:        p.log,
 		death:      make(chan struct{}),
 		mu:         newChanMutex(),
+
+		propagateDeadlines: p.propagateDeadlines,
 	}
 	conn.bg, conn.bgCancel = context.WithCancel(context.Background())
 	conn.workers.Add(2)
@@ -310,9 +322,15 @@ func (c *Conn) Bootstrap(ctx context.Context) capnp.Client {
 // message.  m cannot be held onto past the return of handleMessage, and
 // c.mu is not held at the start of handleMessage.
 func (c *Conn) handleMessage(m rpccapnp.Message) {
+	dl := c.nextDeadline
+	c.nextDeadline = nil
 	switch m.Which() {
 	case rpccapnp.Message_Which_unimplemented:
-		// no-op for now to avoid feedback loop
+		// Nothing is sent in reply, to avoid a feedback loop.  The only
+		// message that can be recovered from is a deadline.
+		if um, err := m.Unimplemented(); err == nil && um.Which() == deadlineMessageWhich {
+			c.handleUnimplementedDeadline()
+		}
 	case rpccapnp.Message_Which_abort:
 		a, err := copyAbort(m)
 		if err != nil {
@@ -370,7 +388,7 @@ func (c *Conn) handleMessage(m rpccapnp.Message) {
 	case rpccapnp.Message_Which_call:
 		m = copyRPCMessage(m)
 		c.mu.Lock()
-		err := c.handleCallMessage(m)
+		err := c.handleCallMessage(m, dl)
 		c.mu.Unlock()
 
 		if err != nil {
@@ -398,6 +416,8 @@ func (c *Conn) handleMessage(m rpccapnp.Message) {
 			// Any failure in a disembargo is a protocol violation.
 			c.abort(err)
 		}
+	case deadlineMessageWhich:
+		c.handleDeadlineMessage(m)
 	default:
 		c.infof("received unimplemented message, which = %v", m.Which())
 		um := newUnimplementedMessage(nil, m)
@@ -531,6 +551,10 @@ func newFinishMessage(buf []byte, questionID questionID, release bool) rpccapnp.
 // populateMessageCapTable converts the descriptors in the payload into
 // clients and sets it on the message the payload is a part of.
 func (c *Conn) populateMessageCapTable(payload rpccapnp.Payload) error {
+	if !payload.IsValid() {
+		// A null payload has no capabilities.
+		return nil
+	}
 	msg := payload.Segment().Message()
 	ctab, err := payload.CapTable()
 	if err != nil {
@@ -638,8 +662,9 @@ func (c *Conn) handleBootstrapMessage(id answerID) error {
 }
 
 // handleCallMessage handles a received call message.  It mutates the
-// capability table of its parameter.  The caller holds onto c.mu.
-func (c *Conn) handleCallMessage(m rpccapnp.Message) error {
+// capability table of its parameter.  dl is the deadline received
+// right before the call, if any.  The caller holds onto c.mu.
+func (c *Conn) handleCallMessage(m rpccapnp.Message, dl *callDeadline) error {
 	mcall, err := m.Call()
 	if err != nil {
 		return err
@@ -663,7 +688,7 @@ func (c *Conn) handleCallMessage(m rpccapnp.Message) error {
 		c.abort(err)
 		return err
 	}
-	ctx, cancel := c.newContext()
+	ctx, cancel := c.newCallContext(mcall, dl)
 	id := answerID(mcall.QuestionId())
 	a := c.insertAnswer(id, cancel)
 	if a == nil {
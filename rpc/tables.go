@@ -96,14 +96,9 @@ func (ic *importClient) lockedCall(cl *capnp.Call) capnp.Answer {
 		return capnp.ErrorAnswer(err)
 	}
 
-	select {
-	case ic.conn.out <- msg:
-	case <-cl.Ctx.Done():
+	if err := ic.conn.sendCall(cl.Ctx, q.id, msg); err != nil {
 		ic.conn.popQuestion(q.id)
-		return capnp.ErrorAnswer(cl.Ctx.Err())
-	case <-ic.conn.bg.Done():
-		ic.conn.popQuestion(q.id)
-		return capnp.ErrorAnswer(ErrConnClosed)
+		return capnp.ErrorAnswer(err)
 	}
 	q.start()
 	return q
@@ -0,0 +1,524 @@
+// Package compat checks that a new version of a schema can still talk
+// to an old version on the wire.
+//
+// Check compares two sets of nodes by ID and reports changes that break
+// the Cap'n Proto schema evolution rules described at
+// https://capnproto.org/language.html#evolving-your-protocol.
+// Renames, new fields, new enumerants, new methods and moving types to
+// a new scope are all allowed and are not reported.
+package compat
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/schemas"
+	"zombiezen.com/go/capnproto2/std/capnp/schema"
+)
+
+// A NodeSet is a set of schema nodes indexed by ID.
+type NodeSet map[uint64]schema.Node
+
+// FromRequest returns the nodes in a CodeGeneratorRequest.
+func FromRequest(req schema.CodeGeneratorRequest) (NodeSet, error) {
+	set := make(NodeSet)
+	if err := set.addRequest(req); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// FromRegistry returns the nodes in the schemas registered for the
+// given IDs.  Each schema blob is loaded in full, so passing the ID of
+// any node in a generated file adds every node in that file.
+func FromRegistry(reg *schemas.Registry, ids ...uint64) (NodeSet, error) {
+	set := make(NodeSet)
+	for _, id := range ids {
+		if _, ok := set[id]; ok {
+			continue
+		}
+		data, err := reg.Find(id)
+		if err != nil {
+			return nil, err
+		}
+		msg, err := capnp.Unmarshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("compat: schema for @%#x: %v", id, err)
+		}
+		req, err := schema.ReadRootCodeGeneratorRequest(msg)
+		if err != nil {
+			return nil, fmt.Errorf("compat: schema for @%#x: %v", id, err)
+		}
+		if err := set.addRequest(req); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+func (set NodeSet) addRequest(req schema.CodeGeneratorRequest) error {
+	nodes, err := req.Nodes()
+	if err != nil {
+		return fmt.Errorf("compat: reading nodes: %v", err)
+	}
+	for i := 0; i < nodes.Len(); i++ {
+		n := nodes.At(i)
+		set[n.Id()] = n
+	}
+	return nil
+}
+
+// A Kind identifies a class of incompatible change.  Kinds are stable
+// strings so that tools can filter on them.
+type Kind string
+
+// Kinds of incompatible changes.
+const (
+	NodeKindChanged    Kind = "node-kind-changed"
+	GroupChanged       Kind = "group-changed"
+	FieldRemoved       Kind = "field-removed"
+	FieldMoved         Kind = "field-moved"
+	FieldKindChanged   Kind = "field-kind-changed"
+	FieldTypeChanged   Kind = "field-type-changed"
+	DefaultChanged     Kind = "default-changed"
+	UnionChanged       Kind = "union-changed"
+	ListUpgradeInvalid Kind = "list-upgrade-invalid"
+	EnumerantRemoved   Kind = "enumerant-removed"
+	MethodRemoved      Kind = "method-removed"
+	MethodChanged      Kind = "method-signature-changed"
+	SuperclassRemoved  Kind = "superclass-removed"
+)
+
+// A Problem is a single incompatible change.
+type Problem struct {
+	Kind Kind `json:"kind"`
+
+	// ID and Node identify the node in the new schema.  Node is the
+	// node's display name.
+	ID   uint64 `json:"id,string"`
+	Node string `json:"node"`
+
+	// Member is the name of the field, enumerant or method that
+	// changed, if any.
+	Member string `json:"member,omitempty"`
+
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	name := p.Node
+	if p.Member != "" {
+		name += "." + p.Member
+	}
+	return fmt.Sprintf("%s: %s", name, p.Message)
+}
+
+// Check reports the incompatible changes between two versions of a
+// schema.  Nodes are matched by ID; nodes that appear in only one of
+// the sets are not compared.  The problems are sorted by node name.
+func Check(old, new NodeSet) []Problem {
+	c := &checker{
+		old:  old,
+		new:  new,
+		seen: make(map[[2]uint64]bool),
+	}
+	ids := make([]uint64, 0, len(old))
+	for id, n := range old {
+		if isParamStruct(n) {
+			// Checked along with the method.
+			continue
+		}
+		if _, ok := new[id]; ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Sort(uint64Slice(ids))
+	for _, id := range ids {
+		c.checkNode(old[id], new[id])
+	}
+	sort.Stable(byNode(c.problems))
+	return c.problems
+}
+
+type uint64Slice []uint64
+
+func (p uint64Slice) Len() int           { return len(p) }
+func (p uint64Slice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p uint64Slice) Less(i, j int) bool { return p[i] < p[j] }
+
+type byNode []Problem
+
+func (p byNode) Len() int           { return len(p) }
+func (p byNode) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p byNode) Less(i, j int) bool { return p[i].Node < p[j].Node }
+
+type checker struct {
+	old, new NodeSet
+	seen     map[[2]uint64]bool
+	problems []Problem
+
+	// method is non-empty while checking the parameters or results of
+	// a method whose struct type changed.  Problems are reported
+	// against the method instead of the struct.
+	method *Problem
+}
+
+func (c *checker) report(kind Kind, n schema.Node, member string, format string, args ...interface{}) {
+	p := Problem{
+		Kind:    kind,
+		ID:      n.Id(),
+		Member:  member,
+		Message: fmt.Sprintf(format, args...),
+	}
+	p.Node, _ = n.DisplayName()
+	if c.method != nil {
+		if member != "" {
+			p.Message = member + ": " + p.Message
+		}
+		p.Message = c.method.Message + ": " + p.Message
+		p.Kind, p.ID, p.Node, p.Member = MethodChanged, c.method.ID, c.method.Node, c.method.Member
+	}
+	c.problems = append(c.problems, p)
+}
+
+func (c *checker) checkNode(old, new schema.Node) {
+	key := [2]uint64{old.Id(), new.Id()}
+	if c.seen[key] {
+		return
+	}
+	c.seen[key] = true
+	if old.Which() != new.Which() {
+		c.report(NodeKindChanged, new, "", "changed from %v to %v", old.Which(), new.Which())
+		return
+	}
+	switch old.Which() {
+	case schema.Node_Which_structNode:
+		c.checkStruct(old, new)
+	case schema.Node_Which_enum:
+		c.checkEnum(old, new)
+	case schema.Node_Which_interface:
+		c.checkInterface(old, new)
+	}
+}
+
+func (c *checker) checkStruct(oldNode, newNode schema.Node) {
+	old, new := oldNode.StructNode(), newNode.StructNode()
+	if old.IsGroup() != new.IsGroup() {
+		c.report(GroupChanged, newNode, "", "changed between a struct and a group")
+		return
+	}
+	if old.DiscriminantCount() > 0 {
+		if new.DiscriminantCount() < old.DiscriminantCount() {
+			c.report(UnionChanged, newNode, "", "union shrank from %d to %d members", old.DiscriminantCount(), new.DiscriminantCount())
+		}
+		if new.DiscriminantOffset() != old.DiscriminantOffset() {
+			c.report(UnionChanged, newNode, "", "union discriminant moved")
+		}
+	}
+	oldFields, _ := old.Fields()
+	newFields, _ := new.Fields()
+	// Fields are listed in ordinal order, and since ordinals can't be
+	// removed, a field keeps its index as the schema evolves.
+	for i := 0; i < oldFields.Len(); i++ {
+		of := oldFields.At(i)
+		name, _ := of.Name()
+		if i >= newFields.Len() {
+			c.report(FieldRemoved, newNode, name, "field removed")
+			continue
+		}
+		c.checkField(newNode, old.DiscriminantCount() > 0, of, newFields.At(i))
+	}
+}
+
+func (c *checker) checkField(newNode schema.Node, hadUnion bool, old, new schema.Field) {
+	name, _ := new.Name()
+	if isExplicit(old) && isExplicit(new) && ordinal(old) != ordinal(new) {
+		c.report(FieldMoved, newNode, name, "ordinal changed from @%d to @%d", ordinal(old), ordinal(new))
+		return
+	}
+	if od, nd := old.DiscriminantValue(), new.DiscriminantValue(); od != nd {
+		// A field may be moved into a new union as its first member,
+		// since a missing discriminant reads as zero.
+		if !(od == schema.Field_noDiscriminant && nd == 0 && !hadUnion) {
+			c.report(UnionChanged, newNode, name, "union membership changed")
+		}
+	}
+	switch {
+	case old.Which() == schema.Field_Which_group && new.Which() == schema.Field_Which_group:
+		c.checkByID(old.Group().TypeId(), new.Group().TypeId())
+	case old.Which() == schema.Field_Which_slot && new.Which() == schema.Field_Which_slot:
+		c.checkSlot(newNode, name, old.Slot(), new.Slot())
+	case old.Which() == schema.Field_Which_slot && new.Which() == schema.Field_Which_group:
+		// A field can be replaced by a group or union that contains
+		// the same field, keeping its ordinal.
+		g, ok := c.new[new.Group().TypeId()]
+		if !ok {
+			return
+		}
+		if inner, ok := fieldWithOrdinal(g, ordinal(old)); ok && inner.Which() == schema.Field_Which_slot {
+			c.checkSlot(newNode, name, old.Slot(), inner.Slot())
+			return
+		}
+		c.report(FieldKindChanged, newNode, name, "replaced with a group that does not contain @%d", ordinal(old))
+	default:
+		c.report(FieldKindChanged, newNode, name, "changed from a group to a field")
+	}
+}
+
+// checkByID compares two nodes if both are present.
+func (c *checker) checkByID(oldID, newID uint64) {
+	og, ok1 := c.old[oldID]
+	ng, ok2 := c.new[newID]
+	if !ok1 || !ok2 {
+		return
+	}
+	c.checkNode(og, ng)
+}
+
+func (c *checker) checkSlot(newNode schema.Node, name string, old, new schema.Field_slot) {
+	ot, _ := old.Type()
+	nt, _ := new.Type()
+	if msg := c.checkType(ot, nt); msg != "" {
+		kind := FieldTypeChanged
+		if ot.Which() == schema.Type_Which_list && nt.Which() == schema.Type_Which_list {
+			if et, _ := nt.List().ElementType(); et.Which() == schema.Type_Which_structType {
+				kind = ListUpgradeInvalid
+			}
+		}
+		c.report(kind, newNode, name, "%s", msg)
+		return
+	}
+	if ot.Which() == nt.Which() && old.Offset() != new.Offset() {
+		c.report(FieldMoved, newNode, name, "offset changed from %d to %d", old.Offset(), new.Offset())
+	}
+	ov, _ := old.DefaultValue()
+	nv, _ := new.DefaultValue()
+	if !sameDefault(ov, nv) {
+		c.report(DefaultChanged, newNode, name, "default value changed")
+	}
+}
+
+// checkType returns a description of why a value of type old can't
+// be read as type new, or the empty string if it can.
+func (c *checker) checkType(old, new schema.Type) string {
+	if new.Which() == schema.Type_Which_anyPointer && isPointer(old) {
+		return ""
+	}
+	if old.Which() != new.Which() {
+		return fmt.Sprintf("type changed from %s to %s", c.typeString(old), c.typeString(new))
+	}
+	var oldID, newID uint64
+	switch old.Which() {
+	case schema.Type_Which_enum:
+		oldID, newID = old.Enum().TypeId(), new.Enum().TypeId()
+	case schema.Type_Which_structType:
+		oldID, newID = old.StructType().TypeId(), new.StructType().TypeId()
+	case schema.Type_Which_interface:
+		oldID, newID = old.Interface().TypeId(), new.Interface().TypeId()
+	case schema.Type_Which_list:
+		oe, _ := old.List().ElementType()
+		ne, _ := new.List().ElementType()
+		if c.checkType(oe, ne) == "" {
+			return ""
+		}
+		if ne.Which() == schema.Type_Which_structType && oe.Which() != schema.Type_Which_structType {
+			return c.checkListUpgrade(oe, ne)
+		}
+		return fmt.Sprintf("type changed from %s to %s", c.typeString(old), c.typeString(new))
+	}
+	if oldID != newID {
+		return fmt.Sprintf("type changed from %s to %s", c.typeString(old), c.typeString(new))
+	}
+	return ""
+}
+
+// checkListUpgrade checks that a List(T) can be read as a List(S),
+// which is allowed if S's @0 field has type T and T is not Bool.
+func (c *checker) checkListUpgrade(oldElem, newElem schema.Type) string {
+	s, ok := c.new[newElem.StructType().TypeId()]
+	if !ok {
+		return ""
+	}
+	bad := fmt.Sprintf("List(%s) upgraded to List(%s)", c.typeString(oldElem), c.typeString(newElem))
+	if oldElem.Which() == schema.Type_Which_bool {
+		return bad + ": List(Bool) cannot be upgraded"
+	}
+	f, ok := fieldWithOrdinal(s, 0)
+	if !ok || f.Which() != schema.Field_Which_slot {
+		return bad + ": struct has no @0 field"
+	}
+	ft, _ := f.Slot().Type()
+	if oldElem.Which() != ft.Which() || c.checkType(oldElem, ft) != "" {
+		return fmt.Sprintf("%s: @0 field has type %s", bad, c.typeString(ft))
+	}
+	return ""
+}
+
+func (c *checker) checkEnum(oldNode, newNode schema.Node) {
+	old, _ := oldNode.Enum().Enumerants()
+	new, _ := newNode.Enum().Enumerants()
+	for i := new.Len(); i < old.Len(); i++ {
+		name, _ := old.At(i).Name()
+		c.report(EnumerantRemoved, newNode, name, "enumerant removed")
+	}
+}
+
+func (c *checker) checkInterface(oldNode, newNode schema.Node) {
+	oldMethods, _ := oldNode.Interface().Methods()
+	newMethods, _ := newNode.Interface().Methods()
+	for i := 0; i < oldMethods.Len(); i++ {
+		om := oldMethods.At(i)
+		name, _ := om.Name()
+		if i >= newMethods.Len() {
+			c.report(MethodRemoved, newNode, name, "method removed")
+			continue
+		}
+		nm := newMethods.At(i)
+		c.checkParams(newNode, name, "params", om.ParamStructType(), nm.ParamStructType())
+		c.checkParams(newNode, name, "results", om.ResultStructType(), nm.ResultStructType())
+	}
+	oldSupers, _ := oldNode.Interface().Superclasses()
+	newSupers, _ := newNode.Interface().Superclasses()
+	ids := make(map[uint64]bool, newSupers.Len())
+	for i := 0; i < newSupers.Len(); i++ {
+		ids[newSupers.At(i).Id()] = true
+	}
+	for i := 0; i < oldSupers.Len(); i++ {
+		id := oldSupers.At(i).Id()
+		if !ids[id] {
+			c.report(SuperclassRemoved, newNode, "", "no longer extends %s", c.nodeName(c.old, id))
+		}
+	}
+}
+
+// checkParams compares a method's parameter or result structs.  A
+// named struct with an unchanged ID is compared like any other node.
+func (c *checker) checkParams(newNode schema.Node, method, which string, oldID, newID uint64) {
+	if oldID == newID && !isParamStruct(c.old[oldID]) {
+		return
+	}
+	saved := c.method
+	p := Problem{ID: newNode.Id(), Member: method, Message: which + " type changed"}
+	p.Node, _ = newNode.DisplayName()
+	c.method = &p
+	c.checkByID(oldID, newID)
+	c.method = saved
+}
+
+func (c *checker) typeString(t schema.Type) string {
+	switch t.Which() {
+	case schema.Type_Which_list:
+		et, _ := t.List().ElementType()
+		return "List(" + c.typeString(et) + ")"
+	case schema.Type_Which_enum:
+		return c.nodeName(c.new, t.Enum().TypeId())
+	case schema.Type_Which_structType:
+		return c.nodeName(c.new, t.StructType().TypeId())
+	case schema.Type_Which_interface:
+		return c.nodeName(c.new, t.Interface().TypeId())
+	case schema.Type_Which_anyPointer:
+		return "AnyPointer"
+	}
+	s := t.Which().String()
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// nodeName returns the display name of a node, looking in both sets
+// since a type may have been removed.
+func (c *checker) nodeName(set NodeSet, id uint64) string {
+	n, ok := set[id]
+	if !ok {
+		if n, ok = c.old[id]; !ok {
+			return fmt.Sprintf("@%#x", id)
+		}
+	}
+	name, _ := n.DisplayName()
+	return name
+}
+
+func isExplicit(f schema.Field) bool {
+	return f.Ordinal().Which() == schema.Field_ordinal_Which_explicit
+}
+
+func ordinal(f schema.Field) uint16 {
+	if isExplicit(f) {
+		return f.Ordinal().Explicit()
+	}
+	return 0
+}
+
+// fieldWithOrdinal finds the field in a struct or group with the given
+// ordinal.
+func fieldWithOrdinal(n schema.Node, ord uint16) (schema.Field, bool) {
+	fields, _ := n.StructNode().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		f := fields.At(i)
+		if f.Which() == schema.Field_Which_slot && isExplicit(f) && f.Ordinal().Explicit() == ord {
+			return f, true
+		}
+	}
+	return schema.Field{}, false
+}
+
+// isParamStruct reports whether n is the implicit struct for a
+// method's parameter or result list.
+func isParamStruct(n schema.Node) bool {
+	return n.IsValid() && n.Which() == schema.Node_Which_structNode && n.ScopeId() == 0
+}
+
+func isPointer(t schema.Type) bool {
+	switch t.Which() {
+	case schema.Type_Which_text, schema.Type_Which_data, schema.Type_Which_list,
+		schema.Type_Which_structType, schema.Type_Which_interface, schema.Type_Which_anyPointer:
+		return true
+	}
+	return false
+}
+
+// sameDefault reports whether two default values are equal.  Only
+// scalar and blob defaults are compared; other pointer defaults are
+// assumed equal.
+func sameDefault(a, b schema.Value) bool {
+	if a.Which() != b.Which() {
+		// A type change will have already been reported.
+		return true
+	}
+	switch a.Which() {
+	case schema.Value_Which_bool:
+		return a.Bool() == b.Bool()
+	case schema.Value_Which_int8:
+		return a.Int8() == b.Int8()
+	case schema.Value_Which_int16:
+		return a.Int16() == b.Int16()
+	case schema.Value_Which_int32:
+		return a.Int32() == b.Int32()
+	case schema.Value_Which_int64:
+		return a.Int64() == b.Int64()
+	case schema.Value_Which_uint8:
+		return a.Uint8() == b.Uint8()
+	case schema.Value_Which_uint16:
+		return a.Uint16() == b.Uint16()
+	case schema.Value_Which_uint32:
+		return a.Uint32() == b.Uint32()
+	case schema.Value_Which_uint64:
+		return a.Uint64() == b.Uint64()
+	case schema.Value_Which_float32:
+		// Compare bits so that NaN defaults compare equal.
+		return a.Struct.Uint32(4) == b.Struct.Uint32(4)
+	case schema.Value_Which_float64:
+		return a.Struct.Uint64(8) == b.Struct.Uint64(8)
+	case schema.Value_Which_enum:
+		return a.Enum() == b.Enum()
+	case schema.Value_Which_text:
+		at, _ := a.Text()
+		bt, _ := b.Text()
+		return at == bt
+	case schema.Value_Which_data:
+		ad, _ := a.Data()
+		bd, _ := b.Data()
+		return string(ad) == string(bd)
+	}
+	return true
+}
@@ -0,0 +1,220 @@
+package compat_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"zombiezen.com/go/capnproto2/compiler"
+	"zombiezen.com/go/capnproto2/schemas"
+	"zombiezen.com/go/capnproto2/schemas/compat"
+	"zombiezen.com/go/capnproto2/std/capnp/schema"
+)
+
+func compile(t *testing.T, src string) compat.NodeSet {
+	opts := &compiler.Options{
+		ReadFile: func(name string) ([]byte, error) {
+			if name != "test.capnp" {
+				return nil, fmt.Errorf("open %s: no such file", name)
+			}
+			return []byte("@0xc8a3c4ce2e0b2d58;\n" + src), nil
+		},
+	}
+	req, err := compiler.Compile(opts, "test.capnp")
+	if err != nil {
+		t.Fatalf("compile %q: %v", src, err)
+	}
+	set, err := compat.FromRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return set
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     []compat.Kind
+	}{
+		{
+			name: "unchanged",
+			old:  "struct Foo { a @0 :Int32; b @1 :Text; }",
+			new:  "struct Foo { a @0 :Int32; b @1 :Text; }",
+		},
+		{
+			name: "add and rename fields",
+			old:  "struct Foo { a @0 :Int32; }",
+			new:  "struct Foo { renamed @0 :Int32; b @1 :Text; c @2 :Float64; }",
+		},
+		{
+			name: "change type",
+			old:  "struct Foo { a @0 :Int32; }",
+			new:  "struct Foo { a @0 :Int64; }",
+			want: []compat.Kind{compat.FieldTypeChanged},
+		},
+		{
+			name: "change struct type",
+			old:  "struct Foo { a @0 :Bar; } struct Bar {} struct Baz {}",
+			new:  "struct Foo { a @0 :Baz; } struct Bar {} struct Baz {}",
+			want: []compat.Kind{compat.FieldTypeChanged},
+		},
+		{
+			name: "to AnyPointer",
+			old:  "struct Foo { a @0 :List(Text); }",
+			new:  "struct Foo { a @0 :AnyPointer; }",
+		},
+		{
+			name: "remove field",
+			old:  "struct Foo { a @0 :Int32; b @1 :Text; }",
+			new:  "struct Foo { a @0 :Int32; }",
+			want: []compat.Kind{compat.FieldRemoved},
+		},
+		{
+			name: "renumber fields",
+			old:  "struct Foo { a @0 :Int32; b @1 :Text; }",
+			new:  "struct Foo { b @0 :Text; a @1 :Int32; }",
+			want: []compat.Kind{compat.FieldTypeChanged, compat.FieldTypeChanged},
+		},
+		{
+			name: "change default",
+			old:  "struct Foo { a @0 :Int32 = 5; b @1 :Text = \"x\"; }",
+			new:  "struct Foo { a @0 :Int32 = 6; b @1 :Text = \"y\"; }",
+			want: []compat.Kind{compat.DefaultChanged, compat.DefaultChanged},
+		},
+		{
+			name: "retroactive union",
+			old:  "struct Foo { a @0 :Int32; }",
+			new:  "struct Foo { union { a @0 :Int32; b @1 :Text; } }",
+		},
+		{
+			name: "move field out of union",
+			old:  "struct Foo { union { a @0 :Int32; b @1 :Text; } }",
+			new:  "struct Foo { a @0 :Int32; b @1 :Text; }",
+			want: []compat.Kind{compat.UnionChanged, compat.UnionChanged, compat.UnionChanged, compat.UnionChanged},
+		},
+		{
+			name: "field into group",
+			old:  "struct Foo { a @0 :Int32; }",
+			new:  "struct Foo { g :group { a @0 :Int32; b @1 :Text; } }",
+		},
+		{
+			name: "list upgrade",
+			old:  "struct Foo { a @0 :List(Int32); }",
+			new:  "struct Foo { a @0 :List(Elem); } struct Elem { x @0 :Int32; y @1 :Text; }",
+		},
+		{
+			name: "bad list upgrade",
+			old:  "struct Foo { a @0 :List(Int32); }",
+			new:  "struct Foo { a @0 :List(Elem); } struct Elem { x @0 :Text; }",
+			want: []compat.Kind{compat.ListUpgradeInvalid},
+		},
+		{
+			name: "bool list upgrade",
+			old:  "struct Foo { a @0 :List(Bool); }",
+			new:  "struct Foo { a @0 :List(Elem); } struct Elem { x @0 :Bool; }",
+			want: []compat.Kind{compat.ListUpgradeInvalid},
+		},
+		{
+			name: "remove enumerant",
+			old:  "enum E { a @0; b @1; c @2; }",
+			new:  "enum E { a @0; renamed @1; }",
+			want: []compat.Kind{compat.EnumerantRemoved},
+		},
+		{
+			name: "add method and param",
+			old:  "interface I { f @0 (a :Int32) -> (b :Text); }",
+			new:  "interface I { f @0 (a :Int32, c :Text) -> (b :Text); g @1 (); }",
+		},
+		{
+			name: "change param type",
+			old:  "interface I { f @0 (a :Int32) -> (b :Text); }",
+			new:  "interface I { f @0 (a :Int64) -> (b :Text); }",
+			want: []compat.Kind{compat.MethodChanged},
+		},
+		{
+			name: "change results to a struct",
+			old:  "interface I { f @0 () -> (b :Text); } struct R { b @0 :Data; }",
+			new:  "interface I { f @0 () -> R; } struct R { b @0 :Data; }",
+			want: []compat.Kind{compat.MethodChanged},
+		},
+		{
+			name: "remove method",
+			old:  "interface I { f @0 (); g @1 (); }",
+			new:  "interface I { f @0 (); }",
+			want: []compat.Kind{compat.MethodRemoved},
+		},
+		{
+			name: "remove superclass",
+			old:  "interface A {} interface B extends(A) {}",
+			new:  "interface A {} interface B {}",
+			want: []compat.Kind{compat.SuperclassRemoved},
+		},
+		{
+			name: "struct to enum",
+			old:  "struct Foo {}",
+			new:  "enum Foo { a @0; }",
+			want: []compat.Kind{compat.NodeKindChanged},
+		},
+	}
+	for _, test := range tests {
+		problems := compat.Check(compile(t, test.old), compile(t, test.new))
+		var got []compat.Kind
+		for _, p := range problems {
+			got = append(got, p.Kind)
+		}
+		if !sameKinds(got, test.want) {
+			t.Errorf("%s: Check(...) = %v; want kinds %v", test.name, problems, test.want)
+		}
+	}
+}
+
+func sameKinds(a, b []compat.Kind) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestProblemString(t *testing.T) {
+	problems := compat.Check(
+		compile(t, "struct Foo { a @0 :Int32; }"),
+		compile(t, "struct Foo { a @0 :Text; }"))
+	if len(problems) != 1 {
+		t.Fatalf("Check(...) = %v; want 1 problem", problems)
+	}
+	const want = "test.capnp:Foo.a: type changed from Int32 to Text"
+	if s := problems[0].String(); s != want {
+		t.Errorf("problems[0].String() = %q; want %q", s, want)
+	}
+}
+
+func TestRegistryMatchesSource(t *testing.T) {
+	old, err := compat.FromRegistry(&schemas.DefaultRegistry, schema.Node_TypeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &compiler.Options{
+		ImportPath: []string{".."},
+		ReadFile: func(name string) ([]byte, error) {
+			return ioutil.ReadFile(filepath.Join("../../std/capnp", name))
+		},
+	}
+	req, err := compiler.Compile(opts, "schema.capnp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	new, err := compat.FromRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems := compat.Check(old, new); len(problems) > 0 {
+		t.Errorf("Check(registered, compiled) = %v; want no problems", problems)
+	}
+}
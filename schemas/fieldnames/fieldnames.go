@@ -0,0 +1,126 @@
+// Package fieldnames names the pointer fields in a Message's traversal
+// report using the schemas in a registry.
+package fieldnames
+
+import (
+	"strings"
+	"sync"
+
+	"zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/internal/nodemap"
+	"zombiezen.com/go/capnproto2/schemas"
+	"zombiezen.com/go/capnproto2/std/capnp/schema"
+)
+
+// New returns a capnp.FieldNamer that names fields from the schemas in
+// reg.  If reg is nil, the default registry is used.  Fields in groups
+// are named by their path from the struct, like "struct.fields".
+func New(reg *schemas.Registry) capnp.FieldNamer {
+	fn := new(fieldNamer)
+	if reg != nil {
+		fn.nodes.UseRegistry(reg)
+	}
+	return fn
+}
+
+type fieldNamer struct {
+	mu    sync.Mutex
+	nodes nodemap.Map
+}
+
+func (fn *fieldNamer) PointerField(typeID uint64, s capnp.Struct, i uint16) (name string, target uint64, ok bool) {
+	fn.mu.Lock()
+	defer fn.mu.Unlock()
+	var names []string
+	if err := fn.collect(&names, &target, typeID, s, "", i); err != nil || len(names) == 0 {
+		return "", 0, false
+	}
+	// Only one field should be found, unless the union discriminant
+	// is unknown.
+	return strings.Join(names, "|"), target, true
+}
+
+// collect appends the names of the fields of the struct or group
+// typeID that are stored in pointer i of s to names, and sets target
+// to the struct type of the first one that holds structs.  Fields in
+// unions are only collected if they are set.
+func (fn *fieldNamer) collect(names *[]string, target *uint64, typeID uint64, s capnp.Struct, prefix string, i uint16) error {
+	n, err := fn.nodes.Find(typeID)
+	if err != nil {
+		return err
+	}
+	if n.Which() != schema.Node_Which_structNode {
+		return nil
+	}
+	st := n.StructNode()
+	discriminant := schema.Field_noDiscriminant
+	if st.DiscriminantCount() > 0 {
+		discriminant = s.Uint16(capnp.DataOffset(st.DiscriminantOffset() * 2))
+		if discriminant >= st.DiscriminantCount() {
+			// Unknown member: name every member that could be set.
+			discriminant = schema.Field_noDiscriminant
+		}
+	}
+	fields, err := st.Fields()
+	if err != nil {
+		return err
+	}
+	for j := 0; j < fields.Len(); j++ {
+		f := fields.At(j)
+		if dv := f.DiscriminantValue(); dv != schema.Field_noDiscriminant && discriminant != schema.Field_noDiscriminant && dv != discriminant {
+			continue
+		}
+		name, err := f.Name()
+		if err != nil {
+			return err
+		}
+		switch f.Which() {
+		case schema.Field_Which_group:
+			if err := fn.collect(names, target, f.Group().TypeId(), s, prefix+name+".", i); err != nil {
+				return err
+			}
+		case schema.Field_Which_slot:
+			typ, err := f.Slot().Type()
+			if err != nil {
+				return err
+			}
+			if !isPointerType(typ) || f.Slot().Offset() != uint32(i) {
+				continue
+			}
+			*names = append(*names, prefix+name)
+			if *target == 0 {
+				*target = structTarget(typ)
+			}
+		}
+	}
+	return nil
+}
+
+func isPointerType(t schema.Type) bool {
+	switch t.Which() {
+	case schema.Type_Which_text, schema.Type_Which_data, schema.Type_Which_list,
+		schema.Type_Which_structType, schema.Type_Which_interface, schema.Type_Which_anyPointer:
+		return true
+	default:
+		return false
+	}
+}
+
+// structTarget returns the struct type that t holds, directly or as
+// (possibly nested) list elements, or zero if it doesn't hold structs.
+func structTarget(t schema.Type) uint64 {
+	for {
+		switch t.Which() {
+		case schema.Type_Which_structType:
+			return t.StructType().TypeId()
+		case schema.Type_Which_list:
+			elem, err := t.List().ElementType()
+			if err != nil {
+				return 0
+			}
+			t = elem
+		default:
+			return 0
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package fieldnames_test
+
+import (
+	"testing"
+
+	"zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/schemas/fieldnames"
+	"zombiezen.com/go/capnproto2/std/capnp/schema"
+)
+
+func TestNew(t *testing.T) {
+	_, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := schema.NewRootNode(seg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.SetDisplayName("foo.capnp:Foo")
+	nested, err := n.NewNestedNodes(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested.At(0).SetName("A")
+	nested.At(1).SetName("B")
+	n.SetStructNode()
+	fields, err := n.StructNode().NewFields(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields.At(0).SetName("x")
+	data, err := seg.Message().Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := capnp.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg.TrackTraversal()
+	n, err = schema.ReadRootNode(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.DisplayName()
+	nested, _ = n.NestedNodes()
+	nested.At(1).Name()
+	fields, _ = n.StructNode().Fields()
+	fields.At(0).Name()
+
+	r := msg.TraversalReport(schema.Node_TypeID, fieldnames.New(nil))
+	want := map[string]bool{
+		"":                      true,
+		"displayName":           true,
+		"nestedNodes":           true,
+		"nestedNodes[1].name":   true,
+		"struct.fields":         true,
+		"struct.fields[0].name": true,
+	}
+	for _, e := range r.Entries {
+		if !want[e.Path] {
+			t.Errorf("unexpected entry for %q", e.Path)
+		}
+		delete(want, e.Path)
+	}
+	for path := range want {
+		t.Errorf("no entry for %q", path)
+	}
+}
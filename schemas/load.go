@@ -0,0 +1,236 @@
+package schemas
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// RegisterRequest indexes a CodeGeneratorRequest message that was
+// produced at runtime, such as one received over the network or read
+// from a file written by `capnp compile -o-`.  data must be in the
+// standard unpacked Cap'n Proto framing format; any data after the
+// first message is ignored.  RegisterRequest copies data, so the caller
+// may reuse it.
+//
+// The request is checked to be well-formed before any of its nodes are
+// registered.  Nodes that are already in the registry are skipped, so
+// a request may include dependencies that were compiled into the
+// program, like the go.capnp annotations.  Use the returned IDs to see
+// which nodes were added.
+//
+// RegisterRequest doesn't read the nodes beyond their IDs and kinds,
+// so it can't check that the types they refer to are present.  The
+// loader package checks the node graph, and can register a list of
+// schema.Node values.
+func (reg *Registry) RegisterRequest(data []byte) (ids []uint64, err error) {
+	msg, err := parseFrame(data)
+	if err != nil {
+		return nil, err
+	}
+	all, err := msg.nodeIDs()
+	if err != nil {
+		return nil, err
+	}
+	r := &record{data: append([]byte(nil), data[:msg.size]...)}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.m == nil {
+		reg.m = make(map[uint64]*record)
+	}
+	for _, id := range all {
+		if _, dup := reg.m[id]; dup {
+			continue
+		}
+		reg.m[id] = r
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// rawMessage is just enough of a Cap'n Proto message reader to find the
+// nodes in a CodeGeneratorRequest, since this package can't depend on
+// the capnp package.
+type rawMessage struct {
+	segs [][]byte
+	size int // size of the message's framing and segments in bytes
+}
+
+type requestError string
+
+func (e requestError) Error() string {
+	return "schemas: invalid CodeGeneratorRequest: " + string(e)
+}
+
+const (
+	errTruncated    = requestError("message truncated")
+	errPointerRange = requestError("pointer out of bounds")
+)
+
+func parseFrame(data []byte) (*rawMessage, error) {
+	if len(data) < 8 {
+		return nil, errTruncated
+	}
+	n := uint64(binary.LittleEndian.Uint32(data)) + 1
+	hdr := (4 + 4*n + 7) &^ 7
+	if hdr > uint64(len(data)) {
+		return nil, errTruncated
+	}
+	msg := &rawMessage{segs: make([][]byte, n)}
+	off := hdr
+	for i := range msg.segs {
+		sz := uint64(binary.LittleEndian.Uint32(data[4+4*i:])) * 8
+		if sz > uint64(len(data))-off {
+			return nil, errTruncated
+		}
+		msg.segs[i] = data[off : off+sz]
+		off += sz
+	}
+	msg.size = int(off)
+	return msg, nil
+}
+
+func (msg *rawMessage) word(seg uint32, off uint64) (uint64, error) {
+	if uint64(seg) >= uint64(len(msg.segs)) {
+		return 0, requestError(fmt.Sprintf("segment %d out of bounds", seg))
+	}
+	s := msg.segs[seg]
+	if off >= uint64(len(s))/8 {
+		return 0, errPointerRange
+	}
+	return binary.LittleEndian.Uint64(s[off*8:]), nil
+}
+
+// inBounds reports whether n words starting at off are in seg.
+func (msg *rawMessage) inBounds(seg uint32, off, n uint64) bool {
+	sz := uint64(len(msg.segs[seg])) / 8
+	return off <= sz && n <= sz-off
+}
+
+// pointer reads the pointer at the given word and follows any far
+// pointers.  It returns the location of the pointed-to object and the
+// pointer that describes it, which is zero for a null pointer.
+func (msg *rawMessage) pointer(seg uint32, off uint64) (uint32, uint64, uint64, error) {
+	p, err := msg.word(seg, off)
+	if err != nil || p == 0 {
+		return 0, 0, 0, err
+	}
+	if p&3 != 2 {
+		return msg.near(seg, off, p)
+	}
+	seg, pad := uint32(p>>32), p>>3&(1<<29-1)
+	if p&4 == 0 {
+		// Single far pointer: the landing pad is a normal pointer.
+		lp, err := msg.word(seg, pad)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		if lp&3 == 2 {
+			return 0, 0, 0, requestError("far pointer to far pointer")
+		}
+		return msg.near(seg, pad, lp)
+	}
+	// Double far pointer: a far pointer to the content, followed by a
+	// tag describing it.
+	far, err := msg.word(seg, pad)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	tag, err := msg.word(seg, pad+1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if far&7 != 2 || tag&3 == 2 {
+		return 0, 0, 0, requestError("bad double-far landing pad")
+	}
+	seg = uint32(far >> 32)
+	if uint64(seg) >= uint64(len(msg.segs)) {
+		return 0, 0, 0, requestError(fmt.Sprintf("segment %d out of bounds", seg))
+	}
+	return seg, far >> 3 & (1<<29 - 1), tag, nil
+}
+
+func (msg *rawMessage) near(seg uint32, off uint64, p uint64) (uint32, uint64, uint64, error) {
+	if p&3 == 3 {
+		return 0, 0, 0, requestError("unexpected capability pointer")
+	}
+	target := int64(off) + 1 + int64(int32(uint32(p))>>2)
+	if target < 0 {
+		return 0, 0, 0, errPointerRange
+	}
+	return seg, uint64(target), p, nil
+}
+
+type rawStruct struct {
+	seg             uint32
+	off             uint64
+	dataWords, ptrs uint64
+}
+
+func (msg *rawMessage) readStruct(seg uint32, off uint64) (rawStruct, error) {
+	seg, off, p, err := msg.pointer(seg, off)
+	if err != nil {
+		return rawStruct{}, err
+	}
+	if p == 0 {
+		return rawStruct{}, nil
+	}
+	if p&3 != 0 {
+		return rawStruct{}, requestError("expected struct pointer")
+	}
+	s := rawStruct{seg: seg, off: off, dataWords: p >> 32 & 0xffff, ptrs: p >> 48}
+	if !msg.inBounds(seg, off, s.dataWords+s.ptrs) {
+		return rawStruct{}, errPointerRange
+	}
+	return s, nil
+}
+
+// nodeIDs returns the IDs of the nodes in a CodeGeneratorRequest.
+func (msg *rawMessage) nodeIDs() ([]uint64, error) {
+	root, err := msg.readStruct(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if root.ptrs == 0 {
+		return nil, requestError("no nodes")
+	}
+	seg, off, p, err := msg.pointer(root.seg, root.off+root.dataWords)
+	if err != nil {
+		return nil, err
+	}
+	if p == 0 {
+		return nil, requestError("no nodes")
+	}
+	if p&3 != 1 || p>>32&7 != 7 {
+		return nil, requestError("nodes is not a list of structs")
+	}
+	words := p >> 35
+	if !msg.inBounds(seg, off, words+1) {
+		return nil, errPointerRange
+	}
+	tag, _ := msg.word(seg, off)
+	n, dataWords, ptrs := uint64(uint32(tag)>>2), tag>>32&0xffff, tag>>48
+	if tag&3 != 0 || n*(dataWords+ptrs) > words {
+		return nil, requestError("bad list tag")
+	}
+	if n > 0 && dataWords == 0 {
+		return nil, requestError("node has no ID")
+	}
+	ids := make([]uint64, 0, n)
+	seen := make(map[uint64]bool, n)
+	for i := uint64(0); i < n; i++ {
+		elem := off + 1 + i*(dataWords+ptrs)
+		id, _ := msg.word(seg, elem)
+		if seen[id] {
+			return nil, requestError(fmt.Sprintf("node @%#x appears twice", id))
+		}
+		seen[id] = true
+		if dataWords >= 2 {
+			w, _ := msg.word(seg, elem+1)
+			if which := uint16(w >> 32); which > 5 {
+				return nil, requestError(fmt.Sprintf("node @%#x has unknown kind %d", id, which))
+			}
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,353 @@
+// Package loader adds schema nodes that were produced at runtime to a
+// schemas.Registry, after checking that they form a consistent graph.
+//
+// Registry.RegisterRequest only checks that a CodeGeneratorRequest is
+// well-formed, since the schemas package can't read schema nodes.  The
+// functions in this package also check that every node that a node
+// refers to is present, either among the nodes being loaded or in the
+// registry, and that it is the kind of node the reference expects.
+package loader
+
+import (
+	"fmt"
+
+	"zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/internal/nodemap"
+	"zombiezen.com/go/capnproto2/schemas"
+	"zombiezen.com/go/capnproto2/std/capnp/schema"
+)
+
+// Register checks nodes and adds them to reg.  Nodes that are already
+// in reg are skipped.  It returns the IDs of the nodes that were added.
+// The nodes are copied, so they may belong to any message.
+func Register(reg *schemas.Registry, nodes []schema.Node) ([]uint64, error) {
+	if err := Check(reg, nodes); err != nil {
+		return nil, err
+	}
+	msg, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		return nil, err
+	}
+	req, err := schema.NewRootCodeGeneratorRequest(seg)
+	if err != nil {
+		return nil, err
+	}
+	list, err := req.NewNodes(int32(len(nodes)))
+	if err != nil {
+		return nil, err
+	}
+	for i, n := range nodes {
+		if err := list.Set(i, n); err != nil {
+			return nil, fmt.Errorf("loader: copying node @%#x: %v", n.Id(), err)
+		}
+	}
+	data, err := msg.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return reg.RegisterRequest(data)
+}
+
+// RegisterRequest checks the nodes in the CodeGeneratorRequest message
+// in data and adds them to reg.  It is like reg.RegisterRequest, but
+// rejects requests whose nodes refer to missing or mismatched nodes.
+func RegisterRequest(reg *schemas.Registry, data []byte) ([]uint64, error) {
+	msg, err := capnp.Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+	req, err := schema.ReadRootCodeGeneratorRequest(msg)
+	if err != nil {
+		return nil, err
+	}
+	list, err := req.Nodes()
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]schema.Node, list.Len())
+	for i := range nodes {
+		nodes[i] = list.At(i)
+	}
+	if err := Check(reg, nodes); err != nil {
+		return nil, err
+	}
+	return reg.RegisterRequest(data)
+}
+
+// Check reports the first problem found in the graph formed by nodes.
+// References are resolved against nodes first and then against reg; if
+// reg is nil, the default registry is used.  Besides checking that
+// node IDs are nonzero and unique and that node scopes are present,
+// Check verifies that struct, enum and interface types refer to nodes
+// of that kind, that groups refer to group nodes scoped to their
+// struct, that superclasses are interfaces, that method parameters and
+// results are structs, that annotations refer to annotation nodes, and
+// that constant values and field defaults match their types.
+func Check(reg *schemas.Registry, nodes []schema.Node) error {
+	c := &checker{nodes: make(map[uint64]schema.Node, len(nodes))}
+	if reg != nil {
+		c.reg.UseRegistry(reg)
+	}
+	for _, n := range nodes {
+		id := n.Id()
+		if id == 0 {
+			return fmt.Errorf("loader: node %q has no ID", displayName(n))
+		}
+		if _, dup := c.nodes[id]; dup {
+			return fmt.Errorf("loader: node @%#x appears twice", id)
+		}
+		c.nodes[id] = n
+	}
+	for _, n := range nodes {
+		if err := c.node(n); err != nil {
+			return fmt.Errorf("loader: %s (@%#x): %v", displayName(n), n.Id(), err)
+		}
+	}
+	return nil
+}
+
+type checker struct {
+	nodes map[uint64]schema.Node
+	reg   nodemap.Map
+}
+
+// find returns the node with the given ID.
+func (c *checker) find(id uint64) (schema.Node, error) {
+	if n, ok := c.nodes[id]; ok {
+		return n, nil
+	}
+	n, err := c.reg.Find(id)
+	if schemas.IsNotFound(err) {
+		return schema.Node{}, fmt.Errorf("node @%#x not found", id)
+	}
+	return n, err
+}
+
+// findKind returns the node with the given ID and checks that it is a
+// node of kind k.
+func (c *checker) findKind(id uint64, k schema.Node_Which) (schema.Node, error) {
+	n, err := c.find(id)
+	if err != nil {
+		return schema.Node{}, err
+	}
+	if n.Which() != k {
+		return schema.Node{}, fmt.Errorf("@%#x is %v, not %v", id, n.Which(), k)
+	}
+	return n, nil
+}
+
+func (c *checker) node(n schema.Node) error {
+	if id := n.ScopeId(); id != 0 {
+		// Nodes without a scope are allowed for any kind of node, but a
+		// scope that is named must be present.
+		if _, err := c.find(id); err != nil {
+			return fmt.Errorf("scope: %v", err)
+		}
+	}
+	if err := c.annotations(n.Annotations()); err != nil {
+		return err
+	}
+	switch n.Which() {
+	case schema.Node_Which_file:
+		return nil
+	case schema.Node_Which_enum:
+		return c.enumerants(n.Enum())
+	case schema.Node_Which_structNode:
+		return c.structNode(n)
+	case schema.Node_Which_interface:
+		return c.interfaceNode(n.Interface())
+	case schema.Node_Which_const:
+		t, err := n.Const().Type()
+		if err != nil {
+			return err
+		}
+		v, err := n.Const().Value()
+		if err != nil {
+			return err
+		}
+		return c.value(t, v)
+	case schema.Node_Which_annotation:
+		t, err := n.Annotation().Type()
+		if err != nil {
+			return err
+		}
+		return c.typ(t)
+	default:
+		return fmt.Errorf("unknown node kind %v", n.Which())
+	}
+}
+
+func (c *checker) structNode(n schema.Node) error {
+	st := n.StructNode()
+	if st.IsGroup() && n.ScopeId() == 0 {
+		return fmt.Errorf("group has no scope")
+	}
+	fields, err := st.Fields()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < fields.Len(); i++ {
+		f := fields.At(i)
+		if err := c.field(n, f); err != nil {
+			name, _ := f.Name()
+			return fmt.Errorf("field %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func (c *checker) field(n schema.Node, f schema.Field) error {
+	if dv := f.DiscriminantValue(); dv != schema.Field_noDiscriminant && dv >= n.StructNode().DiscriminantCount() {
+		return fmt.Errorf("discriminant %d out of range for a union of %d", dv, n.StructNode().DiscriminantCount())
+	}
+	if err := c.annotations(f.Annotations()); err != nil {
+		return err
+	}
+	switch f.Which() {
+	case schema.Field_Which_slot:
+		t, err := f.Slot().Type()
+		if err != nil {
+			return err
+		}
+		if !f.Slot().HasDefaultValue() {
+			return c.typ(t)
+		}
+		v, err := f.Slot().DefaultValue()
+		if err != nil {
+			return err
+		}
+		return c.value(t, v)
+	case schema.Field_Which_group:
+		g, err := c.findKind(f.Group().TypeId(), schema.Node_Which_structNode)
+		if err != nil {
+			return err
+		}
+		if !g.StructNode().IsGroup() {
+			return fmt.Errorf("@%#x is not a group", g.Id())
+		}
+		if g.ScopeId() != n.Id() {
+			return fmt.Errorf("group @%#x is scoped to @%#x", g.Id(), g.ScopeId())
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown field kind %v", f.Which())
+	}
+}
+
+func (c *checker) enumerants(e schema.Node_enum) error {
+	list, err := e.Enumerants()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < list.Len(); i++ {
+		if err := c.annotations(list.At(i).Annotations()); err != nil {
+			name, _ := list.At(i).Name()
+			return fmt.Errorf("enumerant %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func (c *checker) interfaceNode(iface schema.Node_interface) error {
+	supers, err := iface.Superclasses()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < supers.Len(); i++ {
+		if _, err := c.findKind(supers.At(i).Id(), schema.Node_Which_interface); err != nil {
+			return fmt.Errorf("superclass: %v", err)
+		}
+	}
+	methods, err := iface.Methods()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < methods.Len(); i++ {
+		if err := c.method(methods.At(i)); err != nil {
+			name, _ := methods.At(i).Name()
+			return fmt.Errorf("method %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func (c *checker) method(m schema.Method) error {
+	if err := c.annotations(m.Annotations()); err != nil {
+		return err
+	}
+	if err := c.structType(m.ParamStructType()); err != nil {
+		return fmt.Errorf("params: %v", err)
+	}
+	if err := c.structType(m.ResultStructType()); err != nil {
+		return fmt.Errorf("results: %v", err)
+	}
+	return nil
+}
+
+// structType checks that id refers to a struct that isn't a group.
+func (c *checker) structType(id uint64) error {
+	n, err := c.findKind(id, schema.Node_Which_structNode)
+	if err != nil {
+		return err
+	}
+	if n.StructNode().IsGroup() {
+		return fmt.Errorf("@%#x is a group", id)
+	}
+	return nil
+}
+
+func (c *checker) annotations(list schema.Annotation_List, err error) error {
+	if err != nil {
+		return err
+	}
+	for i := 0; i < list.Len(); i++ {
+		if _, err := c.findKind(list.At(i).Id(), schema.Node_Which_annotation); err != nil {
+			return fmt.Errorf("annotation: %v", err)
+		}
+	}
+	return nil
+}
+
+// typ checks that the nodes referred to by t are present.
+func (c *checker) typ(t schema.Type) error {
+	switch t.Which() {
+	case schema.Type_Which_list:
+		elem, err := t.List().ElementType()
+		if err != nil {
+			return err
+		}
+		return c.typ(elem)
+	case schema.Type_Which_enum:
+		_, err := c.findKind(t.Enum().TypeId(), schema.Node_Which_enum)
+		return err
+	case schema.Type_Which_structType:
+		return c.structType(t.StructType().TypeId())
+	case schema.Type_Which_interface:
+		_, err := c.findKind(t.Interface().TypeId(), schema.Node_Which_interface)
+		return err
+	}
+	if t.Which() > schema.Type_Which_anyPointer {
+		return fmt.Errorf("unknown type %v", t.Which())
+	}
+	return nil
+}
+
+// value checks t and that v is a value of type t.
+func (c *checker) value(t schema.Type, v schema.Value) error {
+	if err := c.typ(t); err != nil {
+		return err
+	}
+	// The members of the Type and Value unions are in the same order.
+	if uint16(v.Which()) != uint16(t.Which()) {
+		return fmt.Errorf("%v value for %v type", v.Which(), t.Which())
+	}
+	return nil
+}
+
+func displayName(n schema.Node) string {
+	name, err := n.DisplayName()
+	if err != nil || name == "" {
+		return "<unnamed node>"
+	}
+	return name
+}
@@ -0,0 +1,240 @@
+package loader_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"zombiezen.com/go/capnproto2/compiler"
+	"zombiezen.com/go/capnproto2/schemas"
+	"zombiezen.com/go/capnproto2/schemas/loader"
+	"zombiezen.com/go/capnproto2/std/capnp/schema"
+)
+
+const testSource = `
+struct Foo {
+	a @0 :Bar;
+	e @1 :E;
+	g :group { x @2 :Int32; }
+}
+struct Bar {}
+enum E { zero @0; }
+interface I { m @0 (x :Int32) -> (); }
+interface J extends(I) {}
+const c :Int32 = 5;
+annotation ann(struct) :Text;
+struct Annotated $ann("hi") {}
+`
+
+// compile compiles testSource and returns its nodes, indexed by their
+// names.
+func compile(t *testing.T) ([]schema.Node, map[string]schema.Node) {
+	opts := &compiler.Options{
+		ReadFile: func(name string) ([]byte, error) {
+			if name != "test.capnp" {
+				return nil, fmt.Errorf("open %s: no such file", name)
+			}
+			return []byte("@0xc8a3c4ce2e0b2d58;\n" + testSource), nil
+		},
+	}
+	req, err := compiler.Compile(opts, "test.capnp")
+	if err != nil {
+		t.Fatal("compile:", err)
+	}
+	list, err := req.Nodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := make([]schema.Node, list.Len())
+	byName := make(map[string]schema.Node)
+	for i := range nodes {
+		nodes[i] = list.At(i)
+		name, _ := nodes[i].DisplayName()
+		byName[strings.TrimPrefix(name, "test.capnp:")] = nodes[i]
+	}
+	return nodes, byName
+}
+
+func firstField(t *testing.T, n schema.Node) schema.Field {
+	fields, err := n.StructNode().Fields()
+	if err != nil || fields.Len() == 0 {
+		t.Fatal("no fields:", err)
+	}
+	return fields.At(0)
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, nodes []schema.Node, byName map[string]schema.Node) []schema.Node
+		want   string
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name: "duplicate ID",
+			change: func(t *testing.T, nodes []schema.Node, byName map[string]schema.Node) []schema.Node {
+				return append(nodes, byName["Bar"])
+			},
+			want: "appears twice",
+		},
+		{
+			name: "missing struct type",
+			change: func(t *testing.T, nodes []schema.Node, byName map[string]schema.Node) []schema.Node {
+				bar := byName["Bar"].Id()
+				var out []schema.Node
+				for _, n := range nodes {
+					if n.Id() != bar {
+						out = append(out, n)
+					}
+				}
+				return out
+			},
+			want: "not found",
+		},
+		{
+			name: "missing scope",
+			change: func(t *testing.T, nodes []schema.Node, byName map[string]schema.Node) []schema.Node {
+				byName["Bar"].SetScopeId(0x1234)
+				return nodes
+			},
+			want: "scope: node @0x1234 not found",
+		},
+		{
+			name: "struct type is an enum",
+			change: func(t *testing.T, nodes []schema.Node, byName map[string]schema.Node) []schema.Node {
+				typ, _ := firstField(t, byName["Foo"]).Slot().Type()
+				typ.StructType().SetTypeId(byName["E"].Id())
+				return nodes
+			},
+			want: "is enum, not structNode",
+		},
+		{
+			name: "group scoped to another struct",
+			change: func(t *testing.T, nodes []schema.Node, byName map[string]schema.Node) []schema.Node {
+				byName["Foo.g"].SetScopeId(byName["Bar"].Id())
+				return nodes
+			},
+			want: "is scoped to",
+		},
+		{
+			name: "group used as a type",
+			change: func(t *testing.T, nodes []schema.Node, byName map[string]schema.Node) []schema.Node {
+				typ, _ := firstField(t, byName["Foo"]).Slot().Type()
+				typ.StructType().SetTypeId(byName["Foo.g"].Id())
+				return nodes
+			},
+			want: "is a group",
+		},
+		{
+			name: "superclass is a struct",
+			change: func(t *testing.T, nodes []schema.Node, byName map[string]schema.Node) []schema.Node {
+				supers, _ := byName["J"].Interface().Superclasses()
+				supers.At(0).SetId(byName["Bar"].Id())
+				return nodes
+			},
+			want: "superclass",
+		},
+		{
+			name: "method params are an interface",
+			change: func(t *testing.T, nodes []schema.Node, byName map[string]schema.Node) []schema.Node {
+				methods, _ := byName["I"].Interface().Methods()
+				methods.At(0).SetParamStructType(byName["I"].Id())
+				return nodes
+			},
+			want: "method m: params",
+		},
+		{
+			name: "annotation is a struct",
+			change: func(t *testing.T, nodes []schema.Node, byName map[string]schema.Node) []schema.Node {
+				anns, _ := byName["Annotated"].Annotations()
+				anns.At(0).SetId(byName["Bar"].Id())
+				return nodes
+			},
+			want: "annotation",
+		},
+		{
+			name: "constant value of the wrong type",
+			change: func(t *testing.T, nodes []schema.Node, byName map[string]schema.Node) []schema.Node {
+				v, _ := byName["c"].Const().Value()
+				v.SetText("five")
+				return nodes
+			},
+			want: "text value for int32 type",
+		},
+	}
+	for _, test := range tests {
+		nodes, byName := compile(t)
+		if test.change != nil {
+			nodes = test.change(t, nodes, byName)
+		}
+		err := loader.Check(new(schemas.Registry), nodes)
+		switch {
+		case test.want == "" && err != nil:
+			t.Errorf("%s: Check: %v", test.name, err)
+		case test.want != "" && err == nil:
+			t.Errorf("%s: Check succeeded; want error containing %q", test.name, test.want)
+		case test.want != "" && !strings.Contains(err.Error(), test.want):
+			t.Errorf("%s: Check: %v; want error containing %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	nodes, byName := compile(t)
+	reg := new(schemas.Registry)
+	ids, err := loader.Register(reg, nodes)
+	if err != nil {
+		t.Fatal("Register:", err)
+	}
+	if len(ids) != len(nodes) {
+		t.Errorf("Register added %d nodes; want %d", len(ids), len(nodes))
+	}
+	if _, err := reg.Find(byName["Foo"].Id()); err != nil {
+		t.Errorf("Find(Foo): %v", err)
+	}
+
+	// Nodes may refer to nodes that are already registered.
+	var more []schema.Node
+	for _, n := range nodes {
+		if n.Id() != byName["Bar"].Id() {
+			more = append(more, n)
+		}
+	}
+	if ids, err := loader.Register(reg, more); err != nil || len(ids) != 0 {
+		t.Errorf("Register again = %#x, %v; want [], <nil>", ids, err)
+	}
+	if _, err := loader.Register(new(schemas.Registry), more); err == nil {
+		t.Error("Register without a referenced node succeeded")
+	}
+}
+
+func TestRegisterRequest(t *testing.T) {
+	// These requests were written by the capnp tool.
+	files, err := filepath.Glob("../../capnpc-go/testdata/*.capnp.out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no test requests found")
+	}
+	for _, name := range files {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		reg := new(schemas.Registry)
+		ids, err := loader.RegisterRequest(reg, data)
+		if err != nil {
+			t.Errorf("%s: RegisterRequest: %v", name, err)
+			continue
+		}
+		if len(ids) == 0 {
+			t.Errorf("%s: RegisterRequest added no nodes", name)
+		}
+	}
+}
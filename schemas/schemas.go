@@ -4,7 +4,10 @@
 //
 // Most programs will use the default registry.  However, a program
 // could dynamically build up a registry, perhaps by invoking the capnp
-// tool or querying a service.
+// tool or querying a service and passing the resulting
+// CodeGeneratorRequest to Registry.RegisterRequest.  The loader
+// package also checks the request's nodes before registering them, and
+// can register a list of nodes.
 package schemas
 
 import (
@@ -15,10 +18,11 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"sort"
 	"strings"
 	"sync"
 
-	"zombiezen.com/go/capnproto2/internal/packed"
+	"zombiezen.com/go/capnproto2/packed"
 )
 
 // A Schema is a collection of schema nodes parsed by the capnp tool.
@@ -35,10 +39,11 @@ type Schema struct {
 	Nodes []uint64
 }
 
-// A Registry is a mapping of IDs to schema blobs.  It is safe to read
+// A Registry is a mapping of IDs to schema blobs.  It is safe to use
 // from multiple goroutines.  The zero value is an empty registry.
 type Registry struct {
-	m map[uint64]*record
+	mu sync.RWMutex
+	m  map[uint64]*record
 }
 
 // Register indexes a schema in the registry.  It is an error to
@@ -52,6 +57,8 @@ func (reg *Registry) Register(s *Schema) error {
 		data:       s.Bytes,
 		compressed: s.Compressed,
 	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
 	if reg.m == nil {
 		reg.m = make(map[uint64]*record)
 	}
@@ -69,7 +76,9 @@ func (reg *Registry) Register(s *Schema) error {
 // an error that can be identified with IsNotFound.  The returned byte
 // slice should not be modified.
 func (reg *Registry) Find(id uint64) ([]byte, error) {
+	reg.mu.RLock()
 	r := reg.m[id]
+	reg.mu.RUnlock()
 	if r == nil {
 		return nil, &notFoundError{id: id}
 	}
@@ -80,6 +89,25 @@ func (reg *Registry) Find(id uint64) ([]byte, error) {
 	return b, nil
 }
 
+// IDs returns the IDs of all the nodes in the registry, in ascending
+// order.
+func (reg *Registry) IDs() []uint64 {
+	reg.mu.RLock()
+	ids := make([]uint64, 0, len(reg.m))
+	for id := range reg.m {
+		ids = append(ids, id)
+	}
+	reg.mu.RUnlock()
+	sort.Sort(idSlice(ids))
+	return ids
+}
+
+type idSlice []uint64
+
+func (ids idSlice) Len() int           { return len(ids) }
+func (ids idSlice) Less(i, j int) bool { return ids[i] < ids[j] }
+func (ids idSlice) Swap(i, j int)      { ids[i], ids[j] = ids[j], ids[i] }
+
 type record struct {
 	// All the fields are protected by once.
 	once       sync.Once
@@ -140,8 +168,7 @@ func Register(data string, ids ...uint64) {
 // Find returns the CodeGeneratorRequest message for the given ID,
 // suitable for capnp.Unmarshal, or nil if the ID was not found.
 // It is safe to call Find from multiple goroutines, so the returned
-// byte slice should not be modified.  However, it is not safe to
-// call Find concurrently with Register.
+// byte slice should not be modified.
 func Find(id uint64) []byte {
 	b, err := DefaultRegistry.Find(id)
 	if IsNotFound(err) {
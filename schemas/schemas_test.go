@@ -1,9 +1,16 @@
 package schemas_test
 
 import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 
 	"zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/compiler"
+	"zombiezen.com/go/capnproto2/encoding/text"
+	"zombiezen.com/go/capnproto2/pogs"
 	"zombiezen.com/go/capnproto2/schemas"
 	"zombiezen.com/go/capnproto2/std/capnp/schema"
 )
@@ -51,3 +58,204 @@ func TestNotFound(t *testing.T) {
 		t.Errorf("new(schemas.Registry).Find(0) = %v; want not found error", err)
 	}
 }
+
+func TestIDs(t *testing.T) {
+	reg := new(schemas.Registry)
+	if ids := reg.IDs(); len(ids) != 0 {
+		t.Errorf("new(schemas.Registry).IDs() = %#x; want []", ids)
+	}
+	if err := reg.Register(&schemas.Schema{Nodes: []uint64{3, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Register(&schemas.Schema{Nodes: []uint64{2}}); err != nil {
+		t.Fatal(err)
+	}
+	if ids := reg.IDs(); len(ids) != 3 || ids[0] != 1 || ids[1] != 2 || ids[2] != 3 {
+		t.Errorf("reg.IDs() = %d; want [1 2 3]", ids)
+	}
+}
+
+func TestRegisterRequest(t *testing.T) {
+	// These requests were written by the capnp tool, and some have
+	// multiple segments.
+	files, err := filepath.Glob("../capnpc-go/testdata/*.capnp.out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no test requests found")
+	}
+	for _, name := range files {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		reg := new(schemas.Registry)
+		ids, err := reg.RegisterRequest(data)
+		if err != nil {
+			t.Errorf("%s: RegisterRequest: %v", name, err)
+			continue
+		}
+		msg, err := capnp.Unmarshal(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		req, _ := schema.ReadRootCodeGeneratorRequest(msg)
+		nodes, _ := req.Nodes()
+		if len(ids) != nodes.Len() {
+			t.Errorf("%s: RegisterRequest added %d nodes; want %d", name, len(ids), nodes.Len())
+			continue
+		}
+		for i := 0; i < nodes.Len(); i++ {
+			id := nodes.At(i).Id()
+			if ids[i] != id {
+				t.Errorf("%s: ids[%d] = %#x; want %#x", name, i, ids[i], id)
+			}
+			if _, err := reg.Find(id); err != nil {
+				t.Errorf("%s: Find(%#x): %v", name, id, err)
+			}
+		}
+		if again, err := reg.RegisterRequest(data); err != nil || len(again) != 0 {
+			t.Errorf("%s: second RegisterRequest = %#x, %v; want [], <nil>", name, again, err)
+		}
+	}
+}
+
+func TestRegisterRequestInvalid(t *testing.T) {
+	valid, err := ioutil.ReadFile("../capnpc-go/testdata/const.capnp.out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dup, err := dupNodeRequest(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"truncated header", valid[:6]},
+		{"truncated segment", valid[:len(valid)-8]},
+		{"too many segments", []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0}},
+		{"null root", []byte{0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
+		{"root out of bounds", []byte{0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 1, 0, 1, 0}},
+		{"duplicate node", dup},
+	}
+	for _, test := range tests {
+		reg := new(schemas.Registry)
+		if _, err := reg.RegisterRequest(test.data); err == nil {
+			t.Errorf("%s: RegisterRequest succeeded; want error", test.name)
+		}
+	}
+}
+
+// dupNodeRequest returns a request with the first node of data repeated.
+func dupNodeRequest(data []byte) ([]byte, error) {
+	msg, err := capnp.Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+	req, err := schema.ReadRootCodeGeneratorRequest(msg)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := req.Nodes()
+	if err != nil {
+		return nil, err
+	}
+	msg2, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		return nil, err
+	}
+	req2, err := schema.NewRootCodeGeneratorRequest(seg)
+	if err != nil {
+		return nil, err
+	}
+	nodes2, err := req2.NewNodes(2)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < 2; i++ {
+		if err := nodes2.Set(i, nodes.At(0)); err != nil {
+			return nil, err
+		}
+	}
+	return msg2.Marshal()
+}
+
+// TestRegisterRequestForText checks that a schema loaded at runtime
+// can be used by packages that consult the registry.
+func TestRegisterRequestForText(t *testing.T) {
+	const src = `@0xd9e8b2c3a4f50617;
+struct Widget {
+  name @0 :Text;
+  count @1 :Int32;
+}
+`
+	opts := &compiler.Options{
+		ReadFile: func(name string) ([]byte, error) {
+			if name != "widget.capnp" {
+				return nil, fmt.Errorf("open %s: no such file", name)
+			}
+			return []byte(src), nil
+		},
+	}
+	req, err := compiler.Compile(opts, "widget.capnp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := req.Struct.Segment().Message().Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := new(schemas.Registry)
+	if _, err := reg.RegisterRequest(data); err != nil {
+		t.Fatal("RegisterRequest:", err)
+	}
+	nodes, _ := req.Nodes()
+	var widget schema.Node
+	for i := 0; i < nodes.Len(); i++ {
+		if name, _ := nodes.At(i).DisplayName(); name == "widget.capnp:Widget" {
+			widget = nodes.At(i)
+		}
+	}
+	if !widget.IsValid() {
+		t.Fatal("Widget not compiled")
+	}
+
+	_, seg, _ := capnp.NewMessage(capnp.SingleSegment(nil))
+	s, err := capnp.NewRootStruct(seg, capnp.ObjectSize{DataSize: 8, PointerCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetUint32(0, 42)
+	name, _ := capnp.NewText(seg, "gear")
+	s.SetPtr(0, name.List.ToPtr())
+
+	var buf bytes.Buffer
+	enc := text.NewEncoder(&buf)
+	enc.UseRegistry(reg)
+	if err := enc.Encode(widget.Id(), s); err != nil {
+		t.Fatal("Encode:", err)
+	}
+	if got, want := buf.String(), `(name = "gear", count = 42)`; got != want {
+		t.Errorf("Encode(...) = %q; want %q", got, want)
+	}
+
+	// pogs uses the default registry.
+	if _, err := schemas.DefaultRegistry.RegisterRequest(data); err != nil {
+		t.Fatal("DefaultRegistry.RegisterRequest:", err)
+	}
+	var w struct {
+		Name  string
+		Count int32
+	}
+	if err := pogs.Extract(&w, widget.Id(), s); err != nil {
+		t.Fatal("pogs.Extract:", err)
+	}
+	if w.Name != "gear" || w.Count != 42 {
+		t.Errorf("pogs.Extract(...) = %+v; want {Name:gear Count:42}", w)
+	}
+}
@@ -4,8 +4,11 @@ package server // import "zombiezen.com/go/capnproto2/server"
 
 import (
 	"errors"
+	"fmt"
+	"runtime"
 	"sort"
 	"sync"
+	"time"
 
 	"golang.org/x/net/context"
 	"zombiezen.com/go/capnproto2"
@@ -32,25 +35,107 @@ type server struct {
 	methods sortedMethods
 	closer  Closer
 	queue   chan *call
+	bounded bool
+	limits  map[*Method]*tokenBucket
 	stop    chan struct{}
 	done    chan struct{}
 }
 
+// An Option is an option for creating a server.
+type Option struct {
+	f func(*serverParams)
+}
+
+type serverParams struct {
+	interceptors []Interceptor
+	bounded      bool
+	queueSize    int
+	rateLimit    func(m *capnp.Method) (rate float64, burst int)
+}
+
+// Interceptors adds interceptors that are run around every method
+// call on the server.  The first interceptor given is the outermost.
+// Interceptors may be given multiple times; later interceptors are
+// nested inside earlier ones.
+func Interceptors(interceptors ...Interceptor) Option {
+	return Option{func(p *serverParams) {
+		p.interceptors = append(p.interceptors, interceptors...)
+	}}
+}
+
+// MaxQueue bounds the number of calls that may wait for delivery to
+// the server.  By default, Call blocks until the server is ready to
+// accept the call, which can hold up the caller: an rpc.Conn, for
+// example, stops reading messages for every capability on the
+// connection.  With MaxQueue, calls that arrive while n calls are
+// already waiting fail immediately with an overloaded error.
+// MaxQueue(0) leaves no room to wait, so a call is only accepted if the
+// server is ready to deliver it right away: every call made while
+// another call is in progress, that is, before the other call's
+// implementation returns or calls Ack, is rejected.
+func MaxQueue(n int) Option {
+	return Option{func(p *serverParams) {
+		if n < 0 {
+			n = 0
+		}
+		p.bounded = true
+		p.queueSize = n
+	}}
+}
+
+// RateLimit limits each method of the server to rate calls per second
+// on average, allowing bursts of up to burst calls.  See RateLimitFunc.
+func RateLimit(rate float64, burst int) Option {
+	return RateLimitFunc(func(*capnp.Method) (float64, int) { return rate, burst })
+}
+
+// RateLimitFunc limits calls to each method of the server using a token
+// bucket with the rate (in calls per second) and burst size that f
+// returns for the method.  f is called once for each method when the
+// server is created.  If f returns a rate of zero or less, the method
+// is not limited.  Calls that exceed the limit fail immediately with an
+// overloaded error.
+func RateLimitFunc(f func(m *capnp.Method) (rate float64, burst int)) Option {
+	return Option{func(p *serverParams) {
+		p.rateLimit = f
+	}}
+}
+
 // New returns a client that makes calls to a set of methods.
 // If closer is nil then the client's Close is a no-op.  The server
 // guarantees message delivery order by blocking each call on the
 // return or acknowledgment of the previous call.  See the Ack function
 // for more details.
-func New(methods []Method, closer Closer) capnp.Client {
+func New(methods []Method, closer Closer, options ...Option) capnp.Client {
+	var p serverParams
+	for _, o := range options {
+		o.f(&p)
+	}
 	s := &server{
 		methods: make(sortedMethods, len(methods)),
 		closer:  closer,
-		queue:   make(chan *call),
+		queue:   make(chan *call, p.queueSize),
+		bounded: p.bounded,
 		stop:    make(chan struct{}),
 		done:    make(chan struct{}),
 	}
 	copy(s.methods, methods)
 	sort.Sort(s.methods)
+	if p.rateLimit != nil {
+		s.limits = make(map[*Method]*tokenBucket)
+		for i := range s.methods {
+			m := &s.methods[i]
+			if rate, burst := p.rateLimit(&m.Method); rate > 0 {
+				s.limits[m] = newTokenBucket(rate, burst)
+			}
+		}
+	}
+	if len(p.interceptors) > 0 {
+		ic := Chain(p.interceptors...)
+		for i := range s.methods {
+			s.methods[i].Impl = intercept(ic, &s.methods[i].Method, s.methods[i].Impl)
+		}
+	}
 	go s.dispatch()
 	return s
 }
@@ -61,9 +146,14 @@ func (s *server) dispatch() {
 	for {
 		select {
 		case cl := <-s.queue:
+			if err := cl.Ctx.Err(); err != nil {
+				// The caller gave up while the call was waiting.
+				cl.resolve(capnp.Struct{}, err)
+				continue
+			}
 			err := s.startCall(cl)
 			if err != nil {
-				cl.ans.Reject(err)
+				cl.resolve(capnp.Struct{}, err)
 			}
 		case <-s.stop:
 			return
@@ -83,22 +173,35 @@ func (s *server) startCall(cl *call) error {
 	}
 	acksig := newAckSignal()
 	opts := cl.Options.With([]capnp.CallOption{capnp.SetOptionValue(ackSignalKey, acksig)})
+	returned := make(chan struct{})
 	go func() {
-		err := cl.method.Impl(cl.Ctx, opts, cl.Params, results)
-		if err == nil {
-			cl.ans.Fulfill(results)
-		} else {
-			cl.ans.Reject(err)
+		// Recover here too, so that a panic fails the call instead of
+		// crashing the program.
+		err := Recover(cl.Ctx, &cl.method.Method, opts, cl.Params, results, cl.method.Impl)
+		cl.resolve(results, err)
+		close(returned)
+	}()
+	go func() {
+		// Reject the answer as soon as the caller cancels, so that the
+		// caller isn't held up by an implementation that ignores its
+		// context.  The implementation keeps running until it returns,
+		// but its results are never delivered.
+		select {
+		case <-cl.Ctx.Done():
+			cl.resolve(capnp.Struct{}, cl.Ctx.Err())
+		case <-cl.ans.Done():
 		}
 	}()
+	// Wait on the implementation rather than the answer, since the
+	// answer is rejected early if the call is canceled.
 	select {
 	case <-acksig.c:
-	case <-cl.ans.Done():
+	case <-returned:
 		// Implementation functions may not call Ack, which is fine for
 		// smaller functions.
-	case <-cl.Ctx.Done():
-		// Ideally, this would reject the answer immediately, but then you
-		// would race with the implementation function.
+	case <-s.stop:
+		// Don't hold up Close on an implementation that ignores its
+		// context.
 	}
 	return nil
 }
@@ -111,11 +214,27 @@ func (s *server) Call(cl *capnp.Call) capnp.Answer {
 			Err:    capnp.ErrUnimplemented,
 		})
 	}
+	if tb := s.limits[sm]; tb != nil && !tb.take() {
+		return capnp.ErrorAnswer(&capnp.MethodError{
+			Method: &cl.Method,
+			Err:    errRateLimited,
+		})
+	}
 	cl, err := cl.Copy(nil)
 	if err != nil {
 		return capnp.ErrorAnswer(err)
 	}
 	scall := newCall(cl, sm)
+	if s.bounded {
+		select {
+		case s.queue <- scall:
+			return &scall.ans
+		case <-s.stop:
+			return capnp.ErrorAnswer(errClosed)
+		default:
+			return capnp.ErrorAnswer(errOverloaded)
+		}
+	}
 	select {
 	case s.queue <- scall:
 		return &scall.ans
@@ -159,16 +278,111 @@ func Ack(opts capnp.CallOptions) {
 	}
 }
 
+// An Interceptor runs around a call to a method implementation.  It
+// is given the method being called and the call's arguments, and it
+// calls next to continue the call.  An interceptor may inspect or
+// modify the context, options, params, and results, or return an error
+// without calling next at all, as an authorization check might.
+//
+// Interceptors run in the same goroutine as the method implementation.
+type Interceptor func(ctx context.Context, m *capnp.Method, opts capnp.CallOptions, params, results capnp.Struct, next Func) error
+
+// Chain returns an interceptor that runs the given interceptors in
+// order, with the first interceptor outermost.
+func Chain(interceptors ...Interceptor) Interceptor {
+	return func(ctx context.Context, m *capnp.Method, opts capnp.CallOptions, params, results capnp.Struct, next Func) error {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			next = intercept(interceptors[i], m, next)
+		}
+		return next(ctx, opts, params, results)
+	}
+}
+
+// intercept returns a Func that calls ic with impl as the next Func.
+func intercept(ic Interceptor, m *capnp.Method, impl Func) Func {
+	return func(ctx context.Context, opts capnp.CallOptions, params, results capnp.Struct) error {
+		return ic(ctx, m, opts, params, results, impl)
+	}
+}
+
+// A PanicError is returned by Recover when a method implementation
+// panics.
+type PanicError struct {
+	Method *capnp.Method
+	Value  interface{}
+
+	// Stack is the formatted stack trace of the panicking goroutine.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%v: panic: %v", e.Method, e.Value)
+}
+
+// Recover is an interceptor that converts a panic in the method
+// implementation into a *PanicError, which the caller receives as a
+// failed call.  The server always recovers from panics in the methods
+// passed to New, so Recover is only needed to let other interceptors
+// observe the error.
+func Recover(ctx context.Context, m *capnp.Method, opts capnp.CallOptions, params, results capnp.Struct, next Func) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			stack := make([]byte, 16<<10)
+			stack = stack[:runtime.Stack(stack, false)]
+			err = &PanicError{Method: m, Value: v, Stack: stack}
+		}
+	}()
+	return next(ctx, opts, params, results)
+}
+
+// Timeout returns an interceptor that cancels the context of every
+// call after d.  See TimeoutFunc.
+func Timeout(d time.Duration) Interceptor {
+	return TimeoutFunc(func(*capnp.Method) time.Duration { return d })
+}
+
+// TimeoutFunc returns an interceptor that cancels a call's context
+// after the duration that f returns for the call's method.  If f
+// returns zero, the call has no timeout.
+//
+// Once the timeout passes, the implementation is expected to notice
+// that its context is done and return.  The interceptor still waits
+// for it, since the implementation may be writing to the results.
+func TimeoutFunc(f func(m *capnp.Method) time.Duration) Interceptor {
+	return func(ctx context.Context, m *capnp.Method, opts capnp.CallOptions, params, results capnp.Struct, next Func) error {
+		d := f(m)
+		if d <= 0 {
+			return next(ctx, opts, params, results)
+		}
+		ctx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+		return next(ctx, opts, params, results)
+	}
+}
+
 type call struct {
 	*capnp.Call
 	ans    fulfiller.Fulfiller
 	method *Method
+	once   sync.Once
 }
 
 func newCall(cl *capnp.Call, sm *Method) *call {
 	return &call{Call: cl, method: sm}
 }
 
+// resolve fulfills or rejects the call's answer.  Only the first call
+// to resolve has any effect.
+func (cl *call) resolve(results capnp.Struct, err error) {
+	cl.once.Do(func() {
+		if err == nil {
+			cl.ans.Fulfill(results)
+		} else {
+			cl.ans.Reject(err)
+		}
+	})
+}
+
 type sortedMethods []Method
 
 // find returns the method with the given ID or nil.
@@ -228,4 +442,49 @@ const (
 	ackSignalKey callOptionKey = iota + 1
 )
 
-var errClosed = errors.New("capnp: server closed")
+// tokenBucket is a rate limiter.  It holds up to burst tokens and gains
+// rate tokens per second.  Each call takes one token.
+type tokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// take removes a token from the bucket, reporting false if the bucket
+// is empty.
+func (tb *tokenBucket) take() bool {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	now := time.Now()
+	tb.tokens += now.Sub(tb.last).Seconds() * tb.rate
+	if tb.tokens > tb.burst {
+		tb.tokens = tb.burst
+	}
+	tb.last = now
+	if tb.tokens < 1 {
+		return false
+	}
+	tb.tokens--
+	return true
+}
+
+var (
+	errClosed      = &capnp.Error{Type: capnp.Disconnected, Err: errors.New("capnp: server closed")}
+	errOverloaded  = &capnp.Error{Type: capnp.Overloaded, Err: errors.New("capnp: server queue full")}
+	errRateLimited = &capnp.Error{Type: capnp.Overloaded, Err: errors.New("capnp: rate limit exceeded")}
+)
@@ -1,10 +1,14 @@
 package server_test
 
 import (
+	"errors"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"golang.org/x/net/context"
+	"zombiezen.com/go/capnproto2"
 	air "zombiezen.com/go/capnproto2/internal/aircraftlib"
 	. "zombiezen.com/go/capnproto2/server"
 )
@@ -108,3 +112,425 @@ func testCallOrder(t *testing.T, seq air.CallSequence) {
 	check(call3, 3)
 	check(call4, 4)
 }
+
+func TestServerInterceptors(t *testing.T) {
+	var log []string
+	record := func(name string) Interceptor {
+		return func(ctx context.Context, m *capnp.Method, opts capnp.CallOptions, params, results capnp.Struct, next Func) error {
+			log = append(log, name+" "+m.MethodName)
+			return next(ctx, opts, params, results)
+		}
+	}
+	echo := air.Echo_ServerToClient(echoImpl{}, Interceptors(record("a"), record("b")), Interceptors(record("c")))
+	defer echo.Client.Close()
+
+	result, err := echo.Echo(context.Background(), func(p air.Echo_echo_Params) error {
+		return p.SetIn("foo")
+	}).Struct()
+	if err != nil {
+		t.Fatal("echo.Echo():", err)
+	}
+	if out, _ := result.Out(); out != "foofoo" {
+		t.Errorf("echo.Echo() = %q; want \"foofoo\"", out)
+	}
+	want := []string{"a echo", "b echo", "c echo"}
+	if len(log) != len(want) {
+		t.Fatalf("interceptor log = %q; want %q", log, want)
+	}
+	for i := range want {
+		if log[i] != want[i] {
+			t.Errorf("interceptor log = %q; want %q", log, want)
+			break
+		}
+	}
+}
+
+func TestServerInterceptorReject(t *testing.T) {
+	errDenied := errors.New("permission denied")
+	deny := func(ctx context.Context, m *capnp.Method, opts capnp.CallOptions, params, results capnp.Struct, next Func) error {
+		return errDenied
+	}
+	echo := air.Echo_ServerToClient(echoImpl{}, Interceptors(deny))
+	defer echo.Client.Close()
+
+	_, err := echo.Echo(context.Background(), nil).Struct()
+	if err != errDenied {
+		t.Errorf("echo.Echo() error = %v; want %v", err, errDenied)
+	}
+}
+
+type panicEcho struct{}
+
+func (panicEcho) Echo(call air.Echo_echo) error {
+	panic("boom")
+}
+
+func TestRecover(t *testing.T) {
+	echo := air.Echo_ServerToClient(panicEcho{}, Interceptors(Recover))
+	defer echo.Client.Close()
+
+	_, err := echo.Echo(context.Background(), nil).Struct()
+	pe, ok := err.(*PanicError)
+	if !ok {
+		t.Fatalf("echo.Echo() error = %v; want *PanicError", err)
+	}
+	if pe.Value != "boom" {
+		t.Errorf("PanicError.Value = %v; want boom", pe.Value)
+	}
+	if pe.Method == nil || pe.Method.MethodName != "echo" {
+		t.Errorf("PanicError.Method = %v; want echo", pe.Method)
+	}
+	if len(pe.Stack) == 0 {
+		t.Error("PanicError.Stack is empty")
+	}
+}
+
+type deadlineEcho struct {
+	deadline time.Time
+	ok       bool
+}
+
+func (e *deadlineEcho) Echo(call air.Echo_echo) error {
+	e.deadline, e.ok = call.Ctx.Deadline()
+	<-call.Ctx.Done()
+	return call.Ctx.Err()
+}
+
+func TestTimeout(t *testing.T) {
+	impl := new(deadlineEcho)
+	echo := air.Echo_ServerToClient(impl, Interceptors(Timeout(10*time.Millisecond)))
+	defer echo.Client.Close()
+
+	start := time.Now()
+	_, err := echo.Echo(context.Background(), nil).Struct()
+	if err != context.DeadlineExceeded {
+		t.Errorf("echo.Echo() error = %v; want %v", err, context.DeadlineExceeded)
+	}
+	if !impl.ok {
+		t.Error("implementation context has no deadline")
+	} else if d := impl.deadline.Sub(start); d > time.Second {
+		t.Errorf("implementation deadline is %v after call; want about 10ms", d)
+	}
+}
+
+func TestTimeoutFuncZero(t *testing.T) {
+	echo := air.Echo_ServerToClient(echoImpl{}, Interceptors(TimeoutFunc(func(m *capnp.Method) time.Duration {
+		if m.MethodName == "echo" {
+			return 0
+		}
+		return time.Nanosecond
+	})))
+	defer echo.Client.Close()
+
+	if _, err := echo.Echo(context.Background(), nil).Struct(); err != nil {
+		t.Errorf("echo.Echo() error = %v; want <nil>", err)
+	}
+}
+
+func TestServerRecoversPanics(t *testing.T) {
+	echo := air.Echo_ServerToClient(panicEcho{})
+	defer echo.Client.Close()
+
+	_, err := echo.Echo(context.Background(), nil).Struct()
+	if _, ok := err.(*PanicError); !ok {
+		t.Fatalf("echo.Echo() error = %v; want *PanicError", err)
+	}
+	if typ := capnp.ErrorTypeOf(err); typ != capnp.Failed {
+		t.Errorf("ErrorTypeOf(%v) = %v; want failed", err, typ)
+	}
+
+	// The server keeps working after a panic.
+	_, err = echo.Echo(context.Background(), nil).Struct()
+	if _, ok := err.(*PanicError); !ok {
+		t.Errorf("second echo.Echo() error = %v; want *PanicError", err)
+	}
+}
+
+// blockEcho is an Echo that ignores its context and blocks until
+// release is closed.
+type blockEcho struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (e blockEcho) Echo(call air.Echo_echo) error {
+	close(e.started)
+	<-e.release
+	return nil
+}
+
+func TestServerCancel(t *testing.T) {
+	impl := blockEcho{started: make(chan struct{}), release: make(chan struct{})}
+	defer close(impl.release)
+	echo := air.Echo_ServerToClient(impl)
+	defer echo.Client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ans := echo.Echo(ctx, nil)
+	<-impl.started
+	cancel()
+	if _, err := ans.Struct(); err != context.Canceled {
+		t.Errorf("echo.Echo() error = %v; want %v", err, context.Canceled)
+	}
+}
+
+// orderEcho is an Echo that reports when each call starts and blocks
+// the first call until release is closed.
+type orderEcho struct {
+	started chan int
+	release chan struct{}
+
+	mu sync.Mutex
+	n  int
+}
+
+func (e *orderEcho) Echo(call air.Echo_echo) error {
+	e.mu.Lock()
+	n := e.n
+	e.n++
+	e.mu.Unlock()
+	e.started <- n
+	if n == 0 {
+		<-e.release
+	}
+	return nil
+}
+
+func TestServerCancelKeepsOrder(t *testing.T) {
+	impl := &orderEcho{started: make(chan int, 2), release: make(chan struct{})}
+	echo := air.Echo_ServerToClient(impl)
+	defer echo.Client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ans := echo.Echo(ctx, nil)
+	<-impl.started
+	cancel()
+	if _, err := ans.Struct(); err != context.Canceled {
+		t.Errorf("first echo.Echo() error = %v; want %v", err, context.Canceled)
+	}
+	second := make(chan error, 1)
+	go func() {
+		_, err := echo.Echo(context.Background(), nil).Struct()
+		second <- err
+	}()
+	select {
+	case <-impl.started:
+		t.Fatal("second call started before the canceled call returned")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(impl.release)
+	if n := <-impl.started; n != 1 {
+		t.Errorf("started call %d; want 1", n)
+	}
+	if err := <-second; err != nil {
+		t.Error("second echo.Echo() error:", err)
+	}
+}
+
+func TestServerCancelBeforeDispatch(t *testing.T) {
+	impl := blockEcho{started: make(chan struct{}), release: make(chan struct{})}
+	defer close(impl.release)
+	echo := air.Echo_ServerToClient(impl)
+	defer echo.Client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := echo.Echo(ctx, nil).Struct(); err != context.Canceled {
+		t.Errorf("echo.Echo() error = %v; want %v", err, context.Canceled)
+	}
+	select {
+	case <-impl.started:
+		t.Error("implementation called after context canceled")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestMock(t *testing.T) {
+	mock := &air.Echo_Mock{
+		EchoFunc: func(call air.Echo_echo) error {
+			in, err := call.Params.In()
+			if err != nil {
+				return err
+			}
+			return call.Results.SetOut(in + in)
+		},
+	}
+	echo := air.Echo_ServerToClient(mock)
+	defer echo.Client.Close()
+
+	for _, in := range []string{"foo", "bar"} {
+		res, err := echo.Echo(context.Background(), func(p air.Echo_echo_Params) error {
+			return p.SetIn(in)
+		}).Struct()
+		if err != nil {
+			t.Fatalf("echo.Echo(%q) error: %v", in, err)
+		}
+		if out, _ := res.Out(); out != in+in {
+			t.Errorf("echo.Echo(%q) = %q; want %q", in, out, in+in)
+		}
+	}
+	if n := mock.EchoCallCount(); n != 2 {
+		t.Errorf("EchoCallCount() = %d; want 2", n)
+	}
+	calls := mock.EchoCalls()
+	if len(calls) != 2 {
+		t.Fatalf("len(EchoCalls()) = %d; want 2", len(calls))
+	}
+	if in, _ := calls[1].In(); in != "bar" {
+		t.Errorf("EchoCalls()[1].In() = %q; want \"bar\"", in)
+	}
+}
+
+func TestMockRecordsCopy(t *testing.T) {
+	mock := &air.Echo_Mock{
+		EchoFunc: func(call air.Echo_echo) error {
+			// The recorded parameters must not see changes to the
+			// call's message.
+			return call.Params.SetIn("changed")
+		},
+	}
+	echo := air.Echo_ServerToClient(mock)
+	defer echo.Client.Close()
+
+	_, err := echo.Echo(context.Background(), func(p air.Echo_echo_Params) error {
+		return p.SetIn("foo")
+	}).Struct()
+	if err != nil {
+		t.Fatal("echo.Echo:", err)
+	}
+	calls := mock.EchoCalls()
+	if len(calls) != 1 {
+		t.Fatalf("len(EchoCalls()) = %d; want 1", len(calls))
+	}
+	if in, _ := calls[0].In(); in != "foo" {
+		t.Errorf("EchoCalls()[0].In() = %q; want \"foo\"", in)
+	}
+}
+
+func TestMockUnimplemented(t *testing.T) {
+	mock := new(air.CallSequence_Mock)
+	seq := air.CallSequence_ServerToClient(mock)
+	defer seq.Client.Close()
+
+	_, err := seq.GetNumber(context.Background(), nil).Struct()
+	if !capnp.IsUnimplemented(err) {
+		t.Errorf("GetNumber() error = %v; want unimplemented", err)
+	}
+	if n := mock.GetNumberCallCount(); n != 1 {
+		t.Errorf("GetNumberCallCount() = %d; want 1", n)
+	}
+}
+
+func TestMaxQueue(t *testing.T) {
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	mock := &air.Echo_Mock{
+		EchoFunc: func(call air.Echo_echo) error {
+			started <- struct{}{}
+			<-release
+			return nil
+		},
+	}
+	echo := air.Echo_ServerToClient(mock, MaxQueue(1))
+	defer echo.Client.Close()
+	ctx := context.Background()
+
+	// The first call occupies the server and the second fills the queue.
+	ans1 := echo.Echo(ctx, nil)
+	<-started
+	ans2 := echo.Echo(ctx, nil)
+	_, err := echo.Echo(ctx, nil).Struct()
+	if typ := capnp.ErrorTypeOf(err); typ != capnp.Overloaded {
+		t.Errorf("third call error = %v with type %v; want overloaded", err, typ)
+	}
+
+	close(release)
+	if _, err := ans1.Struct(); err != nil {
+		t.Error("first call:", err)
+	}
+	if _, err := ans2.Struct(); err != nil {
+		t.Error("second call:", err)
+	}
+	if n := mock.EchoCallCount(); n != 2 {
+		t.Errorf("EchoCallCount() = %d; want 2", n)
+	}
+}
+
+func TestMaxQueueZero(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	mock := &air.Echo_Mock{
+		EchoFunc: func(call air.Echo_echo) error {
+			started <- struct{}{}
+			<-release
+			return nil
+		},
+	}
+	echo := air.Echo_ServerToClient(mock, MaxQueue(0))
+	defer echo.Client.Close()
+	ctx := context.Background()
+
+	// The first call is rejected too if the server isn't ready for it
+	// yet, so retry until it starts.
+	var ans capnp.Answer
+	for ans == nil {
+		a := echo.Echo(ctx, nil).Answer()
+		select {
+		case <-started:
+			ans = a
+		case <-capnp.AnswerDone(a):
+			if _, err := a.Struct(); capnp.ErrorTypeOf(err) != capnp.Overloaded {
+				t.Fatal("first call:", err)
+			}
+		}
+	}
+	_, err := echo.Echo(ctx, nil).Struct()
+	if typ := capnp.ErrorTypeOf(err); typ != capnp.Overloaded {
+		t.Errorf("call during first call: error = %v with type %v; want overloaded", err, typ)
+	}
+	close(release)
+	if _, err := ans.Struct(); err != nil {
+		t.Error("first call:", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	mock := new(air.CallSequence_Mock)
+	mock.GetNumberFunc = func(call air.CallSequence_getNumber) error {
+		return nil
+	}
+	seq := air.CallSequence_ServerToClient(mock, RateLimit(0.001, 2))
+	defer seq.Client.Close()
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := seq.GetNumber(ctx, nil).Struct(); err != nil {
+			t.Errorf("call #%d: %v", i+1, err)
+		}
+	}
+	_, err := seq.GetNumber(ctx, nil).Struct()
+	if typ := capnp.ErrorTypeOf(err); typ != capnp.Overloaded {
+		t.Errorf("call #3 error = %v with type %v; want overloaded", err, typ)
+	} else if msg := err.Error(); !strings.HasSuffix(msg, ": capnp: rate limit exceeded") {
+		t.Errorf("call #3 error = %q; want suffix \": capnp: rate limit exceeded\"", msg)
+	}
+	if n := mock.GetNumberCallCount(); n != 2 {
+		t.Errorf("GetNumberCallCount() = %d; want 2", n)
+	}
+}
+
+func TestRateLimitFuncUnlimited(t *testing.T) {
+	seq := air.CallSequence_ServerToClient(new(callSeq), RateLimitFunc(func(m *capnp.Method) (float64, int) {
+		if m.MethodName == "getNumber" {
+			return 0, 0
+		}
+		return 0.001, 1
+	}))
+	defer seq.Client.Close()
+
+	for i := 0; i < 10; i++ {
+		if _, err := seq.GetNumber(context.Background(), nil).Struct(); err != nil {
+			t.Fatalf("call #%d: %v", i+1, err)
+		}
+	}
+}
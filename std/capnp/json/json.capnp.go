@@ -3,6 +3,7 @@
 package json
 
 import (
+	context "golang.org/x/net/context"
 	math "math"
 	strconv "strconv"
 	capnp "zombiezen.com/go/capnproto2"
@@ -228,6 +229,22 @@ func (p JsonValue_Promise) Struct() (JsonValue, error) {
 	return JsonValue{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p JsonValue_Promise) Await(ctx context.Context) (JsonValue, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return JsonValue{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p JsonValue_Promise) Then(f func(JsonValue, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(JsonValue{s}, err)
+	})
+}
+
 func (p JsonValue_Promise) Call() JsonValue_Call_Promise {
 	return JsonValue_Call_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -324,6 +341,22 @@ func (p JsonValue_Field_Promise) Struct() (JsonValue_Field, error) {
 	return JsonValue_Field{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p JsonValue_Field_Promise) Await(ctx context.Context) (JsonValue_Field, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return JsonValue_Field{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p JsonValue_Field_Promise) Then(f func(JsonValue_Field, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(JsonValue_Field{s}, err)
+	})
+}
+
 func (p JsonValue_Field_Promise) Value() JsonValue_Promise {
 	return JsonValue_Promise{Pipeline: p.Pipeline.GetPipeline(1)}
 }
@@ -418,6 +451,22 @@ func (p JsonValue_Call_Promise) Struct() (JsonValue_Call, error) {
 	return JsonValue_Call{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p JsonValue_Call_Promise) Await(ctx context.Context) (JsonValue_Call, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return JsonValue_Call{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p JsonValue_Call_Promise) Then(f func(JsonValue_Call, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(JsonValue_Call{s}, err)
+	})
+}
+
 const schema_8ef99297a43a5e34 = "x\xdat\x92AHTQ\x14\x86\xff\xff\xde7\xa3\xe2" +
 	"L\xf3^\xf3\xa4\x16\x89\x9b\xa2\x1235!\x18\x88)" +
 	"-\x09\x17\xe1m\xa8e\xf9\x1c_1r}O\xde8" +
@@ -42,9 +42,9 @@ type Persistent_Server interface {
 	Save(Persistent_save) error
 }
 
-func Persistent_ServerToClient(s Persistent_Server) Persistent {
+func Persistent_ServerToClient(s Persistent_Server, opts ...server.Option) Persistent {
 	c, _ := s.(server.Closer)
-	return Persistent{Client: server.New(Persistent_Methods(nil, s), c)}
+	return Persistent{Client: server.New(Persistent_Methods(nil, s), c, opts...)}
 }
 
 func Persistent_Methods(methods []server.Method, s Persistent_Server) []server.Method {
@@ -148,6 +148,22 @@ func (p Persistent_SaveParams_Promise) Struct() (Persistent_SaveParams, error) {
 	return Persistent_SaveParams{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Persistent_SaveParams_Promise) Await(ctx context.Context) (Persistent_SaveParams, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Persistent_SaveParams{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Persistent_SaveParams_Promise) Then(f func(Persistent_SaveParams, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Persistent_SaveParams{s}, err)
+	})
+}
+
 func (p Persistent_SaveParams_Promise) SealFor() *capnp.Pipeline {
 	return p.Pipeline.GetPipeline(0)
 }
@@ -223,6 +239,22 @@ func (p Persistent_SaveResults_Promise) Struct() (Persistent_SaveResults, error)
 	return Persistent_SaveResults{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Persistent_SaveResults_Promise) Await(ctx context.Context) (Persistent_SaveResults, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Persistent_SaveResults{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Persistent_SaveResults_Promise) Then(f func(Persistent_SaveResults, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Persistent_SaveResults{s}, err)
+	})
+}
+
 func (p Persistent_SaveResults_Promise) SturdyRef() *capnp.Pipeline {
 	return p.Pipeline.GetPipeline(0)
 }
@@ -279,9 +311,9 @@ type RealmGateway_Server interface {
 	Export(RealmGateway_export) error
 }
 
-func RealmGateway_ServerToClient(s RealmGateway_Server) RealmGateway {
+func RealmGateway_ServerToClient(s RealmGateway_Server, opts ...server.Option) RealmGateway {
 	c, _ := s.(server.Closer)
-	return RealmGateway{Client: server.New(RealmGateway_Methods(nil, s), c)}
+	return RealmGateway{Client: server.New(RealmGateway_Methods(nil, s), c, opts...)}
 }
 
 func RealmGateway_Methods(methods []server.Method, s RealmGateway_Server) []server.Method {
@@ -430,6 +462,22 @@ func (p RealmGateway_import_Params_Promise) Struct() (RealmGateway_import_Params
 	return RealmGateway_import_Params{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p RealmGateway_import_Params_Promise) Await(ctx context.Context) (RealmGateway_import_Params, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return RealmGateway_import_Params{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p RealmGateway_import_Params_Promise) Then(f func(RealmGateway_import_Params, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(RealmGateway_import_Params{s}, err)
+	})
+}
+
 func (p RealmGateway_import_Params_Promise) Cap() Persistent {
 	return Persistent{Client: p.Pipeline.GetPipeline(0).Client()}
 }
@@ -532,6 +580,22 @@ func (p RealmGateway_export_Params_Promise) Struct() (RealmGateway_export_Params
 	return RealmGateway_export_Params{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p RealmGateway_export_Params_Promise) Await(ctx context.Context) (RealmGateway_export_Params, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return RealmGateway_export_Params{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p RealmGateway_export_Params_Promise) Then(f func(RealmGateway_export_Params, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(RealmGateway_export_Params{s}, err)
+	})
+}
+
 func (p RealmGateway_export_Params_Promise) Cap() Persistent {
 	return Persistent{Client: p.Pipeline.GetPipeline(0).Client()}
 }
@@ -3,6 +3,7 @@
 package rpc
 
 import (
+	context "golang.org/x/net/context"
 	strconv "strconv"
 	capnp "zombiezen.com/go/capnproto2"
 	text "zombiezen.com/go/capnproto2/encoding/text"
@@ -524,6 +525,22 @@ func (p Message_Promise) Struct() (Message, error) {
 	return Message{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Message_Promise) Await(ctx context.Context) (Message, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Message{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Message_Promise) Then(f func(Message, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Message{s}, err)
+	})
+}
+
 func (p Message_Promise) Unimplemented() Message_Promise {
 	return Message_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -655,6 +672,22 @@ func (p Bootstrap_Promise) Struct() (Bootstrap, error) {
 	return Bootstrap{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Bootstrap_Promise) Await(ctx context.Context) (Bootstrap, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Bootstrap{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Bootstrap_Promise) Then(f func(Bootstrap, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Bootstrap{s}, err)
+	})
+}
+
 func (p Bootstrap_Promise) DeprecatedObjectId() *capnp.Pipeline {
 	return p.Pipeline.GetPipeline(0)
 }
@@ -850,6 +883,22 @@ func (p Call_Promise) Struct() (Call, error) {
 	return Call{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Call_Promise) Await(ctx context.Context) (Call, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Call{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Call_Promise) Then(f func(Call, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Call{s}, err)
+	})
+}
+
 func (p Call_Promise) Target() MessageTarget_Promise {
 	return MessageTarget_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -870,6 +919,22 @@ func (p Call_sendResultsTo_Promise) Struct() (Call_sendResultsTo, error) {
 	return Call_sendResultsTo{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Call_sendResultsTo_Promise) Await(ctx context.Context) (Call_sendResultsTo, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Call_sendResultsTo{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Call_sendResultsTo_Promise) Then(f func(Call_sendResultsTo, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Call_sendResultsTo{s}, err)
+	})
+}
+
 func (p Call_sendResultsTo_Promise) ThirdParty() *capnp.Pipeline {
 	return p.Pipeline.GetPipeline(2)
 }
@@ -1074,6 +1139,22 @@ func (p Return_Promise) Struct() (Return, error) {
 	return Return{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Return_Promise) Await(ctx context.Context) (Return, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Return{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Return_Promise) Then(f func(Return, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Return{s}, err)
+	})
+}
+
 func (p Return_Promise) Results() Payload_Promise {
 	return Payload_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -1148,6 +1229,22 @@ func (p Finish_Promise) Struct() (Finish, error) {
 	return Finish{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Finish_Promise) Await(ctx context.Context) (Finish, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Finish{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Finish_Promise) Then(f func(Finish, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Finish{s}, err)
+	})
+}
+
 type Resolve struct{ capnp.Struct }
 type Resolve_Which uint16
 
@@ -1283,6 +1380,22 @@ func (p Resolve_Promise) Struct() (Resolve, error) {
 	return Resolve{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Resolve_Promise) Await(ctx context.Context) (Resolve, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Resolve{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Resolve_Promise) Then(f func(Resolve, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Resolve{s}, err)
+	})
+}
+
 func (p Resolve_Promise) Cap() CapDescriptor_Promise {
 	return CapDescriptor_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -1353,6 +1466,22 @@ func (p Release_Promise) Struct() (Release, error) {
 	return Release{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Release_Promise) Await(ctx context.Context) (Release, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Release{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Release_Promise) Then(f func(Release, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Release{s}, err)
+	})
+}
+
 type Disembargo struct{ capnp.Struct }
 type Disembargo_context Disembargo
 type Disembargo_context_Which uint16
@@ -1486,6 +1615,22 @@ func (p Disembargo_Promise) Struct() (Disembargo, error) {
 	return Disembargo{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Disembargo_Promise) Await(ctx context.Context) (Disembargo, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Disembargo{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Disembargo_Promise) Then(f func(Disembargo, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Disembargo{s}, err)
+	})
+}
+
 func (p Disembargo_Promise) Target() MessageTarget_Promise {
 	return MessageTarget_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -1502,6 +1647,22 @@ func (p Disembargo_context_Promise) Struct() (Disembargo_context, error) {
 	return Disembargo_context{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Disembargo_context_Promise) Await(ctx context.Context) (Disembargo_context, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Disembargo_context{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Disembargo_context_Promise) Then(f func(Disembargo_context, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Disembargo_context{s}, err)
+	})
+}
+
 type Provide struct{ capnp.Struct }
 
 // Provide_TypeID is the unique identifier for the type Provide.
@@ -1602,6 +1763,22 @@ func (p Provide_Promise) Struct() (Provide, error) {
 	return Provide{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Provide_Promise) Await(ctx context.Context) (Provide, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Provide{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Provide_Promise) Then(f func(Provide, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Provide{s}, err)
+	})
+}
+
 func (p Provide_Promise) Target() MessageTarget_Promise {
 	return MessageTarget_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -1693,6 +1870,22 @@ func (p Accept_Promise) Struct() (Accept, error) {
 	return Accept{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Accept_Promise) Await(ctx context.Context) (Accept, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Accept{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Accept_Promise) Then(f func(Accept, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Accept{s}, err)
+	})
+}
+
 func (p Accept_Promise) Provision() *capnp.Pipeline {
 	return p.Pipeline.GetPipeline(0)
 }
@@ -1797,6 +1990,22 @@ func (p Join_Promise) Struct() (Join, error) {
 	return Join{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Join_Promise) Await(ctx context.Context) (Join, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Join{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Join_Promise) Then(f func(Join, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Join{s}, err)
+	})
+}
+
 func (p Join_Promise) Target() MessageTarget_Promise {
 	return MessageTarget_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -1911,6 +2120,22 @@ func (p MessageTarget_Promise) Struct() (MessageTarget, error) {
 	return MessageTarget{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p MessageTarget_Promise) Await(ctx context.Context) (MessageTarget, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return MessageTarget{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p MessageTarget_Promise) Then(f func(MessageTarget, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(MessageTarget{s}, err)
+	})
+}
+
 func (p MessageTarget_Promise) PromisedAnswer() PromisedAnswer_Promise {
 	return PromisedAnswer_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -2007,6 +2232,22 @@ func (p Payload_Promise) Struct() (Payload, error) {
 	return Payload{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Payload_Promise) Await(ctx context.Context) (Payload, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Payload{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Payload_Promise) Then(f func(Payload, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Payload{s}, err)
+	})
+}
+
 func (p Payload_Promise) Content() *capnp.Pipeline {
 	return p.Pipeline.GetPipeline(0)
 }
@@ -2182,6 +2423,22 @@ func (p CapDescriptor_Promise) Struct() (CapDescriptor, error) {
 	return CapDescriptor{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p CapDescriptor_Promise) Await(ctx context.Context) (CapDescriptor, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return CapDescriptor{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p CapDescriptor_Promise) Then(f func(CapDescriptor, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(CapDescriptor{s}, err)
+	})
+}
+
 func (p CapDescriptor_Promise) ReceiverAnswer() PromisedAnswer_Promise {
 	return PromisedAnswer_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -2269,6 +2526,22 @@ func (p PromisedAnswer_Promise) Struct() (PromisedAnswer, error) {
 	return PromisedAnswer{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p PromisedAnswer_Promise) Await(ctx context.Context) (PromisedAnswer, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return PromisedAnswer{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p PromisedAnswer_Promise) Then(f func(PromisedAnswer, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(PromisedAnswer{s}, err)
+	})
+}
+
 type PromisedAnswer_Op struct{ capnp.Struct }
 type PromisedAnswer_Op_Which uint16
 
@@ -2354,6 +2627,22 @@ func (p PromisedAnswer_Op_Promise) Struct() (PromisedAnswer_Op, error) {
 	return PromisedAnswer_Op{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p PromisedAnswer_Op_Promise) Await(ctx context.Context) (PromisedAnswer_Op, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return PromisedAnswer_Op{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p PromisedAnswer_Op_Promise) Then(f func(PromisedAnswer_Op, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(PromisedAnswer_Op{s}, err)
+	})
+}
+
 type ThirdPartyCapDescriptor struct{ capnp.Struct }
 
 // ThirdPartyCapDescriptor_TypeID is the unique identifier for the type ThirdPartyCapDescriptor.
@@ -2433,6 +2722,22 @@ func (p ThirdPartyCapDescriptor_Promise) Struct() (ThirdPartyCapDescriptor, erro
 	return ThirdPartyCapDescriptor{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p ThirdPartyCapDescriptor_Promise) Await(ctx context.Context) (ThirdPartyCapDescriptor, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return ThirdPartyCapDescriptor{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p ThirdPartyCapDescriptor_Promise) Then(f func(ThirdPartyCapDescriptor, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(ThirdPartyCapDescriptor{s}, err)
+	})
+}
+
 func (p ThirdPartyCapDescriptor_Promise) Id() *capnp.Pipeline {
 	return p.Pipeline.GetPipeline(0)
 }
@@ -2526,6 +2831,22 @@ func (p Exception_Promise) Struct() (Exception, error) {
 	return Exception{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Exception_Promise) Await(ctx context.Context) (Exception, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Exception{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Exception_Promise) Then(f func(Exception, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Exception{s}, err)
+	})
+}
+
 type Exception_Type uint16
 
 // Exception_Type_TypeID is the unique identifier for the type Exception_Type.
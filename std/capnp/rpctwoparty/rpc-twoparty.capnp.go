@@ -3,6 +3,7 @@
 package rpctwoparty
 
 import (
+	context "golang.org/x/net/context"
 	capnp "zombiezen.com/go/capnproto2"
 	text "zombiezen.com/go/capnproto2/encoding/text"
 	schemas "zombiezen.com/go/capnproto2/schemas"
@@ -117,6 +118,22 @@ func (p VatId_Promise) Struct() (VatId, error) {
 	return VatId{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p VatId_Promise) Await(ctx context.Context) (VatId, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return VatId{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p VatId_Promise) Then(f func(VatId, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(VatId{s}, err)
+	})
+}
+
 type ProvisionId struct{ capnp.Struct }
 
 // ProvisionId_TypeID is the unique identifier for the type ProvisionId.
@@ -171,6 +188,22 @@ func (p ProvisionId_Promise) Struct() (ProvisionId, error) {
 	return ProvisionId{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p ProvisionId_Promise) Await(ctx context.Context) (ProvisionId, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return ProvisionId{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p ProvisionId_Promise) Then(f func(ProvisionId, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(ProvisionId{s}, err)
+	})
+}
+
 type RecipientId struct{ capnp.Struct }
 
 // RecipientId_TypeID is the unique identifier for the type RecipientId.
@@ -217,6 +250,22 @@ func (p RecipientId_Promise) Struct() (RecipientId, error) {
 	return RecipientId{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p RecipientId_Promise) Await(ctx context.Context) (RecipientId, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return RecipientId{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p RecipientId_Promise) Then(f func(RecipientId, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(RecipientId{s}, err)
+	})
+}
+
 type ThirdPartyCapId struct{ capnp.Struct }
 
 // ThirdPartyCapId_TypeID is the unique identifier for the type ThirdPartyCapId.
@@ -265,6 +314,22 @@ func (p ThirdPartyCapId_Promise) Struct() (ThirdPartyCapId, error) {
 	return ThirdPartyCapId{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p ThirdPartyCapId_Promise) Await(ctx context.Context) (ThirdPartyCapId, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return ThirdPartyCapId{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p ThirdPartyCapId_Promise) Then(f func(ThirdPartyCapId, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(ThirdPartyCapId{s}, err)
+	})
+}
+
 type JoinKeyPart struct{ capnp.Struct }
 
 // JoinKeyPart_TypeID is the unique identifier for the type JoinKeyPart.
@@ -335,6 +400,22 @@ func (p JoinKeyPart_Promise) Struct() (JoinKeyPart, error) {
 	return JoinKeyPart{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p JoinKeyPart_Promise) Await(ctx context.Context) (JoinKeyPart, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return JoinKeyPart{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p JoinKeyPart_Promise) Then(f func(JoinKeyPart, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(JoinKeyPart{s}, err)
+	})
+}
+
 type JoinResult struct{ capnp.Struct }
 
 // JoinResult_TypeID is the unique identifier for the type JoinResult.
@@ -418,6 +499,22 @@ func (p JoinResult_Promise) Struct() (JoinResult, error) {
 	return JoinResult{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p JoinResult_Promise) Await(ctx context.Context) (JoinResult, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return JoinResult{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p JoinResult_Promise) Then(f func(JoinResult, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(JoinResult{s}, err)
+	})
+}
+
 func (p JoinResult_Promise) Cap() *capnp.Pipeline {
 	return p.Pipeline.GetPipeline(0)
 }
@@ -3,6 +3,7 @@
 package schema
 
 import (
+	context "golang.org/x/net/context"
 	math "math"
 	strconv "strconv"
 	capnp "zombiezen.com/go/capnproto2"
@@ -573,6 +574,22 @@ func (p Node_Promise) Struct() (Node, error) {
 	return Node{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Node_Promise) Await(ctx context.Context) (Node, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Node{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Node_Promise) Then(f func(Node, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Node{s}, err)
+	})
+}
+
 func (p Node_Promise) StructNode() Node_structNode_Promise { return Node_structNode_Promise{p.Pipeline} }
 
 // Node_structNode_Promise is a wrapper for a Node_structNode promised by a client call.
@@ -583,6 +600,22 @@ func (p Node_structNode_Promise) Struct() (Node_structNode, error) {
 	return Node_structNode{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Node_structNode_Promise) Await(ctx context.Context) (Node_structNode, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Node_structNode{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Node_structNode_Promise) Then(f func(Node_structNode, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Node_structNode{s}, err)
+	})
+}
+
 func (p Node_Promise) Enum() Node_enum_Promise { return Node_enum_Promise{p.Pipeline} }
 
 // Node_enum_Promise is a wrapper for a Node_enum promised by a client call.
@@ -593,6 +626,22 @@ func (p Node_enum_Promise) Struct() (Node_enum, error) {
 	return Node_enum{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Node_enum_Promise) Await(ctx context.Context) (Node_enum, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Node_enum{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Node_enum_Promise) Then(f func(Node_enum, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Node_enum{s}, err)
+	})
+}
+
 func (p Node_Promise) Interface() Node_interface_Promise { return Node_interface_Promise{p.Pipeline} }
 
 // Node_interface_Promise is a wrapper for a Node_interface promised by a client call.
@@ -603,6 +652,22 @@ func (p Node_interface_Promise) Struct() (Node_interface, error) {
 	return Node_interface{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Node_interface_Promise) Await(ctx context.Context) (Node_interface, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Node_interface{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Node_interface_Promise) Then(f func(Node_interface, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Node_interface{s}, err)
+	})
+}
+
 func (p Node_Promise) Const() Node_const_Promise { return Node_const_Promise{p.Pipeline} }
 
 // Node_const_Promise is a wrapper for a Node_const promised by a client call.
@@ -613,6 +678,22 @@ func (p Node_const_Promise) Struct() (Node_const, error) {
 	return Node_const{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Node_const_Promise) Await(ctx context.Context) (Node_const, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Node_const{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Node_const_Promise) Then(f func(Node_const, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Node_const{s}, err)
+	})
+}
+
 func (p Node_const_Promise) Type() Type_Promise {
 	return Type_Promise{Pipeline: p.Pipeline.GetPipeline(3)}
 }
@@ -631,6 +712,22 @@ func (p Node_annotation_Promise) Struct() (Node_annotation, error) {
 	return Node_annotation{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Node_annotation_Promise) Await(ctx context.Context) (Node_annotation, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Node_annotation{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Node_annotation_Promise) Then(f func(Node_annotation, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Node_annotation{s}, err)
+	})
+}
+
 func (p Node_annotation_Promise) Type() Type_Promise {
 	return Type_Promise{Pipeline: p.Pipeline.GetPipeline(3)}
 }
@@ -695,6 +792,22 @@ func (p Node_Parameter_Promise) Struct() (Node_Parameter, error) {
 	return Node_Parameter{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Node_Parameter_Promise) Await(ctx context.Context) (Node_Parameter, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Node_Parameter{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Node_Parameter_Promise) Then(f func(Node_Parameter, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Node_Parameter{s}, err)
+	})
+}
+
 type Node_NestedNode struct{ capnp.Struct }
 
 // Node_NestedNode_TypeID is the unique identifier for the type Node_NestedNode.
@@ -765,6 +878,22 @@ func (p Node_NestedNode_Promise) Struct() (Node_NestedNode, error) {
 	return Node_NestedNode{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Node_NestedNode_Promise) Await(ctx context.Context) (Node_NestedNode, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Node_NestedNode{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Node_NestedNode_Promise) Then(f func(Node_NestedNode, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Node_NestedNode{s}, err)
+	})
+}
+
 type Field struct{ capnp.Struct }
 type Field_slot Field
 type Field_group Field
@@ -1014,6 +1143,22 @@ func (p Field_Promise) Struct() (Field, error) {
 	return Field{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Field_Promise) Await(ctx context.Context) (Field, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Field{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Field_Promise) Then(f func(Field, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Field{s}, err)
+	})
+}
+
 func (p Field_Promise) Slot() Field_slot_Promise { return Field_slot_Promise{p.Pipeline} }
 
 // Field_slot_Promise is a wrapper for a Field_slot promised by a client call.
@@ -1024,6 +1169,22 @@ func (p Field_slot_Promise) Struct() (Field_slot, error) {
 	return Field_slot{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Field_slot_Promise) Await(ctx context.Context) (Field_slot, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Field_slot{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Field_slot_Promise) Then(f func(Field_slot, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Field_slot{s}, err)
+	})
+}
+
 func (p Field_slot_Promise) Type() Type_Promise {
 	return Type_Promise{Pipeline: p.Pipeline.GetPipeline(2)}
 }
@@ -1042,6 +1203,22 @@ func (p Field_group_Promise) Struct() (Field_group, error) {
 	return Field_group{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Field_group_Promise) Await(ctx context.Context) (Field_group, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Field_group{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Field_group_Promise) Then(f func(Field_group, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Field_group{s}, err)
+	})
+}
+
 func (p Field_Promise) Ordinal() Field_ordinal_Promise { return Field_ordinal_Promise{p.Pipeline} }
 
 // Field_ordinal_Promise is a wrapper for a Field_ordinal promised by a client call.
@@ -1052,6 +1229,22 @@ func (p Field_ordinal_Promise) Struct() (Field_ordinal, error) {
 	return Field_ordinal{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Field_ordinal_Promise) Await(ctx context.Context) (Field_ordinal, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Field_ordinal{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Field_ordinal_Promise) Then(f func(Field_ordinal, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Field_ordinal{s}, err)
+	})
+}
+
 type Enumerant struct{ capnp.Struct }
 
 // Enumerant_TypeID is the unique identifier for the type Enumerant.
@@ -1145,6 +1338,22 @@ func (p Enumerant_Promise) Struct() (Enumerant, error) {
 	return Enumerant{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Enumerant_Promise) Await(ctx context.Context) (Enumerant, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Enumerant{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Enumerant_Promise) Then(f func(Enumerant, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Enumerant{s}, err)
+	})
+}
+
 type Superclass struct{ capnp.Struct }
 
 // Superclass_TypeID is the unique identifier for the type Superclass.
@@ -1219,6 +1428,22 @@ func (p Superclass_Promise) Struct() (Superclass, error) {
 	return Superclass{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Superclass_Promise) Await(ctx context.Context) (Superclass, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Superclass{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Superclass_Promise) Then(f func(Superclass, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Superclass{s}, err)
+	})
+}
+
 func (p Superclass_Promise) Brand() Brand_Promise {
 	return Brand_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -1407,6 +1632,22 @@ func (p Method_Promise) Struct() (Method, error) {
 	return Method{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Method_Promise) Await(ctx context.Context) (Method, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Method{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Method_Promise) Then(f func(Method, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Method{s}, err)
+	})
+}
+
 func (p Method_Promise) ParamBrand() Brand_Promise {
 	return Brand_Promise{Pipeline: p.Pipeline.GetPipeline(2)}
 }
@@ -1879,6 +2120,22 @@ func (p Type_Promise) Struct() (Type, error) {
 	return Type{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Type_Promise) Await(ctx context.Context) (Type, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Type{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Type_Promise) Then(f func(Type, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Type{s}, err)
+	})
+}
+
 func (p Type_Promise) List() Type_list_Promise { return Type_list_Promise{p.Pipeline} }
 
 // Type_list_Promise is a wrapper for a Type_list promised by a client call.
@@ -1889,6 +2146,22 @@ func (p Type_list_Promise) Struct() (Type_list, error) {
 	return Type_list{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Type_list_Promise) Await(ctx context.Context) (Type_list, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Type_list{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Type_list_Promise) Then(f func(Type_list, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Type_list{s}, err)
+	})
+}
+
 func (p Type_list_Promise) ElementType() Type_Promise {
 	return Type_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -1903,6 +2176,22 @@ func (p Type_enum_Promise) Struct() (Type_enum, error) {
 	return Type_enum{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Type_enum_Promise) Await(ctx context.Context) (Type_enum, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Type_enum{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Type_enum_Promise) Then(f func(Type_enum, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Type_enum{s}, err)
+	})
+}
+
 func (p Type_enum_Promise) Brand() Brand_Promise {
 	return Brand_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -1917,6 +2206,22 @@ func (p Type_structType_Promise) Struct() (Type_structType, error) {
 	return Type_structType{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Type_structType_Promise) Await(ctx context.Context) (Type_structType, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Type_structType{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Type_structType_Promise) Then(f func(Type_structType, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Type_structType{s}, err)
+	})
+}
+
 func (p Type_structType_Promise) Brand() Brand_Promise {
 	return Brand_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -1931,6 +2236,22 @@ func (p Type_interface_Promise) Struct() (Type_interface, error) {
 	return Type_interface{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Type_interface_Promise) Await(ctx context.Context) (Type_interface, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Type_interface{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Type_interface_Promise) Then(f func(Type_interface, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Type_interface{s}, err)
+	})
+}
+
 func (p Type_interface_Promise) Brand() Brand_Promise {
 	return Brand_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -1945,6 +2266,22 @@ func (p Type_anyPointer_Promise) Struct() (Type_anyPointer, error) {
 	return Type_anyPointer{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Type_anyPointer_Promise) Await(ctx context.Context) (Type_anyPointer, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Type_anyPointer{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Type_anyPointer_Promise) Then(f func(Type_anyPointer, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Type_anyPointer{s}, err)
+	})
+}
+
 func (p Type_anyPointer_Promise) Unconstrained() Type_anyPointer_unconstrained_Promise {
 	return Type_anyPointer_unconstrained_Promise{p.Pipeline}
 }
@@ -1957,6 +2294,22 @@ func (p Type_anyPointer_unconstrained_Promise) Struct() (Type_anyPointer_unconst
 	return Type_anyPointer_unconstrained{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Type_anyPointer_unconstrained_Promise) Await(ctx context.Context) (Type_anyPointer_unconstrained, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Type_anyPointer_unconstrained{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Type_anyPointer_unconstrained_Promise) Then(f func(Type_anyPointer_unconstrained, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Type_anyPointer_unconstrained{s}, err)
+	})
+}
+
 func (p Type_anyPointer_Promise) Parameter() Type_anyPointer_parameter_Promise {
 	return Type_anyPointer_parameter_Promise{p.Pipeline}
 }
@@ -1969,6 +2322,22 @@ func (p Type_anyPointer_parameter_Promise) Struct() (Type_anyPointer_parameter,
 	return Type_anyPointer_parameter{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Type_anyPointer_parameter_Promise) Await(ctx context.Context) (Type_anyPointer_parameter, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Type_anyPointer_parameter{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Type_anyPointer_parameter_Promise) Then(f func(Type_anyPointer_parameter, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Type_anyPointer_parameter{s}, err)
+	})
+}
+
 func (p Type_anyPointer_Promise) ImplicitMethodParameter() Type_anyPointer_implicitMethodParameter_Promise {
 	return Type_anyPointer_implicitMethodParameter_Promise{p.Pipeline}
 }
@@ -1981,6 +2350,22 @@ func (p Type_anyPointer_implicitMethodParameter_Promise) Struct() (Type_anyPoint
 	return Type_anyPointer_implicitMethodParameter{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Type_anyPointer_implicitMethodParameter_Promise) Await(ctx context.Context) (Type_anyPointer_implicitMethodParameter, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Type_anyPointer_implicitMethodParameter{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Type_anyPointer_implicitMethodParameter_Promise) Then(f func(Type_anyPointer_implicitMethodParameter, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Type_anyPointer_implicitMethodParameter{s}, err)
+	})
+}
+
 type Brand struct{ capnp.Struct }
 
 // Brand_TypeID is the unique identifier for the type Brand.
@@ -2047,6 +2432,22 @@ func (p Brand_Promise) Struct() (Brand, error) {
 	return Brand{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Brand_Promise) Await(ctx context.Context) (Brand, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Brand{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Brand_Promise) Then(f func(Brand, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Brand{s}, err)
+	})
+}
+
 type Brand_Scope struct{ capnp.Struct }
 type Brand_Scope_Which uint16
 
@@ -2152,6 +2553,22 @@ func (p Brand_Scope_Promise) Struct() (Brand_Scope, error) {
 	return Brand_Scope{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Brand_Scope_Promise) Await(ctx context.Context) (Brand_Scope, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Brand_Scope{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Brand_Scope_Promise) Then(f func(Brand_Scope, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Brand_Scope{s}, err)
+	})
+}
+
 type Brand_Binding struct{ capnp.Struct }
 type Brand_Binding_Which uint16
 
@@ -2249,6 +2666,22 @@ func (p Brand_Binding_Promise) Struct() (Brand_Binding, error) {
 	return Brand_Binding{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Brand_Binding_Promise) Await(ctx context.Context) (Brand_Binding, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Brand_Binding{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Brand_Binding_Promise) Then(f func(Brand_Binding, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Brand_Binding{s}, err)
+	})
+}
+
 func (p Brand_Binding_Promise) Type() Type_Promise {
 	return Type_Promise{Pipeline: p.Pipeline.GetPipeline(0)}
 }
@@ -2603,6 +3036,22 @@ func (p Value_Promise) Struct() (Value, error) {
 	return Value{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Value_Promise) Await(ctx context.Context) (Value, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Value{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Value_Promise) Then(f func(Value, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Value{s}, err)
+	})
+}
+
 func (p Value_Promise) List() *capnp.Pipeline {
 	return p.Pipeline.GetPipeline(0)
 }
@@ -2714,6 +3163,22 @@ func (p Annotation_Promise) Struct() (Annotation, error) {
 	return Annotation{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p Annotation_Promise) Await(ctx context.Context) (Annotation, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return Annotation{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p Annotation_Promise) Then(f func(Annotation, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(Annotation{s}, err)
+	})
+}
+
 func (p Annotation_Promise) Brand() Brand_Promise {
 	return Brand_Promise{Pipeline: p.Pipeline.GetPipeline(1)}
 }
@@ -2902,6 +3367,22 @@ func (p CodeGeneratorRequest_Promise) Struct() (CodeGeneratorRequest, error) {
 	return CodeGeneratorRequest{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p CodeGeneratorRequest_Promise) Await(ctx context.Context) (CodeGeneratorRequest, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return CodeGeneratorRequest{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p CodeGeneratorRequest_Promise) Then(f func(CodeGeneratorRequest, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(CodeGeneratorRequest{s}, err)
+	})
+}
+
 type CodeGeneratorRequest_RequestedFile struct{ capnp.Struct }
 
 // CodeGeneratorRequest_RequestedFile_TypeID is the unique identifier for the type CodeGeneratorRequest_RequestedFile.
@@ -2999,6 +3480,22 @@ func (p CodeGeneratorRequest_RequestedFile_Promise) Struct() (CodeGeneratorReque
 	return CodeGeneratorRequest_RequestedFile{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p CodeGeneratorRequest_RequestedFile_Promise) Await(ctx context.Context) (CodeGeneratorRequest_RequestedFile, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return CodeGeneratorRequest_RequestedFile{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p CodeGeneratorRequest_RequestedFile_Promise) Then(f func(CodeGeneratorRequest_RequestedFile, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(CodeGeneratorRequest_RequestedFile{s}, err)
+	})
+}
+
 type CodeGeneratorRequest_RequestedFile_Import struct{ capnp.Struct }
 
 // CodeGeneratorRequest_RequestedFile_Import_TypeID is the unique identifier for the type CodeGeneratorRequest_RequestedFile_Import.
@@ -3071,6 +3568,22 @@ func (p CodeGeneratorRequest_RequestedFile_Import_Promise) Struct() (CodeGenerat
 	return CodeGeneratorRequest_RequestedFile_Import{s}, err
 }
 
+// Await waits until the promise is resolved or ctx is done, returning
+// ctx.Err() in the latter case.  It does not cancel the call.
+func (p CodeGeneratorRequest_RequestedFile_Import_Promise) Await(ctx context.Context) (CodeGeneratorRequest_RequestedFile_Import, error) {
+	s, err := p.Pipeline.Await(ctx)
+	return CodeGeneratorRequest_RequestedFile_Import{s}, err
+}
+
+// Then calls f with the result once the promise is resolved.  f is
+// called from the goroutine that resolves the promise, so it must not
+// block or make calls.
+func (p CodeGeneratorRequest_RequestedFile_Import_Promise) Then(f func(CodeGeneratorRequest_RequestedFile_Import, error)) {
+	p.Pipeline.Then(func(s capnp.Struct, err error) {
+		f(CodeGeneratorRequest_RequestedFile_Import{s}, err)
+	})
+}
+
 const schema_a93fc509624c72d9 = "x\xda\xacY}\x94\x14\xd5\x95\xbf\xf7U\x7f\xccG\x17" +
 	"\xdd5\xaf\x10\x8784\xa8\xa8\x10\x9d\xc0\x0c\xb08\x91" +
 	"\x1d\x18\x18\x0c,\x90)\x1aP8\xeb\x095\xd35L" +
@@ -0,0 +1,314 @@
+package capnp
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// TrackTraversal makes the message record where each read is charged
+// against its traversal limit, so that TraversalReport can show which
+// parts of the message used up the limit.  Reads made before
+// TrackTraversal is called are not recorded.  Tracking makes reads
+// slower, so it is meant for debugging.
+func (m *Message) TrackTraversal() {
+	m.mu.Lock()
+	if m.tracker() == nil {
+		m.trav.Store(new(traversalTracker))
+	}
+	m.mu.Unlock()
+}
+
+// tracker returns the message's traversal tracker or nil if traversal
+// isn't being tracked.
+func (m *Message) tracker() *traversalTracker {
+	t, _ := m.trav.Load().(*traversalTracker)
+	return t
+}
+
+// A FieldNamer supplies schema information for a TraversalReport.
+type FieldNamer interface {
+	// PointerField returns the name of the field stored in pointer i of
+	// s, a struct of type typeID, along with the ID of the struct type
+	// that the field points to, either directly or as list elements.
+	// s can be used to find which member of a union is set.  target is
+	// zero if the field doesn't hold structs.  ok is false if the namer
+	// doesn't know the field.
+	PointerField(typeID uint64, s Struct, i uint16) (name string, target uint64, ok bool)
+}
+
+// A TraversalReport breaks down the traversal limit charges made while
+// reading a message by the pointer that was read.
+type TraversalReport struct {
+	// Entries lists the pointers that were read, most bytes first.
+	Entries []TraversalEntry
+
+	// Total is the number of bytes charged in Entries.
+	Total uint64
+
+	// Remaining is the number of bytes left in the traversal limit.
+	Remaining uint64
+}
+
+// A TraversalEntry is the traversal limit charged for reading one
+// pointer in a message.
+type TraversalEntry struct {
+	// Path locates the pointer, such as "nodes[3].displayName".  It is
+	// empty for the root pointer.  Fields are named "<pointer N>" when
+	// the schema isn't known, and pointers that can't be reached from
+	// the root are named by their segment and address.
+	Path string
+
+	// Reads is the number of times the pointer was read.
+	Reads int
+
+	// Bytes is the number of bytes charged for all the reads.
+	Bytes uint64
+
+	// Rejected is the number of reads that failed because the
+	// traversal limit was reached.
+	Rejected int
+}
+
+// TraversalReport reports the traversal limit charges made since
+// TrackTraversal was called.  rootType is the struct type ID of the
+// root and names supplies field names; either may be zero or nil, in
+// which case pointers are named by their index.
+func (m *Message) TraversalReport(rootType uint64, names FieldNamer) *TraversalReport {
+	r := &TraversalReport{Remaining: atomic.LoadUint64(&m.ReadLimiter().limit)}
+	t := m.tracker()
+	if t == nil {
+		return r
+	}
+	w := &traversalWalk{
+		names:   names,
+		charges: t.snapshot(),
+		visited: make(map[pointerSlot]bool),
+		report:  r,
+	}
+	if s, err := m.Segment(0); err == nil && len(s.data) >= int(wordSize) {
+		w.pointer(s, 0, nil, rootType, m.depthLimit())
+	}
+	for slot, c := range w.charges {
+		w.add(fmt.Sprintf("<segment %d, %v>", slot.seg, slot.off), c)
+	}
+	sort.Sort(traversalEntries(r.Entries))
+	return r
+}
+
+// String formats the report as a table.
+func (r *TraversalReport) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "traversed %d bytes, %d bytes remaining\n", r.Total, r.Remaining)
+	for _, e := range r.Entries {
+		path := e.Path
+		if path == "" {
+			path = "<root>"
+		}
+		fmt.Fprintf(&buf, "%10d bytes %6d reads  %s", e.Bytes, e.Reads, path)
+		if e.Rejected > 0 {
+			fmt.Fprintf(&buf, " (%d rejected)", e.Rejected)
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+type traversalEntries []TraversalEntry
+
+func (e traversalEntries) Len() int      { return len(e) }
+func (e traversalEntries) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+
+func (e traversalEntries) Less(i, j int) bool {
+	if e[i].Bytes != e[j].Bytes {
+		return e[i].Bytes > e[j].Bytes
+	}
+	return e[i].Path < e[j].Path
+}
+
+// canRead charges sz bytes against the message's traversal limit for
+// reading the pointer at slot.
+func (m *Message) canRead(slot pointerSlot, sz Size) bool {
+	ok := m.ReadLimiter().canRead(sz)
+	if t := m.tracker(); t != nil {
+		t.record(slot, sz, ok)
+	}
+	return ok
+}
+
+// pointerSlot is the location of a pointer in a message.
+type pointerSlot struct {
+	seg SegmentID
+	off Address
+}
+
+type traversalCharge struct {
+	reads    int
+	bytes    uint64
+	rejected int
+}
+
+type traversalTracker struct {
+	mu      sync.Mutex
+	charges map[pointerSlot]traversalCharge
+}
+
+// record notes a charge of sz bytes for reading the pointer at slot.
+func (t *traversalTracker) record(slot pointerSlot, sz Size, ok bool) {
+	t.mu.Lock()
+	if t.charges == nil {
+		t.charges = make(map[pointerSlot]traversalCharge)
+	}
+	c := t.charges[slot]
+	c.reads++
+	if ok {
+		c.bytes += uint64(sz)
+	} else {
+		c.rejected++
+	}
+	t.charges[slot] = c
+	t.mu.Unlock()
+}
+
+func (t *traversalTracker) snapshot() map[pointerSlot]traversalCharge {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	m := make(map[pointerSlot]traversalCharge, len(t.charges))
+	for slot, c := range t.charges {
+		m[slot] = c
+	}
+	return m
+}
+
+// traversalWalk names the charged pointers by walking the message
+// from the root.  Each object is visited at most once, so the walk
+// takes time proportional to the size of the message.
+type traversalWalk struct {
+	names   FieldNamer
+	charges map[pointerSlot]traversalCharge
+	visited map[pointerSlot]bool
+	report  *TraversalReport
+}
+
+func (w *traversalWalk) add(path string, c traversalCharge) {
+	w.report.Entries = append(w.report.Entries, TraversalEntry{
+		Path:     path,
+		Reads:    c.reads,
+		Bytes:    c.bytes,
+		Rejected: c.rejected,
+	})
+	w.report.Total += c.bytes
+}
+
+// pointer visits the pointer at off in s and the objects it points to.
+func (w *traversalWalk) pointer(s *Segment, off Address, p *traversalPath, typeID uint64, depth uint) {
+	slot := pointerSlot{s.id, off}
+	if c, ok := w.charges[slot]; ok {
+		w.add(p.String(), c)
+		delete(w.charges, slot)
+	}
+	if len(w.charges) == 0 || depth == 0 {
+		return
+	}
+	val := s.readRawPointer(off)
+	s, off, val, err := s.resolveFarPointer(off, val)
+	if err != nil || val == 0 {
+		return
+	}
+	switch val.pointerType() {
+	case structPointer:
+		st, err := s.readStructPtr(off, val)
+		if err != nil || !w.visit(st.seg, st.off) {
+			return
+		}
+		w.structPointers(st.seg, st.off, st.size, p, typeID, depth-1)
+	case listPointer:
+		l, err := s.readListPtr(off, val)
+		if err != nil || !w.visit(l.seg, l.off) {
+			return
+		}
+		switch {
+		case l.flags&isCompositeList != 0:
+			for i := int32(0); i < l.length; i++ {
+				addr, _ := l.off.element(i, l.size.totalSize())
+				w.structPointers(l.seg, addr, l.size, p.elem(int(i)), typeID, depth-1)
+			}
+		case l.flags&isBitList == 0 && l.size == (ObjectSize{PointerCount: 1}):
+			for i := int32(0); i < l.length; i++ {
+				addr, _ := l.off.element(i, wordSize)
+				w.pointer(l.seg, addr, p.elem(int(i)), typeID, depth-1)
+			}
+		}
+	}
+}
+
+// structPointers visits the pointer section of the struct at addr.
+func (w *traversalWalk) structPointers(s *Segment, addr Address, sz ObjectSize, p *traversalPath, typeID uint64, depth uint) {
+	st := Struct{seg: s, off: addr, size: sz}
+	base, _ := addr.addSize(sz.DataSize)
+	for i := uint16(0); i < sz.PointerCount; i++ {
+		off, _ := base.element(int32(i), wordSize)
+		var name string
+		var target uint64
+		ok := false
+		if w.names != nil && typeID != 0 {
+			name, target, ok = w.names.PointerField(typeID, st, i)
+		}
+		if !ok {
+			name, target = fmt.Sprintf("<pointer %d>", i), 0
+		}
+		w.pointer(s, off, p.field(name), target, depth)
+	}
+}
+
+// visit reports whether the object at addr hasn't been visited before.
+func (w *traversalWalk) visit(s *Segment, addr Address) bool {
+	obj := pointerSlot{s.id, addr}
+	if w.visited[obj] {
+		return false
+	}
+	w.visited[obj] = true
+	return true
+}
+
+// traversalPath is a location in a message, built up as a linked list
+// from the root.
+type traversalPath struct {
+	parent *traversalPath
+	name   string
+	index  int
+}
+
+func (p *traversalPath) field(name string) *traversalPath {
+	return &traversalPath{parent: p, name: name, index: -1}
+}
+
+func (p *traversalPath) elem(i int) *traversalPath {
+	return &traversalPath{parent: p, index: i}
+}
+
+func (p *traversalPath) String() string {
+	if p == nil {
+		return ""
+	}
+	var buf bytes.Buffer
+	p.write(&buf)
+	return buf.String()
+}
+
+func (p *traversalPath) write(buf *bytes.Buffer) {
+	if p == nil {
+		return
+	}
+	p.parent.write(buf)
+	if p.index >= 0 {
+		fmt.Fprintf(buf, "[%d]", p.index)
+		return
+	}
+	if buf.Len() > 0 {
+		buf.WriteByte('.')
+	}
+	buf.WriteString(p.name)
+}
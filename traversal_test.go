@@ -0,0 +1,203 @@
+package capnp
+
+import (
+	"strings"
+	"testing"
+)
+
+// newTraversalMessage builds a message whose root has a text field in
+// pointer 0 and a list of two structs in pointer 1, each with a text
+// field, and returns it unmarshaled so that nothing has been read yet.
+func newTraversalMessage(t *testing.T) *Message {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewRootStruct(seg, ObjectSize{PointerCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetText(0, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	l, err := NewCompositeList(seg, ObjectSize{DataSize: 8, PointerCount: 1}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < l.Len(); i++ {
+		if err := l.Struct(i).SetText(0, "element"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := root.SetPtr(1, l.ToPtr()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := seg.Message().Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+// readTraversalMessage reads the root twice, the text once, and the
+// second element's text three times.
+func readTraversalMessage(t *testing.T, msg *Message) error {
+	if _, err := msg.RootPtr(); err != nil {
+		return err
+	}
+	p, err := msg.RootPtr()
+	if err != nil {
+		return err
+	}
+	root := p.Struct()
+	if _, err := root.Ptr(0); err != nil {
+		return err
+	}
+	lp, err := root.Ptr(1)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := lp.List().Struct(1).Ptr(0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type testNamer struct{}
+
+func (testNamer) PointerField(typeID uint64, s Struct, i uint16) (string, uint64, bool) {
+	switch {
+	case typeID == 1 && i == 0:
+		return "text", 0, true
+	case typeID == 1 && i == 1:
+		return "items", 2, true
+	case typeID == 2 && i == 0:
+		return "name", 0, true
+	}
+	return "", 0, false
+}
+
+func TestTraversalReport(t *testing.T) {
+	tests := []struct {
+		name     string
+		rootType uint64
+		names    FieldNamer
+		paths    map[string]TraversalEntry
+	}{
+		{
+			name: "no schema",
+			paths: map[string]TraversalEntry{
+				"":                           {Reads: 2, Bytes: 32},
+				"<pointer 0>":                {Reads: 1, Bytes: 6},
+				"<pointer 1>":                {Reads: 1, Bytes: 32},
+				"<pointer 1>[1].<pointer 0>": {Reads: 3, Bytes: 24},
+			},
+		},
+		{
+			name:     "with namer",
+			rootType: 1,
+			names:    testNamer{},
+			paths: map[string]TraversalEntry{
+				"":              {Reads: 2, Bytes: 32},
+				"text":          {Reads: 1, Bytes: 6},
+				"items":         {Reads: 1, Bytes: 32},
+				"items[1].name": {Reads: 3, Bytes: 24},
+			},
+		},
+	}
+	for _, test := range tests {
+		msg := newTraversalMessage(t)
+		msg.TrackTraversal()
+		if err := readTraversalMessage(t, msg); err != nil {
+			t.Fatalf("%s: reading message: %v", test.name, err)
+		}
+		r := msg.TraversalReport(test.rootType, test.names)
+		if len(r.Entries) != len(test.paths) {
+			t.Errorf("%s: report has %d entries; want %d\n%v", test.name, len(r.Entries), len(test.paths), r)
+		}
+		var total uint64
+		for _, e := range r.Entries {
+			want, ok := test.paths[e.Path]
+			if !ok {
+				t.Errorf("%s: unexpected entry for %q", test.name, e.Path)
+				continue
+			}
+			want.Path = e.Path
+			if e != want {
+				t.Errorf("%s: entry = %+v; want %+v", test.name, e, want)
+			}
+			total += e.Bytes
+		}
+		if r.Total != total {
+			t.Errorf("%s: Total = %d; want %d", test.name, r.Total, total)
+		}
+		for i := 1; i < len(r.Entries); i++ {
+			if r.Entries[i].Bytes > r.Entries[i-1].Bytes {
+				t.Errorf("%s: entries not sorted by bytes:\n%v", test.name, r)
+				break
+			}
+		}
+	}
+}
+
+func TestTraversalReport_Rejected(t *testing.T) {
+	msg := newTraversalMessage(t)
+	msg.TraverseLimit = 80
+	msg.TrackTraversal()
+	if err := readTraversalMessage(t, msg); err == nil {
+		t.Fatal("reading message with small traversal limit succeeded")
+	}
+	r := msg.TraversalReport(0, nil)
+	if r.Remaining != 0 {
+		t.Errorf("Remaining = %d; want 0", r.Remaining)
+	}
+	var rejected int
+	for _, e := range r.Entries {
+		rejected += e.Rejected
+	}
+	if rejected != 1 {
+		t.Errorf("report has %d rejected reads; want 1\n%v", rejected, r)
+	}
+	if s := r.String(); !strings.Contains(s, "(1 rejected)") || !strings.Contains(s, "<root>") {
+		t.Errorf("String() =\n%s\nwant a rejected read and the root", s)
+	}
+}
+
+func TestTraversalReport_NotTracking(t *testing.T) {
+	msg := newTraversalMessage(t)
+	if err := readTraversalMessage(t, msg); err != nil {
+		t.Fatal(err)
+	}
+	if r := msg.TraversalReport(0, nil); len(r.Entries) != 0 || r.Total != 0 {
+		t.Errorf("report without tracking = %v; want empty", r)
+	}
+}
+
+func TestTrackTraversal_WhileReading(t *testing.T) {
+	// Tracking may start while another goroutine is reading.
+	msg := newTraversalMessage(t)
+	done := make(chan error, 1)
+	go func() {
+		var err error
+		for i := 0; i < 100 && err == nil; i++ {
+			_, err = msg.RootPtr()
+		}
+		done <- err
+	}()
+	msg.TrackTraversal()
+	if err := <-done; err != nil {
+		t.Fatal("RootPtr:", err)
+	}
+	if _, err := msg.RootPtr(); err != nil {
+		t.Fatal("RootPtr:", err)
+	}
+	if r := msg.TraversalReport(0, nil); len(r.Entries) == 0 {
+		t.Error("report after tracking started is empty")
+	}
+}
@@ -0,0 +1,446 @@
+// Package validate checks that a Cap'n Proto message matches its
+// schema.
+//
+// Readers of Cap'n Proto messages are lazy: a corrupt pointer deep
+// inside a message is only found when something reads it.  Validate
+// instead walks the whole message up front, so that a service can
+// reject bad input before handing it to the rest of the program.
+package validate
+
+import (
+	"bytes"
+	"fmt"
+
+	"zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/internal/nodemap"
+	"zombiezen.com/go/capnproto2/schemas"
+	"zombiezen.com/go/capnproto2/std/capnp/schema"
+)
+
+// Options controls validation.  The zero value is the default.
+type Options struct {
+	// Registry is consulted for schemas.  If nil, the default registry
+	// is used.
+	Registry *schemas.Registry
+
+	// AllowUnknownValues permits enum values and union discriminants
+	// that aren't in the schema, as a peer with a newer version of the
+	// schema may send.
+	AllowUnknownValues bool
+
+	// MaxErrors is the number of errors after which validation stops.
+	// If zero, validation stops after 100 errors.
+	MaxErrors int
+}
+
+const defaultMaxErrors = 100
+
+// An Error is a problem found at a location in a message.
+type Error struct {
+	// Path locates the problem, for example "nodes[3].displayName".
+	// It is empty for the root struct.
+	Path string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+// ErrorList is the error returned by Validate.
+type ErrorList []*Error
+
+func (list ErrorList) Error() string {
+	switch len(list) {
+	case 0:
+		return "no errors"
+	case 1:
+		return list[0].Error()
+	case 2:
+		return list[0].Error() + " (and 1 more error)"
+	default:
+		return fmt.Sprintf("%v (and %d more errors)", list[0], len(list)-1)
+	}
+}
+
+// Validate checks that s and every object reachable from it is a valid
+// encoding of the struct type typeID.  It checks that each pointer
+// refers to the kind of object that its field's type calls for, that
+// lists have the right element size, and that enum values and union
+// discriminants are known.  AnyPointer fields are traversed without a
+// schema.
+//
+// Reads are counted against the message's ReadLimiter and depth limit
+// as usual, so a message that is too large or too deep fails to
+// validate.  Callers that go on to read the message may need to reset
+// the read limit afterward.
+//
+// If the message is invalid, Validate returns an ErrorList.  Other
+// errors, such as a missing schema, are returned as is.
+func Validate(typeID uint64, s capnp.Struct, opts *Options) error {
+	v := &validator{maxErrors: defaultMaxErrors}
+	if opts != nil {
+		v.opts = *opts
+		if opts.Registry != nil {
+			v.nodes.UseRegistry(opts.Registry)
+		}
+		if opts.MaxErrors > 0 {
+			v.maxErrors = opts.MaxErrors
+		}
+	}
+	if err := v.structValue(nil, typeID, s); err != nil {
+		if _, stop := err.(errStop); !stop {
+			return err
+		}
+	}
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+type validator struct {
+	opts      Options
+	nodes     nodemap.Map
+	maxErrors int
+	errs      ErrorList
+}
+
+// errStop is returned internally once maxErrors is reached.
+type errStop struct{}
+
+func (errStop) Error() string { return "too many errors" }
+
+// path is a location in the message, built up lazily since most
+// messages are valid.
+type path struct {
+	parent *path
+	name   string
+	index  int // -1 for a field
+}
+
+func (p *path) field(name string) *path {
+	return &path{parent: p, name: name, index: -1}
+}
+
+func (p *path) elem(i int) *path {
+	return &path{parent: p, index: i}
+}
+
+func (p *path) String() string {
+	if p == nil {
+		return ""
+	}
+	var buf bytes.Buffer
+	p.write(&buf)
+	return buf.String()
+}
+
+func (p *path) write(buf *bytes.Buffer) {
+	if p == nil {
+		return
+	}
+	p.parent.write(buf)
+	if p.index >= 0 {
+		fmt.Fprintf(buf, "[%d]", p.index)
+		return
+	}
+	if buf.Len() > 0 {
+		buf.WriteByte('.')
+	}
+	buf.WriteString(p.name)
+}
+
+// report records a problem.  It returns a non-nil error once
+// validation should stop.
+func (v *validator) report(p *path, format string, args ...interface{}) error {
+	v.errs = append(v.errs, &Error{Path: p.String(), Err: fmt.Errorf(format, args...)})
+	if len(v.errs) >= v.maxErrors {
+		return errStop{}
+	}
+	return nil
+}
+
+func (v *validator) findStruct(id uint64) (schema.Node, error) {
+	n, err := v.nodes.Find(id)
+	if err != nil {
+		return schema.Node{}, err
+	}
+	if !n.IsValid() || n.Which() != schema.Node_Which_structNode {
+		return schema.Node{}, fmt.Errorf("validate: cannot find struct type %#x", id)
+	}
+	return n, nil
+}
+
+// structValue validates a struct of the given type.  It only returns
+// an error if validation should stop.
+func (v *validator) structValue(p *path, typeID uint64, s capnp.Struct) error {
+	n, err := v.findStruct(typeID)
+	if err != nil {
+		return err
+	}
+	st := n.StructNode()
+	discriminant := schema.Field_noDiscriminant
+	if st.DiscriminantCount() > 0 {
+		discriminant = s.Uint16(capnp.DataOffset(st.DiscriminantOffset() * 2))
+		if discriminant >= st.DiscriminantCount() && !v.opts.AllowUnknownValues {
+			if err := v.report(p, "unknown union discriminant %d", discriminant); err != nil {
+				return err
+			}
+		}
+	}
+	fields, err := st.Fields()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < fields.Len(); i++ {
+		f := fields.At(i)
+		if dv := f.DiscriminantValue(); dv != schema.Field_noDiscriminant && dv != discriminant {
+			continue
+		}
+		name, err := f.Name()
+		if err != nil {
+			return err
+		}
+		fp := p.field(name)
+		switch f.Which() {
+		case schema.Field_Which_group:
+			err = v.structValue(fp, f.Group().TypeId(), s)
+		case schema.Field_Which_slot:
+			err = v.slot(fp, s, f.Slot())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *validator) slot(p *path, s capnp.Struct, slot schema.Field_slot) error {
+	typ, err := slot.Type()
+	if err != nil {
+		return err
+	}
+	switch typ.Which() {
+	case schema.Type_Which_enum:
+		dv, err := slot.DefaultValue()
+		if err != nil {
+			return err
+		}
+		val := s.Uint16(capnp.DataOffset(slot.Offset()*2)) ^ dv.Enum()
+		return v.enumValue(p, typ.Enum().TypeId(), val)
+	case schema.Type_Which_text, schema.Type_Which_data, schema.Type_Which_list,
+		schema.Type_Which_structType, schema.Type_Which_interface, schema.Type_Which_anyPointer:
+		ptr, err := s.Ptr(uint16(slot.Offset()))
+		if err != nil {
+			return v.report(p, "%v", err)
+		}
+		return v.pointer(p, typ, ptr)
+	}
+	return nil
+}
+
+func (v *validator) enumValue(p *path, typeID uint64, val uint16) error {
+	if v.opts.AllowUnknownValues {
+		return nil
+	}
+	n, err := v.nodes.Find(typeID)
+	if err != nil {
+		return err
+	}
+	if !n.IsValid() || n.Which() != schema.Node_Which_enum {
+		return fmt.Errorf("validate: cannot find enum type %#x", typeID)
+	}
+	enumerants, err := n.Enum().Enumerants()
+	if err != nil {
+		return err
+	}
+	if int(val) >= enumerants.Len() {
+		return v.report(p, "unknown enum value %d", val)
+	}
+	return nil
+}
+
+// pointer validates a pointer of the given type.
+func (v *validator) pointer(p *path, typ schema.Type, ptr capnp.Ptr) error {
+	if !ptr.IsValid() {
+		return nil
+	}
+	switch typ.Which() {
+	case schema.Type_Which_text:
+		l := ptr.List()
+		if !isByteList(l) {
+			return v.report(p, "expected text, found %s", describe(ptr))
+		}
+		if l.Len() == 0 || ptr.Data()[l.Len()-1] != 0 {
+			return v.report(p, "text is not NUL-terminated")
+		}
+	case schema.Type_Which_data:
+		if !isByteList(ptr.List()) {
+			return v.report(p, "expected data, found %s", describe(ptr))
+		}
+	case schema.Type_Which_structType:
+		s := ptr.Struct()
+		if !s.IsValid() {
+			return v.report(p, "expected struct, found %s", describe(ptr))
+		}
+		return v.structValue(p, typ.StructType().TypeId(), s)
+	case schema.Type_Which_interface:
+		i := ptr.Interface()
+		if !i.IsValid() {
+			return v.report(p, "expected capability, found %s", describe(ptr))
+		}
+		if msg := i.Segment().Message(); int(i.Capability()) >= len(msg.CapTable) {
+			return v.report(p, "capability index %d out of range", i.Capability())
+		}
+	case schema.Type_Which_list:
+		l := ptr.List()
+		if !l.IsValid() {
+			return v.report(p, "expected list, found %s", describe(ptr))
+		}
+		elem, err := typ.List().ElementType()
+		if err != nil {
+			return err
+		}
+		return v.list(p, elem, l)
+	case schema.Type_Which_anyPointer:
+		return v.any(p, ptr)
+	}
+	return nil
+}
+
+func (v *validator) list(p *path, elem schema.Type, l capnp.List) error {
+	want, ok := elementSize(elem)
+	sz := l.ElementSize()
+	switch {
+	case elem.Which() == schema.Type_Which_void:
+		return nil
+	case elem.Which() == schema.Type_Which_bool:
+		if !l.IsBitList() {
+			return v.report(p, "expected list of bits, found list with %d-byte elements", sz.DataSize)
+		}
+		return nil
+	case l.IsBitList():
+		return v.report(p, "expected list of %v, found list of bits", elem.Which())
+	case ok && l.IsComposite():
+		if sz.DataSize < want.DataSize || sz.PointerCount < want.PointerCount {
+			return v.report(p, "list elements too small for %v", elem.Which())
+		}
+	case ok && sz != want:
+		return v.report(p, "expected list of %v, found list of %s", elem.Which(), describeSize(sz))
+	}
+
+	for i := 0; i < l.Len(); i++ {
+		// List.Struct works for any non-bit list, treating each
+		// element as a struct of the list's element size.
+		s := l.Struct(i)
+		var err error
+		switch elem.Which() {
+		case schema.Type_Which_enum:
+			err = v.enumValue(p.elem(i), elem.Enum().TypeId(), s.Uint16(0))
+		case schema.Type_Which_structType:
+			err = v.structValue(p.elem(i), elem.StructType().TypeId(), s)
+		case schema.Type_Which_text, schema.Type_Which_data, schema.Type_Which_list,
+			schema.Type_Which_interface, schema.Type_Which_anyPointer:
+			ptr, perr := s.Ptr(0)
+			if perr != nil {
+				err = v.report(p.elem(i), "%v", perr)
+				break
+			}
+			err = v.pointer(p.elem(i), elem, ptr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// any traverses an object without a schema, checking that every
+// pointer in it can be read.
+func (v *validator) any(p *path, ptr capnp.Ptr) error {
+	if s := ptr.Struct(); s.IsValid() {
+		return v.anyStruct(p, s)
+	}
+	l := ptr.List()
+	if !l.IsValid() || l.IsBitList() || l.ElementSize().PointerCount == 0 {
+		return nil
+	}
+	for i := 0; i < l.Len(); i++ {
+		if err := v.anyStruct(p.elem(i), l.Struct(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *validator) anyStruct(p *path, s capnp.Struct) error {
+	for i := uint16(0); i < s.Size().PointerCount; i++ {
+		ptr, err := s.Ptr(i)
+		if err != nil {
+			if err := v.report(p.field(fmt.Sprintf("<pointer %d>", i)), "%v", err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := v.any(p.field(fmt.Sprintf("<pointer %d>", i)), ptr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// elementSize returns the size of a non-composite list element of the
+// given type.  ok is false for struct types, which may use any
+// non-bit encoding.
+func elementSize(t schema.Type) (sz capnp.ObjectSize, ok bool) {
+	switch t.Which() {
+	case schema.Type_Which_int8, schema.Type_Which_uint8:
+		return capnp.ObjectSize{DataSize: 1}, true
+	case schema.Type_Which_int16, schema.Type_Which_uint16, schema.Type_Which_enum:
+		return capnp.ObjectSize{DataSize: 2}, true
+	case schema.Type_Which_int32, schema.Type_Which_uint32, schema.Type_Which_float32:
+		return capnp.ObjectSize{DataSize: 4}, true
+	case schema.Type_Which_int64, schema.Type_Which_uint64, schema.Type_Which_float64:
+		return capnp.ObjectSize{DataSize: 8}, true
+	case schema.Type_Which_text, schema.Type_Which_data, schema.Type_Which_list,
+		schema.Type_Which_interface, schema.Type_Which_anyPointer:
+		return capnp.ObjectSize{PointerCount: 1}, true
+	}
+	return capnp.ObjectSize{}, false
+}
+
+func isByteList(l capnp.List) bool {
+	return l.IsValid() && !l.IsBitList() && !l.IsComposite() && l.ElementSize() == capnp.ObjectSize{DataSize: 1}
+}
+
+// describe names the kind of object a pointer refers to, for errors.
+func describe(ptr capnp.Ptr) string {
+	switch {
+	case ptr.Struct().IsValid():
+		return "struct"
+	case ptr.Interface().IsValid():
+		return "capability"
+	}
+	l := ptr.List()
+	switch {
+	case l.IsBitList():
+		return "list of bits"
+	case l.IsComposite():
+		return "list of structs"
+	}
+	return "list of " + describeSize(l.ElementSize())
+}
+
+func describeSize(sz capnp.ObjectSize) string {
+	switch {
+	case sz.PointerCount > 0:
+		return "pointers"
+	case sz.DataSize == 0:
+		return "void"
+	}
+	return fmt.Sprintf("%d-byte elements", sz.DataSize)
+}
@@ -0,0 +1,362 @@
+package validate_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/compiler"
+	"zombiezen.com/go/capnproto2/schemas"
+	"zombiezen.com/go/capnproto2/std/capnp/schema"
+	"zombiezen.com/go/capnproto2/validate"
+)
+
+const testSchema = `@0xe1b5d0c5a9e1d3f7;
+struct S {
+  c @0 :I;
+  e @1 :E;
+  l @2 :List(UInt16);
+  t @3 :List(Text);
+  a @4 :AnyPointer;
+}
+interface I {}
+enum E { a @0; b @1; }
+`
+
+// compile compiles testSchema into a new registry and returns the
+// registry and the ID of S.
+func compile(t *testing.T) (*schemas.Registry, uint64) {
+	opts := &compiler.Options{
+		ReadFile: func(name string) ([]byte, error) {
+			if name != "test.capnp" {
+				return nil, fmt.Errorf("open %s: no such file", name)
+			}
+			return []byte(testSchema), nil
+		},
+	}
+	req, err := compiler.Compile(opts, "test.capnp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := req.Struct.Segment().Message().Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := new(schemas.Registry)
+	if _, err := reg.RegisterRequest(data); err != nil {
+		t.Fatal(err)
+	}
+	nodes, _ := req.Nodes()
+	for i := 0; i < nodes.Len(); i++ {
+		if name, _ := nodes.At(i).DisplayName(); name == "test.capnp:S" {
+			return reg, nodes.At(i).Id()
+		}
+	}
+	t.Fatal("S not compiled")
+	return nil, 0
+}
+
+func newNode(t *testing.T) (schema.Node, *capnp.Segment) {
+	_, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := schema.NewRootNode(seg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n, seg
+}
+
+func TestValidateCompiledRequest(t *testing.T) {
+	opts := &compiler.Options{
+		ReadFile: func(name string) ([]byte, error) {
+			return []byte(testSchema), nil
+		},
+	}
+	req, err := compiler.Compile(opts, "test.capnp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate.Validate(schema.CodeGeneratorRequest_TypeID, req.Struct, nil); err != nil {
+		t.Errorf("Validate(compiled request) = %v; want nil", err)
+	}
+}
+
+func TestValidateSchemaErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(schema.Node, *capnp.Segment) error
+		want  string
+	}{
+		{
+			name: "valid",
+			build: func(n schema.Node, seg *capnp.Segment) error {
+				n.SetDisplayName("foo.capnp:Foo")
+				_, err := n.NewNestedNodes(2)
+				return err
+			},
+		},
+		{
+			name: "struct for text",
+			build: func(n schema.Node, seg *capnp.Segment) error {
+				s, err := capnp.NewStruct(seg, capnp.ObjectSize{DataSize: 8})
+				if err != nil {
+					return err
+				}
+				return n.Struct.SetPtr(0, s.ToPtr())
+			},
+			want: "displayName: expected text, found struct",
+		},
+		{
+			name: "text without NUL",
+			build: func(n schema.Node, seg *capnp.Segment) error {
+				d, err := capnp.NewData(seg, []byte("abc"))
+				if err != nil {
+					return err
+				}
+				return n.Struct.SetPtr(0, d.ToPtr())
+			},
+			want: "displayName: text is not NUL-terminated",
+		},
+		{
+			name: "bits for struct list",
+			build: func(n schema.Node, seg *capnp.Segment) error {
+				l, err := capnp.NewBitList(seg, 3)
+				if err != nil {
+					return err
+				}
+				return n.Struct.SetPtr(1, l.ToPtr())
+			},
+			want: "nestedNodes: expected list of structType, found list of bits",
+		},
+		{
+			name: "bad nested text",
+			build: func(n schema.Node, seg *capnp.Segment) error {
+				nested, err := n.NewNestedNodes(2)
+				if err != nil {
+					return err
+				}
+				l, err := capnp.NewInt32List(seg, 1)
+				if err != nil {
+					return err
+				}
+				return nested.At(1).Struct.SetPtr(0, l.ToPtr())
+			},
+			want: "nestedNodes[1].name: expected text, found list of 4-byte elements",
+		},
+		{
+			name: "unknown discriminant",
+			build: func(n schema.Node, seg *capnp.Segment) error {
+				n.Struct.SetUint16(12, 99)
+				return nil
+			},
+			want: "unknown union discriminant 99",
+		},
+		{
+			name: "unknown enum value",
+			build: func(n schema.Node, seg *capnp.Segment) error {
+				n.SetStructNode()
+				n.StructNode().SetPreferredListEncoding(schema.ElementSize(42))
+				return nil
+			},
+			want: "struct.preferredListEncoding: unknown enum value 42",
+		},
+	}
+	for _, test := range tests {
+		n, seg := newNode(t)
+		if err := test.build(n, seg); err != nil {
+			t.Errorf("%s: build: %v", test.name, err)
+			continue
+		}
+		err := validate.Validate(schema.Node_TypeID, n.Struct, nil)
+		if test.want == "" {
+			if err != nil {
+				t.Errorf("%s: Validate(...) = %v; want nil", test.name, err)
+			}
+			continue
+		}
+		if _, ok := err.(validate.ErrorList); !ok {
+			t.Errorf("%s: Validate(...) = %#v; want ErrorList", test.name, err)
+			continue
+		}
+		if err.Error() != test.want {
+			t.Errorf("%s: Validate(...) = %q; want %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestValidateRegistry(t *testing.T) {
+	reg, id := compile(t)
+	sz := capnp.ObjectSize{DataSize: 8, PointerCount: 4}
+	tests := []struct {
+		name  string
+		opts  validate.Options
+		build func(capnp.Struct, *capnp.Segment) error
+		want  string
+	}{
+		{
+			name: "valid",
+			build: func(s capnp.Struct, seg *capnp.Segment) error {
+				msg := seg.Message()
+				msg.AddCap(nil)
+				if err := s.SetPtr(0, capnp.NewInterface(seg, 0).ToPtr()); err != nil {
+					return err
+				}
+				s.SetUint16(0, 1)
+				l, err := capnp.NewUInt16List(seg, 3)
+				if err != nil {
+					return err
+				}
+				if err := s.SetPtr(1, l.ToPtr()); err != nil {
+					return err
+				}
+				tl, err := capnp.NewTextList(seg, 2)
+				if err != nil {
+					return err
+				}
+				tl.Set(0, "hi")
+				if err := s.SetPtr(2, tl.ToPtr()); err != nil {
+					return err
+				}
+				return s.SetPtr(3, tl.ToPtr())
+			},
+		},
+		{
+			name: "capability out of range",
+			build: func(s capnp.Struct, seg *capnp.Segment) error {
+				return s.SetPtr(0, capnp.NewInterface(seg, 3).ToPtr())
+			},
+			want: "c: capability index 3 out of range",
+		},
+		{
+			name: "struct for capability",
+			build: func(s capnp.Struct, seg *capnp.Segment) error {
+				return s.SetPtr(0, s.ToPtr())
+			},
+			want: "c: expected capability, found struct",
+		},
+		{
+			name: "unknown enum value",
+			build: func(s capnp.Struct, seg *capnp.Segment) error {
+				s.SetUint16(0, 2)
+				return nil
+			},
+			want: "e: unknown enum value 2",
+		},
+		{
+			name: "allow unknown enum value",
+			opts: validate.Options{AllowUnknownValues: true},
+			build: func(s capnp.Struct, seg *capnp.Segment) error {
+				s.SetUint16(0, 2)
+				return nil
+			},
+		},
+		{
+			name: "list element too small",
+			build: func(s capnp.Struct, seg *capnp.Segment) error {
+				l, err := capnp.NewUInt8List(seg, 3)
+				if err != nil {
+					return err
+				}
+				return s.SetPtr(1, l.ToPtr())
+			},
+			want: "l: expected list of uint16, found list of 1-byte elements",
+		},
+		{
+			name: "composite list for primitives",
+			build: func(s capnp.Struct, seg *capnp.Segment) error {
+				l, err := capnp.NewCompositeList(seg, capnp.ObjectSize{DataSize: 8}, 2)
+				if err != nil {
+					return err
+				}
+				return s.SetPtr(1, l.ToPtr())
+			},
+		},
+		{
+			name: "bad text in list",
+			build: func(s capnp.Struct, seg *capnp.Segment) error {
+				tl, err := capnp.NewTextList(seg, 2)
+				if err != nil {
+					return err
+				}
+				d, err := capnp.NewData(seg, []byte("x"))
+				if err != nil {
+					return err
+				}
+				if err := (capnp.PointerList{List: tl.List}).SetPtr(1, d.ToPtr()); err != nil {
+					return err
+				}
+				return s.SetPtr(2, tl.ToPtr())
+			},
+			want: "t[1]: text is not NUL-terminated",
+		},
+		{
+			name: "max errors",
+			opts: validate.Options{MaxErrors: 2},
+			build: func(s capnp.Struct, seg *capnp.Segment) error {
+				s.SetUint16(0, 5)
+				if err := s.SetPtr(0, s.ToPtr()); err != nil {
+					return err
+				}
+				return s.SetPtr(1, s.ToPtr())
+			},
+			want: "c: expected capability, found struct (and 1 more error)",
+		},
+	}
+	for _, test := range tests {
+		_, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := capnp.NewRootStruct(seg, sz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := test.build(s, seg); err != nil {
+			t.Errorf("%s: build: %v", test.name, err)
+			continue
+		}
+		opts := test.opts
+		opts.Registry = reg
+		err = validate.Validate(id, s, &opts)
+		if test.want == "" {
+			if err != nil {
+				t.Errorf("%s: Validate(...) = %v; want nil", test.name, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%s: Validate(...) = %v; want %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestValidateReadLimit(t *testing.T) {
+	n, _ := newNode(t)
+	nested, err := n.NewNestedNodes(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < nested.Len(); i++ {
+		nested.At(i).SetName("a fairly long nested node name")
+	}
+	msg := n.Struct.Segment().Message()
+	msg.ReadLimiter().Reset(512)
+	err = validate.Validate(schema.Node_TypeID, n.Struct, nil)
+	if err == nil || !strings.Contains(err.Error(), "read traversal limit") {
+		t.Errorf("Validate(...) = %v; want read traversal limit error", err)
+	}
+}
+
+func TestValidateMissingType(t *testing.T) {
+	n, _ := newNode(t)
+	err := validate.Validate(0xdeadbeef, n.Struct, &validate.Options{Registry: new(schemas.Registry)})
+	if err == nil {
+		t.Fatal("Validate(unknown type) = nil; want error")
+	}
+	if _, ok := err.(validate.ErrorList); ok {
+		t.Errorf("Validate(unknown type) = %v; want non-ErrorList error", err)
+	}
+}